@@ -19,12 +19,13 @@ type Template struct {
 	*yaml.Node
 }
 
-// TODO - We really need a convenient Template data structure
-// that lets us easily access elements.
-// t.Resources["MyResource"].Properties["MyProp"]
+// Use Template.Model for typed access to the sections of the template:
 //
-// Add a Model attribute to the struct and an Init function to populate it.
-// t.Model.Resources
+//	m, err := t.Model()
+//	if err != nil {
+//		return err
+//	}
+//	prop := m.Resources["MyResource"].Properties["MyProp"]
 
 // Map returns the template as a map[string]interface{}
 func (t Template) Map() map[string]interface{} {
@@ -62,6 +63,7 @@ const (
 	Transform                Section = "Transform"
 	Outputs                  Section = "Outputs"
 	State                    Section = "State"
+	Rain                     Section = "Rain"
 )
 
 // GetResource returns the yaml node for a resource by logical id
@@ -1,18 +1,107 @@
 package diff
 
 import (
-	"math"
 	"reflect"
+	"strings"
 
 	"github.com/aws-cloudformation/rain/cft"
 )
 
+// Options controls how templates are compared
+type Options struct {
+	// IdentityKeys maps a property path to the keys that identify the
+	// elements of a list at that path. Elements of those lists are matched
+	// by the values of their identity keys instead of by their position,
+	// so inserting or reordering elements only shows the real change.
+	//
+	// A path is a list of map keys separated by slashes, and matches
+	// the end of the full path from the top of the template. For example,
+	// "Tags" matches every Tags list, and "Resources/*/Properties/Rules"
+	// matches the Rules property of any resource.
+	// List indices are not part of the path, and * matches any key.
+	// When more than one path matches, the longest one is used.
+	//
+	// If IdentityKeys is nil, DefaultIdentityKeys is used.
+	IdentityKeys map[string][]string
+
+	// DetectRenames pairs resources that were removed and added with the
+	// same Type and nearly the same content, and shows the difference
+	// between them as a rename instead of a removal and an addition
+	DetectRenames bool
+
+	// RenameThreshold is the similarity, from 0 to 1, that resources
+	// need to be detected as renames.
+	// If RenameThreshold is 0, DefaultRenameThreshold is used.
+	RenameThreshold float64
+}
+
+// DefaultIdentityKeys are the identity keys for lists
+// that are common in CloudFormation templates
+var DefaultIdentityKeys = map[string][]string{
+	"Tags":                 {"Key"},
+	"ContainerDefinitions": {"Name"},
+	"Environment":          {"Name"},
+	"Statement":            {"Sid"},
+	"SecurityGroupIngress": {
+		"IpProtocol", "FromPort", "ToPort",
+		"CidrIp", "CidrIpv6", "SourceSecurityGroupId", "SourceSecurityGroupName", "SourcePrefixListId",
+	},
+	"SecurityGroupEgress": {
+		"IpProtocol", "FromPort", "ToPort",
+		"CidrIp", "CidrIpv6", "DestinationSecurityGroupId", "DestinationPrefixListId",
+	},
+}
+
 // New returns a Diff that represents the difference between two templates
 func New(a, b cft.Template) Diff {
-	return CompareMaps(a.Map(), b.Map())
+	return NewWithOptions(a, b, Options{})
 }
 
+// NewWithOptions returns a Diff that represents the difference
+// between two templates, compared according to options
+func NewWithOptions(a, b cft.Template, options Options) Diff {
+	c := newComparer(options)
+	d := c.compareMaps(nil, a.Map(), b.Map())
+
+	if options.DetectRenames {
+		c.applyRenames(d, a, b, FindRenames(a, b, options))
+	}
+
+	return d
+}
+
+// CompareMaps returns a Diff that represents the difference between two maps
+func CompareMaps(old, new map[string]interface{}) Diff {
+	return newComparer(Options{}).compareMaps(nil, old, new)
+}
+
+// compareValues compares two values with the default options
 func compareValues(old, new interface{}) Diff {
+	return newComparer(Options{}).compareValues(nil, old, new)
+}
+
+// comparer holds the options for a comparison
+type comparer struct {
+	identityKeys map[string][]string
+}
+
+func newComparer(options Options) comparer {
+	keys := options.IdentityKeys
+	if keys == nil {
+		keys = DefaultIdentityKeys
+	}
+
+	return comparer{identityKeys: keys}
+}
+
+// child returns a copy of path with key added to the end
+func child(path []string, key string) []string {
+	out := make([]string, len(path), len(path)+1)
+	copy(out, path)
+	return append(out, key)
+}
+
+func (c comparer) compareValues(path []string, old, new interface{}) Diff {
 	if reflect.TypeOf(old) != reflect.TypeOf(new) {
 
 		// In YAML there is no difference between "" and null
@@ -25,9 +114,9 @@ func compareValues(old, new interface{}) Diff {
 
 	switch v := old.(type) {
 	case []interface{}:
-		return compareSlices(v, new.([]interface{}))
+		return c.compareSlices(path, v, new.([]interface{}))
 	case map[string]interface{}:
-		return CompareMaps(v, new.(map[string]interface{}))
+		return c.compareMaps(path, v, new.(map[string]interface{}))
 	default:
 		if !reflect.DeepEqual(old, new) {
 			return value{new, Changed}
@@ -37,24 +126,17 @@ func compareValues(old, new interface{}) Diff {
 	return value{old, Unchanged}
 }
 
-func compareSlices(old, new []interface{}) Diff {
-	max := int(math.Max(float64(len(old)), float64(len(new))))
-	d := make(slice, max)
-
-	for i := 0; i < max; i++ {
-		if i >= len(old) {
-			d[i] = value{new[i], Added}
-		} else if i >= len(new) {
-			d[i] = value{old[i], Removed}
-		} else {
-			d[i] = compareValues(old[i], new[i])
+func (c comparer) compareSlices(path []string, old, new []interface{}) Diff {
+	if keys := c.keysFor(path); len(keys) > 0 {
+		if d, ok := c.compareByIdentity(path, keys, old, new); ok {
+			return d
 		}
 	}
 
-	return d
+	return c.compareByAlignment(path, old, new)
 }
 
-func CompareMaps(old, new map[string]interface{}) Diff {
+func (c comparer) compareMaps(path []string, old, new map[string]interface{}) Diff {
 	d := make(dmap)
 
 	// New and updated keys
@@ -62,7 +144,7 @@ func CompareMaps(old, new map[string]interface{}) Diff {
 		if _, ok := old[key]; !ok {
 			d[key] = value{val, Added}
 		} else {
-			d[key] = compareValues(old[key], val)
+			d[key] = c.compareValues(child(path, key), old[key], val)
 		}
 	}
 
@@ -75,3 +157,37 @@ func CompareMaps(old, new map[string]interface{}) Diff {
 
 	return d
 }
+
+// keysFor returns the identity keys for the list at path, if there are any
+func (c comparer) keysFor(path []string) []string {
+	var best []string
+	bestLen := 0
+	bestPattern := ""
+
+	for pattern, keys := range c.identityKeys {
+		parts := strings.Split(pattern, "/")
+		if len(parts) > len(path) {
+			continue
+		}
+
+		offset := len(path) - len(parts)
+		matched := true
+		for i, part := range parts {
+			if part != "*" && part != path[offset+i] {
+				matched = false
+				break
+			}
+		}
+
+		if !matched {
+			continue
+		}
+
+		// Prefer the most specific pattern, and be consistent about ties
+		if len(parts) > bestLen || (len(parts) == bestLen && pattern < bestPattern) {
+			best, bestLen, bestPattern = keys, len(parts), pattern
+		}
+	}
+
+	return best
+}
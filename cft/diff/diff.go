@@ -28,6 +28,9 @@ const (
 
 	// Unchanged represents a value that has not changed
 	Unchanged Mode = "="
+
+	// Renamed represents a resource that has a new logical id
+	Renamed Mode = "~"
 )
 
 func (m Mode) String() string {
@@ -170,6 +173,10 @@ const (
 	Update ActionType = "Update"
 	Delete ActionType = "Delete"
 	None   ActionType = "None"
+
+	// Replace is an update that creates a new resource and deletes the old one.
+	// GetResourceActions never returns it, since that depends on the resource's schema.
+	Replace ActionType = "Replace"
 )
 
 func GetResourceActions(d Diff) map[string]ActionType {
@@ -192,6 +199,10 @@ func GetResourceActions(d Diff) map[string]ActionType {
 					actions[rname] = None
 				case Changed:
 					actions[rname] = Update
+				case Renamed:
+					// CloudFormation replaces a resource with a new logical id
+					actions[rname] = Create
+					actions[resource.(renamed).from] = Delete
 				}
 			}
 		}
@@ -2,6 +2,7 @@ package diff
 
 import (
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -174,3 +175,82 @@ func TestCompare(t *testing.T) {
 		},
 	})
 }
+
+func TestCompareLists(t *testing.T) {
+	tag := func(k, v string) interface{} {
+		return map[string]interface{}{"Key": k, "Value": v}
+	}
+	rule := func(port int, cidr string) interface{} {
+		return map[string]interface{}{"IpProtocol": "tcp", "FromPort": port, "ToPort": port, "CidrIp": cidr}
+	}
+
+	cases := []struct {
+		path     []string
+		old      []interface{}
+		new      []interface{}
+		expected string
+	}{
+		{
+			// Scalars are aligned, so an insert at the front is one change
+			nil,
+			[]interface{}{"a", "b", "c"},
+			[]interface{}{"x", "a", "b", "c"},
+			"(|)[(+)x (=)a (=)b (=)c]",
+		},
+		{
+			nil,
+			[]interface{}{"a", "b", "c", "d"},
+			[]interface{}{"a", "c", "e", "d"},
+			"(|)[(=)a (-)b (=)c (+)e (=)d]",
+		},
+		{
+			// Tags are matched by Key
+			[]string{"Resources", "Bucket", "Properties", "Tags"},
+			[]interface{}{tag("A", "1"), tag("B", "2")},
+			[]interface{}{tag("C", "3"), tag("A", "1"), tag("B", "4")},
+			"(|)[(+)map[Key:C Value:3] (=)map[Key:(=)A Value:(=)1] (|)map[Key:(=)B Value:(>)4]]",
+		},
+		{
+			// Reordering rules is not a change
+			[]string{"Resources", "Group", "Properties", "SecurityGroupIngress"},
+			[]interface{}{rule(80, "0.0.0.0/0"), rule(443, "0.0.0.0/0")},
+			[]interface{}{rule(443, "0.0.0.0/0"), rule(80, "0.0.0.0/0")},
+			"(=)[(=)map[CidrIp:(=)0.0.0.0/0 FromPort:(=)443 IpProtocol:(=)tcp ToPort:(=)443] (=)map[CidrIp:(=)0.0.0.0/0 FromPort:(=)80 IpProtocol:(=)tcp ToPort:(=)80]]",
+		},
+		{
+			// Duplicate identities fall back to alignment
+			[]string{"Tags"},
+			[]interface{}{tag("A", "1"), tag("A", "2")},
+			[]interface{}{tag("A", "2")},
+			"(|)[(-)map[Key:A Value:1] (=)map[Key:(=)A Value:(=)2]]",
+		},
+	}
+
+	c := newComparer(Options{})
+	for _, testCase := range cases {
+		actual := c.compareSlices(testCase.path, testCase.old, testCase.new).String()
+		if actual != testCase.expected {
+			t.Errorf("%s\n!=\n%s", actual, testCase.expected)
+		}
+	}
+}
+
+func TestIdentityKeyPaths(t *testing.T) {
+	c := newComparer(Options{IdentityKeys: map[string][]string{
+		"Rules":                        {"Id"},
+		"Resources/*/Properties/Rules": {"Name"},
+	}})
+
+	for path, expected := range map[string][]string{
+		"Rules":                               {"Id"},
+		"Resources/Thing/Properties/Rules":    {"Name"},
+		"Resources/Thing/Properties/Rules/X":  nil,
+		"Resources/Thing/Properties/Tags":     nil,
+		"Outputs/Thing/Value/Properties/Rule": nil,
+	} {
+		actual := c.keysFor(strings.Split(path, "/"))
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("%s: expected %v, got %v", path, expected, actual)
+		}
+	}
+}
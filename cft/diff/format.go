@@ -55,17 +55,27 @@ func formatDiff(d Diff, path []interface{}, long bool) string {
 		return formatMap(v, path, long)
 	case value:
 		return v.Format(long)
+	case renamed:
+		return formatDiff(v.Diff, path, long)
 	default:
 		panic(fmt.Errorf("unexpected type '%T'", d))
 	}
 }
 
+// formatSlice numbers each element by its position in the new list.
+// Removed elements are numbered with the position they were removed from.
 func formatSlice(s slice, path []interface{}, long bool) string {
 	output := strings.Builder{}
 
-	for i, v := range s {
+	next := 0
+	for _, v := range s {
 		m := v.Mode()
 
+		i := next
+		if m != Removed {
+			next++
+		}
+
 		if !long && m == Unchanged {
 			continue
 		}
@@ -96,6 +106,12 @@ func formatMap(m dmap, path []interface{}, long bool) string {
 			continue
 		}
 
+		if r, ok := v.(renamed); ok {
+			output.WriteString(fmt.Sprintf("%s %s: (renamed from %s)", m, k, r.from))
+			output.WriteString(formatSub(r.Diff, append(path, k), long))
+			continue
+		}
+
 		output.WriteString(fmt.Sprintf("%s %s:", m, k))
 
 		if !long && (m == Removed || m == Unchanged) {
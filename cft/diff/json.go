@@ -0,0 +1,155 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws-cloudformation/rain/cft"
+)
+
+// Change is a single difference between two templates,
+// in a form that is easy for other programs to read
+type Change struct {
+	// Path is a JSON pointer to the changed element in the new template,
+	// or in the old template for removed elements
+	Path string `json:"path"`
+
+	// Mode is one of added, removed, changed, or renamed
+	Mode string `json:"mode"`
+
+	// Value is the new value, or the old value if it was removed
+	Value interface{} `json:"value,omitempty"`
+
+	// From is the old logical id of a renamed resource
+	From string `json:"from,omitempty"`
+
+	// Replacement is true if the change replaces the resource.
+	// Changes does not set it, since that needs the resource's schema.
+	Replacement bool `json:"replacement,omitempty"`
+}
+
+var modeNames = map[Mode]string{
+	Added:   "added",
+	Removed: "removed",
+	Changed: "changed",
+	Renamed: "renamed",
+}
+
+// Changes returns a flat list of the differences in d, in the same order
+// that Format shows them. Unchanged elements are left out.
+func Changes(d Diff) []Change {
+	changes := make([]Change, 0)
+	collectChanges(d, "", &changes)
+	return changes
+}
+
+func collectChanges(d Diff, path string, changes *[]Change) {
+	visit := func(child Diff, childPath string) {
+		switch child.Mode() {
+		case Unchanged:
+		case Involved:
+			collectChanges(child, childPath, changes)
+		case Renamed:
+			r := child.(renamed)
+			*changes = append(*changes, Change{Path: childPath, Mode: modeNames[Renamed], From: r.from})
+			collectChanges(r.Diff, childPath, changes)
+		default:
+			*changes = append(*changes, Change{Path: childPath, Mode: modeNames[child.Mode()], Value: child.Value()})
+		}
+	}
+
+	switch v := d.(type) {
+	case dmap:
+		keys := v.keys()
+		sort.Strings(keys)
+		for _, k := range keys {
+			visit(v[k], path+"/"+escapePointer(k))
+		}
+	case slice:
+		next := 0
+		for _, child := range v {
+			visit(child, fmt.Sprintf("%s/%d", path, next))
+			if child.Mode() != Removed {
+				next++
+			}
+		}
+	}
+}
+
+// Operation is a JSON Patch operation, as described in RFC 6902
+type Operation struct {
+	Op    string      `json:"op" yaml:"op"`
+	Path  string      `json:"path" yaml:"path"`
+	From  string      `json:"from,omitempty" yaml:"from,omitempty"`
+	Value interface{} `json:"value,omitempty" yaml:"value,omitempty"`
+}
+
+// MarshalJSON always includes the value of operations that need one, even if it is null
+func (o Operation) MarshalJSON() ([]byte, error) {
+	type plain Operation
+	if o.Value != nil || (o.Op != "add" && o.Op != "replace" && o.Op != "test") {
+		return json.Marshal(plain(o))
+	}
+
+	return json.Marshal(struct {
+		plain
+		Value interface{} `json:"value"`
+	}{plain: plain(o)})
+}
+
+// JSONPatch returns the RFC 6902 operations that turn template a into template b.
+// List elements are aligned by their longest common subsequence, without
+// identity keys, and renamed resources are removed and added, so that the
+// operations can be applied in order.
+func JSONPatch(a, b cft.Template) []Operation {
+	d := newComparer(Options{IdentityKeys: map[string][]string{}}).compareMaps(nil, a.Map(), b.Map())
+
+	ops := make([]Operation, 0)
+	collectOperations(d, "", &ops)
+	return ops
+}
+
+func collectOperations(d Diff, path string, ops *[]Operation) {
+	visit := func(child Diff, childPath string) {
+		switch child.Mode() {
+		case Added:
+			*ops = append(*ops, Operation{Op: "add", Path: childPath, Value: child.Value()})
+		case Removed:
+			*ops = append(*ops, Operation{Op: "remove", Path: childPath})
+		case Changed:
+			*ops = append(*ops, Operation{Op: "replace", Path: childPath, Value: child.Value()})
+		case Involved:
+			collectOperations(child, childPath, ops)
+		}
+	}
+
+	switch v := d.(type) {
+	case dmap:
+		keys := v.keys()
+		sort.Strings(keys)
+		for _, k := range keys {
+			visit(v[k], path+"/"+escapePointer(k))
+		}
+	case slice:
+		// Each operation sees the list as the earlier operations left it
+		next := 0
+		for _, child := range v {
+			visit(child, fmt.Sprintf("%s/%d", path, next))
+			if child.Mode() != Removed {
+				next++
+			}
+		}
+	}
+}
+
+// escapePointer escapes a key for use in a JSON pointer
+func escapePointer(key string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+}
+
+// unescapePointer reverses escapePointer
+func unescapePointer(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+}
@@ -0,0 +1,173 @@
+package diff
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// identity returns a string made from the values of keys in the map v.
+// It returns false if v is not a map or has none of the keys.
+func identity(v interface{}, keys []string) (string, bool) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+
+	values := make([]interface{}, len(keys))
+	found := false
+	for i, key := range keys {
+		if val, ok := m[key]; ok {
+			values[i] = val
+			found = true
+		}
+	}
+
+	if !found {
+		return "", false
+	}
+
+	out, err := json.Marshal(values)
+	if err != nil {
+		return "", false
+	}
+
+	return string(out), true
+}
+
+// identities returns the identity of each element of list,
+// or false if any element has no identity or two elements have the same one
+func identities(list []interface{}, keys []string) ([]string, bool) {
+	ids := make([]string, len(list))
+	seen := make(map[string]bool)
+
+	for i, v := range list {
+		id, ok := identity(v, keys)
+		if !ok || seen[id] {
+			return nil, false
+		}
+		seen[id] = true
+		ids[i] = id
+	}
+
+	return ids, true
+}
+
+// compareByIdentity matches the elements of two lists by their identity keys.
+// The order of the elements is not treated as a change.
+// It returns false if the elements can't be identified.
+func (c comparer) compareByIdentity(path []string, keys []string, old, new []interface{}) (Diff, bool) {
+	oldIds, ok := identities(old, keys)
+	if !ok {
+		return nil, false
+	}
+
+	newIds, ok := identities(new, keys)
+	if !ok {
+		return nil, false
+	}
+
+	oldIndex := make(map[string]int)
+	for i, id := range oldIds {
+		oldIndex[id] = i
+	}
+
+	d := make(slice, 0, len(new))
+	used := make([]bool, len(old))
+	next := 0
+
+	// removeBefore adds any unmatched old elements before index i
+	removeBefore := func(i int) {
+		for ; next < i; next++ {
+			if !used[next] && !contains(newIds, oldIds[next]) {
+				used[next] = true
+				d = append(d, value{old[next], Removed})
+			}
+		}
+	}
+
+	for j, id := range newIds {
+		i, ok := oldIndex[id]
+		if !ok {
+			d = append(d, value{new[j], Added})
+			continue
+		}
+
+		removeBefore(i)
+		used[i] = true
+		d = append(d, c.compareValues(path, old[i], new[j]))
+	}
+
+	removeBefore(len(old))
+
+	return d, true
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// compareByAlignment lines up the elements of two lists using their longest
+// common subsequence, so that inserted and removed elements don't make
+// the rest of the list look changed. Runs of removed and added elements
+// that fall between the same unchanged elements are compared with each other.
+func (c comparer) compareByAlignment(path []string, old, new []interface{}) Diff {
+	// lcs[i][j] is the length of the longest common subsequence of old[i:] and new[j:]
+	lcs := make([][]int, len(old)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(new)+1)
+	}
+	for i := len(old) - 1; i >= 0; i-- {
+		for j := len(new) - 1; j >= 0; j-- {
+			if reflect.DeepEqual(old[i], new[j]) {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	d := make(slice, 0, max(len(old), len(new)))
+	removed := make([]interface{}, 0)
+	added := make([]interface{}, 0)
+
+	// flush compares the elements that were removed and added since the last match
+	flush := func() {
+		for k := 0; k < max(len(removed), len(added)); k++ {
+			switch {
+			case k >= len(removed):
+				d = append(d, value{added[k], Added})
+			case k >= len(added):
+				d = append(d, value{removed[k], Removed})
+			default:
+				d = append(d, c.compareValues(path, removed[k], added[k]))
+			}
+		}
+		removed = removed[:0]
+		added = added[:0]
+	}
+
+	i, j := 0, 0
+	for i < len(old) || j < len(new) {
+		switch {
+		case i < len(old) && j < len(new) && reflect.DeepEqual(old[i], new[j]):
+			flush()
+			d = append(d, c.compareValues(path, old[i], new[j]))
+			i++
+			j++
+		case j < len(new) && (i == len(old) || lcs[i][j+1] >= lcs[i+1][j]):
+			added = append(added, new[j])
+			j++
+		default:
+			removed = append(removed, old[i])
+			i++
+		}
+	}
+	flush()
+
+	return d
+}
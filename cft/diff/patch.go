@@ -0,0 +1,272 @@
+package diff
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/aws-cloudformation/rain/cft"
+	"github.com/aws-cloudformation/rain/internal/node"
+	"gopkg.in/yaml.v3"
+)
+
+// Apply applies RFC 6902 JSON Patch operations to the template, in order.
+// The template's nodes are changed in place, so comments and the
+// formatting of anything that the operations don't touch are kept.
+// If an operation fails, Apply stops and returns an error,
+// and the operations before it will have been applied.
+func Apply(t cft.Template, ops []Operation) error {
+	if t.Node == nil {
+		return errors.New("t.Node is nil")
+	}
+
+	root := t.Node
+	if root.Kind == yaml.DocumentNode {
+		if len(root.Content) == 0 {
+			return errors.New("missing Document Content")
+		}
+		root = root.Content[0]
+	}
+
+	for i, op := range ops {
+		err := applyOperation(root, op)
+		if err != nil {
+			return fmt.Errorf("operation %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+
+	return nil
+}
+
+func applyOperation(root *yaml.Node, op Operation) error {
+	switch op.Op {
+	case "add":
+		val, err := toNode(op.Value)
+		if err != nil {
+			return err
+		}
+		return addNode(root, op.Path, val)
+	case "remove":
+		_, err := removeNode(root, op.Path)
+		return err
+	case "replace":
+		val, err := toNode(op.Value)
+		if err != nil {
+			return err
+		}
+		target, err := getNode(root, op.Path)
+		if err != nil {
+			return err
+		}
+		val.HeadComment = target.HeadComment
+		val.LineComment = target.LineComment
+		val.FootComment = target.FootComment
+		*target = *val
+		return nil
+	case "move":
+		if op.Path == op.From || strings.HasPrefix(op.Path, op.From+"/") {
+			return fmt.Errorf("unable to move %s in to itself", op.From)
+		}
+		val, err := removeNode(root, op.From)
+		if err != nil {
+			return err
+		}
+		return addNode(root, op.Path, val)
+	case "copy":
+		val, err := getNode(root, op.From)
+		if err != nil {
+			return err
+		}
+		return addNode(root, op.Path, node.Clone(val))
+	case "test":
+		target, err := getNode(root, op.Path)
+		if err != nil {
+			return err
+		}
+		equal, err := sameValue(target, op.Value)
+		if err != nil {
+			return err
+		}
+		if !equal {
+			return errors.New("test failed")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown op '%s'", op.Op)
+	}
+}
+
+// toNode encodes a value as a yaml node
+func toNode(value interface{}) (*yaml.Node, error) {
+	n := &yaml.Node{}
+	err := n.Encode(value)
+	if err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// sameValue returns true if n holds the same data as value
+func sameValue(n *yaml.Node, value interface{}) (bool, error) {
+	var decoded interface{}
+	err := n.Decode(&decoded)
+	if err != nil {
+		return false, err
+	}
+
+	// Compare through JSON so that numbers of different types match
+	normalize := func(v interface{}) (interface{}, error) {
+		j, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		var out interface{}
+		err = json.Unmarshal(j, &out)
+		return out, err
+	}
+
+	a, err := normalize(decoded)
+	if err != nil {
+		return false, err
+	}
+	b, err := normalize(value)
+	if err != nil {
+		return false, err
+	}
+
+	return reflect.DeepEqual(a, b), nil
+}
+
+// splitPointer returns the unescaped tokens of a JSON pointer
+func splitPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid path '%s'", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = unescapePointer(token)
+	}
+
+	return tokens, nil
+}
+
+// childIndex returns the index of token in the content of n,
+// which is the value for a mapping or the element for a sequence
+func childIndex(n *yaml.Node, token string) (int, error) {
+	switch n.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if n.Content[i].Value == token {
+				return i + 1, nil
+			}
+		}
+		return -1, fmt.Errorf("key '%s' not found", token)
+	case yaml.SequenceNode:
+		i, err := strconv.Atoi(token)
+		if err != nil || i < 0 || i >= len(n.Content) || (len(token) > 1 && token[0] == '0') {
+			return -1, fmt.Errorf("invalid index '%s'", token)
+		}
+		return i, nil
+	default:
+		return -1, fmt.Errorf("unable to find '%s' in a scalar", token)
+	}
+}
+
+func getNode(root *yaml.Node, pointer string) (*yaml.Node, error) {
+	tokens, err := splitPointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+
+	n := root
+	for _, token := range tokens {
+		for n.Kind == yaml.AliasNode {
+			n = n.Alias
+		}
+		i, err := childIndex(n, token)
+		if err != nil {
+			return nil, err
+		}
+		n = n.Content[i]
+	}
+
+	return n, nil
+}
+
+// getParent returns the node that contains the target of the pointer, and the last token
+func getParent(root *yaml.Node, pointer string) (*yaml.Node, string, error) {
+	if pointer == "" {
+		return nil, "", errors.New("the whole template can't be added or removed")
+	}
+
+	i := strings.LastIndex(pointer, "/")
+	if i < 0 {
+		return nil, "", fmt.Errorf("invalid path '%s'", pointer)
+	}
+
+	parent, err := getNode(root, pointer[:i])
+	if err != nil {
+		return nil, "", err
+	}
+
+	return parent, unescapePointer(pointer[i+1:]), nil
+}
+
+func addNode(root *yaml.Node, pointer string, val *yaml.Node) error {
+	parent, token, err := getParent(root, pointer)
+	if err != nil {
+		return err
+	}
+
+	switch parent.Kind {
+	case yaml.MappingNode:
+		if i, err := childIndex(parent, token); err == nil {
+			parent.Content[i] = val
+			return nil
+		}
+		parent.Content = append(parent.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: token}, val)
+	case yaml.SequenceNode:
+		i := len(parent.Content)
+		if token != "-" {
+			i, err = strconv.Atoi(token)
+			if err != nil || i < 0 || i > len(parent.Content) {
+				return fmt.Errorf("invalid index '%s'", token)
+			}
+		}
+		parent.Content = append(parent.Content[:i], append([]*yaml.Node{val}, parent.Content[i:]...)...)
+	default:
+		return fmt.Errorf("unable to add '%s' to a scalar", token)
+	}
+
+	return nil
+}
+
+// removeNode removes the target of the pointer and returns it
+func removeNode(root *yaml.Node, pointer string) (*yaml.Node, error) {
+	parent, token, err := getParent(root, pointer)
+	if err != nil {
+		return nil, err
+	}
+
+	i, err := childIndex(parent, token)
+	if err != nil {
+		return nil, err
+	}
+
+	removed := parent.Content[i]
+	if parent.Kind == yaml.MappingNode {
+		parent.Content = append(parent.Content[:i-1], parent.Content[i+1:]...)
+	} else {
+		parent.Content = append(parent.Content[:i], parent.Content[i+1:]...)
+	}
+
+	return removed, nil
+}
@@ -0,0 +1,166 @@
+package diff_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/aws-cloudformation/rain/cft/diff"
+	"github.com/aws-cloudformation/rain/cft/format"
+	"github.com/aws-cloudformation/rain/cft/parse"
+)
+
+const patchFrom = `
+Resources:
+  Bucket:
+    Type: AWS::S3::Bucket # The bucket
+    Properties:
+      BucketName: data
+      Tags:
+        - Key: A
+          Value: "1"
+        - Key: B
+          Value: "2"
+        - Key: C
+          Value: "3"
+      Names: [a, b, c, d]
+  Queue/Name~:
+    Type: AWS::SQS::Queue
+`
+
+const patchTo = `
+Resources:
+  Bucket:
+    Type: AWS::S3::Bucket # The bucket
+    Properties:
+      BucketName: !Ref Name
+      Tags:
+        - Key: Z
+          Value: "0"
+        - Key: A
+          Value: "1"
+        - Key: C
+          Value: "4"
+      Names: [x, a, c, d, e]
+Outputs:
+  Name:
+    Value: !Ref Bucket
+`
+
+func TestJSONPatch(t *testing.T) {
+	a, err := parse.String(patchFrom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := parse.String(patchTo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ops := diff.JSONPatch(a, b)
+
+	// Make sure the operations survive serialization
+	j, err := json.Marshal(ops)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ops = nil
+	if err := json.Unmarshal(j, &ops); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := diff.Apply(a, ops); err != nil {
+		t.Fatal(err)
+	}
+
+	if d := diff.New(a, b); d.Mode() != diff.Unchanged {
+		t.Errorf("patched template does not match:\n%s\n%s", d.Format(false), j)
+	}
+
+	if !strings.Contains(format.String(a, format.Options{}), "# The bucket") {
+		t.Error("expected the comment to be kept")
+	}
+}
+
+func TestApply(t *testing.T) {
+	tmpl, err := parse.String(patchFrom)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = diff.Apply(tmpl, []diff.Operation{
+		{Op: "test", Path: "/Resources/Queue~1Name~0/Type", Value: "AWS::SQS::Queue"},
+		{Op: "copy", From: "/Resources/Bucket/Properties/Names/0", Path: "/Resources/Bucket/Properties/Names/-"},
+		{Op: "move", From: "/Resources/Bucket/Properties/Tags", Path: "/Resources/Bucket/Metadata"},
+		{Op: "replace", Path: "/Resources/Bucket/Properties/BucketName", Value: map[string]interface{}{"Ref": "AWS::StackName"}},
+		{Op: "remove", Path: "/Resources/Queue~1Name~0"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `Resources:
+  Bucket:
+    Type: AWS::S3::Bucket # The bucket
+    Properties:
+      BucketName: !Ref AWS::StackName
+      Names:
+        - a
+        - b
+        - c
+        - d
+        - a
+    Metadata:
+      - Key: A
+        Value: "1"
+      - Key: B
+        Value: "2"
+      - Key: C
+        Value: "3"
+`
+	actual := format.String(tmpl, format.Options{Unsorted: true})
+	if actual != expected {
+		t.Errorf("\n%s\n!=\n%s", actual, expected)
+	}
+
+	for _, op := range []diff.Operation{
+		{Op: "test", Path: "/Resources/Bucket/Type", Value: "AWS::SNS::Topic"},
+		{Op: "remove", Path: "/Resources/Missing"},
+		{Op: "add", Path: "/Resources/Bucket/Properties/Names/9", Value: "z"},
+		{Op: "move", From: "/Resources", Path: "/Resources/Bucket/X"},
+		{Op: "frobnicate", Path: "/Resources"},
+	} {
+		if err := diff.Apply(tmpl, []diff.Operation{op}); err == nil {
+			t.Errorf("expected %s %s to fail", op.Op, op.Path)
+		}
+	}
+}
+
+func TestUnified(t *testing.T) {
+	a := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"
+	b := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\n"
+
+	expected := `--- old
++++ new
+@@ -1,5 +1,5 @@
+ a
+-b
++B
+ c
+ d
+ e
+@@ -8,3 +8,4 @@
+ h
+ i
+ j
++k
+`
+	actual := diff.Unified("old", "new", a, b)
+	if actual != expected {
+		t.Errorf("\n%s\n!=\n%s", actual, expected)
+	}
+
+	if diff.Unified("old", "new", a, a) != "" {
+		t.Error("expected no output for the same text")
+	}
+}
@@ -0,0 +1,299 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aws-cloudformation/rain/cft"
+)
+
+// subRe matches the variables in a Fn::Sub string, but not ${!Literal}
+var subRe = regexp.MustCompile(`\$\{([^!}][^}]*)\}`)
+
+// DefaultRenameThreshold is the similarity that two resources must
+// have for one to be treated as a rename of the other
+const DefaultRenameThreshold = 0.8
+
+// Rename is a resource that appears to have been given a new logical id,
+// for example by being moved in to or out of a module
+type Rename struct {
+	From string
+	To   string
+
+	// Similarity is the fraction of values that the two resources share,
+	// from 0 to 1
+	Similarity float64
+}
+
+func (r Rename) String() string {
+	return fmt.Sprintf("%s renamed from %s (%.0f%% similar)", r.To, r.From, r.Similarity*100)
+}
+
+// renamed represents a resource whose logical id has changed
+type renamed struct {
+	Diff
+	from string
+}
+
+// Mode returns Renamed
+func (r renamed) Mode() Mode {
+	return Renamed
+}
+
+// String returns a string representation of the renamed resource
+func (r renamed) String() string {
+	return fmt.Sprintf("%s{%s}%s", r.Mode(), r.from, r.Diff)
+}
+
+// Format returns a pretty-printed representation of the renamed resource
+func (r renamed) Format(long bool) string {
+	return r.Diff.Format(long)
+}
+
+// FindRenames returns the resources that were removed from old and
+// added to new, but have the same Type and nearly the same content.
+// Each removed resource is matched with at most one added resource,
+// starting with the most similar pairs.
+func FindRenames(old, new cft.Template, options Options) []Rename {
+	threshold := options.RenameThreshold
+	if threshold == 0 {
+		threshold = DefaultRenameThreshold
+	}
+
+	oldResources := resources(old)
+	newResources := resources(new)
+
+	candidates := make([]Rename, 0)
+	for from, a := range oldResources {
+		if _, ok := newResources[from]; ok {
+			continue
+		}
+		for to, b := range newResources {
+			if _, ok := oldResources[to]; ok {
+				continue
+			}
+			if a["Type"] == nil || a["Type"] != b["Type"] {
+				continue
+			}
+			s := similarity(a, b)
+			if s >= threshold {
+				candidates = append(candidates, Rename{From: from, To: to, Similarity: s})
+			}
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.Similarity != b.Similarity {
+			return a.Similarity > b.Similarity
+		}
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		return a.To < b.To
+	})
+
+	renames := make([]Rename, 0)
+	used := make(map[string]bool)
+	for _, c := range candidates {
+		if used["-"+c.From] || used["+"+c.To] {
+			continue
+		}
+		used["-"+c.From] = true
+		used["+"+c.To] = true
+		renames = append(renames, c)
+	}
+
+	sort.Slice(renames, func(i, j int) bool {
+		return renames[i].To < renames[j].To
+	})
+
+	return renames
+}
+
+// applyRenames replaces each pair of removed and added resources
+// in the diff of two templates with a diff between the two resources
+func (c comparer) applyRenames(d Diff, old, new cft.Template, renames []Rename) {
+	dm, ok := d.(dmap)
+	if !ok {
+		return
+	}
+	rd, ok := dm[string(cft.Resources)].(dmap)
+	if !ok {
+		return
+	}
+
+	oldResources := resources(old)
+	newResources := resources(new)
+
+	for _, r := range renames {
+		delete(rd, r.From)
+		rd[r.To] = renamed{
+			Diff: c.compareValues([]string{string(cft.Resources), r.To}, oldResources[r.From], newResources[r.To]),
+			from: r.From,
+		}
+	}
+}
+
+// resources returns the resources in a template
+func resources(t cft.Template) map[string]map[string]interface{} {
+	out := make(map[string]map[string]interface{})
+
+	rs, ok := t.Map()[string(cft.Resources)].(map[string]interface{})
+	if !ok {
+		return out
+	}
+
+	for name, r := range rs {
+		if m, ok := r.(map[string]interface{}); ok {
+			out[name] = m
+		}
+	}
+
+	return out
+}
+
+// similarity returns the fraction of values that a and b have in common
+func similarity(a, b map[string]interface{}) float64 {
+	fa := make(map[string]string)
+	fb := make(map[string]string)
+	flatten(a, "", fa)
+	flatten(b, "", fb)
+
+	if len(fa)+len(fb) == 0 {
+		return 1
+	}
+
+	same := 0
+	for k, v := range fa {
+		if fb[k] == v {
+			if _, ok := fb[k]; ok {
+				same++
+			}
+		}
+	}
+
+	return float64(2*same) / float64(len(fa)+len(fb))
+}
+
+// flatten adds each scalar value in v to out, keyed by its path
+func flatten(v interface{}, path string, out map[string]string) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, child := range t {
+			flatten(child, path+"/"+k, out)
+		}
+	case []interface{}:
+		for i, child := range t {
+			flatten(child, fmt.Sprintf("%s/%d", path, i), out)
+		}
+	default:
+		j, err := json.Marshal(t)
+		if err != nil {
+			j = []byte(fmt.Sprint(t))
+		}
+		out[path] = string(j)
+	}
+}
+
+// ReferenceEdits returns the references in t to the old names of renamed
+// resources, with the change that would make each one use the new name.
+// Each edit starts with the path to the key of the reference, like
+// Resources/Queue/Properties/QueueName/Fn::Sub or Resources/Queue/DependsOn.
+func ReferenceEdits(t cft.Template, renames []Rename) []string {
+	names := make(map[string]string)
+	for _, r := range renames {
+		names[r.From] = r.To
+	}
+
+	edits := make([]string, 0)
+	add := func(path []string, kind, from, to string) {
+		edits = append(edits, fmt.Sprintf("%s: %s %s -> %s", strings.Join(path, "/"), kind, from, to))
+	}
+
+	var walk func(v interface{}, path []string)
+	walk = func(v interface{}, path []string) {
+		switch t := v.(type) {
+		case []interface{}:
+			for i, val := range t {
+				walk(val, child(path, fmt.Sprint(i)))
+			}
+		case map[string]interface{}:
+			for key, val := range t {
+				p := child(path, key)
+				switch key {
+				case "Ref":
+					if s, ok := val.(string); ok {
+						if to, ok := names[s]; ok {
+							add(p, "Ref", s, to)
+						}
+						continue
+					}
+				case "Fn::GetAtt":
+					var name, rest string
+					switch g := val.(type) {
+					case string:
+						name, rest, _ = strings.Cut(g, ".")
+					case []interface{}:
+						if len(g) > 0 {
+							name, _ = g[0].(string)
+						}
+						if len(g) > 1 {
+							rest = fmt.Sprint(g[1])
+						}
+					}
+					if to, ok := names[name]; ok {
+						add(p, "GetAtt", name+"."+rest, to+"."+rest)
+						continue
+					}
+				case "Fn::Sub":
+					s, _ := val.(string)
+					if l, ok := val.([]interface{}); ok && len(l) > 0 {
+						s, _ = l[0].(string)
+					}
+					for _, groups := range subRe.FindAllStringSubmatch(s, -1) {
+						name, rest, found := strings.Cut(groups[1], ".")
+						if to, ok := names[name]; ok {
+							if found {
+								add(p, "Sub", "${"+groups[1]+"}", "${"+to+"."+rest+"}")
+							} else {
+								add(p, "Sub", "${"+groups[1]+"}", "${"+to+"}")
+							}
+						}
+					}
+				case "DependsOn":
+					deps := make([]interface{}, 0)
+					switch d := val.(type) {
+					case string:
+						deps = append(deps, d)
+					case []interface{}:
+						deps = d
+					}
+					for _, dep := range deps {
+						if s, ok := dep.(string); ok {
+							if to, ok := names[s]; ok {
+								add(p, "DependsOn", s, to)
+							}
+						}
+					}
+					continue
+				}
+				walk(val, p)
+			}
+		}
+	}
+
+	m := t.Map()
+	for _, section := range []cft.Section{cft.Conditions, cft.Resources, cft.Outputs} {
+		if s, ok := m[string(section)]; ok {
+			walk(s, []string{string(section)})
+		}
+	}
+
+	sort.Strings(edits)
+
+	return edits
+}
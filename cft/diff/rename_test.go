@@ -0,0 +1,119 @@
+package diff_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws-cloudformation/rain/cft/diff"
+	"github.com/aws-cloudformation/rain/cft/parse"
+)
+
+const renameOld = `
+Resources:
+  Bucket:
+    Type: AWS::S3::Bucket
+    Properties:
+      BucketName: data
+      VersioningConfiguration:
+        Status: Enabled
+      Tags:
+        - Key: Team
+          Value: a
+        - Key: App
+          Value: b
+  Topic:
+    Type: AWS::SNS::Topic
+    Properties:
+      TopicName: alerts
+`
+
+const renameNew = `
+Resources:
+  ModuleBucket:
+    Type: AWS::S3::Bucket
+    Properties:
+      BucketName: data
+      VersioningConfiguration:
+        Status: Enabled
+      Tags:
+        - Key: Team
+          Value: a
+        - Key: App
+          Value: c
+  Queue:
+    Type: AWS::SQS::Queue
+    DependsOn: [Bucket, Other]
+    Properties:
+      QueueName: !Sub ${Bucket}-${AWS::Region}
+  Topic2:
+    Type: AWS::SNS::Topic
+    Properties:
+      TopicName: a-completely-different-topic
+Outputs:
+  Arn:
+    Value: !GetAtt Bucket.Arn
+`
+
+func TestRenames(t *testing.T) {
+	a, err := parse.String(renameOld)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := parse.String(renameNew)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	renames := diff.FindRenames(a, b, diff.Options{})
+	if len(renames) != 1 || renames[0].From != "Bucket" || renames[0].To != "ModuleBucket" {
+		t.Fatalf("unexpected renames: %v", renames)
+	}
+
+	d := diff.NewWithOptions(a, b, diff.Options{DetectRenames: true})
+
+	expected := `(+) Outputs:
+(+)   Arn:
+(+)     Value:
+(+)       Fn::GetAtt:
+(+)         - Bucket
+(+)         - Arn
+(|) Resources:
+(~)   ModuleBucket: (renamed from Bucket)
+(|)     Properties:
+(|)       Tags:
+(|)         [1]:
+(>)           Value: c
+(+)   Queue:
+(+)     DependsOn:
+(+)       - Bucket
+(+)       - Other
+(+)     Properties:
+(+)       QueueName:
+(+)         Fn::Sub: ${Bucket}-${AWS::Region}
+(+)     Type: AWS::SQS::Queue
+(-)   Topic: {...}
+(+)   Topic2:
+(+)     Properties:
+(+)       TopicName: a-completely-different-topic
+(+)     Type: AWS::SNS::Topic
+`
+	actual := d.Format(false)
+	if actual != expected {
+		t.Errorf("\n%s\n!=\n%s", actual, expected)
+	}
+
+	actions := diff.GetResourceActions(d)
+	if actions["Bucket"] != diff.Delete || actions["ModuleBucket"] != diff.Create {
+		t.Errorf("unexpected actions: %v", actions)
+	}
+
+	edits := diff.ReferenceEdits(b, renames)
+	expectedEdits := []string{
+		"Outputs/Arn/Value/Fn::GetAtt: GetAtt Bucket.Arn -> ModuleBucket.Arn",
+		"Resources/Queue/DependsOn: DependsOn Bucket -> ModuleBucket",
+		"Resources/Queue/Properties/QueueName/Fn::Sub: Sub ${Bucket} -> ${ModuleBucket}",
+	}
+	if !reflect.DeepEqual(edits, expectedEdits) {
+		t.Errorf("unexpected edits: %v", edits)
+	}
+}
@@ -0,0 +1,145 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// contextLines is the number of unchanged lines shown around each change
+const contextLines = 3
+
+// Unified returns a unified diff of two texts, in the format used by
+// diff -u and git. The names are used in the --- and +++ header lines.
+// It returns an empty string if the texts are the same.
+func Unified(fromName, toName, a, b string) string {
+	if a == b {
+		return ""
+	}
+
+	x := splitLines(a)
+	y := splitLines(b)
+
+	// Leave common lines at the start and end out of the alignment
+	prefix := 0
+	for prefix < len(x) && prefix < len(y) && x[prefix] == y[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(x)-prefix && suffix < len(y)-prefix && x[len(x)-1-suffix] == y[len(y)-1-suffix] {
+		suffix++
+	}
+
+	// Build the edit script as a list of lines, each prefixed with ' ', '-' or '+'
+	type line struct {
+		op   byte
+		text string
+	}
+	lines := make([]line, 0, len(x)+len(y))
+	for _, l := range x[:prefix] {
+		lines = append(lines, line{' ', l})
+	}
+
+	mx := x[prefix : len(x)-suffix]
+	my := y[prefix : len(y)-suffix]
+	lcs := make([][]int, len(mx)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(my)+1)
+	}
+	for i := len(mx) - 1; i >= 0; i-- {
+		for j := len(my) - 1; j >= 0; j-- {
+			if mx[i] == my[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(mx) || j < len(my) {
+		switch {
+		case i < len(mx) && j < len(my) && mx[i] == my[j]:
+			lines = append(lines, line{' ', mx[i]})
+			i++
+			j++
+		case i < len(mx) && (j == len(my) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, line{'-', mx[i]})
+			i++
+		default:
+			lines = append(lines, line{'+', my[j]})
+			j++
+		}
+	}
+
+	for _, l := range x[len(x)-suffix:] {
+		lines = append(lines, line{' ', l})
+	}
+
+	out := strings.Builder{}
+	out.WriteString(fmt.Sprintf("--- %s\n+++ %s\n", fromName, toName))
+
+	// Group the changes into hunks with some context around them
+	for start := 0; start < len(lines); {
+		if lines[start].op == ' ' {
+			start++
+			continue
+		}
+
+		// Extend the hunk while changes are close together
+		end := start
+		for k := start; k < len(lines); k++ {
+			if lines[k].op != ' ' {
+				end = k + 1
+			} else if k-end >= 2*contextLines {
+				break
+			}
+		}
+
+		from := max(start-contextLines, 0)
+		to := min(end+contextLines, len(lines))
+
+		// Count the line numbers at the start of the hunk
+		oldStart, newStart := 1, 1
+		for _, l := range lines[:from] {
+			if l.op != '+' {
+				oldStart++
+			}
+			if l.op != '-' {
+				newStart++
+			}
+		}
+		oldCount, newCount := 0, 0
+		for _, l := range lines[from:to] {
+			if l.op != '+' {
+				oldCount++
+			}
+			if l.op != '-' {
+				newCount++
+			}
+		}
+		if oldCount == 0 {
+			oldStart--
+		}
+		if newCount == 0 {
+			newStart--
+		}
+
+		out.WriteString(fmt.Sprintf("@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount))
+		for _, l := range lines[from:to] {
+			out.WriteByte(l.op)
+			out.WriteString(l.text)
+			out.WriteString("\n")
+		}
+
+		start = to
+	}
+
+	return out.String()
+}
+
+func splitLines(s string) []string {
+	s = strings.TrimSuffix(s, "\n")
+	if s == "" {
+		return []string{}
+	}
+	return strings.Split(s, "\n")
+}
@@ -0,0 +1,726 @@
+// Package eval evaluates conditions and intrinsic functions in a
+// CloudFormation template, using supplied parameter and pseudo-parameter
+// values, so that rain can see the template as CloudFormation would.
+//
+// Values that can't be known before deployment, like a Ref to a resource
+// or a Fn::GetAtt, are left in place. Anything that can be resolved around
+// them is resolved, so the output is still a valid template.
+//
+// Supported:
+//
+//	Ref (parameters, pseudo-parameters and AWS::NoValue)
+//	Fn::If, and Fn::Equals, Fn::And, Fn::Or, Fn::Not and Condition in Conditions
+//	Fn::FindInMap
+//	Fn::Select
+//	Fn::Split
+//	Fn::Join
+//	Fn::Sub
+//	Fn::GetAZs (from a supplied list)
+//	Fn::Base64
+//	Fn::Length and Fn::ToJsonString (from AWS::LanguageExtensions)
+//
+// Expand applies the rest of the AWS::LanguageExtensions transform.
+package eval
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws-cloudformation/rain/cft"
+	"github.com/aws-cloudformation/rain/cft/parse"
+	"gopkg.in/yaml.v3"
+)
+
+// Options are the values used to evaluate a template
+type Options struct {
+	// Parameters holds values for the template's parameters.
+	// Parameters that are not set here use their Default, except for
+	// AWS::SSM::Parameter::Value types, whose Default is the name of the SSM parameter.
+	// List parameters are comma-delimited.
+	Parameters map[string]string
+
+	// PseudoParameters holds values like "AWS::Region".
+	// See DefaultPseudoParameters.
+	PseudoParameters map[string]string
+
+	// AZs is returned by Fn::GetAZs. If it is empty, Fn::GetAZs is left as-is.
+	AZs []string
+}
+
+// Evaluator evaluates conditions and intrinsic functions in a template
+type Evaluator struct {
+	model      *cft.Model
+	options    Options
+	conditions map[string]bool
+	evaluating map[string]bool
+}
+
+// errUndecided is returned for a condition that depends on a value that
+// is not known, like a pseudo-parameter that was not supplied
+var errUndecided = errors.New("unable to resolve the values in Fn::Equals")
+
+// noValue is returned when a value resolves to AWS::NoValue.
+// The key or sequence element that holds it is removed.
+var noValue = &yaml.Node{Kind: yaml.ScalarNode, Value: "AWS::NoValue"}
+
+// New returns an Evaluator for the template
+func New(t cft.Template, options Options) (*Evaluator, error) {
+	m, err := t.Model()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Evaluator{
+		model:      m,
+		options:    options,
+		conditions: make(map[string]bool),
+		evaluating: make(map[string]bool),
+	}, nil
+}
+
+// DefaultPseudoParameters returns pseudo-parameter values for a deployment.
+// Empty arguments are left out, so Refs to them are not resolved.
+func DefaultPseudoParameters(region string, accountId string, stackName string) map[string]string {
+	retval := make(map[string]string)
+	if region != "" {
+		retval["AWS::Region"] = region
+		retval["AWS::Partition"] = Partition(region)
+		retval["AWS::URLSuffix"] = URLSuffix(region)
+	}
+	if accountId != "" {
+		retval["AWS::AccountId"] = accountId
+	}
+	if stackName != "" {
+		retval["AWS::StackName"] = stackName
+	}
+	return retval
+}
+
+// Partition returns the partition that the region belongs to
+func Partition(region string) string {
+	switch {
+	case strings.HasPrefix(region, "us-gov"):
+		return "aws-us-gov"
+	case strings.HasPrefix(region, "cn-"):
+		return "aws-cn"
+	case strings.HasPrefix(region, "us-isob-"):
+		return "aws-iso-b"
+	case strings.HasPrefix(region, "us-iso-"):
+		return "aws-iso"
+	}
+	return "aws"
+}
+
+// URLSuffix returns the domain suffix for the region
+func URLSuffix(region string) string {
+	if strings.HasPrefix(region, "cn-") {
+		return "amazonaws.com.cn"
+	}
+	return "amazonaws.com"
+}
+
+// Condition evaluates the named condition from the Conditions section
+func (e *Evaluator) Condition(name string) (bool, error) {
+	if val, ok := e.conditions[name]; ok {
+		return val, nil
+	}
+
+	n, ok := e.model.Conditions[name]
+	if !ok {
+		return false, fmt.Errorf("condition %s not found", name)
+	}
+
+	if e.evaluating[name] {
+		return false, fmt.Errorf("circular reference in condition %s", name)
+	}
+	e.evaluating[name] = true
+	defer delete(e.evaluating, name)
+
+	val, err := e.evalCondition(n)
+	if err != nil {
+		return false, fmt.Errorf("unable to evaluate condition %s: %w", name, err)
+	}
+
+	e.conditions[name] = val
+
+	return val, nil
+}
+
+// Resolve returns a copy of n with intrinsic functions evaluated.
+// It returns nil if the whole value resolves to AWS::NoValue.
+func (e *Evaluator) Resolve(n *yaml.Node) (*yaml.Node, error) {
+	retval, err := e.resolve(n)
+	if err != nil {
+		return nil, err
+	}
+	if retval == noValue {
+		return nil, nil
+	}
+	return retval, nil
+}
+
+func (e *Evaluator) resolve(n *yaml.Node) (*yaml.Node, error) {
+	switch n.Kind {
+	case yaml.SequenceNode:
+		retval := *n
+		retval.Content = make([]*yaml.Node, 0, len(n.Content))
+		for _, item := range n.Content {
+			r, err := e.resolve(item)
+			if err != nil {
+				return nil, err
+			}
+			if r != noValue {
+				retval.Content = append(retval.Content, r)
+			}
+		}
+		return &retval, nil
+	case yaml.MappingNode:
+		if len(n.Content) == 2 {
+			if fn, ok := functions[n.Content[0].Value]; ok {
+				return fn(e, n.Content[1])
+			}
+		}
+
+		retval := *n
+		retval.Content = make([]*yaml.Node, 0, len(n.Content))
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			r, err := e.resolve(n.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			if r != noValue {
+				retval.Content = append(retval.Content, n.Content[i], r)
+			}
+		}
+		return &retval, nil
+	}
+
+	return n, nil
+}
+
+// isResolved returns true if the node does not contain any intrinsic functions
+func isResolved(n *yaml.Node) bool {
+	if isIntrinsic(n) {
+		return false
+	}
+	for _, c := range n.Content {
+		if !isResolved(c) {
+			return false
+		}
+	}
+	return true
+}
+
+func isIntrinsic(n *yaml.Node) bool {
+	if n.Kind != yaml.MappingNode || len(n.Content) != 2 {
+		return false
+	}
+	key := n.Content[0].Value
+	return key == "Ref" || key == "Condition" || strings.HasPrefix(key, "Fn::")
+}
+
+// equal returns true if two resolved nodes have the same value
+func equal(a *yaml.Node, b *yaml.Node) bool {
+	if a.Kind != b.Kind || a.Value != b.Value || len(a.Content) != len(b.Content) {
+		return false
+	}
+	for i := range a.Content {
+		if !equal(a.Content[i], b.Content[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *Evaluator) evalCondition(n *yaml.Node) (bool, error) {
+	if n.Kind != yaml.MappingNode || len(n.Content) != 2 {
+		return false, errors.New("expected a condition function")
+	}
+
+	name := n.Content[0].Value
+	arg := n.Content[1]
+
+	if name == "Condition" {
+		if arg.Kind != yaml.ScalarNode {
+			return false, errors.New("expected Condition to be a string")
+		}
+		return e.Condition(arg.Value)
+	}
+
+	if arg.Kind != yaml.SequenceNode {
+		return false, fmt.Errorf("expected %s to be a list", name)
+	}
+
+	switch name {
+	case "Fn::Equals":
+		if len(arg.Content) != 2 {
+			return false, errors.New("expected Fn::Equals to have 2 elements")
+		}
+		a, err := e.resolve(arg.Content[0])
+		if err != nil {
+			return false, err
+		}
+		b, err := e.resolve(arg.Content[1])
+		if err != nil {
+			return false, err
+		}
+		if !isResolved(a) || !isResolved(b) {
+			return false, errUndecided
+		}
+		return equal(a, b), nil
+	case "Fn::And", "Fn::Or":
+		if len(arg.Content) < 2 || len(arg.Content) > 10 {
+			return false, fmt.Errorf("expected %s to have 2 to 10 elements", name)
+		}
+		// A condition that can't be decided only matters
+		// if none of the others decide the result
+		undecided := false
+		for _, c := range arg.Content {
+			val, err := e.evalCondition(c)
+			if errors.Is(err, errUndecided) {
+				undecided = true
+				continue
+			}
+			if err != nil {
+				return false, err
+			}
+			if name == "Fn::And" && !val {
+				return false, nil
+			}
+			if name == "Fn::Or" && val {
+				return true, nil
+			}
+		}
+		if undecided {
+			return false, errUndecided
+		}
+		return name == "Fn::And", nil
+	case "Fn::Not":
+		if len(arg.Content) != 1 {
+			return false, errors.New("expected Fn::Not to have 1 element")
+		}
+		val, err := e.evalCondition(arg.Content[0])
+		if err != nil {
+			return false, err
+		}
+		return !val, nil
+	}
+
+	return false, fmt.Errorf("unsupported condition function %s", name)
+}
+
+type function func(e *Evaluator, arg *yaml.Node) (*yaml.Node, error)
+
+var functions map[string]function
+
+func init() {
+	functions = map[string]function{
+		"Ref":           resolveRef,
+		"Fn::If":        resolveIf,
+		"Fn::FindInMap": resolveFindInMap,
+		"Fn::Select":    resolveSelect,
+		"Fn::Split":     resolveSplit,
+		"Fn::Join":      resolveJoin,
+		"Fn::Sub":       resolveSub,
+		"Fn::GetAZs":    resolveGetAZs,
+		"Fn::Base64":    resolveBase64,
+
+		"Fn::Length":       resolveLength,
+		"Fn::ToJsonString": resolveToJsonString,
+	}
+}
+
+// unresolved re-creates the function with its arguments partially resolved
+func unresolved(name string, arg *yaml.Node) *yaml.Node {
+	return &yaml.Node{
+		Kind: yaml.MappingNode,
+		Tag:  "!!map",
+		Content: []*yaml.Node{
+			{Kind: yaml.ScalarNode, Tag: "!!str", Value: name},
+			arg,
+		},
+	}
+}
+
+func str(val string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: val}
+}
+
+func list(vals []string) *yaml.Node {
+	retval := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	for _, val := range vals {
+		retval.Content = append(retval.Content, str(val))
+	}
+	return retval
+}
+
+// resolveArgs resolves each element of a function's list argument.
+// The bool is false if any of them could not be fully resolved.
+func (e *Evaluator) resolveArgs(name string, arg *yaml.Node, count ...int) (*yaml.Node, bool, error) {
+	if arg.Kind != yaml.SequenceNode {
+		return nil, false, fmt.Errorf("expected %s to be a list", name)
+	}
+	if len(count) > 0 && (len(arg.Content) < count[0] || len(arg.Content) > count[len(count)-1]) {
+		return nil, false, fmt.Errorf("unexpected number of arguments to %s", name)
+	}
+
+	r, err := e.resolve(arg)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(r.Content) != len(arg.Content) {
+		return nil, false, fmt.Errorf("unexpected AWS::NoValue in %s", name)
+	}
+
+	return r, isResolved(r), nil
+}
+
+// refValue returns the value of a parameter or pseudo-parameter,
+// or nil if it is not known
+func (e *Evaluator) refValue(name string) *yaml.Node {
+	if name == "AWS::NoValue" {
+		return noValue
+	}
+
+	if strings.HasPrefix(name, "AWS::") {
+		if val, ok := e.options.PseudoParameters[name]; ok {
+			return str(val)
+		}
+		return nil
+	}
+
+	p, ok := e.model.Parameters[name]
+	if !ok {
+		return nil
+	}
+
+	// The Default of an SSM parameter is the name of a parameter in
+	// Parameter Store, so its value is only known if it is supplied
+	typeName := p.Type
+	isSSM := strings.HasPrefix(typeName, "AWS::SSM::Parameter::Value<")
+	if isSSM {
+		typeName = strings.TrimSuffix(strings.TrimPrefix(typeName, "AWS::SSM::Parameter::Value<"), ">")
+	}
+
+	val, ok := e.options.Parameters[name]
+	if !ok {
+		if isSSM || p.Default == nil || p.Default.Kind != yaml.ScalarNode {
+			return nil
+		}
+		val = p.Default.Value
+	}
+
+	if typeName == "CommaDelimitedList" || strings.HasPrefix(typeName, "List<") {
+		vals := strings.Split(val, ",")
+		for i := range vals {
+			vals[i] = strings.TrimSpace(vals[i])
+		}
+		return list(vals)
+	}
+
+	if typeName == "Number" {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: val}
+	}
+
+	return str(val)
+}
+
+func resolveRef(e *Evaluator, arg *yaml.Node) (*yaml.Node, error) {
+	if arg.Kind != yaml.ScalarNode {
+		return nil, errors.New("expected Ref to be a string")
+	}
+
+	if val := e.refValue(arg.Value); val != nil {
+		return val, nil
+	}
+
+	return unresolved("Ref", arg), nil
+}
+
+func resolveIf(e *Evaluator, arg *yaml.Node) (*yaml.Node, error) {
+	if arg.Kind != yaml.SequenceNode || len(arg.Content) != 3 {
+		return nil, errors.New("expected Fn::If to be a list with 3 elements")
+	}
+	if arg.Content[0].Kind != yaml.ScalarNode {
+		return nil, errors.New("expected the Fn::If condition to be a string")
+	}
+
+	val, err := e.Condition(arg.Content[0].Value)
+	if errors.Is(err, errUndecided) {
+		// Keep the Fn::If, with both values resolved as far as they can be
+		r := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Content: []*yaml.Node{arg.Content[0]}}
+		for _, c := range arg.Content[1:] {
+			v, err := e.resolve(c)
+			if err != nil {
+				return nil, err
+			}
+			if v == noValue {
+				v = unresolved("Ref", str("AWS::NoValue"))
+			}
+			r.Content = append(r.Content, v)
+		}
+		return unresolved("Fn::If", r), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if val {
+		return e.resolve(arg.Content[1])
+	}
+	return e.resolve(arg.Content[2])
+}
+
+func resolveFindInMap(e *Evaluator, arg *yaml.Node) (*yaml.Node, error) {
+	if arg.Kind != yaml.SequenceNode || len(arg.Content) < 3 || len(arg.Content) > 4 {
+		return nil, errors.New("expected Fn::FindInMap to be a list with 3 elements")
+	}
+
+	keys := make([]string, 3)
+	resolvedArgs := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	ok := true
+	for i, k := range arg.Content[:3] {
+		r, err := e.resolve(k)
+		if err != nil {
+			return nil, err
+		}
+		if r.Kind != yaml.ScalarNode {
+			ok = false
+		}
+		keys[i] = r.Value
+		resolvedArgs.Content = append(resolvedArgs.Content, r)
+	}
+	if !ok {
+		resolvedArgs.Content = append(resolvedArgs.Content, arg.Content[3:]...)
+		return unresolved("Fn::FindInMap", resolvedArgs), nil
+	}
+
+	if mapping, ok := e.model.Mappings[keys[0]]; ok {
+		if val, ok := mapping[keys[1]][keys[2]]; ok {
+			return e.resolve(val)
+		}
+	}
+
+	// The language extensions transform adds an optional default value
+	if len(arg.Content) == 4 {
+		if opt := arg.Content[3]; opt.Kind == yaml.MappingNode &&
+			len(opt.Content) == 2 && opt.Content[0].Value == "DefaultValue" {
+			return e.resolve(opt.Content[1])
+		}
+	}
+
+	return nil, fmt.Errorf("unable to find %s/%s/%s in Mappings", keys[0], keys[1], keys[2])
+}
+
+func resolveSelect(e *Evaluator, arg *yaml.Node) (*yaml.Node, error) {
+	r, ok, err := e.resolveArgs("Fn::Select", arg, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	index := r.Content[0]
+	items := r.Content[1]
+	if index.Kind != yaml.ScalarNode || items.Kind != yaml.SequenceNode {
+		return unresolved("Fn::Select", r), nil
+	}
+
+	i, err := strconv.Atoi(index.Value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Fn::Select index %s", index.Value)
+	}
+	if i < 0 || i >= len(items.Content) {
+		return nil, fmt.Errorf("Fn::Select index %d is out of range", i)
+	}
+
+	// A single element is usable even if the rest of the list is not resolved
+	if !ok && !isResolved(items.Content[i]) {
+		return unresolved("Fn::Select", r), nil
+	}
+
+	return items.Content[i], nil
+}
+
+func resolveSplit(e *Evaluator, arg *yaml.Node) (*yaml.Node, error) {
+	r, ok, err := e.resolveArgs("Fn::Split", arg, 2)
+	if err != nil {
+		return nil, err
+	}
+	if !ok || r.Content[0].Kind != yaml.ScalarNode || r.Content[1].Kind != yaml.ScalarNode {
+		return unresolved("Fn::Split", r), nil
+	}
+
+	return list(strings.Split(r.Content[1].Value, r.Content[0].Value)), nil
+}
+
+func resolveJoin(e *Evaluator, arg *yaml.Node) (*yaml.Node, error) {
+	r, ok, err := e.resolveArgs("Fn::Join", arg, 2)
+	if err != nil {
+		return nil, err
+	}
+	if !ok || r.Content[0].Kind != yaml.ScalarNode || r.Content[1].Kind != yaml.SequenceNode {
+		return unresolved("Fn::Join", r), nil
+	}
+
+	vals := make([]string, 0, len(r.Content[1].Content))
+	for _, v := range r.Content[1].Content {
+		if v.Kind != yaml.ScalarNode {
+			return nil, errors.New("expected Fn::Join values to be strings")
+		}
+		vals = append(vals, v.Value)
+	}
+
+	return str(strings.Join(vals, r.Content[0].Value)), nil
+}
+
+func resolveSub(e *Evaluator, arg *yaml.Node) (*yaml.Node, error) {
+	var source *yaml.Node
+	vars := make(map[string]*yaml.Node)
+	varNames := make([]string, 0)
+
+	switch arg.Kind {
+	case yaml.ScalarNode:
+		source = arg
+	case yaml.SequenceNode:
+		if len(arg.Content) != 2 || arg.Content[1].Kind != yaml.MappingNode {
+			return nil, errors.New("expected Fn::Sub to be a string or a list with a string and a map")
+		}
+		r, err := e.resolve(arg.Content[0])
+		if err != nil {
+			return nil, err
+		}
+		source = r
+		m := arg.Content[1]
+		for i := 0; i+1 < len(m.Content); i += 2 {
+			v, err := e.resolve(m.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			vars[m.Content[i].Value] = v
+			varNames = append(varNames, m.Content[i].Value)
+		}
+	default:
+		return nil, errors.New("expected Fn::Sub to be a string or a list")
+	}
+
+	if source.Kind != yaml.ScalarNode {
+		return unresolved("Fn::Sub", arg), nil
+	}
+
+	words, err := parse.ParseSub(source.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	// Build the resolved string, and the string to use if anything is left
+	var out, partial strings.Builder
+	done := true
+	used := make(map[string]bool)
+	for _, w := range words {
+		var name string
+		switch w.T {
+		case parse.STR:
+			out.WriteString(w.W)
+			partial.WriteString(strings.ReplaceAll(w.W, "${", "${!"))
+			continue
+		case parse.AWS:
+			name = "AWS::" + w.W
+		default:
+			name = w.W
+		}
+
+		val, ok := vars[name]
+		if !ok && w.T != parse.GETATT {
+			val = e.refValue(name)
+		}
+		if val == nil || val == noValue || val.Kind != yaml.ScalarNode {
+			done = false
+			if ok {
+				used[name] = true
+			}
+			partial.WriteString("${" + name + "}")
+			continue
+		}
+		out.WriteString(val.Value)
+		partial.WriteString(val.Value)
+	}
+
+	if done {
+		return str(out.String()), nil
+	}
+
+	if len(used) == 0 {
+		return unresolved("Fn::Sub", str(partial.String())), nil
+	}
+
+	m := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for _, name := range varNames {
+		if used[name] {
+			m.Content = append(m.Content, str(name), vars[name])
+		}
+	}
+	return unresolved("Fn::Sub", &yaml.Node{
+		Kind:    yaml.SequenceNode,
+		Tag:     "!!seq",
+		Content: []*yaml.Node{str(partial.String()), m},
+	}), nil
+}
+
+func resolveGetAZs(e *Evaluator, arg *yaml.Node) (*yaml.Node, error) {
+	if len(e.options.AZs) == 0 {
+		r, err := e.resolve(arg)
+		if err != nil {
+			return nil, err
+		}
+		return unresolved("Fn::GetAZs", r), nil
+	}
+	return list(e.options.AZs), nil
+}
+
+func resolveBase64(e *Evaluator, arg *yaml.Node) (*yaml.Node, error) {
+	r, err := e.resolve(arg)
+	if err != nil {
+		return nil, err
+	}
+	if r.Kind != yaml.ScalarNode {
+		return unresolved("Fn::Base64", r), nil
+	}
+	return str(base64.StdEncoding.EncodeToString([]byte(r.Value))), nil
+}
+
+func resolveLength(e *Evaluator, arg *yaml.Node) (*yaml.Node, error) {
+	r, err := e.resolve(arg)
+	if err != nil {
+		return nil, err
+	}
+	if r.Kind != yaml.SequenceNode {
+		return unresolved("Fn::Length", r), nil
+	}
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(len(r.Content))}, nil
+}
+
+func resolveToJsonString(e *Evaluator, arg *yaml.Node) (*yaml.Node, error) {
+	r, err := e.resolve(arg)
+	if err != nil {
+		return nil, err
+	}
+	if !isResolved(r) {
+		return unresolved("Fn::ToJsonString", r), nil
+	}
+
+	var v interface{}
+	err = r.Decode(&v)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode Fn::ToJsonString: %v", err)
+	}
+
+	out, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode Fn::ToJsonString: %v", err)
+	}
+
+	return str(string(out)), nil
+}
@@ -0,0 +1,362 @@
+package eval_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/aws-cloudformation/rain/cft/eval"
+	"github.com/aws-cloudformation/rain/cft/format"
+	"github.com/aws-cloudformation/rain/cft/parse"
+	"github.com/google/go-cmp/cmp"
+	"gopkg.in/yaml.v3"
+)
+
+const source = `
+Parameters:
+  Env:
+    Type: String
+    Default: dev
+  Subnets:
+    Type: CommaDelimitedList
+  Size:
+    Type: Number
+
+Mappings:
+  Sizes:
+    dev:
+      Instance: t3.micro
+    prod:
+      Instance: m5.large
+
+Conditions:
+  IsProd: !Equals [!Ref Env, prod]
+  IsDev: !Not [!Condition IsProd]
+  IsEast: !Equals [!Ref AWS::Region, us-east-1]
+  IsDevInEast: !And [!Condition IsDev, !Condition IsEast]
+
+Resources:
+  Bucket:
+    Type: AWS::S3::Bucket
+    DeletionPolicy: !If [IsProd, Retain, Delete]
+    Properties:
+      BucketName: !Sub ${AWS::StackName}-${Env}-${Bucket.Arn}
+      Tags:
+        - Key: Subnet
+          Value: !Select [1, !Ref Subnets]
+        - !If
+          - IsProd
+          - Key: Prod
+            Value: "true"
+          - !Ref AWS::NoValue
+
+  Instance:
+    Type: AWS::EC2::Instance
+    Condition: IsDevInEast
+    DependsOn: [Bucket, Topic]
+    Properties:
+      InstanceType: !FindInMap [Sizes, !Ref Env, Instance]
+      AvailabilityZone: !Select [0, !GetAZs ""]
+      UserData:
+        Fn::Base64: !Join ["-", !Split [",", "a,b"]]
+
+  Topic:
+    Type: AWS::SNS::Topic
+    Condition: IsProd
+
+Outputs:
+  TopicArn:
+    Condition: IsProd
+    Value: !Ref Topic
+  InstanceId:
+    Value: !If [IsDevInEast, !Ref Instance, !Sub "${!Literal}-${Size}"]
+`
+
+func TestRender(t *testing.T) {
+	tmpl, err := parse.String(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rendered, err := eval.Render(tmpl, eval.Options{
+		Parameters: map[string]string{
+			"Subnets": "a, b",
+		},
+		PseudoParameters: eval.DefaultPseudoParameters("us-east-1", "", "my-stack"),
+		AZs:              []string{"us-east-1a", "us-east-1b"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `Parameters:
+  Env:
+    Type: String
+    Default: dev
+
+  Subnets:
+    Type: CommaDelimitedList
+
+  Size:
+    Type: Number
+
+Mappings:
+  Sizes:
+    dev:
+      Instance: t3.micro
+    prod:
+      Instance: m5.large
+
+Resources:
+  Bucket:
+    Type: AWS::S3::Bucket
+    DeletionPolicy: Delete
+    Properties:
+      BucketName: !Sub my-stack-dev-${Bucket.Arn}
+      Tags:
+        - Key: Subnet
+          Value: b
+
+  Instance:
+    Type: AWS::EC2::Instance
+    DependsOn:
+      - Bucket
+    Properties:
+      InstanceType: t3.micro
+      AvailabilityZone: us-east-1a
+      UserData: YS1i
+
+Outputs:
+  InstanceId:
+    Value: !Ref Instance
+`
+
+	actual := format.String(rendered, format.Options{Unsorted: true})
+	if d := cmp.Diff(expected, actual); d != "" {
+		t.Error(d)
+	}
+}
+
+func TestRenderServerless(t *testing.T) {
+	tmpl, err := parse.String(`
+Transform: AWS::Serverless-2016-10-31
+Parameters:
+  Prod:
+    Type: String
+Conditions:
+  IsProd: !Equals [!Ref Prod, "true"]
+Resources:
+  Table:
+    Type: AWS::Serverless::SimpleTable
+    Condition: IsProd
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rendered, err := eval.Render(tmpl, eval.Options{
+		Parameters: map[string]string{"Prod": "true"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := rendered.Model()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, ok := m.Resources["Table"]; !ok || r.Type != "AWS::DynamoDB::Table" {
+		t.Errorf("expected the table to be translated: %v", m.Resources)
+	}
+}
+
+func TestRenderUndecided(t *testing.T) {
+	tmpl, err := parse.String(`
+Parameters:
+  Env:
+    Type: String
+Conditions:
+  IsProdStack: !Equals [!Ref AWS::StackName, prod]
+  IsDev: !Equals [!Ref Env, dev]
+  IsProdOrDev: !Or [!Condition IsProdStack, !Condition IsDev]
+Resources:
+  Bucket:
+    Type: AWS::S3::Bucket
+    Condition: IsProdStack
+    Properties:
+      BucketName: !If [IsProdStack, !Sub "${Env}-bucket", !Ref AWS::NoValue]
+  Queue:
+    Type: AWS::SQS::Queue
+    Condition: IsProdOrDev
+Outputs:
+  BucketName:
+    Condition: IsProdStack
+    Value: !Ref Bucket
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rendered, err := eval.Render(tmpl, eval.Options{
+		Parameters: map[string]string{"Env": "dev"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `Parameters:
+  Env:
+    Type: String
+
+Conditions:
+  IsProdStack: !Equals
+    - !Ref AWS::StackName
+    - prod
+
+  IsDev: !Equals
+    - !Ref Env
+    - dev
+
+  IsProdOrDev: !Or
+    - !Condition IsProdStack
+    - !Condition IsDev
+
+Resources:
+  Bucket:
+    Type: AWS::S3::Bucket
+    Condition: IsProdStack
+    Properties:
+      BucketName: !If
+        - IsProdStack
+        - dev-bucket
+        - !Ref AWS::NoValue
+
+  Queue:
+    Type: AWS::SQS::Queue
+
+Outputs:
+  BucketName:
+    Condition: IsProdStack
+    Value: !Ref Bucket
+`
+
+	actual := format.String(rendered, format.Options{Unsorted: true})
+	if d := cmp.Diff(expected, actual); d != "" {
+		t.Error(d)
+	}
+}
+
+func TestConditionErrors(t *testing.T) {
+	tmpl, err := parse.String(`
+Parameters:
+  Env:
+    Type: String
+Conditions:
+  IsProd: !Equals [!Ref Env, prod]
+  A: !Condition B
+  B: !Condition A
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := eval.New(tmpl, eval.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := e.Condition("IsProd"); err == nil {
+		t.Error("expected an error for a parameter with no value")
+	}
+
+	if _, err := e.Condition("A"); err == nil {
+		t.Error("expected an error for a circular condition")
+	}
+
+	if _, err := e.Condition("Missing"); err == nil {
+		t.Error("expected an error for a missing condition")
+	}
+}
+
+func TestSSMParameter(t *testing.T) {
+	tmpl, err := parse.String(`
+Parameters:
+  ImageId:
+    Type: AWS::SSM::Parameter::Value<AWS::EC2::Image::Id>
+    Default: /aws/service/ami-amazon-linux-latest/al2023-ami-kernel-default-x86_64
+  Subnets:
+    Type: AWS::SSM::Parameter::Value<List<String>>
+    Default: /network/subnets
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ref, err := parse.String("!Ref ImageId")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The Default is the name of the SSM parameter, not its value
+	e, err := eval.New(tmpl, eval.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := e.Resolve(ref.Content[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, _ := yaml.Marshal(r)
+	if d := cmp.Diff("Ref: ImageId\n", string(out)); d != "" {
+		t.Error(d)
+	}
+
+	e, err = eval.New(tmpl, eval.Options{
+		Parameters: map[string]string{"ImageId": "ami-123", "Subnets": "a, b"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err = e.Resolve(ref.Content[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, _ = yaml.Marshal(r)
+	if d := cmp.Diff("ami-123\n", string(out)); d != "" {
+		t.Error(d)
+	}
+
+	selected, err := parse.String("!Select [1, !Ref Subnets]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err = e.Resolve(selected.Content[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, _ = yaml.Marshal(r)
+	if d := cmp.Diff("b\n", string(out)); d != "" {
+		t.Error(d)
+	}
+}
+
+func Example_resolve() {
+	tmpl, _ := parse.String(`
+Parameters:
+  Name:
+    Type: String
+Resources:
+  Bucket:
+    Type: AWS::S3::Bucket
+`)
+
+	e, _ := eval.New(tmpl, eval.Options{
+		Parameters: map[string]string{"Name": "b"},
+	})
+
+	value, _ := parse.String(`!Sub "${!Name}=${Name} in ${AWS::Region}"`)
+	r, _ := e.Resolve(value.Content[0])
+	out, _ := yaml.Marshal(r)
+	fmt.Print(string(out))
+	// Output:
+	// Fn::Sub: ${!Name}=b in ${AWS::Region}
+}
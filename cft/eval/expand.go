@@ -0,0 +1,362 @@
+package eval
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/aws-cloudformation/rain/cft"
+	"github.com/aws-cloudformation/rain/internal/config"
+	"github.com/aws-cloudformation/rain/internal/node"
+	"github.com/aws-cloudformation/rain/internal/s11n"
+	"gopkg.in/yaml.v3"
+)
+
+// LanguageExtensions is the name of the transform that Expand emulates
+const LanguageExtensions = "AWS::LanguageExtensions"
+
+const forEachPrefix = "Fn::ForEach::"
+
+// HasLanguageExtensions returns true if the template
+// declares the AWS::LanguageExtensions transform
+func HasLanguageExtensions(t cft.Template) bool {
+	transform, err := t.GetSection(cft.Transform)
+	if err != nil {
+		return false
+	}
+
+	switch transform.Kind {
+	case yaml.ScalarNode:
+		return transform.Value == LanguageExtensions
+	case yaml.SequenceNode:
+		for _, n := range transform.Content {
+			if n.Value == LanguageExtensions {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Expand returns a copy of the template with the AWS::LanguageExtensions
+// transform applied, the way CloudFormation applies it before a deployment:
+//
+//	Fn::ForEach is expanded in Conditions, Resources and Outputs,
+//	including nested loops and loops inside resources
+//	Fn::Length and Fn::ToJsonString are evaluated
+//	DeletionPolicy and UpdateReplacePolicy values that are intrinsic functions are evaluated
+//
+// The transform is removed from the Transform section.
+// It is an error if a loop's collection or a policy can't be resolved
+// with the supplied options.
+func Expand(t cft.Template, options Options) (cft.Template, error) {
+	expanded := cft.Template{Node: node.Clone(t.Node)}
+
+	e, err := New(expanded, options)
+	if err != nil {
+		return expanded, err
+	}
+
+	for _, section := range []cft.Section{cft.Conditions, cft.Resources, cft.Outputs} {
+		s, err := expanded.GetSection(section)
+		if err != nil {
+			continue
+		}
+		err = e.ExpandForEach(s)
+		if err != nil {
+			return expanded, fmt.Errorf("%s: %v", section, err)
+		}
+	}
+
+	// Loops can add conditions, so start again with the expanded template
+	e, err = New(expanded, options)
+	if err != nil {
+		return expanded, err
+	}
+
+	for _, section := range []cft.Section{cft.Resources, cft.Outputs} {
+		s, err := expanded.GetSection(section)
+		if err != nil {
+			continue
+		}
+		err = e.resolveExtensions(s)
+		if err != nil {
+			return expanded, fmt.Errorf("%s: %v", section, err)
+		}
+	}
+
+	err = e.resolvePolicies(expanded)
+	if err != nil {
+		return expanded, err
+	}
+
+	removeTransform(expanded)
+
+	return expanded, nil
+}
+
+// ExpandForEach replaces each Fn::ForEach key in the mapping n, and in
+// any of its values, with the output of the loop. n is changed in place.
+func (e *Evaluator) ExpandForEach(n *yaml.Node) error {
+	if n.Kind != yaml.MappingNode {
+		return fmt.Errorf("expected a mapping, got %s", n.Tag)
+	}
+	return e.expandMap(n, map[string]string{})
+}
+
+// expandMap expands loops in the mapping n, and replaces loop
+// identifiers in keys and values with their value in vars
+func (e *Evaluator) expandMap(n *yaml.Node, vars map[string]string) error {
+	content := make([]*yaml.Node, 0, len(n.Content))
+	seen := make(map[string]bool)
+
+	add := func(key *yaml.Node, val *yaml.Node) error {
+		if seen[key.Value] {
+			return fmt.Errorf("duplicate key %s after expanding Fn::ForEach", key.Value)
+		}
+		seen[key.Value] = true
+		content = append(content, key, val)
+		return nil
+	}
+
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key := n.Content[i]
+		val := n.Content[i+1]
+
+		if strings.HasPrefix(key.Value, forEachPrefix) {
+			pairs, err := e.loop(key.Value, val, vars)
+			if err != nil {
+				return err
+			}
+			for j := 0; j+1 < len(pairs); j += 2 {
+				err = add(pairs[j], pairs[j+1])
+				if err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		if len(vars) > 0 {
+			key = node.Clone(key)
+			key.Value = replaceInKey(key.Value, vars)
+		}
+
+		err := e.expandValue(val, vars)
+		if err != nil {
+			return fmt.Errorf("%s: %v", key.Value, err)
+		}
+
+		err = add(key, val)
+		if err != nil {
+			return err
+		}
+	}
+
+	n.Content = content
+
+	return nil
+}
+
+// expandValue expands loops in n and replaces loop identifiers
+// in Ref and Fn::Sub with their value in vars
+func (e *Evaluator) expandValue(n *yaml.Node, vars map[string]string) error {
+	switch n.Kind {
+	case yaml.SequenceNode:
+		for _, item := range n.Content {
+			err := e.expandValue(item, vars)
+			if err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		if len(n.Content) == 2 {
+			arg := n.Content[1]
+			switch n.Content[0].Value {
+			case "Ref":
+				if val, ok := vars[arg.Value]; ok && arg.Kind == yaml.ScalarNode {
+					n.Kind = yaml.ScalarNode
+					n.Tag = "!!str"
+					n.Value = val
+					n.Content = nil
+					return nil
+				}
+			case "Fn::Sub":
+				if arg.Kind == yaml.ScalarNode {
+					arg.Value = replaceInSub(arg.Value, vars)
+				} else if arg.Kind == yaml.SequenceNode && len(arg.Content) > 0 {
+					arg.Content[0].Value = replaceInSub(arg.Content[0].Value, vars)
+				}
+			}
+		}
+		return e.expandMap(n, vars)
+	}
+
+	return nil
+}
+
+// loop returns the keys and values that a Fn::ForEach produces
+func (e *Evaluator) loop(name string, n *yaml.Node, vars map[string]string) ([]*yaml.Node, error) {
+	if n.Kind != yaml.SequenceNode || len(n.Content) != 3 {
+		return nil, fmt.Errorf("expected %s to be a list of an identifier, a collection, and an output map", name)
+	}
+
+	identifier := n.Content[0]
+	if identifier.Kind != yaml.ScalarNode {
+		return nil, fmt.Errorf("expected the %s identifier to be a string", name)
+	}
+	if _, ok := vars[identifier.Value]; ok {
+		return nil, fmt.Errorf("%s reuses the identifier %s", name, identifier.Value)
+	}
+
+	// The collection can use the identifiers of outer loops
+	collection := node.Clone(n.Content[1])
+	err := e.expandValue(collection, vars)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	items, err := e.Resolve(collection)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	if items == nil || items.Kind != yaml.SequenceNode || !isResolved(items) {
+		return nil, fmt.Errorf("unable to resolve the collection for %s", name)
+	}
+
+	body := n.Content[2]
+	if body.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("expected the %s output to be a mapping", name)
+	}
+
+	retval := make([]*yaml.Node, 0)
+	for _, item := range items.Content {
+		if item.Kind != yaml.ScalarNode {
+			return nil, fmt.Errorf("expected the %s collection to be a list of strings", name)
+		}
+
+		itemVars := make(map[string]string, len(vars)+1)
+		for k, v := range vars {
+			itemVars[k] = v
+		}
+		itemVars[identifier.Value] = item.Value
+
+		output := node.Clone(body)
+		err := e.expandMap(output, itemVars)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		retval = append(retval, output.Content...)
+	}
+
+	config.Debugf("Expanded %s to %d values", name, len(retval)/2)
+
+	return retval, nil
+}
+
+// replaceInKey replaces ${Identifier} with the identifier's value,
+// and &{Identifier} with the value without any non-alphanumeric characters
+func replaceInKey(s string, vars map[string]string) string {
+	for id, val := range vars {
+		s = strings.ReplaceAll(s, "${"+id+"}", val)
+		s = strings.ReplaceAll(s, "&{"+id+"}", strings.Map(func(r rune) rune {
+			if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+				return r
+			}
+			return -1
+		}, val))
+	}
+	return s
+}
+
+// replaceInSub replaces ${Identifier} in a Fn::Sub string with the identifier's value
+func replaceInSub(s string, vars map[string]string) string {
+	for id, val := range vars {
+		s = strings.ReplaceAll(s, "${"+id+"}", val)
+	}
+	return s
+}
+
+// resolveExtensions evaluates Fn::Length and Fn::ToJsonString in n, in place
+func (e *Evaluator) resolveExtensions(n *yaml.Node) error {
+	if n.Kind == yaml.MappingNode && len(n.Content) == 2 {
+		name := n.Content[0].Value
+		if name == "Fn::Length" || name == "Fn::ToJsonString" {
+			r, err := e.resolve(n)
+			if err != nil {
+				return err
+			}
+			if isIntrinsic(r) && r.Content[0].Value == name {
+				if name == "Fn::Length" {
+					return fmt.Errorf("unable to resolve %s", name)
+				}
+				// Values that are only known after deployment are
+				// left for CloudFormation to convert
+				config.Debugf("unable to resolve %s: %s", name, node.ToSJson(n))
+				return nil
+			}
+			n.Kind, n.Tag, n.Value, n.Content = r.Kind, r.Tag, r.Value, nil
+			return nil
+		}
+	}
+
+	for _, c := range n.Content {
+		err := e.resolveExtensions(c)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// resolvePolicies evaluates DeletionPolicy and UpdateReplacePolicy values
+// that are intrinsic functions
+func (e *Evaluator) resolvePolicies(t cft.Template) error {
+	resources, err := t.GetSection(cft.Resources)
+	if err != nil {
+		return nil
+	}
+
+	for i := 0; i+1 < len(resources.Content); i += 2 {
+		logicalId := resources.Content[i].Value
+		for _, policy := range []string{"DeletionPolicy", "UpdateReplacePolicy"} {
+			_, val, _ := s11n.GetMapValue(resources.Content[i+1], policy)
+			if val == nil || val.Kind == yaml.ScalarNode {
+				continue
+			}
+			r, err := e.Resolve(val)
+			if err != nil {
+				return fmt.Errorf("%s %s: %v", logicalId, policy, err)
+			}
+			if r == nil || r.Kind != yaml.ScalarNode {
+				return fmt.Errorf("unable to resolve the %s for %s", policy, logicalId)
+			}
+			val.Kind, val.Tag, val.Value, val.Content = r.Kind, r.Tag, r.Value, nil
+		}
+	}
+
+	return nil
+}
+
+// removeTransform removes AWS::LanguageExtensions from the Transform section
+func removeTransform(t cft.Template) {
+	transform, err := t.GetSection(cft.Transform)
+	if err != nil {
+		return
+	}
+
+	if transform.Kind == yaml.SequenceNode {
+		transform.Content = slices.DeleteFunc(transform.Content, func(n *yaml.Node) bool {
+			return n.Value == LanguageExtensions
+		})
+		if len(transform.Content) > 0 {
+			return
+		}
+	} else if transform.Value != LanguageExtensions {
+		return
+	}
+
+	node.RemoveFromMap(t.Node.Content[0], string(cft.Transform))
+}
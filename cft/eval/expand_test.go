@@ -0,0 +1,193 @@
+package eval_test
+
+import (
+	"testing"
+
+	"github.com/aws-cloudformation/rain/cft/eval"
+	"github.com/aws-cloudformation/rain/cft/format"
+	"github.com/aws-cloudformation/rain/cft/parse"
+	"github.com/google/go-cmp/cmp"
+)
+
+const extensions = `
+Transform: AWS::LanguageExtensions
+
+Parameters:
+  Envs:
+    Type: CommaDelimitedList
+    Default: dev,prod
+  Policy:
+    Type: String
+    Default: retain
+
+Conditions:
+  ShouldRetain: !Equals [!Ref Policy, retain]
+  Fn::ForEach::EnvConditions:
+    - Env
+    - !Ref Envs
+    - Is${Env}: !Equals [!Ref Env, prod]
+
+Resources:
+  Fn::ForEach::Topics:
+    - Env
+    - !Ref Envs
+    - Fn::ForEach::Names:
+        - Name
+        - [a.b, c-d]
+        - Topic${Env}&{Name}:
+            Type: AWS::SNS::Topic
+            DeletionPolicy: !If [ShouldRetain, Retain, Delete]
+            Properties:
+              TopicName: !Sub ${Env}-${Name}-${AWS::Region}
+              DisplayName: !Ref Name
+
+  Queue:
+    Type: AWS::SQS::Queue
+    Properties:
+      DelaySeconds: !Length [a, b, c]
+      Tags:
+        - Key: Config
+          Value: !ToJsonString
+            Envs: !Ref Envs
+            Count:
+              Fn::Length: !Ref Envs
+        - Key: Deferred
+          Value: !ToJsonString
+            Arn: !GetAtt Queue.Arn
+
+Outputs:
+  Fn::ForEach::Outputs:
+    - Env
+    - !Ref Envs
+    - ${Env}Arn:
+        Value: !Ref Topicdevab
+`
+
+func TestExpand(t *testing.T) {
+	tmpl, err := parse.String(extensions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !eval.HasLanguageExtensions(tmpl) {
+		t.Fatal("expected the template to use the transform")
+	}
+
+	expanded, err := eval.Expand(tmpl, eval.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `Parameters:
+  Envs:
+    Type: CommaDelimitedList
+    Default: dev,prod
+
+  Policy:
+    Type: String
+    Default: retain
+
+Conditions:
+  ShouldRetain: !Equals
+    - !Ref Policy
+    - retain
+
+  Isdev: !Equals
+    - dev
+    - prod
+
+  Isprod: !Equals
+    - prod
+    - prod
+
+Resources:
+  Topicdevab:
+    Type: AWS::SNS::Topic
+    DeletionPolicy: Retain
+    Properties:
+      TopicName: !Sub dev-a.b-${AWS::Region}
+      DisplayName: a.b
+
+  Topicdevcd:
+    Type: AWS::SNS::Topic
+    DeletionPolicy: Retain
+    Properties:
+      TopicName: !Sub dev-c-d-${AWS::Region}
+      DisplayName: c-d
+
+  Topicprodab:
+    Type: AWS::SNS::Topic
+    DeletionPolicy: Retain
+    Properties:
+      TopicName: !Sub prod-a.b-${AWS::Region}
+      DisplayName: a.b
+
+  Topicprodcd:
+    Type: AWS::SNS::Topic
+    DeletionPolicy: Retain
+    Properties:
+      TopicName: !Sub prod-c-d-${AWS::Region}
+      DisplayName: c-d
+
+  Queue:
+    Type: AWS::SQS::Queue
+    Properties:
+      DelaySeconds: 3
+      Tags:
+        - Key: Config
+          Value: '{"Count":2,"Envs":["dev","prod"]}'
+        - Key: Deferred
+          Value: !ToJsonString
+            Arn: !GetAtt Queue.Arn
+
+Outputs:
+  devArn:
+    Value: !Ref Topicdevab
+
+  prodArn:
+    Value: !Ref Topicdevab
+`
+
+	actual := format.String(expanded, format.Options{Unsorted: true})
+	if d := cmp.Diff(expected, actual); d != "" {
+		t.Error(d)
+	}
+}
+
+func TestExpandErrors(t *testing.T) {
+	for name, source := range map[string]string{
+		"policy": `
+Resources:
+  Queue:
+    Type: AWS::SQS::Queue
+    UpdateReplacePolicy: !Ref AWS::NoValue
+`,
+		"collection": `
+Parameters:
+  Names:
+    Type: CommaDelimitedList
+Resources:
+  Fn::ForEach::Queues:
+    - Name
+    - !Ref Names
+    - ${Name}:
+        Type: AWS::SQS::Queue
+`,
+		"duplicate": `
+Resources:
+  Fn::ForEach::Queues:
+    - Name
+    - [a, b]
+    - Queue:
+        Type: AWS::SQS::Queue
+`,
+	} {
+		tmpl, err := parse.String(source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := eval.Expand(tmpl, eval.Options{}); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
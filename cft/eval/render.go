@@ -0,0 +1,155 @@
+package eval
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/aws-cloudformation/rain/cft"
+	"github.com/aws-cloudformation/rain/cft/sam"
+	"github.com/aws-cloudformation/rain/internal/config"
+	"github.com/aws-cloudformation/rain/internal/node"
+	"gopkg.in/yaml.v3"
+)
+
+// Render returns a copy of the template as CloudFormation would see it
+// with the supplied options.
+//
+// Templates that use AWS::LanguageExtensions are expanded first,
+// and templates that use the AWS::Serverless transform are translated.
+// Resources and Outputs with a Condition that is false are removed,
+// along with the Conditions section, and intrinsic functions in
+// Resources and Outputs are evaluated. Conditions that depend on values
+// that are not supplied, like a pseudo-parameter, can't be decided.
+// Elements and Fn::If with those conditions are kept as they are, and
+// so is the Conditions section.
+func Render(t cft.Template, options Options) (cft.Template, error) {
+	rendered := cft.Template{Node: node.Clone(t.Node)}
+
+	if HasLanguageExtensions(t) {
+		expanded, err := Expand(t, options)
+		if err != nil {
+			return rendered, err
+		}
+		rendered = expanded
+	}
+
+	if sam.HasTransform(rendered) {
+		translated, err := sam.Translate(rendered)
+		if err != nil {
+			return rendered, err
+		}
+		rendered = translated
+	}
+
+	e, err := New(rendered, options)
+	if err != nil {
+		return rendered, err
+	}
+
+	m, err := rendered.Model()
+	if err != nil {
+		return rendered, err
+	}
+
+	// The Conditions section is kept if any of the conditions can't be decided
+	undecided := false
+	for name := range m.Conditions {
+		if _, err := e.Condition(name); errors.Is(err, errUndecided) {
+			config.Debugf("Keeping Conditions, condition %s can't be decided", name)
+			undecided = true
+		}
+	}
+
+	// Remove conditional resources and outputs
+	for name, r := range m.Resources {
+		keep, err := e.keep(r.Condition)
+		if errors.Is(err, errUndecided) {
+			continue
+		}
+		if err != nil {
+			return rendered, fmt.Errorf("resource %s: %v", name, err)
+		}
+		if !keep {
+			config.Debugf("Removing resource %s, condition %s is false", name, r.Condition)
+			delete(m.Resources, name)
+		}
+		r.Condition = ""
+	}
+
+	for name, o := range m.Outputs {
+		keep, err := e.keep(o.Condition)
+		if errors.Is(err, errUndecided) {
+			continue
+		}
+		if err != nil {
+			return rendered, fmt.Errorf("output %s: %v", name, err)
+		}
+		if !keep {
+			config.Debugf("Removing output %s, condition %s is false", name, o.Condition)
+			delete(m.Outputs, name)
+		}
+		o.Condition = ""
+	}
+
+	// Remove dependencies on resources that no longer exist
+	for _, r := range m.Resources {
+		r.DependsOn = slices.DeleteFunc(r.DependsOn, func(d string) bool {
+			_, ok := m.Resources[d]
+			return !ok
+		})
+	}
+
+	// Everything that depends on a condition is about to be resolved
+	if !undecided {
+		for name := range m.Conditions {
+			delete(m.Conditions, name)
+		}
+	}
+
+	err = m.Write()
+	if err != nil {
+		return rendered, err
+	}
+
+	for _, section := range []cft.Section{cft.Resources, cft.Outputs} {
+		s, err := rendered.GetSection(section)
+		if err != nil {
+			continue
+		}
+		err = e.resolveSection(s)
+		if err != nil {
+			return rendered, err
+		}
+	}
+
+	return rendered, nil
+}
+
+// keep returns true if an element with the condition should be rendered
+func (e *Evaluator) keep(condition string) (bool, error) {
+	if condition == "" {
+		return true, nil
+	}
+	return e.Condition(condition)
+}
+
+// resolveSection resolves each element in a section, in place
+func (e *Evaluator) resolveSection(s *yaml.Node) error {
+	for i := 0; i+1 < len(s.Content); i += 2 {
+		name := s.Content[i].Value
+
+		// Fn::ForEach is expanded by the language extensions transform
+		if strings.HasPrefix(name, "Fn::ForEach::") {
+			continue
+		}
+
+		r, err := e.resolve(s.Content[i+1])
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		s.Content[i+1] = r
+	}
+	return nil
+}
@@ -0,0 +1,897 @@
+package format
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/aws-cloudformation/rain/cft"
+	"github.com/aws-cloudformation/rain/internal/s11n"
+	"gopkg.in/yaml.v3"
+)
+
+// CdkOptions configures the output of CftToCdk
+type CdkOptions struct {
+	// ClassName is the name of the generated stack class.
+	// The default is MyStack.
+	ClassName string
+
+	// IsJSON returns true if the resource property at path, a list of
+	// property names below Properties, is a JSON value like a policy
+	// document. The keys in JSON values are not converted to camel case.
+	// If it is nil, a short list of common JSON properties is used.
+	IsJSON func(typeName string, path []string) bool
+}
+
+// cdkJSONProperties are properties that hold JSON values,
+// used when CdkOptions.IsJSON is not set
+var cdkJSONProperties = []string{
+	"AssumeRolePolicyDocument",
+	"Definition",
+	"KeyPolicy",
+	"PolicyDocument",
+	"Variables",
+}
+
+// cdkPseudoParameters maps pseudo parameters to the Aws class in aws-cdk-lib
+var cdkPseudoParameters = map[string]string{
+	"AWS::AccountId":        "ACCOUNT_ID",
+	"AWS::NoValue":          "NO_VALUE",
+	"AWS::NotificationARNs": "NOTIFICATION_ARNS",
+	"AWS::Partition":        "PARTITION",
+	"AWS::Region":           "REGION",
+	"AWS::StackId":          "STACK_ID",
+	"AWS::StackName":        "STACK_NAME",
+	"AWS::URLSuffix":        "URL_SUFFIX",
+}
+
+// cdkParameterProps are the parameter attributes that CfnParameter accepts
+var cdkParameterProps = []string{
+	"Type",
+	"Default",
+	"AllowedValues",
+	"AllowedPattern",
+	"ConstraintDescription",
+	"Description",
+	"MaxLength",
+	"MaxValue",
+	"MinLength",
+	"MinValue",
+	"NoEcho",
+}
+
+// cdkPolicies maps DeletionPolicy and UpdateReplacePolicy values
+// to CfnDeletionPolicy
+var cdkPolicies = map[string]string{
+	"Delete":               "DELETE",
+	"Retain":               "RETAIN",
+	"RetainExceptOnCreate": "RETAIN_EXCEPT_ON_CREATE",
+	"Snapshot":             "SNAPSHOT",
+}
+
+// cdkReserved are words that can't be used as variable names in TypeScript
+var cdkReserved = []string{
+	"break", "case", "catch", "class", "const", "continue", "debugger",
+	"default", "delete", "do", "else", "enum", "export", "extends", "false",
+	"finally", "for", "function", "if", "import", "in", "instanceof", "let",
+	"new", "null", "props", "return", "scope", "super", "switch", "this",
+	"throw", "true", "try", "typeof", "var", "void", "while", "with",
+	"yield", "cdk", "Construct", "id",
+}
+
+var cdkSubVar = regexp.MustCompile(`\$\{([^!][^}]*)\}`)
+
+type cdkWriter struct {
+	sb      strings.Builder
+	options CdkOptions
+
+	// Variable names by logical id
+	params     map[string]string
+	conditions map[string]string
+	resources  map[string]string
+
+	paramTypes    map[string]string
+	resourceTypes map[string]string
+
+	// imports are the module aliases by import path
+	imports map[string]string
+
+	// names are the variable names that are in use
+	names map[string]bool
+}
+
+// CftToCdk converts a CloudFormation template into a TypeScript CDK
+// stack that creates the same resources with L1 (Cfn*) constructs.
+// Logical ids are kept with overrideLogicalId, so that the output can
+// be deployed over a stack that was created from the template.
+func CftToCdk(t cft.Template, options CdkOptions) (string, error) {
+	if options.ClassName == "" {
+		options.ClassName = "MyStack"
+	}
+
+	w := &cdkWriter{
+		options:       options,
+		params:        make(map[string]string),
+		conditions:    make(map[string]string),
+		resources:     make(map[string]string),
+		paramTypes:    make(map[string]string),
+		resourceTypes: make(map[string]string),
+		imports:       make(map[string]string),
+		names:         make(map[string]bool),
+	}
+	for _, r := range cdkReserved {
+		w.names[r] = true
+	}
+
+	if t.Node == nil || len(t.Node.Content) == 0 || t.Node.Content[0].Kind != yaml.MappingNode {
+		return "", fmt.Errorf("expected the template to be a mapping")
+	}
+	m := t.Node.Content[0]
+
+	section := func(name cft.Section) *yaml.Node {
+		_, n, _ := s11n.GetMapValue(m, string(name))
+		if n != nil && n.Kind != yaml.MappingNode {
+			return nil
+		}
+		return n
+	}
+	params := section(cft.Parameters)
+	mappings := section(cft.Mappings)
+	conditions := section(cft.Conditions)
+	resources := section(cft.Resources)
+	outputs := section(cft.Outputs)
+
+	// Imports come first, so that variables can't hide them
+	if resources != nil {
+		for i := 0; i < len(resources.Content); i += 2 {
+			_, typeNode, _ := s11n.GetMapValue(resources.Content[i+1], "Type")
+			if typeNode == nil {
+				return "", fmt.Errorf("resource %s does not have a Type", resources.Content[i].Value)
+			}
+			w.resourceTypes[resources.Content[i].Value] = typeNode.Value
+			if path, alias := cdkModule(typeNode.Value); path != "" {
+				w.imports[path] = alias
+				w.names[alias] = true
+			}
+		}
+	}
+
+	if params != nil {
+		for i := 0; i < len(params.Content); i += 2 {
+			name := params.Content[i].Value
+			w.params[name] = w.varName(name, "")
+			_, typeNode, _ := s11n.GetMapValue(params.Content[i+1], "Type")
+			if typeNode != nil {
+				w.paramTypes[name] = typeNode.Value
+			}
+		}
+	}
+	if conditions != nil {
+		for i := 0; i < len(conditions.Content); i += 2 {
+			name := conditions.Content[i].Value
+			w.conditions[name] = w.varName(name, "Condition")
+		}
+	}
+	if resources != nil {
+		for i := 0; i < len(resources.Content); i += 2 {
+			name := resources.Content[i].Value
+			w.resources[name] = w.varName(name, "")
+		}
+	}
+
+	w.writeHeader()
+
+	if err := w.writeTemplateOptions(m); err != nil {
+		return "", err
+	}
+
+	if params != nil {
+		w.comment("Parameters")
+		for i := 0; i < len(params.Content); i += 2 {
+			w.writeParameter(params.Content[i].Value, params.Content[i+1])
+		}
+	}
+
+	if mappings != nil {
+		w.comment("Mappings")
+		for i := 0; i < len(mappings.Content); i += 2 {
+			name := mappings.Content[i].Value
+			w.line("new cdk.CfnMapping(this, %s, {", tsString(name))
+			w.line("  mapping: %s,", w.raw(mappings.Content[i+1], "        "))
+			w.line("}).overrideLogicalId(%s);", tsString(name))
+			w.line("")
+		}
+	}
+
+	if conditions != nil {
+		w.comment("Conditions")
+		for _, name := range cdkOrder(conditions, w.conditionDeps) {
+			_, c, _ := s11n.GetMapValue(conditions, name)
+			w.line("const %s = new cdk.CfnCondition(this, %s, {", w.conditions[name], tsString(name))
+			w.line("  expression: %s,", w.raw(c, "        "))
+			w.line("});")
+			w.line("%s.overrideLogicalId(%s);", w.conditions[name], tsString(name))
+			w.line("")
+		}
+	}
+
+	if resources != nil {
+		w.comment("Resources")
+		for _, name := range cdkOrder(resources, w.resourceDeps) {
+			_, r, _ := s11n.GetMapValue(resources, name)
+			if err := w.writeResource(name, r); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	if outputs != nil {
+		w.comment("Outputs")
+		for i := 0; i < len(outputs.Content); i += 2 {
+			w.writeOutput(outputs.Content[i].Value, outputs.Content[i+1])
+		}
+	}
+
+	// Remove the blank line after the last block
+	out := strings.TrimRight(w.sb.String(), "\n") + "\n"
+	out += "  }\n}\n"
+
+	return out, nil
+}
+
+// line writes an indented line inside the stack's constructor
+func (w *cdkWriter) line(f string, args ...any) {
+	if f == "" {
+		w.sb.WriteString("\n")
+		return
+	}
+	w.sb.WriteString("    ")
+	w.sb.WriteString(fmt.Sprintf(f, args...))
+	w.sb.WriteString("\n")
+}
+
+func (w *cdkWriter) comment(s string) {
+	w.line("// %s", s)
+}
+
+func (w *cdkWriter) writeHeader() {
+	w.sb.WriteString("import * as cdk from 'aws-cdk-lib';\n")
+	w.sb.WriteString("import { Construct } from 'constructs';\n")
+
+	paths := make([]string, 0, len(w.imports))
+	for path := range w.imports {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		w.sb.WriteString(fmt.Sprintf("import * as %s from '%s';\n", w.imports[path], path))
+	}
+
+	w.sb.WriteString("\n")
+	w.sb.WriteString(fmt.Sprintf("export class %s extends cdk.Stack {\n", w.options.ClassName))
+	w.sb.WriteString("  constructor(scope: Construct, id: string, props?: cdk.StackProps) {\n")
+	w.sb.WriteString("    super(scope, id, props);\n")
+	w.sb.WriteString("\n")
+}
+
+func (w *cdkWriter) writeTemplateOptions(m *yaml.Node) error {
+	wrote := false
+	for i := 0; i < len(m.Content); i += 2 {
+		name := m.Content[i].Value
+		n := m.Content[i+1]
+		switch cft.Section(name) {
+		case cft.AWSTemplateFormatVersion:
+			w.line("this.templateOptions.templateFormatVersion = %s;", tsString(n.Value))
+		case cft.Description:
+			w.line("this.templateOptions.description = %s;", tsString(n.Value))
+		case cft.Metadata:
+			w.line("this.templateOptions.metadata = %s;", w.raw(n, "      "))
+		case cft.Transform:
+			transforms := []*yaml.Node{n}
+			if n.Kind == yaml.SequenceNode {
+				transforms = n.Content
+			}
+			for _, t := range transforms {
+				if t.Kind != yaml.ScalarNode {
+					return fmt.Errorf("unable to convert Transform with parameters to CDK")
+				}
+				w.line("this.addTransform(%s);", tsString(t.Value))
+			}
+		case cft.Rules:
+			w.line("// Rules are not converted, add them with cdk.CfnRule")
+		default:
+			continue
+		}
+		wrote = true
+	}
+	if wrote {
+		w.line("")
+	}
+	return nil
+}
+
+func (w *cdkWriter) writeParameter(name string, p *yaml.Node) {
+	w.line("const %s = new cdk.CfnParameter(this, %s, {", w.params[name], tsString(name))
+	for _, prop := range cdkParameterProps {
+		_, v, _ := s11n.GetMapValue(p, prop)
+		if v == nil {
+			continue
+		}
+		value := w.raw(v, "        ")
+		switch prop {
+		case "MaxLength", "MaxValue", "MinLength", "MinValue":
+			value = tsNumber(v)
+		case "NoEcho":
+			value = strconv.FormatBool(v.Value == "true")
+		}
+		w.line("  %s: %s,", cdkCamel(prop), value)
+	}
+	w.line("});")
+	w.line("%s.overrideLogicalId(%s);", w.params[name], tsString(name))
+	w.line("")
+}
+
+func (w *cdkWriter) writeResource(name string, r *yaml.Node) error {
+	typeName := w.resourceTypes[name]
+	v := w.resources[name]
+
+	_, props, _ := s11n.GetMapValue(r, "Properties")
+	if props == nil {
+		props = &yaml.Node{Kind: yaml.MappingNode}
+	}
+
+	if className := w.className(typeName); className != "" {
+		w.line("const %s = new %s(this, %s, %s);", v, className, tsString(name),
+			w.properties(props, "      ", &cdkPath{typeName: typeName}))
+	} else {
+		w.line("const %s = new cdk.CfnResource(this, %s, {", v, tsString(name))
+		w.line("  type: %s,", tsString(typeName))
+		if len(props.Content) > 0 {
+			w.line("  properties: %s,", w.raw(props, "        "))
+		}
+		w.line("});")
+	}
+	w.line("%s.overrideLogicalId(%s);", v, tsString(name))
+
+	for i := 0; i < len(r.Content); i += 2 {
+		attr := r.Content[i].Value
+		n := r.Content[i+1]
+		switch attr {
+		case "Type", "Properties":
+		case "Condition":
+			if c, ok := w.conditions[n.Value]; ok {
+				w.line("%s.cfnOptions.condition = %s;", v, c)
+			} else {
+				return fmt.Errorf("resource %s has an unknown condition %s", name, n.Value)
+			}
+		case "DependsOn":
+			deps := []*yaml.Node{n}
+			if n.Kind == yaml.SequenceNode {
+				deps = n.Content
+			}
+			for _, dep := range deps {
+				if d, ok := w.resources[dep.Value]; ok {
+					w.line("%s.addDependency(%s);", v, d)
+				} else {
+					return fmt.Errorf("resource %s depends on an unknown resource %s", name, dep.Value)
+				}
+			}
+		case "DeletionPolicy", "UpdateReplacePolicy":
+			policy, ok := cdkPolicies[n.Value]
+			if !ok {
+				return fmt.Errorf("resource %s has an unknown %s %s", name, attr, n.Value)
+			}
+			w.line("%s.cfnOptions.%s = cdk.CfnDeletionPolicy.%s;", v, cdkCamel(attr), policy)
+		case "Metadata":
+			w.line("%s.cfnOptions.metadata = %s;", v, w.raw(n, "      "))
+		case "CreationPolicy", "UpdatePolicy":
+			w.line("%s.cfnOptions.%s = %s;", v, cdkCamel(attr), w.value(n, "      ", &cdkPath{}))
+		default:
+			w.line("%s.addOverride(%s, %s);", v, tsString(attr), w.raw(n, "      "))
+		}
+	}
+	w.line("")
+
+	return nil
+}
+
+func (w *cdkWriter) writeOutput(name string, o *yaml.Node) {
+	w.line("new cdk.CfnOutput(this, %s, {", tsString(name))
+	for i := 0; i < len(o.Content); i += 2 {
+		attr := o.Content[i].Value
+		n := o.Content[i+1]
+		switch attr {
+		case "Value", "Description":
+			w.line("  %s: %s,", cdkCamel(attr), w.raw(n, "        "))
+		case "Export":
+			_, exportName, _ := s11n.GetMapValue(n, "Name")
+			if exportName != nil {
+				w.line("  exportName: %s,", w.raw(exportName, "        "))
+			}
+		case "Condition":
+			if c, ok := w.conditions[n.Value]; ok {
+				w.line("  condition: %s,", c)
+			}
+		}
+	}
+	w.line("}).overrideLogicalId(%s);", tsString(name))
+	w.line("")
+}
+
+// cdkPath is the location of a value in the properties of a resource,
+// used to decide if the keys of a mapping are converted to camel case
+type cdkPath struct {
+	typeName string
+	names    []string
+}
+
+func (p *cdkPath) with(name string) *cdkPath {
+	names := make([]string, len(p.names), len(p.names)+1)
+	copy(names, p.names)
+	return &cdkPath{typeName: p.typeName, names: append(names, name)}
+}
+
+// isJSON returns true if the keys of the mapping at the path are kept as they are
+func (w *cdkWriter) isJSON(p *cdkPath) bool {
+	if len(p.names) == 0 || p.typeName == "" {
+		return false
+	}
+	if w.options.IsJSON != nil {
+		return w.options.IsJSON(p.typeName, p.names)
+	}
+	for _, name := range cdkJSONProperties {
+		if p.names[len(p.names)-1] == name {
+			return true
+		}
+	}
+	return false
+}
+
+// properties returns an object literal for the properties of an L1 construct
+func (w *cdkWriter) properties(n *yaml.Node, indent string, path *cdkPath) string {
+	if len(n.Content) == 0 {
+		return "{}"
+	}
+	return w.value(n, indent, path)
+}
+
+// raw returns a TypeScript expression for a value whose keys are not converted
+func (w *cdkWriter) raw(n *yaml.Node, indent string) string {
+	return w.value(n, indent, nil)
+}
+
+// value returns a TypeScript expression for a template value.
+// Nested lines are indented by indent. If path is not nil, the keys of
+// mappings are converted to camel case, unless the path is a JSON value.
+func (w *cdkWriter) value(n *yaml.Node, indent string, path *cdkPath) string {
+	switch n.Kind {
+	case yaml.AliasNode:
+		return w.value(n.Alias, indent, path)
+	case yaml.ScalarNode:
+		return tsScalar(n)
+	case yaml.SequenceNode:
+		if len(n.Content) == 0 {
+			return "[]"
+		}
+		items := make([]string, len(n.Content))
+		simple := true
+		for i, item := range n.Content {
+			items[i] = w.value(item, indent+"  ", path)
+			if item.Kind != yaml.ScalarNode {
+				simple = false
+			}
+		}
+		if simple {
+			return "[" + strings.Join(items, ", ") + "]"
+		}
+		return "[\n" + indent + strings.Join(items, ",\n"+indent) + ",\n" + indent[2:] + "]"
+	case yaml.MappingNode:
+		if len(n.Content) == 2 && w.isIntrinsic(n.Content[0].Value, n.Content[1]) {
+			return w.intrinsic(n.Content[0].Value, n.Content[1], indent)
+		}
+		if len(n.Content) == 0 {
+			return "{}"
+		}
+		var sb strings.Builder
+		sb.WriteString("{\n")
+		for i := 0; i < len(n.Content); i += 2 {
+			key := n.Content[i].Value
+			var child *cdkPath
+			k := tsKey(key)
+			if path != nil {
+				child = path.with(key)
+				if w.isJSON(child) {
+					sb.WriteString(fmt.Sprintf("%s%s: %s,\n", indent, cdkCamel(key),
+						w.value(n.Content[i+1], indent+"  ", nil)))
+					continue
+				}
+				k = cdkCamel(key)
+			}
+			sb.WriteString(fmt.Sprintf("%s%s: %s,\n", indent, k, w.value(n.Content[i+1], indent+"  ", child)))
+		}
+		sb.WriteString(indent[2:] + "}")
+		return sb.String()
+	}
+	return "undefined"
+}
+
+// isIntrinsic returns true if the key of a mapping with a single key is an
+// intrinsic function or a reference to a condition
+func (w *cdkWriter) isIntrinsic(key string, val *yaml.Node) bool {
+	if key == "Condition" {
+		_, ok := w.conditions[val.Value]
+		return ok && val.Kind == yaml.ScalarNode
+	}
+	return key == "Ref" || strings.HasPrefix(key, "Fn::")
+}
+
+// intrinsic returns a TypeScript expression for an intrinsic function
+func (w *cdkWriter) intrinsic(fn string, arg *yaml.Node, indent string) string {
+	v := func(n *yaml.Node) string {
+		return w.value(n, indent, nil)
+	}
+	args := func() []string {
+		if arg.Kind != yaml.SequenceNode {
+			return []string{v(arg)}
+		}
+		retval := make([]string, len(arg.Content))
+		for i, a := range arg.Content {
+			retval[i] = v(a)
+		}
+		return retval
+	}
+	call := func(name string, a ...string) string {
+		return fmt.Sprintf("cdk.Fn.%s(%s)", name, strings.Join(a, ", "))
+	}
+
+	switch fn {
+	case "Ref":
+		return w.ref(arg.Value)
+	case "Condition":
+		return w.conditions[arg.Value]
+	case "Fn::GetAtt":
+		var name, attr string
+		if arg.Kind == yaml.SequenceNode && len(arg.Content) == 2 && arg.Content[1].Kind == yaml.ScalarNode {
+			name, attr = arg.Content[0].Value, arg.Content[1].Value
+		} else if arg.Kind == yaml.ScalarNode {
+			name, attr, _ = strings.Cut(arg.Value, ".")
+		}
+		if name != "" {
+			return w.getAtt(name, attr)
+		}
+	case "Fn::Sub":
+		if arg.Kind == yaml.ScalarNode {
+			return call("sub", tsString(arg.Value))
+		}
+		if arg.Kind == yaml.SequenceNode && len(arg.Content) == 2 {
+			return call("sub", tsString(arg.Content[0].Value), v(arg.Content[1]))
+		}
+	case "Fn::Join":
+		if arg.Kind == yaml.SequenceNode && len(arg.Content) == 2 {
+			return call("join", v(arg.Content[0]), v(arg.Content[1]))
+		}
+	case "Fn::Select":
+		if arg.Kind == yaml.SequenceNode && len(arg.Content) == 2 {
+			return call("select", tsNumber(arg.Content[0]), v(arg.Content[1]))
+		}
+	case "Fn::Split":
+		return call("split", args()...)
+	case "Fn::Cidr":
+		if arg.Kind == yaml.SequenceNode && len(arg.Content) == 3 {
+			return call("cidr", v(arg.Content[0]), tsNumber(arg.Content[1]), tsNumber(arg.Content[2]))
+		}
+	case "Fn::FindInMap":
+		return call("findInMap", args()...)
+	case "Fn::GetAZs":
+		if arg.Kind == yaml.ScalarNode && arg.Value == "" {
+			return call("getAzs")
+		}
+		return call("getAzs", v(arg))
+	case "Fn::Base64":
+		return call("base64", v(arg))
+	case "Fn::ImportValue":
+		return call("importValue", v(arg))
+	case "Fn::Length":
+		return call("len", v(arg))
+	case "Fn::ToJsonString":
+		return call("toJsonString", v(arg))
+	case "Fn::If":
+		if arg.Kind == yaml.SequenceNode && len(arg.Content) == 3 {
+			expr := call("conditionIf", tsString(arg.Content[0].Value), v(arg.Content[1]), v(arg.Content[2]))
+			// Properties with scalar values are typed as strings
+			if !w.isObject(arg.Content[1]) && !w.isObject(arg.Content[2]) {
+				expr += ".toString()"
+			}
+			return expr
+		}
+	case "Fn::Equals":
+		return call("conditionEquals", args()...)
+	case "Fn::And":
+		return call("conditionAnd", args()...)
+	case "Fn::Or":
+		return call("conditionOr", args()...)
+	case "Fn::Not":
+		return call("conditionNot", args()...)
+	}
+
+	// Anything else is written as it is, for example Fn::Transform
+	return fmt.Sprintf("{ %s: %s }", tsString(fn), v(arg))
+}
+
+// isObject returns true if the value is a list or an object,
+// and not a scalar or an intrinsic function
+func (w *cdkWriter) isObject(n *yaml.Node) bool {
+	switch n.Kind {
+	case yaml.SequenceNode:
+		return true
+	case yaml.MappingNode:
+		return len(n.Content) != 2 || !w.isIntrinsic(n.Content[0].Value, n.Content[1])
+	}
+	return false
+}
+
+// ref returns a TypeScript expression for a Ref
+func (w *cdkWriter) ref(name string) string {
+	if p, ok := w.params[name]; ok {
+		t := w.paramTypes[name]
+		switch {
+		case t == "Number":
+			return p + ".valueAsNumber"
+		case t == "CommaDelimitedList" || strings.HasPrefix(t, "List<"):
+			return p + ".valueAsList"
+		}
+		return p + ".valueAsString"
+	}
+	if r, ok := w.resources[name]; ok {
+		return r + ".ref"
+	}
+	if a, ok := cdkPseudoParameters[name]; ok {
+		return "cdk.Aws." + a
+	}
+	return fmt.Sprintf("cdk.Fn.ref(%s)", tsString(name))
+}
+
+// getAtt returns a TypeScript expression for a GetAtt
+func (w *cdkWriter) getAtt(name, attr string) string {
+	r, ok := w.resources[name]
+	if !ok {
+		return fmt.Sprintf("cdk.Fn.getAtt(%s, %s).toString()", tsString(name), tsString(attr))
+	}
+	if w.className(w.resourceTypes[name]) == "" {
+		return fmt.Sprintf("%s.getAtt(%s).toString()", r, tsString(attr))
+	}
+
+	// L1 constructs have a property for each attribute,
+	// for example Endpoint.Address is attrEndpointAddress
+	var sb strings.Builder
+	sb.WriteString("attr")
+	for _, part := range strings.Split(attr, ".") {
+		sb.WriteString(cdkPascal(part))
+	}
+	return r + "." + sb.String()
+}
+
+// className returns the name of the L1 construct for the type,
+// or an empty string if there isn't one
+func (w *cdkWriter) className(typeName string) string {
+	tokens := strings.Split(typeName, "::")
+	if len(tokens) != 3 || tokens[0] != "AWS" {
+		return ""
+	}
+	if tokens[1] == "CloudFormation" {
+		// Custom resources can have any properties
+		if tokens[2] == "CustomResource" {
+			return ""
+		}
+		return "cdk.Cfn" + tokens[2]
+	}
+	_, alias := cdkModule(typeName)
+	return alias + ".Cfn" + tokens[2]
+}
+
+// cdkModule returns the aws-cdk-lib import path and alias for a resource type,
+// or empty strings if the type is not in its own module
+func cdkModule(typeName string) (string, string) {
+	tokens := strings.Split(typeName, "::")
+	if len(tokens) != 3 || tokens[0] != "AWS" || tokens[1] == "CloudFormation" {
+		return "", ""
+	}
+	service := strings.ToLower(tokens[1])
+	if service == "serverless" {
+		service = "sam"
+	}
+	return "aws-cdk-lib/aws-" + service, service
+}
+
+// resourceDeps returns the resources that a resource refers to
+func (w *cdkWriter) resourceDeps(n *yaml.Node) []string {
+	return cdkRefs(n, w.resources, false)
+}
+
+// conditionDeps returns the conditions that a condition refers to
+func (w *cdkWriter) conditionDeps(n *yaml.Node) []string {
+	return cdkRefs(n, w.conditions, true)
+}
+
+// cdkRefs returns the names in known that n refers to with Ref, GetAtt,
+// Sub and DependsOn, or with Condition if conditions is true
+func cdkRefs(n *yaml.Node, known map[string]string, conditions bool) []string {
+	retval := make([]string, 0)
+	add := func(name string) {
+		if _, ok := known[name]; ok {
+			retval = append(retval, name)
+		}
+	}
+
+	var walk func(n *yaml.Node)
+	walk = func(n *yaml.Node) {
+		if n.Kind == yaml.MappingNode {
+			for i := 0; i < len(n.Content); i += 2 {
+				key := n.Content[i].Value
+				val := n.Content[i+1]
+				switch {
+				case conditions && key == "Condition":
+					add(val.Value)
+				case conditions:
+				case key == "Ref" || key == "DependsOn":
+					add(val.Value)
+					for _, item := range val.Content {
+						add(item.Value)
+					}
+				case key == "Fn::GetAtt":
+					if val.Kind == yaml.ScalarNode {
+						name, _, _ := strings.Cut(val.Value, ".")
+						add(name)
+					} else if len(val.Content) > 0 {
+						add(val.Content[0].Value)
+					}
+				case key == "Fn::Sub":
+					s := val
+					if val.Kind == yaml.SequenceNode && len(val.Content) > 0 {
+						s = val.Content[0]
+					}
+					for _, match := range cdkSubVar.FindAllStringSubmatch(s.Value, -1) {
+						name, _, _ := strings.Cut(match[1], ".")
+						add(name)
+					}
+				}
+				walk(val)
+			}
+			return
+		}
+		for _, c := range n.Content {
+			walk(c)
+		}
+	}
+	walk(n)
+
+	return retval
+}
+
+// cdkOrder returns the keys of the mapping in their original order,
+// except that each one comes after the ones it depends on, since
+// TypeScript variables must be declared before they are used.
+// Cycles are left in their original order.
+func cdkOrder(m *yaml.Node, deps func(*yaml.Node) []string) []string {
+	retval := make([]string, 0, len(m.Content)/2)
+	visited := make(map[string]bool)
+
+	var visit func(name string)
+	visit = func(name string) {
+		if visited[name] {
+			return
+		}
+		visited[name] = true
+		_, n, _ := s11n.GetMapValue(m, name)
+		if n == nil {
+			return
+		}
+		for _, dep := range deps(n) {
+			visit(dep)
+		}
+		retval = append(retval, name)
+	}
+
+	for i := 0; i < len(m.Content); i += 2 {
+		visit(m.Content[i].Value)
+	}
+
+	return retval
+}
+
+// varName returns an unused TypeScript variable name for a logical id
+func (w *cdkWriter) varName(logicalId string, suffix string) string {
+	var sb strings.Builder
+	for _, r := range logicalId {
+		if r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			sb.WriteRune(r)
+		}
+	}
+	name := cdkCamel(sb.String()) + suffix
+	if name == "" || unicode.IsDigit([]rune(name)[0]) {
+		name = "_" + name
+	}
+
+	unique := name
+	for i := 2; w.names[unique]; i++ {
+		unique = fmt.Sprintf("%s%d", name, i)
+	}
+	w.names[unique] = true
+
+	return unique
+}
+
+// cdkCamel converts a name like SSESpecification to sseSpecification,
+// which is how aws-cdk-lib names properties
+func cdkCamel(s string) string {
+	runes := []rune(s)
+	upper := 0
+	for upper < len(runes) && unicode.IsUpper(runes[upper]) {
+		upper++
+	}
+	// Keep the first letter of the next word, as in SSESpecification
+	if upper > 1 && upper < len(runes) && !unicode.IsDigit(runes[upper]) {
+		upper--
+	}
+	for i := 0; i < upper; i++ {
+		runes[i] = unicode.ToLower(runes[i])
+	}
+	return string(runes)
+}
+
+// cdkPascal converts a name like VPCId to VpcId,
+// which is how aws-cdk-lib names attributes
+func cdkPascal(s string) string {
+	c := []rune(cdkCamel(s))
+	if len(c) == 0 {
+		return ""
+	}
+	c[0] = unicode.ToUpper(c[0])
+	return string(c)
+}
+
+var tsIdentifier = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// tsKey returns a key for an object literal, quoted if necessary
+func tsKey(s string) string {
+	if tsIdentifier.MatchString(s) {
+		return s
+	}
+	return tsString(s)
+}
+
+// tsString returns a single quoted TypeScript string
+func tsString(s string) string {
+	r := strings.NewReplacer(
+		`\`, `\\`,
+		`'`, `\'`,
+		"\n", `\n`,
+		"\r", `\r`,
+		"\t", `\t`,
+	)
+	return "'" + r.Replace(s) + "'"
+}
+
+// tsScalar returns a TypeScript literal for a scalar node
+func tsScalar(n *yaml.Node) string {
+	switch n.ShortTag() {
+	case "!!int", "!!float":
+		return tsNumber(n)
+	case "!!bool":
+		return strconv.FormatBool(strings.ToLower(n.Value) == "true")
+	case "!!null":
+		return "undefined"
+	}
+	return tsString(n.Value)
+}
+
+// tsNumber returns a number literal for a scalar, even if it is a string
+func tsNumber(n *yaml.Node) string {
+	if f, err := strconv.ParseFloat(n.Value, 64); err == nil {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return tsString(n.Value)
+}
@@ -0,0 +1,121 @@
+package format
+
+import (
+	"testing"
+
+	"github.com/aws-cloudformation/rain/cft/parse"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCftToCdk(t *testing.T) {
+	input := `
+Parameters:
+  Name:
+    Type: String
+Conditions:
+  HasName: !Not [!Equals [!Ref Name, ""]]
+Resources:
+  MyPolicy:
+    Type: AWS::IAM::ManagedPolicy
+    Properties:
+      PolicyDocument:
+        Statement:
+          - Effect: Allow
+            Action: s3:GetObject
+            Resource: !Sub ${MyBucket.Arn}/*
+  MyBucket:
+    Type: AWS::S3::Bucket
+    Condition: HasName
+    DeletionPolicy: Retain
+    Properties:
+      BucketName: !If [HasName, !Ref Name, !Ref AWS::NoValue]
+      VersioningConfiguration:
+        Status: Enabled
+Outputs:
+  BucketArn:
+    Value: !GetAtt MyBucket.Arn
+`
+
+	expected := `import * as cdk from 'aws-cdk-lib';
+import { Construct } from 'constructs';
+import * as iam from 'aws-cdk-lib/aws-iam';
+import * as s3 from 'aws-cdk-lib/aws-s3';
+
+export class MyStack extends cdk.Stack {
+  constructor(scope: Construct, id: string, props?: cdk.StackProps) {
+    super(scope, id, props);
+
+    // Parameters
+    const name = new cdk.CfnParameter(this, 'Name', {
+      type: 'String',
+    });
+    name.overrideLogicalId('Name');
+
+    // Conditions
+    const hasNameCondition = new cdk.CfnCondition(this, 'HasName', {
+      expression: cdk.Fn.conditionNot(cdk.Fn.conditionEquals(name.valueAsString, '')),
+    });
+    hasNameCondition.overrideLogicalId('HasName');
+
+    // Resources
+    const myBucket = new s3.CfnBucket(this, 'MyBucket', {
+      bucketName: cdk.Fn.conditionIf('HasName', name.valueAsString, cdk.Aws.NO_VALUE).toString(),
+      versioningConfiguration: {
+        status: 'Enabled',
+      },
+    });
+    myBucket.overrideLogicalId('MyBucket');
+    myBucket.cfnOptions.condition = hasNameCondition;
+    myBucket.cfnOptions.deletionPolicy = cdk.CfnDeletionPolicy.RETAIN;
+
+    const myPolicy = new iam.CfnManagedPolicy(this, 'MyPolicy', {
+      policyDocument: {
+        Statement: [
+          {
+            Effect: 'Allow',
+            Action: 's3:GetObject',
+            Resource: cdk.Fn.sub('${MyBucket.Arn}/*'),
+          },
+        ],
+      },
+    });
+    myPolicy.overrideLogicalId('MyPolicy');
+
+    // Outputs
+    new cdk.CfnOutput(this, 'BucketArn', {
+      value: myBucket.attrArn,
+    }).overrideLogicalId('BucketArn');
+  }
+}
+`
+
+	template, err := parse.String(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err := CftToCdk(template, CdkOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d := cmp.Diff(expected, actual); d != "" {
+		t.Errorf(d)
+	}
+}
+
+func TestCdkCamel(t *testing.T) {
+	cases := map[string]string{
+		"BucketName":       "bucketName",
+		"SSESpecification": "sseSpecification",
+		"VPCId":            "vpcId",
+		"EC2Tags":          "ec2Tags",
+		"ID":               "id",
+		"name":             "name",
+	}
+	for input, expected := range cases {
+		if actual := cdkCamel(input); actual != expected {
+			t.Errorf("%s: expected %s, got %s", input, expected, actual)
+		}
+	}
+}
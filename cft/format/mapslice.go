@@ -8,6 +8,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"sort"
+	"sync/atomic"
 )
 
 // MapItem representation of one map item.
@@ -25,9 +26,10 @@ func (ms MapSlice) Swap(i, j int)      { ms[i], ms[j] = ms[j], ms[i] }
 
 var indexCounter uint64
 
+// nextIndex is safe to call from more than one goroutine,
+// so that templates can be formatted in parallel
 func nextIndex() uint64 {
-	indexCounter++
-	return indexCounter
+	return atomic.AddUint64(&indexCounter, 1)
 }
 
 // MapItem as a string.
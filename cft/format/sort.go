@@ -1,6 +1,8 @@
 package format
 
 import (
+	"strings"
+
 	"gopkg.in/yaml.v3"
 )
 
@@ -14,6 +16,7 @@ var orders = ordering{
 		"AWSTemplateFormatVersion",
 		"Description",
 		"Metadata",
+		"Rain",
 		"Parameters",
 		"Rules",
 		"Mappings",
@@ -67,6 +70,36 @@ var orders = ordering{
 	},
 }
 
+// SetOrder changes the order of the keys in the mappings at path.
+// path is a list of keys separated by slashes, where * matches any key,
+// and an empty path is the top level of the template. For example,
+// Resources/* is each resource and Resources/*/Properties is their properties.
+// Keys that are not in keys come after the ones that are, in their original order.
+func SetOrder(path string, keys []string) {
+	var parts []string
+	if path != "" {
+		parts = strings.Split(path, "/")
+	}
+	orders = orders.with(parts, keys)
+}
+
+// with returns a copy of o with keys as the order at path
+func (o ordering) with(path []string, keys []string) ordering {
+	if len(path) == 0 {
+		o.props = keys
+		return o
+	}
+
+	children := make(map[string]ordering, len(o.children)+1)
+	for k, v := range o.children {
+		children[k] = v
+	}
+	children[path[0]] = children[path[0]].with(path[1:], keys)
+	o.children = children
+
+	return o
+}
+
 func orderTemplate(node *yaml.Node) *yaml.Node {
 	if node.Kind == yaml.DocumentNode {
 		node = node.Content[0]
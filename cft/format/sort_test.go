@@ -0,0 +1,53 @@
+package format
+
+import (
+	"testing"
+
+	"github.com/aws-cloudformation/rain/cft/parse"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSetOrder(t *testing.T) {
+	defaults := orders
+	defer func() { orders = defaults }()
+
+	SetOrder("", []string{"Resources", "Description"})
+	SetOrder("Resources/*", []string{"Properties", "Type"})
+	SetOrder("Resources/*/Properties", []string{"Tags", "BucketName"})
+
+	tmpl, err := parse.String(`
+Description: test
+Parameters:
+  Name:
+    Default: a
+    Type: String
+Resources:
+  Bucket:
+    Type: AWS::S3::Bucket
+    Properties:
+      BucketName: !Ref Name
+      Tags: []
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `Resources:
+  Bucket:
+    Properties:
+      Tags: []
+      BucketName: !Ref Name
+    Type: AWS::S3::Bucket
+
+Description: test
+
+Parameters:
+  Name:
+    Type: String
+    Default: a
+`
+
+	if d := cmp.Diff(expected, String(tmpl, Options{})); d != "" {
+		t.Error(d)
+	}
+}
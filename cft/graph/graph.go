@@ -26,73 +26,131 @@ func (n Node) String() string {
 	return fmt.Sprintf("%s/%s", n.Type, n.Name)
 }
 
-// Graph represents a directed, acyclic graph with ordered nodes
+// EdgeKind describes how one node refers to another
+type EdgeKind string
+
+const (
+	Ref       EdgeKind = "Ref"
+	GetAtt    EdgeKind = "GetAtt"
+	Sub       EdgeKind = "Sub"
+	DependsOn EdgeKind = "DependsOn"
+	Condition EdgeKind = "Condition"
+	FindInMap EdgeKind = "FindInMap"
+)
+
+// Edge is a connection from a node to a node that it depends on
+type Edge struct {
+	From Node
+	To   Node
+
+	// Kinds lists each way that From refers to To, in sorted order.
+	// Edges created with Link have no kinds.
+	Kinds []EdgeKind
+}
+
+func (e Edge) String() string {
+	if len(e.Kinds) == 0 {
+		return fmt.Sprintf("%s -> %s", e.From, e.To)
+	}
+
+	kinds := make([]string, len(e.Kinds))
+	for i, k := range e.Kinds {
+		kinds[i] = string(k)
+	}
+
+	return fmt.Sprintf("%s -> %s (%s)", e.From, e.To, strings.Join(kinds, ", "))
+}
+
+// CycleError is returned when the graph contains a circular dependency
+type CycleError struct {
+	// Path starts and ends with the same node
+	Path []Node
+}
+
+func (e CycleError) Error() string {
+	names := make([]string, len(e.Path))
+	for i, n := range e.Path {
+		names[i] = n.String()
+	}
+
+	return fmt.Sprintf("circular dependency: %s", strings.Join(names, " -> "))
+}
+
+// Graph represents a directed graph with ordered nodes.
+// Each edge points from a node to a node that it depends on.
 type Graph struct {
-	nodes map[Node]map[Node]bool
+	nodes map[Node]map[Node]map[EdgeKind]bool
 	order []Node
 }
 
 // Empty returns a new, empty graph
 func Empty() Graph {
 	return Graph{
-		nodes: make(map[Node]map[Node]bool),
+		nodes: make(map[Node]map[Node]map[EdgeKind]bool),
 		order: make([]Node, 0),
 	}
 }
 
 // New returns a Graph representing the connections
 // between elements in the provided template.
-// The type of each item in the graph is Node
+// The type of each item in the graph is Node.
+//
+// Every Parameter, Condition, Mapping, Resource, and Output is a node.
+// Pseudo parameters such as AWS::Region are added to Parameters
+// when they are referred to.
 func New(t cft.Template) Graph {
-	// Map out parameter and resource names so we know which is which
-	entities := make(map[string]string)
-	for typeName, entity := range t.Map() {
-		if typeName != "Parameters" && typeName != "Resources" {
-			continue
-		}
+	graph := Empty()
 
-		if entityTree, ok := entity.(map[string]interface{}); ok {
-			for entityName := range entityTree {
-				entities[entityName] = typeName
-			}
-		}
+	m, err := t.Model()
+	if err != nil {
+		config.Debugf("unable to build the template model: %v", err)
+		return graph
 	}
 
-	// Now find the deps
-	graph := Graph{
-		nodes: make(map[Node]map[Node]bool),
-		order: make([]Node, 0),
+	// Map out the names in each section so we know which is which
+	entities := make(map[cft.Section]map[string]bool)
+	for _, section := range []cft.Section{cft.Parameters, cft.Conditions, cft.Mappings, cft.Resources, cft.Outputs} {
+		entities[section] = make(map[string]bool)
+		for _, name := range m.Names(section) {
+			entities[section][name] = true
+			graph.Link(Node{string(section), name})
+		}
 	}
 
-	for typeName, entity := range t.Map() {
-		if typeName != "Resources" && typeName != "Outputs" {
-			continue
+	// resolve finds the node that a reference of the given kind points to
+	resolve := func(kind EdgeKind, name string) (Node, bool) {
+		var sections []cft.Section
+		switch kind {
+		case Ref, Sub:
+			if strings.HasPrefix(name, "AWS::") {
+				return Node{string(cft.Parameters), name}, true
+			}
+			sections = []cft.Section{cft.Parameters, cft.Resources}
+		case GetAtt, DependsOn:
+			sections = []cft.Section{cft.Resources}
+		case Condition:
+			sections = []cft.Section{cft.Conditions}
+		case FindInMap:
+			sections = []cft.Section{cft.Mappings}
 		}
 
-		if entityTree, ok := entity.(map[string]interface{}); ok {
-			for fromName, res := range entityTree {
-				from := Node{typeName, fromName}
-				graph.Link(from)
-
-				resource := res.(map[string]interface{})
-				for _, toName := range getRefs(resource) {
-					toName = strings.Split(toName, ".")[0]
-
-					toType, ok := entities[toName]
+		for _, section := range sections {
+			if entities[section][name] {
+				return Node{string(section), name}, true
+			}
+		}
 
-					if !ok {
-						if strings.HasPrefix(toName, "AWS::") {
-							toType = "Parameters"
-						} else {
-							config.Debugf("template has unresolved dependency '%s' at %s: %s", toName, typeName, fromName)
-							continue
-						}
-					}
+		return Node{}, false
+	}
 
-					graph.Link(from, Node{toType, toName})
-				}
-			}
+	for _, ref := range findRefs(m) {
+		to, ok := resolve(ref.kind, ref.name)
+		if !ok {
+			config.Debugf("template has unresolved dependency '%s' at %s", ref.name, ref.from)
+			continue
 		}
+
+		graph.LinkKind(ref.kind, ref.from, to)
 	}
 
 	return graph
@@ -115,18 +173,30 @@ func (g *Graph) String() string {
 
 func (g *Graph) add(item Node) {
 	if _, ok := g.nodes[item]; !ok {
-		g.nodes[item] = make(map[Node]bool)
+		g.nodes[item] = make(map[Node]map[EdgeKind]bool)
 		g.order = append(g.order, item)
 	}
 }
 
-// link creates a connection between two nodes in the graph
+// Link creates a connection between two nodes in the graph
 func (g *Graph) Link(item Node, links ...Node) {
 	g.add(item)
 
 	for _, to := range links {
 		g.add(to)
-		g.nodes[item][to] = true
+		if _, ok := g.nodes[item][to]; !ok {
+			g.nodes[item][to] = make(map[EdgeKind]bool)
+		}
+	}
+}
+
+// LinkKind creates a connection between two nodes in the graph
+// and labels it with the kind of reference that created it
+func (g *Graph) LinkKind(kind EdgeKind, item Node, links ...Node) {
+	g.Link(item, links...)
+
+	for _, to := range links {
+		g.nodes[item][to][kind] = true
 	}
 }
 
@@ -180,9 +250,7 @@ func (g Graph) Get(item Node) []Node {
 		links = append(links, to)
 	}
 
-	sort.Slice(links, func(i, j int) bool {
-		return fmt.Sprint(links[i]) < fmt.Sprint(links[j])
-	})
+	sortNodes(links)
 
 	return links
 }
@@ -196,9 +264,139 @@ func (g Graph) GetReverse(item Node) []Node {
 		}
 	}
 
-	sort.Slice(links, func(i, j int) bool {
-		return fmt.Sprint(links[i]) < fmt.Sprint(links[j])
-	})
+	sortNodes(links)
 
 	return links
 }
+
+// Edges returns the connections from the item that you pass in,
+// sorted by the node that they point to
+func (g Graph) Edges(item Node) []Edge {
+	edges := make([]Edge, 0)
+	for _, to := range g.Get(item) {
+		kinds := make([]EdgeKind, 0)
+		for kind := range g.nodes[item][to] {
+			kinds = append(kinds, kind)
+		}
+		sort.Slice(kinds, func(i, j int) bool {
+			return kinds[i] < kinds[j]
+		})
+
+		edges = append(edges, Edge{From: item, To: to, Kinds: kinds})
+	}
+
+	return edges
+}
+
+// Cycle returns a circular dependency in the graph, as the path of nodes
+// from a node back to itself, or nil if the graph has no cycles.
+// The same cycle is returned each time for the same graph.
+func (g Graph) Cycle() []Node {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+
+	state := make(map[Node]int)
+	path := make([]Node, 0)
+
+	var visit func(Node) []Node
+	visit = func(n Node) []Node {
+		state[n] = visiting
+		path = append(path, n)
+
+		for _, to := range g.Get(n) {
+			switch state[to] {
+			case visiting:
+				// Found the way back to a node on the current path
+				for i, p := range path {
+					if p == to {
+						cycle := append([]Node{}, path[i:]...)
+						return append(cycle, to)
+					}
+				}
+			case unvisited:
+				if cycle := visit(to); cycle != nil {
+					return cycle
+				}
+			}
+		}
+
+		path = path[:len(path)-1]
+		state[n] = done
+
+		return nil
+	}
+
+	for _, n := range g.sorted() {
+		if state[n] == unvisited {
+			if cycle := visit(n); cycle != nil {
+				return cycle
+			}
+		}
+	}
+
+	return nil
+}
+
+// TopoSort returns all nodes of the graph so that each node comes
+// after all of the nodes it depends on. Nodes that are ready at the
+// same time are sorted by name, so the order is stable.
+// If the graph has a circular dependency, TopoSort returns a CycleError.
+func (g Graph) TopoSort() ([]Node, error) {
+	if cycle := g.Cycle(); cycle != nil {
+		return nil, CycleError{Path: cycle}
+	}
+
+	remaining := make(map[Node]int)
+	for n, deps := range g.nodes {
+		remaining[n] = len(deps)
+	}
+
+	ready := make([]Node, 0)
+	for _, n := range g.sorted() {
+		if remaining[n] == 0 {
+			ready = append(ready, n)
+		}
+	}
+
+	retval := make([]Node, 0, len(g.nodes))
+	for len(ready) > 0 {
+		n := ready[0]
+		ready = ready[1:]
+		retval = append(retval, n)
+
+		added := false
+		for _, from := range g.GetReverse(n) {
+			remaining[from]--
+			if remaining[from] == 0 {
+				ready = append(ready, from)
+				added = true
+			}
+		}
+		if added {
+			sortNodes(ready)
+		}
+	}
+
+	return retval, nil
+}
+
+// sorted returns the nodes of the graph sorted by name
+func (g Graph) sorted() []Node {
+	nodes := make([]Node, 0, len(g.nodes))
+	for n := range g.nodes {
+		nodes = append(nodes, n)
+	}
+
+	sortNodes(nodes)
+
+	return nodes
+}
+
+func sortNodes(nodes []Node) {
+	sort.Slice(nodes, func(i, j int) bool {
+		return fmt.Sprint(nodes[i]) < fmt.Sprint(nodes[j])
+	})
+}
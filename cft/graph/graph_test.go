@@ -80,3 +80,123 @@ func Example_getReverse() {
 	// [Outputs/BucketArn Outputs/BucketName]
 	// []
 }
+
+const sectionsString = `
+Parameters:
+  Env:
+    Type: String
+Mappings:
+  Sizes:
+    prod:
+      Size: 100
+Conditions:
+  IsProd: !Equals [!Ref Env, prod]
+  UseBig: !And
+    - !Condition IsProd
+    - !Equals [!Ref AWS::Region, us-east-1]
+Resources:
+  Volume:
+    Type: AWS::EC2::Volume
+    Condition: UseBig
+    Properties:
+      Size: !FindInMap [Sizes, !Ref Env, Size]
+  Queue:
+    Type: AWS::SQS::Queue
+    DependsOn: [Volume]
+    Properties:
+      QueueName: !Sub
+        - ${Env}-${Volume.VolumeId}-${Suffix}-${!Literal}
+        - Suffix: !If [IsProd, !GetAtt Volume.Arn, dev]
+Outputs:
+  QueueName:
+    Condition: IsProd
+    Value: !Ref Queue
+`
+
+func Example_edges() {
+	t, err := parse.String(sectionsString)
+	if err != nil {
+		panic(err)
+	}
+
+	g := graph.New(t)
+
+	for _, n := range g.Nodes() {
+		for _, e := range g.Edges(n) {
+			fmt.Println(e)
+		}
+	}
+	// Output:
+	// Conditions/IsProd -> Parameters/Env (Ref)
+	// Conditions/UseBig -> Conditions/IsProd (Condition)
+	// Conditions/UseBig -> Parameters/AWS::Region (Ref)
+	// Resources/Volume -> Conditions/UseBig (Condition)
+	// Resources/Volume -> Mappings/Sizes (FindInMap)
+	// Resources/Volume -> Parameters/Env (Ref)
+	// Resources/Queue -> Conditions/IsProd (Condition)
+	// Resources/Queue -> Parameters/Env (Sub)
+	// Resources/Queue -> Resources/Volume (DependsOn, GetAtt, Sub)
+	// Outputs/QueueName -> Conditions/IsProd (Condition)
+	// Outputs/QueueName -> Resources/Queue (Ref)
+}
+
+func Example_topoSort() {
+	nodes, err := g.TopoSort()
+	if err != nil {
+		panic(err)
+	}
+
+	for _, node := range nodes {
+		fmt.Println(node)
+	}
+	// Output:
+	// Parameters/AWS::AccountId
+	// Parameters/Name
+	// Resources/LogBucket
+	// Resources/Bucket
+	// Outputs/BucketArn
+	// Outputs/BucketName
+}
+
+func TestCycle(t *testing.T) {
+	tmpl, err := parse.String(`
+Resources:
+  A:
+    Type: AWS::SNS::Topic
+    Properties:
+      TopicName: !GetAtt B.TopicName
+  B:
+    Type: AWS::SNS::Topic
+    Properties:
+      TopicName: !Sub ${C}-b
+  C:
+    Type: AWS::SNS::Topic
+    DependsOn: A
+  D:
+    Type: AWS::SNS::Topic
+    DependsOn: A
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := graph.New(tmpl)
+
+	if g.Cycle() == nil {
+		t.Fatal("expected a cycle")
+	}
+
+	_, err = g.TopoSort()
+	if err == nil {
+		t.Fatal("expected TopoSort to fail")
+	}
+
+	expected := "circular dependency: Resources/A -> Resources/B -> Resources/C -> Resources/A"
+	if err.Error() != expected {
+		t.Errorf("expected '%s', got '%s'", expected, err.Error())
+	}
+
+	if c := graph.New(template).Cycle(); c != nil {
+		t.Errorf("unexpected cycle: %v", c)
+	}
+}
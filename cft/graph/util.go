@@ -1,98 +1,198 @@
 package graph
 
 import (
-	"fmt"
-	"regexp"
 	"strings"
 
+	"github.com/aws-cloudformation/rain/cft"
+	"github.com/aws-cloudformation/rain/cft/parse"
 	"github.com/aws-cloudformation/rain/internal/config"
+	"gopkg.in/yaml.v3"
 )
 
-var subRe = regexp.MustCompile(`\$\{([^!].+?)\}`)
-
-func getRefs(t map[string]interface{}) []string {
-	return findRefs(t)
+// ref is a reference from an element of the template to a name
+type ref struct {
+	from Node
+	kind EdgeKind
+	name string
 }
 
-func findRefs(t map[string]interface{}) []string {
-	refs := make([]string, 0)
-
-	for key, value := range t {
-		switch key {
-		case "DependsOn":
-			switch v := value.(type) {
-			case string:
-				refs = append(refs, v)
-			case []interface{}:
-				for _, d := range v {
-					refs = append(refs, d.(string))
+// findRefs returns every reference made by the
+// Conditions, Resources, and Outputs in the model
+func findRefs(m *cft.Model) []ref {
+	refs := make([]ref, 0)
+
+	for _, name := range m.Names(cft.Conditions) {
+		from := Node{string(cft.Conditions), name}
+		walk(m.Conditions[name], func(kind EdgeKind, to string) {
+			refs = append(refs, ref{from, kind, to})
+		})
+	}
+
+	for _, section := range []cft.Section{cft.Resources, cft.Outputs} {
+		for _, name := range m.Names(section) {
+			from := Node{string(section), name}
+			found := func(kind EdgeKind, to string) {
+				refs = append(refs, ref{from, kind, to})
+			}
+
+			var n *yaml.Node
+			if section == cft.Resources {
+				n = m.Resources[name].Node
+			} else {
+				n = m.Outputs[name].Node
+			}
+			if n == nil || n.Kind != yaml.MappingNode {
+				continue
+			}
+
+			// DependsOn and Condition attributes are only
+			// meaningful at the top level of the element
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				key, val := n.Content[i], n.Content[i+1]
+				switch {
+				case key.Value == "DependsOn" && section == cft.Resources:
+					if val.Kind == yaml.ScalarNode {
+						found(DependsOn, val.Value)
+					} else {
+						for _, d := range val.Content {
+							found(DependsOn, d.Value)
+						}
+					}
+				case key.Value == "Condition" && val.Kind == yaml.ScalarNode:
+					found(Condition, val.Value)
+				default:
+					walk(val, found)
 				}
-			default:
-				config.Debugf("invalid DependsOn: %v, %v", key, value)
 			}
+		}
+	}
+
+	return refs
+}
+
+// walk calls found for each intrinsic function in n that refers to
+// another element of the template
+func walk(n *yaml.Node, found func(EdgeKind, string)) {
+	if n == nil {
+		return
+	}
+
+	switch n.Kind {
+	case yaml.AliasNode:
+		walk(n.Alias, found)
+		return
+	case yaml.SequenceNode:
+		for _, c := range n.Content {
+			walk(c, found)
+		}
+		return
+	case yaml.MappingNode:
+	default:
+		return
+	}
+
+	if len(n.Content) == 2 {
+		key, val := n.Content[0], n.Content[1]
+
+		switch key.Value {
 		case "Ref":
-			refs = append(refs, value.(string))
+			if val.Kind == yaml.ScalarNode {
+				found(Ref, val.Value)
+				return
+			}
 		case "Fn::GetAtt":
-			switch v := value.(type) {
-			case string:
-				parts := strings.Split(v, ".")
-				refs = append(refs, parts[0])
-			case []interface{}:
-				if s, ok := v[0].(string); ok {
-					refs = append(refs, s)
+			if val.Kind == yaml.ScalarNode {
+				found(GetAtt, strings.Split(val.Value, ".")[0])
+				return
+			}
+			if val.Kind == yaml.SequenceNode && len(val.Content) > 0 {
+				found(GetAtt, val.Content[0].Value)
+				for _, c := range val.Content[1:] {
+					walk(c, found)
 				}
-			default:
-				fmt.Printf("Malformed GetAtt: %T\n", v)
+				return
 			}
 		case "Fn::Sub":
-			switch v := value.(type) {
-			case string:
-				for _, groups := range subRe.FindAllStringSubmatch(v, 1) {
-					refs = append(refs, groups[1])
+			walkSub(val, found)
+			return
+		case "Fn::FindInMap":
+			if val.Kind == yaml.SequenceNode && len(val.Content) > 0 {
+				if val.Content[0].Kind == yaml.ScalarNode {
+					found(FindInMap, val.Content[0].Value)
+				} else {
+					walk(val.Content[0], found)
 				}
-			case []interface{}:
-				switch {
-				case len(v) != 2:
-					fmt.Printf("Malformed Sub: %T\n", v)
-				default:
-					switch parts := v[1].(type) {
-					case map[string]interface{}:
-						for _, part := range parts {
-							switch p := part.(type) {
-							case map[string]interface{}:
-								refs = append(refs, findRefs(p)...)
-							default:
-								fmt.Printf("Malformed Sub: %T\n", v)
-							}
-						}
-					default:
-						fmt.Printf("Malformed Sub: %T\n", v)
-					}
+				for _, c := range val.Content[1:] {
+					walk(c, found)
+				}
+				return
+			}
+		case "Fn::If":
+			if val.Kind == yaml.SequenceNode && len(val.Content) > 0 {
+				found(Condition, val.Content[0].Value)
+				for _, c := range val.Content[1:] {
+					walk(c, found)
 				}
-			default:
-				fmt.Printf("Malformed Sub: %T\n", v)
+				return
 			}
-		default:
-			for _, tree := range findTrees(value) {
-				refs = append(refs, findRefs(tree)...)
+		case "Condition":
+			// Used inside Conditions, for example in Fn::And
+			if val.Kind == yaml.ScalarNode {
+				found(Condition, val.Value)
+				return
 			}
 		}
 	}
 
-	return refs
+	for i := 1; i < len(n.Content); i += 2 {
+		walk(n.Content[i], found)
+	}
 }
 
-func findTrees(value interface{}) []map[string]interface{} {
-	trees := make([]map[string]interface{}, 0)
+// walkSub finds the variables in a Fn::Sub, leaving out
+// the ones that are defined in its variable map
+func walkSub(n *yaml.Node, found func(EdgeKind, string)) {
+	var s string
+	local := make(map[string]bool)
 
-	switch v := value.(type) {
-	case map[string]interface{}:
-		trees = append(trees, v)
-	case []interface{}:
-		for _, child := range v {
-			trees = append(trees, findTrees(child)...)
+	switch n.Kind {
+	case yaml.ScalarNode:
+		s = n.Value
+	case yaml.SequenceNode:
+		if len(n.Content) == 0 {
+			return
 		}
+		s = n.Content[0].Value
+		if len(n.Content) > 1 && n.Content[1].Kind == yaml.MappingNode {
+			vars := n.Content[1]
+			for i := 0; i+1 < len(vars.Content); i += 2 {
+				local[vars.Content[i].Value] = true
+				walk(vars.Content[i+1], found)
+			}
+		}
+	default:
+		return
+	}
+
+	words, err := parse.ParseSub(s)
+	if err != nil {
+		config.Debugf("unable to parse Sub %s: %v", s, err)
+		return
 	}
 
-	return trees
+	for _, w := range words {
+		switch w.T {
+		case parse.AWS:
+			found(Sub, "AWS::"+w.W)
+		case parse.REF:
+			if !local[w.W] {
+				found(Sub, w.W)
+			}
+		case parse.GETATT:
+			name := strings.Split(w.W, ".")[0]
+			if !local[name] {
+				found(Sub, name)
+			}
+		}
+	}
 }
@@ -0,0 +1,638 @@
+package cft
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/aws-cloudformation/rain/internal/node"
+	"gopkg.in/yaml.v3"
+)
+
+// Model is a typed view of the sections of a Template.
+//
+// Each element keeps a pointer to the yaml node it was read from, so
+// values like Resource.Properties can be read and edited in place.
+// Changes to the typed fields, and any elements that are added to or
+// removed from the maps, are written back to the template with Write.
+// Write leaves comments, ordering, and anything the model does
+// not understand (for example Fn::ForEach keys) untouched.
+//
+//	m, err := t.Model()
+//	bucketName := m.Resources["MyBucket"].Properties["BucketName"]
+type Model struct {
+	Parameters map[string]*Parameter
+	Conditions map[string]*yaml.Node
+	Mappings   map[string]Mapping
+	Resources  map[string]*Resource
+	Outputs    map[string]*Output
+
+	// root is the template's top level mapping node
+	root *yaml.Node
+
+	// order records the names in each section as they appeared in the template
+	order map[Section][]string
+
+	// skipped records entries that could not be modelled, so that Write leaves them alone
+	skipped map[Section]map[string]bool
+}
+
+// Parameter represents an entry in the Parameters section
+type Parameter struct {
+	Name                  string
+	Type                  string
+	Description           string
+	Default               *yaml.Node
+	AllowedValues         []string
+	AllowedPattern        string
+	ConstraintDescription string
+	NoEcho                bool
+
+	// Line is the line number of the parameter name in the source
+	Line int
+
+	// Node is the parameter's mapping node in the template
+	Node *yaml.Node
+}
+
+// Mapping represents an entry in the Mappings section.
+// Values are accessed as mapping[TopLevelKey][SecondLevelKey]
+type Mapping map[string]map[string]*yaml.Node
+
+// Resource represents an entry in the Resources section
+type Resource struct {
+	LogicalId           string
+	Type                string
+	Properties          map[string]*yaml.Node
+	DependsOn           []string
+	Condition           string
+	DeletionPolicy      string
+	UpdateReplacePolicy string
+	Metadata            *yaml.Node
+
+	// Line is the line number of the logical id in the source
+	Line int
+
+	// Origin is where the logical id was written, which
+	// may be a module or included file if the template was packaged
+	Origin node.Origin
+
+	// Node is the resource's mapping node in the template
+	Node *yaml.Node
+}
+
+// Output represents an entry in the Outputs section
+type Output struct {
+	Name        string
+	Description string
+	Value       *yaml.Node
+	Condition   string
+
+	// Export is the value of Export/Name, or nil if the output is not exported
+	Export *yaml.Node
+
+	// Line is the line number of the output name in the source
+	Line int
+
+	// Node is the output's mapping node in the template
+	Node *yaml.Node
+}
+
+// Model returns a typed view of the template
+func (t Template) Model() (*Model, error) {
+	if t.Node == nil {
+		return nil, errors.New("t.Node is nil")
+	}
+
+	root := t.Node
+	if root.Kind == yaml.DocumentNode {
+		if len(root.Content) == 0 {
+			return nil, errors.New("missing Document Content")
+		}
+		root = root.Content[0]
+	}
+	if root.Kind != yaml.MappingNode {
+		return nil, errors.New("expected the template to be a mapping")
+	}
+
+	m := &Model{
+		Parameters: make(map[string]*Parameter),
+		Conditions: make(map[string]*yaml.Node),
+		Mappings:   make(map[string]Mapping),
+		Resources:  make(map[string]*Resource),
+		Outputs:    make(map[string]*Output),
+		root:       root,
+		order:      make(map[Section][]string),
+		skipped:    make(map[Section]map[string]bool),
+	}
+
+	for _, section := range modelSections {
+		err := m.read(section)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// modelSections are the sections of the template that are represented in a Model
+var modelSections = []Section{Parameters, Conditions, Mappings, Resources, Outputs}
+
+func (m *Model) read(section Section) error {
+	s := mapValue(m.root, string(section))
+	if s == nil {
+		return nil
+	}
+	if s.Kind != yaml.MappingNode {
+		return fmt.Errorf("expected %s to be a mapping", section)
+	}
+
+	m.skipped[section] = make(map[string]bool)
+
+	for i := 0; i+1 < len(s.Content); i += 2 {
+		key := s.Content[i]
+		val := s.Content[i+1]
+		name := key.Value
+
+		m.order[section] = append(m.order[section], name)
+
+		if section == Conditions {
+			m.Conditions[name] = val
+			continue
+		}
+
+		// Anything that isn't a mapping (like Fn::ForEach) is left as-is
+		if val.Kind != yaml.MappingNode {
+			m.skipped[section][name] = true
+			continue
+		}
+
+		switch section {
+		case Parameters:
+			m.Parameters[name] = readParameter(name, key.Line, val)
+		case Mappings:
+			mapping, ok := readMapping(val)
+			if !ok {
+				m.skipped[section][name] = true
+				continue
+			}
+			m.Mappings[name] = mapping
+		case Resources:
+			r := readResource(name, key.Line, val)
+			r.Origin, _ = node.GetOrigin(key)
+			m.Resources[name] = r
+		case Outputs:
+			m.Outputs[name] = readOutput(name, key.Line, val)
+		}
+	}
+
+	return nil
+}
+
+func readParameter(name string, line int, n *yaml.Node) *Parameter {
+	return &Parameter{
+		Name:                  name,
+		Type:                  scalarValue(n, "Type"),
+		Description:           scalarValue(n, "Description"),
+		Default:               mapValue(n, "Default"),
+		AllowedValues:         stringsValue(n, "AllowedValues"),
+		AllowedPattern:        scalarValue(n, "AllowedPattern"),
+		ConstraintDescription: scalarValue(n, "ConstraintDescription"),
+		NoEcho:                strings.EqualFold(scalarValue(n, "NoEcho"), "true"),
+		Line:                  line,
+		Node:                  n,
+	}
+}
+
+func readMapping(n *yaml.Node) (Mapping, bool) {
+	mapping := make(Mapping)
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		top := n.Content[i+1]
+		if top.Kind != yaml.MappingNode {
+			return nil, false
+		}
+		values := make(map[string]*yaml.Node)
+		for j := 0; j+1 < len(top.Content); j += 2 {
+			values[top.Content[j].Value] = top.Content[j+1]
+		}
+		mapping[n.Content[i].Value] = values
+	}
+	return mapping, true
+}
+
+func readResource(logicalId string, line int, n *yaml.Node) *Resource {
+	props := make(map[string]*yaml.Node)
+	if p := mapValue(n, "Properties"); p != nil && p.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(p.Content); i += 2 {
+			props[p.Content[i].Value] = p.Content[i+1]
+		}
+	}
+
+	return &Resource{
+		LogicalId:           logicalId,
+		Type:                scalarValue(n, "Type"),
+		Properties:          props,
+		DependsOn:           stringsValue(n, "DependsOn"),
+		Condition:           scalarValue(n, "Condition"),
+		DeletionPolicy:      scalarValue(n, "DeletionPolicy"),
+		UpdateReplacePolicy: scalarValue(n, "UpdateReplacePolicy"),
+		Metadata:            mapValue(n, "Metadata"),
+		Line:                line,
+		Node:                n,
+	}
+}
+
+func readOutput(name string, line int, n *yaml.Node) *Output {
+	var export *yaml.Node
+	if e := mapValue(n, "Export"); e != nil {
+		export = mapValue(e, "Name")
+	}
+
+	return &Output{
+		Name:        name,
+		Description: scalarValue(n, "Description"),
+		Value:       mapValue(n, "Value"),
+		Condition:   scalarValue(n, "Condition"),
+		Export:      export,
+		Line:        line,
+		Node:        n,
+	}
+}
+
+// Names returns the names of the entries in a section, in the order they
+// appear in the template. Entries that were added to the model and are
+// not yet in the template come last, sorted by name.
+func (m *Model) Names(section Section) []string {
+	var names []string
+	switch section {
+	case Parameters:
+		names = keys(m.Parameters)
+	case Conditions:
+		names = keys(m.Conditions)
+	case Mappings:
+		names = keys(m.Mappings)
+	case Resources:
+		names = keys(m.Resources)
+	case Outputs:
+		names = keys(m.Outputs)
+	default:
+		return nil
+	}
+
+	retval := make([]string, 0, len(names))
+	for _, name := range m.order[section] {
+		if slices.Contains(names, name) {
+			retval = append(retval, name)
+		}
+	}
+	for _, name := range names {
+		if !slices.Contains(retval, name) {
+			retval = append(retval, name)
+		}
+	}
+
+	return retval
+}
+
+// Write applies the model to the template it was created from
+func (m *Model) Write() error {
+	if m.root == nil {
+		return errors.New("the model was not created from a template")
+	}
+
+	for _, section := range modelSections {
+		var names []string
+		var write func(name string, existing *yaml.Node) *yaml.Node
+
+		switch section {
+		case Parameters:
+			names = keys(m.Parameters)
+			write = func(name string, existing *yaml.Node) *yaml.Node {
+				return m.Parameters[name].write(existing)
+			}
+		case Conditions:
+			names = keys(m.Conditions)
+			write = func(name string, existing *yaml.Node) *yaml.Node {
+				return m.Conditions[name]
+			}
+		case Mappings:
+			names = keys(m.Mappings)
+			write = func(name string, existing *yaml.Node) *yaml.Node {
+				return m.Mappings[name].write(existing)
+			}
+		case Resources:
+			names = keys(m.Resources)
+			write = func(name string, existing *yaml.Node) *yaml.Node {
+				return m.Resources[name].write(existing)
+			}
+		case Outputs:
+			names = keys(m.Outputs)
+			write = func(name string, existing *yaml.Node) *yaml.Node {
+				return m.Outputs[name].write(existing)
+			}
+		}
+
+		s := mapValue(m.root, string(section))
+		if s == nil {
+			if len(names) == 0 {
+				continue
+			}
+			s = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			m.root.Content = append(m.root.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: string(section)}, s)
+		}
+
+		mergeMap(s, names, m.skipped[section], write)
+
+		if len(s.Content) == 0 {
+			removeMapValue(m.root, string(section))
+		}
+
+		m.order[section] = make([]string, 0, len(s.Content)/2)
+		for i := 0; i < len(s.Content); i += 2 {
+			m.order[section] = append(m.order[section], s.Content[i].Value)
+		}
+	}
+
+	return nil
+}
+
+func (p *Parameter) write(n *yaml.Node) *yaml.Node {
+	n = writeTarget(p.Node, n)
+	setScalar(n, "Type", p.Type)
+	setScalar(n, "Description", p.Description)
+	setNode(n, "Default", p.Default)
+	setStrings(n, "AllowedValues", p.AllowedValues, false)
+	setScalar(n, "AllowedPattern", p.AllowedPattern)
+	setScalar(n, "ConstraintDescription", p.ConstraintDescription)
+	// Keep the value as it was written, like True, unless it changed
+	if p.NoEcho != strings.EqualFold(scalarValue(n, "NoEcho"), "true") {
+		if p.NoEcho {
+			setScalar(n, "NoEcho", "true")
+		} else {
+			setScalar(n, "NoEcho", "")
+		}
+	}
+	p.Node = n
+	return n
+}
+
+func (mapping Mapping) write(n *yaml.Node) *yaml.Node {
+	n = writeTarget(nil, n)
+	mergeMap(n, keys(mapping), nil, func(top string, existing *yaml.Node) *yaml.Node {
+		existing = writeTarget(nil, existing)
+		values := mapping[top]
+		mergeMap(existing, keys(values), nil, func(name string, _ *yaml.Node) *yaml.Node {
+			return values[name]
+		})
+		return existing
+	})
+	return n
+}
+
+func (r *Resource) write(n *yaml.Node) *yaml.Node {
+	n = writeTarget(r.Node, n)
+	setScalar(n, "Type", r.Type)
+	setScalar(n, "Condition", r.Condition)
+	setStrings(n, "DependsOn", r.DependsOn, true)
+	setNode(n, "Metadata", r.Metadata)
+
+	props := mapValue(n, "Properties")
+	if len(r.Properties) == 0 {
+		if props == nil || props.Kind == yaml.MappingNode {
+			removeMapValue(n, "Properties")
+		}
+	} else {
+		if props == nil || props.Kind != yaml.MappingNode {
+			props = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			setNode(n, "Properties", props)
+		}
+		mergeMap(props, keys(r.Properties), nil, func(name string, _ *yaml.Node) *yaml.Node {
+			return r.Properties[name]
+		})
+	}
+
+	setScalar(n, "DeletionPolicy", r.DeletionPolicy)
+	setScalar(n, "UpdateReplacePolicy", r.UpdateReplacePolicy)
+	r.Node = n
+	return n
+}
+
+func (o *Output) write(n *yaml.Node) *yaml.Node {
+	n = writeTarget(o.Node, n)
+	setScalar(n, "Description", o.Description)
+	setNode(n, "Value", o.Value)
+	setScalar(n, "Condition", o.Condition)
+	if o.Export == nil {
+		removeMapValue(n, "Export")
+	} else {
+		export := mapValue(n, "Export")
+		if export == nil || export.Kind != yaml.MappingNode {
+			export = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			setNode(n, "Export", export)
+		}
+		setNode(export, "Name", o.Export)
+	}
+	o.Node = n
+	return n
+}
+
+// writeTarget picks the node to write an element to
+func writeTarget(own *yaml.Node, existing *yaml.Node) *yaml.Node {
+	if own != nil && own.Kind == yaml.MappingNode {
+		return own
+	}
+	if existing != nil && existing.Kind == yaml.MappingNode {
+		return existing
+	}
+	return &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+}
+
+// mergeMap updates the mapping node n so that it contains names.
+// Existing keys stay where they are, with their comments, new keys are appended
+// in the order given, and keys that are not in names or skip are removed.
+func mergeMap(n *yaml.Node, names []string, skip map[string]bool,
+	write func(name string, existing *yaml.Node) *yaml.Node) {
+
+	content := make([]*yaml.Node, 0, len(n.Content))
+	seen := make(map[string]bool)
+
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key := n.Content[i]
+		val := n.Content[i+1]
+		switch {
+		case skip[key.Value]:
+			content = append(content, key, val)
+		case slices.Contains(names, key.Value) && !seen[key.Value]:
+			content = append(content, key, write(key.Value, val))
+		default:
+			continue
+		}
+		seen[key.Value] = true
+	}
+
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+		content = append(content,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: name},
+			write(name, nil))
+	}
+
+	n.Content = content
+}
+
+// mapValue returns the value for key in a mapping node, or nil
+func mapValue(n *yaml.Node, key string) *yaml.Node {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// scalarValue returns the string value for key in a mapping node,
+// or "" if it is missing or not a scalar
+func scalarValue(n *yaml.Node, key string) string {
+	v := mapValue(n, key)
+	if v == nil || v.Kind != yaml.ScalarNode {
+		return ""
+	}
+	return v.Value
+}
+
+// stringsValue returns a scalar or a sequence of scalars as a slice
+func stringsValue(n *yaml.Node, key string) []string {
+	v := mapValue(n, key)
+	if v == nil {
+		return nil
+	}
+	switch v.Kind {
+	case yaml.ScalarNode:
+		return []string{v.Value}
+	case yaml.SequenceNode:
+		retval := make([]string, 0, len(v.Content))
+		for _, item := range v.Content {
+			if item.Kind == yaml.ScalarNode {
+				retval = append(retval, item.Value)
+			}
+		}
+		return retval
+	}
+	return nil
+}
+
+func removeMapValue(n *yaml.Node, key string) {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			n.Content = append(n.Content[:i], n.Content[i+2:]...)
+			return
+		}
+	}
+}
+
+// setNode sets the value for key, keeping the key where it is if it already exists.
+// A nil value removes the key.
+func setNode(n *yaml.Node, key string, val *yaml.Node) {
+	if val == nil {
+		removeMapValue(n, key)
+		return
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			n.Content[i+1] = val
+			return
+		}
+	}
+	n.Content = append(n.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, val)
+}
+
+// setScalar sets a string value. An empty string removes the key.
+// Values that are not scalars in the template, like intrinsic functions,
+// were read as "" and are left alone unless the model sets a new value.
+func setScalar(n *yaml.Node, key string, val string) {
+	existing := mapValue(n, key)
+	if val == "" {
+		if existing != nil && existing.Kind == yaml.ScalarNode {
+			removeMapValue(n, key)
+		}
+		return
+	}
+	if existing != nil && existing.Kind == yaml.ScalarNode {
+		if existing.Value != val {
+			existing.Value = val
+			existing.Tag = ""
+		}
+		return
+	}
+	setNode(n, key, &yaml.Node{Kind: yaml.ScalarNode, Value: val})
+}
+
+// setStrings writes a list of strings as a sequence.
+// If scalarIfOne is true, a single value is written as a scalar,
+// unless the template already has a sequence.
+func setStrings(n *yaml.Node, key string, vals []string, scalarIfOne bool) {
+	existing := mapValue(n, key)
+	if len(vals) == 0 {
+		if existing != nil && existing.Kind != yaml.MappingNode {
+			removeMapValue(n, key)
+		}
+		return
+	}
+
+	if slices.Equal(stringsValue(n, key), vals) {
+		return
+	}
+
+	if existing != nil && existing.Kind == yaml.ScalarNode && len(vals) == 1 {
+		existing.Value = vals[0]
+		return
+	}
+
+	if scalarIfOne && len(vals) == 1 && (existing == nil || existing.Kind != yaml.SequenceNode) {
+		setNode(n, key, &yaml.Node{Kind: yaml.ScalarNode, Value: vals[0]})
+		return
+	}
+
+	seq := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	if existing != nil && existing.Kind == yaml.SequenceNode {
+		seq.Style = existing.Style
+		seq.HeadComment = existing.HeadComment
+		seq.LineComment = existing.LineComment
+		seq.FootComment = existing.FootComment
+	}
+	for _, v := range vals {
+		var item *yaml.Node
+		if existing != nil {
+			for _, old := range existing.Content {
+				if old.Kind == yaml.ScalarNode && old.Value == v {
+					item = old
+					break
+				}
+			}
+		}
+		if item == nil {
+			item = &yaml.Node{Kind: yaml.ScalarNode, Value: v}
+		}
+		seq.Content = append(seq.Content, item)
+	}
+	setNode(n, key, seq)
+}
+
+func keys[V any](m map[string]V) []string {
+	retval := make([]string, 0, len(m))
+	for k := range m {
+		retval = append(retval, k)
+	}
+	sort.Strings(retval)
+	return retval
+}
@@ -0,0 +1,214 @@
+package cft_test
+
+import (
+	"testing"
+
+	"github.com/aws-cloudformation/rain/cft"
+	"github.com/aws-cloudformation/rain/cft/format"
+	"github.com/aws-cloudformation/rain/cft/parse"
+	"github.com/google/go-cmp/cmp"
+)
+
+const modelSource = `
+Parameters:
+  Name:
+    Type: String
+    AllowedValues: [a, b]
+
+Conditions:
+  IsA: !Equals [!Ref Name, a]
+
+Mappings:
+  Sizes:
+    a:
+      Size: 1
+
+Resources:
+  # The bucket
+  Bucket:
+    Type: AWS::S3::Bucket
+    Condition: IsA
+    DependsOn: Other
+    Properties:
+      BucketName: !Ref Name # The name
+      Tags:
+        - Key: a
+          Value: b
+
+  Other:
+    Type: AWS::SNS::Topic
+    DeletionPolicy: !If [IsA, Retain, Delete]
+
+  Fn::ForEach::Topics:
+    - Name
+    - [x, y]
+    - Topic${Name}:
+        Type: AWS::SNS::Topic
+
+Outputs:
+  BucketArn:
+    Value: !GetAtt Bucket.Arn
+    Export:
+      Name: bucket-arn
+`
+
+func TestModel(t *testing.T) {
+	tmpl, err := parse.String(modelSource)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := tmpl.Model()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d := cmp.Diff(m.Names(cft.Resources), []string{"Bucket", "Other"}); d != "" {
+		t.Error(d)
+	}
+
+	bucket := m.Resources["Bucket"]
+	if bucket.Type != "AWS::S3::Bucket" || bucket.Condition != "IsA" || bucket.Line != 17 {
+		t.Errorf("unexpected bucket: %+v", bucket)
+	}
+	if d := cmp.Diff(bucket.DependsOn, []string{"Other"}); d != "" {
+		t.Error(d)
+	}
+	if _, ok := bucket.Properties["BucketName"]; !ok {
+		t.Error("missing BucketName")
+	}
+
+	if m.Resources["Other"].DeletionPolicy != "" {
+		t.Error("expected an intrinsic DeletionPolicy to be empty")
+	}
+
+	if d := cmp.Diff(m.Parameters["Name"].AllowedValues, []string{"a", "b"}); d != "" {
+		t.Error(d)
+	}
+
+	if m.Mappings["Sizes"]["a"]["Size"].Value != "1" {
+		t.Error("unexpected mapping value")
+	}
+
+	if m.Outputs["BucketArn"].Export.Value != "bucket-arn" {
+		t.Error("unexpected export")
+	}
+}
+
+func TestModelWrite(t *testing.T) {
+	tmpl, err := parse.String(modelSource)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := tmpl.Model()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bucket := m.Resources["Bucket"]
+	bucket.DependsOn = append(bucket.DependsOn, "Queue")
+	bucket.Condition = ""
+	delete(bucket.Properties, "Tags")
+
+	m.Resources["Queue"] = &cft.Resource{Type: "AWS::SQS::Queue"}
+	delete(m.Resources, "Other")
+	delete(m.Conditions, "IsA")
+	m.Outputs["BucketArn"].Export = nil
+
+	err = m.Write()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `Parameters:
+  Name:
+    Type: String
+    AllowedValues:
+      - a
+      - b
+
+Mappings:
+  Sizes:
+    a:
+      Size: 1
+
+Resources:
+
+  # The bucket
+  Bucket:
+    Type: AWS::S3::Bucket
+    DependsOn:
+      - Other
+      - Queue
+    Properties:
+      BucketName: !Ref Name # The name
+
+  Fn::ForEach::Topics:
+    - Name
+    - - x
+      - y
+    - Topic${Name}:
+        Type: AWS::SNS::Topic
+
+  Queue:
+    Type: AWS::SQS::Queue
+
+Outputs:
+  BucketArn:
+    Value: !GetAtt Bucket.Arn
+`
+
+	actual := format.String(tmpl, format.Options{Unsorted: true})
+
+	if d := cmp.Diff(expected, actual); d != "" {
+		t.Error(d)
+	}
+
+	if d := cmp.Diff(m.Names(cft.Resources), []string{"Bucket", "Queue"}); d != "" {
+		t.Error(d)
+	}
+}
+
+func TestModelNoEcho(t *testing.T) {
+	tmpl, err := parse.String(`
+Parameters:
+  Password:
+    Type: String
+    NoEcho: True
+  Token:
+    Type: String
+    NoEcho: "TRUE"
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := tmpl.Model()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.Parameters["Password"].NoEcho || !m.Parameters["Token"].NoEcho {
+		t.Fatal("expected NoEcho to be true")
+	}
+
+	m.Parameters["Token"].NoEcho = false
+	if err := m.Write(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `Parameters:
+  Password:
+    Type: String
+    NoEcho: True
+
+  Token:
+    Type: String
+`
+
+	actual := format.String(tmpl, format.Options{Unsorted: true})
+
+	if d := cmp.Diff(expected, actual); d != "" {
+		t.Error(d)
+	}
+}
@@ -3,7 +3,11 @@ package pkg
 import (
 	"testing"
 
+	"github.com/aws-cloudformation/rain/internal/node"
+	"github.com/aws-cloudformation/rain/internal/s11n"
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"gopkg.in/yaml.v3"
 )
 
 func TestAnchors(t *testing.T) {
@@ -25,9 +29,42 @@ func TestAnchors(t *testing.T) {
 	//	t.Errorf("template does not match expected: %v", d.Format(true))
 	//}
 
-	// This correctly shows differences in the templates
-	if d := cmp.Diff(expected, template); d != "" {
+	// This correctly shows differences in the templates.
+	// Positions are ignored, since pkg keeps the ones from the source file.
+	if d := cmp.Diff(expected, template, cmpopts.IgnoreFields(yaml.Node{}, "Line", "Column")); d != "" {
 		t.Error(d)
 	}
 
 }
+
+func TestAnchorsAreCopied(t *testing.T) {
+	template, err := File("./tmpl/anchors.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, err := template.GetNode("Resources", "MyLambda")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, props, _ := s11n.GetMapValue(code, "Properties")
+	_, original, _ := s11n.GetMapValue(props, "Code")
+	_, metadata, _ := s11n.GetMapValue(code, "Metadata")
+	_, alias, _ := s11n.GetMapValue(metadata, "Comment")
+
+	if original == alias || &original.Content[0] == &alias.Content[0] {
+		t.Fatal("expected the alias to be a copy of the anchor")
+	}
+
+	// Changing the copy should not change the anchor
+	alias.Content[1].Value = "changed"
+	if original.Content[1].Value == "changed" {
+		t.Error("changing the alias changed the anchor")
+	}
+
+	// The copy remembers where its children were written
+	origin, ok := node.GetOrigin(alias.Content[0])
+	if !ok || origin.Line != 6 {
+		t.Errorf("expected the copy to have the origin of the anchor, got %v", origin)
+	}
+}
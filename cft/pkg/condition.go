@@ -0,0 +1,358 @@
+// This file implements the Conditions of a !Rain::Module
+package pkg
+
+import (
+	"fmt"
+
+	"github.com/aws-cloudformation/rain/cft"
+	"github.com/aws-cloudformation/rain/cft/eval"
+	"github.com/aws-cloudformation/rain/internal/config"
+	"github.com/aws-cloudformation/rain/internal/node"
+	"github.com/aws-cloudformation/rain/internal/s11n"
+	"gopkg.in/yaml.v3"
+)
+
+// moduleConditions holds the Conditions of a module. Conditions that can be
+// evaluated from the module's Properties are applied when the template is
+// packaged, and the rest are renamed and added to the parent template.
+type moduleConditions struct {
+	// The logical id of the resource in the parent template
+	logicalId string
+
+	// The module's Parameters
+	moduleParams *yaml.Node
+
+	// The parent template's Properties
+	templateProps *yaml.Node
+
+	// The module's conditions, in order
+	names []string
+
+	// The values of the conditions that were evaluated
+	static map[string]bool
+}
+
+// conditionRef is a condition named in the module
+type conditionRef struct {
+	// The name of the condition in the parent template
+	name string
+
+	// True if the condition was evaluated, and the value is known
+	static bool
+	value  bool
+}
+
+// newModuleConditions reads the module's Conditions and evaluates
+// the ones that only depend on the values of the module's Parameters
+func newModuleConditions(section *yaml.Node, ctx *refctx) (*moduleConditions, error) {
+	mc := &moduleConditions{
+		logicalId:     ctx.logicalId,
+		moduleParams:  ctx.moduleParams,
+		templateProps: ctx.templateProps,
+		static:        make(map[string]bool),
+	}
+	if section == nil {
+		return mc, nil
+	}
+	if section.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("expected the module's Conditions to be a map")
+	}
+
+	for i := 0; i+1 < len(section.Content); i += 2 {
+		mc.names = append(mc.names, section.Content[i].Value)
+	}
+
+	// Evaluate the conditions in a template that has the module's parameters.
+	// Properties that are not strings, like a Ref to a parameter of the
+	// parent template, are unknown until the stack is deployed.
+	params := &yaml.Node{Kind: yaml.MappingNode}
+	values := make(map[string]string)
+	if mc.moduleParams != nil {
+		for i := 0; i+1 < len(mc.moduleParams.Content); i += 2 {
+			name, param := mc.moduleParams.Content[i], mc.moduleParams.Content[i+1]
+			_, prop, _ := s11n.GetMapValue(mc.templateProps, name.Value)
+			switch {
+			case prop == nil:
+				params.Content = append(params.Content, name, param)
+			case prop.Kind == yaml.ScalarNode:
+				values[name.Value] = prop.Value
+				params.Content = append(params.Content, name, paramType(param))
+			default:
+				params.Content = append(params.Content, name, paramType(param))
+			}
+		}
+	}
+	doc := &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{
+		{Kind: yaml.ScalarNode, Value: "Parameters"},
+		params,
+		{Kind: yaml.ScalarNode, Value: "Conditions"},
+		node.Clone(section),
+	}}
+
+	e, err := eval.New(cft.Template{Node: doc}, eval.Options{Parameters: values})
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range mc.names {
+		value, err := e.Condition(name)
+		if err != nil {
+			config.Debugf("module %s condition %s is resolved at deploy time: %v", mc.logicalId, name, err)
+			continue
+		}
+		mc.static[name] = value
+	}
+
+	return mc, nil
+}
+
+// paramType returns a parameter with only the Type of param
+func paramType(param *yaml.Node) *yaml.Node {
+	out := &yaml.Node{Kind: yaml.MappingNode}
+	_, t, _ := s11n.GetMapValue(param, "Type")
+	if t != nil {
+		out.Content = append(out.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: "Type"}, node.Clone(t))
+	}
+	return out
+}
+
+// lookup returns the condition that a name in the module refers to.
+// A condition in the module is renamed, and a module parameter is
+// replaced with the name of the condition that the parent template
+// passes to it. Other names are left alone, since they are expected to
+// be conditions in the parent template.
+func (mc *moduleConditions) lookup(name string) (conditionRef, error) {
+	if value, ok := mc.static[name]; ok {
+		return conditionRef{name: name, static: true, value: value}, nil
+	}
+
+	for _, n := range mc.names {
+		if n == name {
+			return conditionRef{name: rename(mc.logicalId, name)}, nil
+		}
+	}
+
+	_, param, _ := s11n.GetMapValue(mc.moduleParams, name)
+	if param != nil {
+		_, prop, _ := s11n.GetMapValue(mc.templateProps, name)
+		if prop == nil || prop.Kind != yaml.ScalarNode {
+			return conditionRef{}, fmt.Errorf("module parameter %s is used as a condition, "+
+				"so it must be set to the name of a condition in the parent template", name)
+		}
+		return conditionRef{name: prop.Value}, nil
+	}
+
+	return conditionRef{name: name}, nil
+}
+
+// removed returns true if a module resource has a condition that is false
+func (mc *moduleConditions) removed(moduleResource, templateOverrides *yaml.Node) (bool, error) {
+	_, condition, _ := s11n.GetMapValue(templateOverrides, "Condition")
+	if condition == nil {
+		_, condition, _ = s11n.GetMapValue(moduleResource, "Condition")
+	}
+	if condition == nil || condition.Kind != yaml.ScalarNode {
+		return false, nil
+	}
+
+	c, err := mc.lookup(condition.Value)
+	if err != nil {
+		return false, err
+	}
+	return c.static && !c.value, nil
+}
+
+// resource sets the Condition of a resource from the module, which is
+// removed if it is true, and resolves the conditions in its other attributes
+func (mc *moduleConditions) resource(resource *yaml.Node) error {
+	for i := 0; i+1 < len(resource.Content); i += 2 {
+		key, value := resource.Content[i], resource.Content[i+1]
+
+		if key.Value != "Condition" {
+			if _, err := mc.resolve(value, false); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if value.Kind != yaml.ScalarNode {
+			return fmt.Errorf("expected Condition to be a string")
+		}
+		c, err := mc.lookup(value.Value)
+		if err != nil {
+			return err
+		}
+		if c.static {
+			// Resources with false conditions have already been removed
+			resource.Content = append(resource.Content[:i], resource.Content[i+2:]...)
+			i -= 2
+			continue
+		}
+		value.Value = c.name
+	}
+
+	return nil
+}
+
+// conditions returns the conditions that are added to the parent template,
+// with the references in them resolved like the module's resources
+func (mc *moduleConditions) conditions(section *yaml.Node, ctx *refctx) (*yaml.Node, error) {
+	out := &yaml.Node{Kind: yaml.MappingNode}
+
+	for _, name := range mc.names {
+		if _, ok := mc.static[name]; ok {
+			continue
+		}
+		_, condition, _ := s11n.GetMapValue(section, name)
+
+		// Resolve the condition as if it was a property of a resource
+		props := &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{
+			{Kind: yaml.ScalarNode, Value: "Value"},
+			node.Clone(condition),
+		}}
+		conditionCtx := *ctx
+		conditionCtx.outNode = &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{
+			{Kind: yaml.ScalarNode, Value: "Properties"},
+			props,
+		}}
+		if err := resolveRefs(&conditionCtx); err != nil {
+			return nil, fmt.Errorf("failed to resolve refs in condition %s: %v", name, err)
+		}
+		_, resolved, _ := s11n.GetMapValue(props, "Value")
+		if _, err := mc.resolve(resolved, true); err != nil {
+			return nil, fmt.Errorf("condition %s: %v", name, err)
+		}
+
+		out.Content = append(out.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: rename(mc.logicalId, name)}, resolved)
+	}
+
+	return out, nil
+}
+
+// isNoValue returns true if n is !Ref AWS::NoValue
+func isNoValue(n *yaml.Node) bool {
+	return n.Kind == yaml.MappingNode && len(n.Content) == 2 &&
+		n.Content[0].Value == "Ref" && n.Content[1].Value == "AWS::NoValue"
+}
+
+// resolve renames the conditions in n, and replaces Fn::If with one of its
+// values if the condition was evaluated. It returns true if n became
+// AWS::NoValue, so that the caller can remove it. Condition functions
+// are only resolved inside conditions, since elsewhere Condition is an
+// attribute or a property.
+func (mc *moduleConditions) resolve(n *yaml.Node, inConditions bool) (bool, error) {
+	if n.Kind == yaml.MappingNode && len(n.Content) == 2 {
+		val := n.Content[1]
+
+		switch n.Content[0].Value {
+		case "Fn::If":
+			if val.Kind != yaml.SequenceNode || len(val.Content) != 3 {
+				return false, fmt.Errorf("expected Fn::If to have 3 elements")
+			}
+			c, err := mc.lookup(val.Content[0].Value)
+			if err != nil {
+				return false, err
+			}
+			if c.static {
+				chosen := val.Content[2]
+				if c.value {
+					chosen = val.Content[1]
+				}
+				*n = *chosen
+				if isNoValue(n) {
+					return true, nil
+				}
+				return mc.resolve(n, inConditions)
+			}
+			val.Content[0].Value = c.name
+		case "Condition":
+			if inConditions && val.Kind == yaml.ScalarNode {
+				c, err := mc.lookup(val.Value)
+				if err != nil {
+					return false, err
+				}
+				if c.static {
+					// Conditions can't be true or false, so compare two strings
+					other := "false"
+					if c.value {
+						other = "true"
+					}
+					*n = yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{
+						{Kind: yaml.ScalarNode, Value: "Fn::Equals"},
+						{Kind: yaml.SequenceNode, Content: []*yaml.Node{
+							{Kind: yaml.ScalarNode, Tag: "!!str", Value: "true"},
+							{Kind: yaml.ScalarNode, Tag: "!!str", Value: other},
+						}},
+					}}
+					return false, nil
+				}
+				val.Value = c.name
+				return false, nil
+			}
+		}
+	}
+
+	switch n.Kind {
+	case yaml.MappingNode:
+		content := make([]*yaml.Node, 0, len(n.Content))
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			noValue, err := mc.resolve(n.Content[i+1], inConditions)
+			if err != nil {
+				return false, err
+			}
+			if !noValue {
+				content = append(content, n.Content[i], n.Content[i+1])
+			}
+		}
+		n.Content = content
+	case yaml.SequenceNode:
+		content := make([]*yaml.Node, 0, len(n.Content))
+		for _, c := range n.Content {
+			noValue, err := mc.resolve(c, inConditions)
+			if err != nil {
+				return false, err
+			}
+			if !noValue {
+				content = append(content, c)
+			}
+		}
+		n.Content = content
+	}
+
+	return false, nil
+}
+
+// addConditions adds the module's conditions to the parent template
+func addConditions(t cft.Template, conditions *yaml.Node) error {
+	if len(conditions.Content) == 0 {
+		return nil
+	}
+
+	root := t.Node.Content[0]
+	_, section, _ := s11n.GetMapValue(root, "Conditions")
+	if section == nil {
+		section = &yaml.Node{Kind: yaml.MappingNode}
+
+		// Conditions go before Resources
+		i := len(root.Content)
+		for j := 0; j+1 < len(root.Content); j += 2 {
+			if root.Content[j].Value == "Resources" {
+				i = j
+				break
+			}
+		}
+		key := &yaml.Node{Kind: yaml.ScalarNode, Value: "Conditions"}
+		root.Content = append(root.Content[:i], append([]*yaml.Node{key, section}, root.Content[i:]...)...)
+	}
+
+	for i := 0; i+1 < len(conditions.Content); i += 2 {
+		name := conditions.Content[i].Value
+		_, existing, _ := s11n.GetMapValue(section, name)
+		if existing != nil {
+			return fmt.Errorf("the template already has a condition named %s", name)
+		}
+		section.Content = append(section.Content, conditions.Content[i], conditions.Content[i+1])
+	}
+
+	return nil
+}
@@ -0,0 +1,138 @@
+// This file implements the Constants in the Rain section of a template
+package pkg
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws-cloudformation/rain/cft"
+	"github.com/aws-cloudformation/rain/internal/node"
+	"github.com/aws-cloudformation/rain/internal/s11n"
+	"gopkg.in/yaml.v3"
+)
+
+// constantPrefix is the name of the directive, and how a constant
+// is written inside a Sub string, as ${Rain::Constant.Name}
+const constantPrefix = "Rain::Constant"
+
+// rainConstant replaces !Rain::Constant Name with the value of the constant
+func rainConstant(ctx *directiveContext) (bool, error) {
+	name, err := expectString(ctx.n)
+	if err != nil {
+		return false, err
+	}
+
+	value, err := lookupConstant(ctx.t, name, nil)
+	if err != nil {
+		return false, err
+	}
+
+	*ctx.n = *value
+	return true, nil
+}
+
+// rainConstantSub replaces ${Rain::Constant.Name} in a Sub with the value of the constant
+func rainConstantSub(ctx *directiveContext) (bool, error) {
+	if len(ctx.n.Content) != 2 {
+		return false, nil
+	}
+	return replaceConstantSub(ctx.t, ctx.n.Content[1], nil)
+}
+
+// lookupConstant returns a copy of the value of a constant, with the
+// constants that it refers to resolved. chain holds the constants that
+// are being resolved, to find constants that refer to themselves.
+func lookupConstant(t cft.Template, name string, chain []string) (*yaml.Node, error) {
+	for _, c := range chain {
+		if c == name {
+			return nil, fmt.Errorf("constant %s refers to itself: %s -> %s",
+				name, strings.Join(chain, " -> "), name)
+		}
+	}
+
+	var constants *yaml.Node
+	if t.Node != nil && len(t.Node.Content) > 0 {
+		_, rain, _ := s11n.GetMapValue(t.Node.Content[0], string(cft.Rain))
+		_, constants, _ = s11n.GetMapValue(rain, "Constants")
+	}
+	_, value, _ := s11n.GetMapValue(constants, name)
+	if value == nil {
+		return nil, fmt.Errorf("the template does not have a constant named %s in Rain Constants", name)
+	}
+
+	value = node.Clone(value)
+	if err := resolveConstants(t, value, append(chain, name)); err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+// resolveConstants replaces the constants that are used in n
+func resolveConstants(t cft.Template, n *yaml.Node, chain []string) error {
+	if n.Kind == yaml.MappingNode && len(n.Content) == 2 {
+		val := n.Content[1]
+
+		switch n.Content[0].Value {
+		case constantPrefix:
+			if val.Kind != yaml.ScalarNode {
+				return fmt.Errorf("expected the name of a constant")
+			}
+			value, err := lookupConstant(t, val.Value, chain)
+			if err != nil {
+				return err
+			}
+			*n = *value
+			return nil
+		case "Fn::Sub":
+			_, err := replaceConstantSub(t, val, chain)
+			return err
+		}
+	}
+
+	for _, c := range n.Content {
+		if err := resolveConstants(t, c, chain); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// replaceConstantSub replaces ${Rain::Constant.Name} in the value of a Sub.
+// Constants that can't be written inside the Sub string are added to its
+// variables. It returns true if the Sub was changed.
+func replaceConstantSub(t cft.Template, n *yaml.Node, chain []string) (bool, error) {
+	if text := subText(n); text == nil || !strings.Contains(text.Value, constantPrefix+".") {
+		return false, nil
+	}
+
+	return rewriteSub(n, func(word string) (*yaml.Node, string, error) {
+		prefix, name, _ := strings.Cut(word, ".")
+		if prefix != constantPrefix {
+			return nil, "", nil
+		}
+		value, err := lookupConstant(t, name, chain)
+		if err != nil {
+			return nil, "", err
+		}
+		return value, "RainConstant" + name, nil
+	})
+}
+
+// removeRainSection removes the Rain section, which is only
+// used while the template is packaged. It returns true if the
+// template had a Rain section.
+func removeRainSection(t cft.Template) bool {
+	if t.Node == nil || len(t.Node.Content) == 0 {
+		return false
+	}
+	root := t.Node.Content[0]
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == string(cft.Rain) {
+			root.Content = append(root.Content[:i], root.Content[i+2:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package pkg_test
+
+import (
+	"testing"
+
+	"github.com/aws-cloudformation/rain/cft/parse"
+	"github.com/aws-cloudformation/rain/cft/pkg"
+)
+
+func TestConstants(t *testing.T) {
+	runTest("constants", t)
+}
+
+func TestConstantErrors(t *testing.T) {
+	for name, source := range map[string]string{
+		"missing": `
+Rain:
+  Constants:
+    Prefix: app
+Resources:
+  Bucket:
+    Type: AWS::S3::Bucket
+    Properties:
+      BucketName: !Sub ${Rain::Constant.Missing}-bucket
+`,
+		"cycle": `
+Rain:
+  Constants:
+    A: !Sub ${Rain::Constant.B}-a
+    B: !Rain::Constant A
+Resources:
+  Bucket:
+    Type: AWS::S3::Bucket
+    Properties:
+      BucketName: !Rain::Constant A
+`,
+	} {
+		in, err := parse.String(source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := pkg.Template(in, "./", nil); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+// This file lists the modules that a template uses
+package pkg
+
+import (
+	"fmt"
+
+	"github.com/aws-cloudformation/rain/cft"
+	"github.com/aws-cloudformation/rain/cft/parse"
+	"gopkg.in/yaml.v3"
+)
+
+// ModuleRef is a resource that is a !Rain::Module
+type ModuleRef struct {
+	// LogicalId is the name of the resource in the template
+	LogicalId string
+
+	// URI is the module as it is written in the template
+	URI string
+
+	// Source is where the module was read from
+	Source string
+
+	// Modules are the modules that the module uses
+	Modules []*ModuleRef
+}
+
+// ListModules returns the modules that a template uses, and the modules
+// that they use. rootDir is the directory that relative paths start from.
+func ListModules(t cft.Template, rootDir string) ([]*ModuleRef, error) {
+	return listModules(t, rootDir, nil)
+}
+
+func listModules(t cft.Template, rootDir string, chain []string) ([]*ModuleRef, error) {
+	refs := make([]*ModuleRef, 0)
+
+	resources, err := t.GetSection(cft.Resources)
+	if err != nil {
+		return refs, nil
+	}
+
+	for i := 0; i+1 < len(resources.Content); i += 2 {
+		name, resource := resources.Content[i].Value, resources.Content[i+1]
+		uri, ok := moduleURI(resource)
+		if !ok {
+			continue
+		}
+
+		content, source, dir, err := readModule(uri, rootDir, nil)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		for _, s := range chain {
+			if s == source {
+				return nil, fmt.Errorf("%s: module %s uses itself", name, source)
+			}
+		}
+
+		module, err := parse.String(string(content))
+		if err != nil {
+			return nil, fmt.Errorf("%s: unable to parse module %s: %v", name, source, err)
+		}
+
+		modules, err := listModules(module, dir, append(chain, source))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+
+		refs = append(refs, &ModuleRef{
+			LogicalId: name,
+			URI:       uri,
+			Source:    source,
+			Modules:   modules,
+		})
+	}
+
+	return refs, nil
+}
+
+// moduleURI returns the module of a resource with Type: !Rain::Module <uri>
+func moduleURI(resource *yaml.Node) (string, bool) {
+	if resource.Kind != yaml.MappingNode {
+		return "", false
+	}
+	for i := 0; i+1 < len(resource.Content); i += 2 {
+		if resource.Content[i].Value != "Type" {
+			continue
+		}
+		t := resource.Content[i+1]
+		if t.Kind == yaml.MappingNode && len(t.Content) == 2 &&
+			t.Content[0].Value == "Rain::Module" && t.Content[1].Kind == yaml.ScalarNode {
+			return t.Content[1].Value, true
+		}
+	}
+	return "", false
+}
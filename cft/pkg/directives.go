@@ -53,6 +53,8 @@ func init() {
 	registry["**/*|Rain::S3Http"] = includeS3Http
 	registry["**/*|Rain::S3"] = includeS3
 	registry["**/*|Rain::Module"] = module
+	registry["**/*|Rain::Constant"] = rainConstant
+	registry["**/*|Fn::Sub"] = rainConstantSub
 }
 
 func includeString(ctx *directiveContext) (bool, error) {
@@ -81,6 +83,11 @@ func includeLiteral(ctx *directiveContext) (bool, error) {
 
 	// Transform
 	parse.NormalizeNode(&contentNode)
+
+	// Included nodes keep the module chain of the node that included them
+	origin, _ := node.GetOrigin(ctx.n)
+	node.Track(&contentNode, path, origin.Modules)
+
 	_, err = transform(&transformContext{
 		nodeToTransform: &contentNode,
 		rootDir:         filepath.Dir(path),
@@ -94,6 +101,9 @@ func includeLiteral(ctx *directiveContext) (bool, error) {
 
 	// Unwrap from the document node
 	*ctx.n = *contentNode.Content[0]
+	if origin, ok := node.GetOrigin(contentNode.Content[0]); ok {
+		node.SetOrigin(ctx.n, origin)
+	}
 	return true, nil
 }
 
@@ -0,0 +1,109 @@
+// This file implements the lock file for modules
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LockFile is the name of the file that pins the modules
+// of the templates in a directory
+const LockFile = "rain.lock"
+
+// Lock pins each module that is read from a remote source to the sha256
+// of its contents, so that packaging a template is reproducible.
+type Lock struct {
+	// Modules maps the source of each module to its sha256
+	Modules map[string]string `yaml:"Modules"`
+
+	// Frozen makes it an error to read a module that is not in the lock,
+	// which is useful to check in CI that the lock file is up to date
+	Frozen bool `yaml:"-"`
+
+	changed bool
+}
+
+// Locked makes File fail if a module from a remote source is not
+// pinned in the lock file, instead of adding it to the lock file
+var Locked bool
+
+// moduleLock is checked and updated when modules are read from remote
+// sources, if it is not nil. File sets it to the lock file that is
+// next to the template.
+var moduleLock *Lock
+
+// NewLock returns an empty Lock
+func NewLock() *Lock {
+	return &Lock{
+		Modules: make(map[string]string),
+	}
+}
+
+// ReadLock reads a lock file. It returns an empty Lock if the file does not exist.
+func ReadLock(path string) (*Lock, error) {
+	l := NewLock()
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := yaml.Unmarshal(content, l); err != nil {
+		return nil, fmt.Errorf("unable to read %s: %v", path, err)
+	}
+	if l.Modules == nil {
+		l.Modules = make(map[string]string)
+	}
+
+	return l, nil
+}
+
+// add records that a module was read
+func (l *Lock) add(source string, sum string) error {
+	if existing, ok := l.Modules[source]; !ok || existing != sum {
+		if l.Frozen {
+			return fmt.Errorf("module %s is not in %s", source, LockFile)
+		}
+		l.Modules[source] = sum
+		l.changed = true
+	}
+	return nil
+}
+
+// Changed returns true if modules were added to the lock. Modules that
+// were not used are left alone, since other templates might use them.
+func (l *Lock) Changed() bool {
+	return l.changed
+}
+
+// Write merges the modules into the lock file at path, which is
+// shared by the templates in the directory
+func (l *Lock) Write(path string) error {
+	out, err := ReadLock(path)
+	if err != nil {
+		return err
+	}
+	for source, sum := range l.Modules {
+		out.Modules[source] = sum
+	}
+
+	buf := strings.Builder{}
+	buf.WriteString("# Written by rain pkg to pin the modules that the templates in this directory use.\n" +
+		"# Commit this file, and remove a module from it to update the module.\n")
+
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(out); err != nil {
+		return err
+	}
+	enc.Close()
+
+	return os.WriteFile(path, []byte(buf.String()), 0644)
+}
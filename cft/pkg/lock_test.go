@@ -0,0 +1,77 @@
+package pkg
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSharedLock(t *testing.T) {
+	modules := map[string]string{
+		"/bucket.yaml": "Resources:\n  Bucket:\n    Type: AWS::S3::Bucket\n",
+		"/queue.yaml":  "Resources:\n  Queue:\n    Type: AWS::SQS::Queue\n",
+	}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(modules[r.URL.Path]))
+	}))
+	defer server.Close()
+
+	transport := http.DefaultTransport
+	http.DefaultTransport = server.Client().Transport
+	defer func() { http.DefaultTransport = transport }()
+
+	cache := ModuleCache
+	ModuleCache = t.TempDir()
+	defer func() { ModuleCache = cache }()
+
+	Experimental = true
+
+	// Two templates in the same directory use different modules,
+	// and a third uses a module that is only pinned with --locked
+	dir := t.TempDir()
+	for name, module := range map[string]string{"a.yaml": "bucket.yaml", "b.yaml": "queue.yaml", "c.yaml": "other.yaml"} {
+		template := fmt.Sprintf("Resources:\n  Module:\n    Type: !Rain::Module %s/%s\n", server.URL, module)
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(template), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lockPath := filepath.Join(dir, LockFile)
+	pkg := func(name string) map[string]string {
+		if _, err := File(filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+		lock, err := ReadLock(lockPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return lock.Modules
+	}
+
+	bucket := server.URL + "/bucket.yaml"
+	queue := server.URL + "/queue.yaml"
+	if locked := pkg("a.yaml"); len(locked) != 1 || locked[bucket] != checksum([]byte(modules["/bucket.yaml"])) {
+		t.Fatalf("expected the first template to add its module to the lock: %v", locked)
+	}
+	if locked := pkg("b.yaml"); len(locked) != 2 || locked[queue] != checksum([]byte(modules["/queue.yaml"])) {
+		t.Fatalf("expected the second template to add its module to the lock: %v", locked)
+	}
+
+	// Packaging one template keeps the module of the other one
+	if locked := pkg("a.yaml"); len(locked) != 2 {
+		t.Errorf("expected the lock to keep both modules: %v", locked)
+	}
+
+	// Locked packaging can't add modules
+	Locked = true
+	defer func() { Locked = false }()
+	if _, err := File(filepath.Join(dir, "c.yaml")); err == nil {
+		t.Error("expected an error for a module that is not in the lock")
+	}
+	if _, err := File(filepath.Join(dir, "a.yaml")); err != nil {
+		t.Errorf("expected a pinned module to be read: %v", err)
+	}
+}
@@ -2,10 +2,10 @@
 package pkg
 
 import (
+	"embed"
 	"errors"
 	"fmt"
-	"io"
-	"net/http"
+	"os"
 	"path/filepath"
 	"strings"
 
@@ -103,11 +103,82 @@ func addScalarAttribute(out *yaml.Node, name string, moduleResource *yaml.Node,
 	}
 }
 
+// moduleChain returns the module chain for nodes brought in by the module
+// resource named by logicalId, which may itself have come from a module
+func moduleChain(logicalId *yaml.Node) []string {
+	if logicalId == nil {
+		return nil
+	}
+	origin, _ := node.GetOrigin(logicalId)
+	chain := make([]string, 0, len(origin.Modules)+1)
+	chain = append(chain, origin.Modules...)
+	return append(chain, logicalId.Value)
+}
+
+// moduleExtension is the name of the resource in a module that extends an
+// existing resource type. It takes the name of the module resource in the
+// parent template, and its Type is the Extends entry in its Metadata.
+const moduleExtension = "ModuleExtension"
+
 // Rename a resource defined in the module to add the template resource name
 func rename(logicalId string, resourceName string) string {
+	// Fn::ForEach can make more than one extension, like
+	// ModuleExtension${Name}, and each one keeps its suffix
+	if strings.HasPrefix(resourceName, moduleExtension) {
+		return logicalId + strings.TrimPrefix(resourceName, moduleExtension)
+	}
 	return logicalId + resourceName
 }
 
+// hasExtension returns true if the module has a ModuleExtension
+// resource with Metadata Extends
+func hasExtension(moduleResources *yaml.Node) bool {
+	_, resource, _ := s11n.GetMapValue(moduleResources, moduleExtension)
+	_, metadata, _ := s11n.GetMapValue(resource, "Metadata")
+	_, extends, _ := s11n.GetMapValue(metadata, "Extends")
+	return extends != nil
+}
+
+// extend sets the Type of a ModuleExtension resource to the type in its
+// Metadata Extends, which is removed since it is only used by rain
+func extend(resource *yaml.Node) error {
+	_, metadata, _ := s11n.GetMapValue(resource, "Metadata")
+	_, extends, _ := s11n.GetMapValue(metadata, "Extends")
+	if extends == nil {
+		return nil
+	}
+	if extends.Kind != yaml.ScalarNode {
+		return errors.New("expected Metadata Extends to be a resource type")
+	}
+
+	_, typeNode, _ := s11n.GetMapValue(resource, "Type")
+	if typeNode == nil {
+		key := &yaml.Node{Kind: yaml.ScalarNode, Value: "Type"}
+		value := node.Clone(extends)
+		node.InheritOrigin(key, extends)
+		resource.Content = append([]*yaml.Node{key, value}, resource.Content...)
+	} else if typeNode.Value != extends.Value {
+		return fmt.Errorf("Type %s does not match Metadata Extends %s", typeNode.Value, extends.Value)
+	}
+
+	for i := 0; i+1 < len(metadata.Content); i += 2 {
+		if metadata.Content[i].Value == "Extends" {
+			metadata.Content = append(metadata.Content[:i], metadata.Content[i+2:]...)
+			break
+		}
+	}
+	if len(metadata.Content) == 0 {
+		for i := 0; i+1 < len(resource.Content); i += 2 {
+			if resource.Content[i].Value == "Metadata" {
+				resource.Content = append(resource.Content[:i], resource.Content[i+2:]...)
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
 // Common context needed to resolve Refs in the module.
 // This is all the common stuff that is the same for this module.
 type refctx struct {
@@ -143,7 +214,7 @@ func replaceProp(prop *yaml.Node, parentName string, v *yaml.Node, outNode *yaml
 			*parentNode.Value = *newVal
 		} else {
 			parentNode := node.GetParent(prop, outNode, nil)
-			if parentNode.Key != nil {
+			if parentNode.Key != nil || isRef(parentNode.Value, prop) {
 				*parentNode.Value = *newVal
 			} else {
 				*prop = *newVal
@@ -175,6 +246,13 @@ func replaceProp(prop *yaml.Node, parentName string, v *yaml.Node, outNode *yaml
 	return nil
 }
 
+// isRef returns true if n is {Ref: arg}, which is replaced
+// as a whole when arg is resolved to a parameter value
+func isRef(n *yaml.Node, arg *yaml.Node) bool {
+	return n != nil && n.Kind == yaml.MappingNode && len(n.Content) == 2 &&
+		n.Content[0].Value == "Ref" && n.Content[1] == arg
+}
+
 // Resolve a Ref.
 // parentName is the name of the Property with the Ref in it.
 // prop is the Scalar node with the value for the Ref.
@@ -364,6 +442,7 @@ func resolveModuleSub(parentName string, prop *yaml.Node, sidx int, ctx *refctx)
 	} else {
 		newProp = &yaml.Node{Kind: yaml.ScalarNode, Value: sub}
 	}
+	node.InheritOrigin(newProp, prop)
 
 	// Replace the prop in the output node
 	replaceProp(prop, parentName, newProp, ctx.outNode, sidx)
@@ -482,12 +561,12 @@ func processModule(
 	outputNode *yaml.Node,
 	t cft.Template,
 	typeNode *yaml.Node,
-	parent node.NodePair) (bool, error) {
+	parent node.NodePair) (map[string]*yaml.Node, error) {
 
 	// The parent arg is the map in the template resource's Content[1] that contains Type, Properties, etc
 
 	if parent.Key == nil {
-		return false, errors.New("expected parent.Key to not be nil. The !Rain::Module directive should come after Type: ")
+		return nil, errors.New("expected parent.Key to not be nil. The !Rain::Module directive should come after Type: ")
 	}
 
 	// Get the logical id of the resource we are transforming
@@ -497,7 +576,7 @@ func processModule(
 	outputNode.Content = make([]*yaml.Node, 0)
 
 	if module.Kind != yaml.DocumentNode {
-		return false, errors.New("expected module to be a DocumentNode")
+		return nil, errors.New("expected module to be a DocumentNode")
 	}
 
 	curNode := module.Content[0] // ScalarNode !!map
@@ -506,7 +585,7 @@ func processModule(
 	_, moduleResources, _ := s11n.GetMapValue(curNode, "Resources")
 
 	if moduleResources == nil {
-		return false, errors.New("expected the module to have a Resources section")
+		return nil, errors.New("expected the module to have a Resources section")
 	}
 
 	// Locate the Parameters: section in the module (might be nil)
@@ -515,15 +594,52 @@ func processModule(
 	templateResource := parent.Value // The !!map node of the resource with Type !Rain::Module
 
 	// Properties are the args that match module params
-	_, templateProps, _ := s11n.GetMapValue(templateResource, "Properties")
+	_, parentProps, _ := s11n.GetMapValue(templateResource, "Properties")
+
+	// Other properties are set on the ModuleExtension
+	extended := hasExtension(moduleResources)
+
+	// Check the Properties against the module's Parameters, and apply defaults
+	templateProps, err := moduleProperties(moduleParams, parentProps, extended)
+	if err != nil {
+		return nil, err
+	}
 
 	// Overrides have overridden values for module resources. Anything in a module can be overridden.
 	_, overrides, _ := s11n.GetMapValue(templateResource, "Overrides")
 
-	fe, err := handleForEach(moduleResources, t, logicalId, outputNode,
-		moduleParams, templateProps)
+	err = expandForEach(module, moduleResources, moduleParams, templateProps)
 	if err != nil {
-		return false, err
+		return nil, err
+	}
+
+	moduleCtx := &refctx{
+		moduleParams:    moduleParams,
+		templateProps:   templateProps,
+		logicalId:       logicalId,
+		moduleResources: moduleResources,
+		overrides:       overrides,
+	}
+
+	// Evaluate the module's Conditions, if it has any
+	_, moduleConditions, _ := s11n.GetMapValue(curNode, "Conditions")
+	conditions, err := newModuleConditions(moduleConditions, moduleCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Leave out resources that have a condition that is false
+	removed := make(map[string]bool)
+	for i := 0; i+1 < len(moduleResources.Content); i += 2 {
+		name := moduleResources.Content[i].Value
+		var templateOverrides *yaml.Node
+		if overrides != nil {
+			_, templateOverrides, _ = s11n.GetMapValue(overrides, name)
+		}
+		removed[name], err = conditions.removed(moduleResources.Content[i+1], templateOverrides)
+		if err != nil {
+			return nil, fmt.Errorf("resource %s: %v", name, err)
+		}
 	}
 
 	// Get module resources and add them to the output
@@ -532,6 +648,9 @@ func processModule(
 			continue
 		}
 		name := moduleResources.Content[i-1].Value
+		if removed[name] {
+			continue
+		}
 		nameNode := node.Clone(moduleResources.Content[i-1])
 		nameNode.Value = rename(logicalId, nameNode.Value)
 		outputNode.Content = append(outputNode.Content, nameNode)
@@ -548,6 +667,10 @@ func processModule(
 		for _, pl := range propLike {
 			_, plProps, _ := s11n.GetMapValue(moduleResource, pl)
 			_, plTemplateProps, _ := s11n.GetMapValue(templateOverrides, pl)
+			if pl == "Properties" && extended && name == moduleExtension {
+				// Overrides take precedence over the properties of the module resource
+				plTemplateProps = cloneAndReplaceProps(clonedResource, pl, parentProps, plTemplateProps, nil)
+			}
 			clonedProps := cloneAndReplaceProps(clonedResource, pl, plProps, plTemplateProps, moduleParams)
 			if clonedProps == nil {
 				// Was not present in the module or in the template, so skip it
@@ -574,7 +697,6 @@ func processModule(
 		_, moduleDependsOn, _ := s11n.GetMapValue(moduleResource, "DependsOn")
 		_, templateDependsOn, _ := s11n.GetMapValue(templateOverrides, "DependsOn")
 		if moduleDependsOn != nil || templateDependsOn != nil {
-			clonedResource.Content = append(clonedResource.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: "DependsOn"})
 			dependsOnValue := &yaml.Node{Kind: yaml.SequenceNode, Content: make([]*yaml.Node, 0)}
 			if moduleDependsOn != nil {
 				// Remove the original DependsOn so we don't end up with two
@@ -595,6 +717,9 @@ func processModule(
 					}
 				}
 				for _, r := range c {
+					if removed[r.Value] {
+						continue
+					}
 					dependsOnValue.Content = append(dependsOnValue.Content,
 						&yaml.Node{Kind: yaml.ScalarNode, Value: rename(logicalId, r.Value)})
 				}
@@ -608,7 +733,11 @@ func processModule(
 					}
 				}
 			}
-			clonedResource.Content = append(clonedResource.Content, dependsOnValue)
+			// Dependencies on resources with a false condition are removed
+			if len(dependsOnValue.Content) > 0 {
+				clonedResource.Content = append(clonedResource.Content,
+					&yaml.Node{Kind: yaml.ScalarNode, Value: "DependsOn"}, dependsOnValue)
+			}
 		}
 
 		/*
@@ -633,52 +762,20 @@ func processModule(
 		}
 		err := resolveRefs(ctx)
 		if err != nil {
-			return false, fmt.Errorf("failed to resolve refs: %v", err)
+			return nil, fmt.Errorf("failed to resolve refs: %v", err)
 		}
 
-		if fe != nil && fe.fnForEachSequence != nil {
-			// If the module has a ForEach extension, add it to the sequence instead
-
-			// The Fn::ForEach resource is a map, so we create that and append outNode to it
-			fnForEachMap := &yaml.Node{Kind: yaml.MappingNode, Content: make([]*yaml.Node, 0)}
-			// TODO
-			newLogicalId := strings.Replace(fe.fnForEachLogicalId, "ModuleExtension", logicalId, 1)
-			fnForEachMap.Content = append(fnForEachMap.Content,
-				&yaml.Node{Kind: yaml.ScalarNode, Value: newLogicalId})
-			fnForEachMap.Content = append(fnForEachMap.Content, clonedResource)
-
-			// Add the map as the 3rd array element in the Fn::ForEach sequence
-			fe.fnForEachSequence.Content = append(fe.fnForEachSequence.Content, fnForEachMap)
-
+		// Resolve the module's Conditions, and parent Conditions passed in as parameters
+		err = conditions.resource(clonedResource)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve conditions in %s: %v", name, err)
 		}
 
-		/*
-			// Resolve Conditions. Rain handles this differently, since a rain
-			// module cannot have a Condition section. This value must be a module parameter
-			// name, and the value must be set in the parent template as the name of
-			// a Condition that is defined in the parent.
-			_, condition, _ := s11n.GetMapValue(moduleResource, "Condition")
-			if condition != nil {
-				conditionErr := errors.New("a Condition in a rain module must be the name of " +
-					"a Parameter that is set the name of a Condition in the parent template")
-				// The value must be present in the module's parameters
-				if condition.Kind != yaml.ScalarNode {
-					return false, conditionErr
-				}
-				_, param, _ := s11n.GetMapValue(moduleParams, condition.Value)
-				if param == nil {
-					return false, conditionErr
-				}
-				_, conditionVal, _ := s11n.GetMapValue(templateProps, condition.Value)
-				if conditionVal == nil {
-					return false, conditionErr
-				}
-				if conditionVal.Kind != yaml.ScalarNode {
-					return false, conditionErr
-				}
-				condition.Value = conditionVal.Value
+		if strings.HasPrefix(name, moduleExtension) {
+			if err := extend(clonedResource); err != nil {
+				return nil, fmt.Errorf("resource %s: %v", name, err)
 			}
-		*/
+		}
 
 		/*
 			// Modify referenced resource names
@@ -706,7 +803,84 @@ func processModule(
 		outputNode.Content = append(outputNode.Content, clonedResource)
 	}
 
-	return true, nil
+	// Resolve the module's Outputs so that the parent can refer to them
+	_, moduleOutputs, _ := s11n.GetMapValue(curNode, "Outputs")
+	outputs, err := resolveOutputs(moduleOutputs, moduleCtx)
+	if err != nil {
+		return nil, err
+	}
+	for name, output := range outputs {
+		if _, err := conditions.resolve(output, false); err != nil {
+			return nil, fmt.Errorf("failed to resolve conditions in output %s: %v", name, err)
+		}
+	}
+
+	// Add the conditions that are not known until deployment to the parent template
+	parentConditions, err := conditions.conditions(moduleConditions, moduleCtx)
+	if err != nil {
+		return nil, err
+	}
+	if err := addConditions(t, parentConditions); err != nil {
+		return nil, err
+	}
+
+	return outputs, nil
+}
+
+// readModule reads a module from a remote source, or from a file relative
+// to root, in templateFiles if it is not nil. It returns the module's contents,
+// its path, and the directory for modules that the module refers to.
+func readModule(uri string, root string, templateFiles *embed.FS) ([]byte, string, string, error) {
+	source, err := resolveSource(uri, root)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	if isRemote(source) {
+		content, source, err := readRemote(source)
+		if err != nil {
+			return nil, "", "", err
+		}
+		dir, err := remoteDir(source)
+		if err != nil {
+			return nil, "", "", err
+		}
+		return content, source, dir, nil
+	}
+
+	if templateFiles != nil {
+		// Read from the embedded file system (for the build -r command)
+		// We have to hack this since embed doesn't understand "path/../"
+		embeddedPath := strings.Replace(root, "../", "", 1) +
+			"/" + strings.Replace(uri, "../", "", 1)
+
+		content, err := templateFiles.ReadFile(embeddedPath)
+		if err != nil {
+			return nil, "", "", err
+		}
+		return content, embeddedPath, filepath.Dir(embeddedPath), nil
+	}
+
+	// Read the local file
+	path := uri
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", "", err
+	}
+	return content, path, filepath.Dir(path), nil
+}
+
+// ReadModule reads and parses the module at uri,
+// which is a remote source or the path to a file
+func ReadModule(uri string) (cft.Template, error) {
+	content, _, _, err := readModule(uri, "", nil)
+	if err != nil {
+		return cft.Template{}, err
+	}
+	return parse.String(string(content))
 }
 
 // Type: !Rain::Module
@@ -727,48 +901,9 @@ func module(ctx *directiveContext) (bool, error) {
 	}
 
 	uri := n.Content[1].Value
-	var content []byte
-	var err error
-	var path string
-	var newRootDir string
-
-	// Is this a local file or a URL?
-	if strings.HasPrefix(uri, "https://") {
-		// Download the file and then parse it
-		resp, err := http.Get(uri)
-		if err != nil {
-			return false, err
-		}
-		defer resp.Body.Close()
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return false, err
-		}
-		content = []byte(body)
-	} else {
-		if templateFiles != nil {
-			// Read from the embedded file system (for the build -r command)
-			path, err = expectString(n)
-			if err != nil {
-				return false, err
-			}
-			// We have to hack this since embed doesn't understand "path/../"
-			embeddedPath := strings.Replace(root, "../", "", 1) +
-				"/" + strings.Replace(path, "../", "", 1)
-
-			content, err = templateFiles.ReadFile(embeddedPath)
-			if err != nil {
-				return false, err
-			}
-			newRootDir = filepath.Dir(embeddedPath)
-		} else {
-			// Read the local file
-			content, path, err = expectFile(n, root)
-			if err != nil {
-				return false, err
-			}
-			newRootDir = filepath.Dir(path)
-		}
+	content, path, newRootDir, err := readModule(uri, root, templateFiles)
+	if err != nil {
+		return false, err
 	}
 
 	// Parse the file
@@ -780,6 +915,9 @@ func module(ctx *directiveContext) (bool, error) {
 
 	// Recurse on directives in the module itself
 	parse.NormalizeNode(&moduleNode)
+
+	// Nodes from the module remember that they came from this resource
+	node.Track(&moduleNode, path, moduleChain(parent.Key))
 	var newParent node.NodePair
 	if parent.Parent != nil && parent.Parent.Value != nil {
 		newParent = node.GetParent(n, parent.Parent.Value, nil)
@@ -798,10 +936,10 @@ func module(ctx *directiveContext) (bool, error) {
 	}
 
 	// Create a new node to represent the processed module
-	var outputNode yaml.Node
-	_, err = processModule(&moduleNode, &outputNode, t, n, parent)
+	outputNode := yaml.Node{Kind: yaml.MappingNode}
+	outputs, err := processModule(&moduleNode, &outputNode, t, n, parent)
 	if err != nil {
-		return false, fmt.Errorf("failed to process module %s: %v", uri, err)
+		return false, fmt.Errorf("failed to process module %s for %s: %v", uri, parent.Key.Value, err)
 	}
 
 	// Find the resource node in the template
@@ -821,6 +959,15 @@ func module(ctx *directiveContext) (bool, error) {
 	// Insert the transformed resource into the template
 	resourceNode.Content = append(resourceNode.Content, outputNode.Content...)
 
+	// Replace references to the module's Outputs in the template. If the module
+	// has a ModuleExtension, it has the name of the module resource, and other
+	// attributes are the attributes of the extension.
+	_, extension, _ := s11n.GetMapValue(&outputNode, parent.Key.Value)
+	err = replaceOutputRefs(t.Node, parent.Key.Value, outputs, extension != nil)
+	if err != nil {
+		return false, err
+	}
+
 	return true, nil
 
 }
@@ -2,11 +2,15 @@ package pkg_test
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
+	"github.com/aws-cloudformation/rain/cft"
 	"github.com/aws-cloudformation/rain/cft/diff"
 	"github.com/aws-cloudformation/rain/cft/parse"
 	"github.com/aws-cloudformation/rain/cft/pkg"
+	"github.com/aws-cloudformation/rain/internal/node"
+	"github.com/aws-cloudformation/rain/internal/s11n"
 	"gopkg.in/yaml.v3"
 )
 
@@ -34,10 +38,200 @@ func TestRefFalse(t *testing.T) {
 	runTest("ref-false", t)
 }
 
-// TODO: This was broken in the refactor, come back to it later
-//func TestForeach(t *testing.T) {
-//	runTest("foreach", t)
-//}
+func TestOutputs(t *testing.T) {
+	runTest("outputs", t)
+}
+
+func TestMissingOutput(t *testing.T) {
+	pkg.Experimental = true
+
+	source := `
+Resources:
+  Storage:
+    Type: !Rain::Module "./tmpl/outputs-module.yaml"
+    Properties:
+      Name: foo
+Outputs:
+  Missing:
+    Value: !GetAtt Storage.Missing
+`
+	tmpl, err := parse.String(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = pkg.Template(tmpl, ".", nil)
+	if err == nil {
+		t.Error("expected an error for an output that is not in the module")
+	}
+}
+
+func TestExtension(t *testing.T) {
+	runTest("extension", t)
+}
+
+func TestConditions(t *testing.T) {
+	runTest("conditions", t)
+}
+
+func TestConditionParameter(t *testing.T) {
+	pkg.Experimental = true
+
+	source := `
+Resources:
+  Storage:
+    Type: !Rain::Module "./tmpl/conditions-module.yaml"
+    Properties:
+      Env: prod
+      Logging: "false"
+      Name: foo
+      ParentCondition: !Ref AWS::Region
+`
+	tmpl, err := parse.String(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = pkg.Template(tmpl, ".", nil)
+	if err == nil {
+		t.Error("expected an error for a condition parameter that is not a string")
+	}
+}
+
+func TestModuleProperties(t *testing.T) {
+	pkg.Experimental = true
+
+	cases := map[string]string{
+		"":                            "",
+		"Name: !Ref ParentName":       "",
+		"Size: 20\n      Zones: [c]":  "",
+		"Retention: Keep":             "AllowedValues",
+		"Name: Not_Valid":             "AllowedPattern",
+		"Size: large":                 "not a number",
+		"Unknown: foo":                "not a parameter",
+		"Zones: {Foo: bar}":           "expected a list",
+		"Retention: !Ref AWS::Region": "",
+	}
+
+	for props, expected := range cases {
+		if !strings.HasPrefix(props, "Name:") {
+			props = "Name: my-bucket\n      " + props
+		}
+		source := `
+Parameters:
+  ParentName:
+    Type: String
+Resources:
+  Storage:
+    Type: !Rain::Module "./tmpl/params-module.yaml"
+    Properties:
+      ` + props + `
+`
+		tmpl, err := parse.String(source)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = pkg.Template(tmpl, ".", nil)
+		if expected == "" {
+			if err != nil {
+				t.Errorf("%q: unexpected error: %v", props, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("%q: expected an error", props)
+			continue
+		}
+		if !strings.Contains(err.Error(), expected) || !strings.Contains(err.Error(), "Storage") {
+			t.Errorf("%q: expected an error about %s for Storage, got %v", props, expected, err)
+		}
+	}
+}
+
+func TestModuleDefaults(t *testing.T) {
+	pkg.Experimental = true
+
+	source := `
+Resources:
+  Storage:
+    Type: !Rain::Module "./tmpl/params-module.yaml"
+    Properties:
+      Name: my-bucket
+`
+	tmpl, err := parse.String(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	packaged, err := pkg.Template(tmpl, ".", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bucket, err := packaged.GetResource("StorageBucket")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, policy, _ := s11n.GetMapValue(bucket, "DeletionPolicy")
+	if policy == nil || policy.Value != "Delete" {
+		t.Errorf("expected the default DeletionPolicy, got %v", node.ToSJson(bucket))
+	}
+
+	if _, err := pkg.Template(mustParse(t, `
+Resources:
+  Storage:
+    Type: !Rain::Module "./tmpl/params-module.yaml"
+`), ".", nil); err == nil || !strings.Contains(err.Error(), "missing required property Name") {
+		t.Errorf("expected an error for the missing Name property, got %v", err)
+	}
+}
+
+func mustParse(t *testing.T, source string) cft.Template {
+	tmpl, err := parse.String(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tmpl
+}
+
+func TestModuleOrigins(t *testing.T) {
+	pkg.Experimental = true
+
+	packaged, err := pkg.File("./tmpl/modinmod-template.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resource, err := packaged.GetResource("MySubBucket")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, props, _ := s11n.GetMapValue(resource, "Properties")
+
+	cases := map[string]string{
+		"BucketName": "tmpl/modinmod-sub-module.yaml:10:19 (via My > Sub)",
+		"XName":      "tmpl/modinmod-module.yaml:8:10 (via My)",
+		"YName":      "tmpl/modinmod-module.yaml:9:10 (via My)",
+	}
+
+	for name, expected := range cases {
+		_, value, _ := s11n.GetMapValue(props, name)
+		origin, ok := node.GetOrigin(value)
+		if !ok {
+			t.Errorf("%s has no origin", name)
+			continue
+		}
+		if origin.String() != expected {
+			t.Errorf("%s: expected %s, got %s", name, expected, origin)
+		}
+	}
+}
+
+func TestForeach(t *testing.T) {
+	runTest("foreach", t)
+}
 
 func runTest(test string, t *testing.T) {
 
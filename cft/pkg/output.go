@@ -0,0 +1,249 @@
+// This file implements the Outputs of a !Rain::Module
+package pkg
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws-cloudformation/rain/cft/parse"
+	"github.com/aws-cloudformation/rain/internal/node"
+	"github.com/aws-cloudformation/rain/internal/s11n"
+	"gopkg.in/yaml.v3"
+)
+
+// resolveOutputs returns the Value of each of the module's Outputs, with
+// references to the module's parameters and resources resolved the same
+// way as they are in the module's resources
+func resolveOutputs(moduleOutputs *yaml.Node, ctx *refctx) (map[string]*yaml.Node, error) {
+	outputs := make(map[string]*yaml.Node)
+	if moduleOutputs == nil {
+		return outputs, nil
+	}
+	if moduleOutputs.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("expected the module's Outputs to be a map")
+	}
+
+	for i := 0; i+1 < len(moduleOutputs.Content); i += 2 {
+		name := moduleOutputs.Content[i].Value
+		_, value, _ := s11n.GetMapValue(moduleOutputs.Content[i+1], "Value")
+		if value == nil {
+			return nil, fmt.Errorf("module output %s does not have a Value", name)
+		}
+
+		// Resolve the value as if it was a property of a resource
+		props := &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{
+			{Kind: yaml.ScalarNode, Value: "Value"},
+			node.Clone(value),
+		}}
+		outputCtx := *ctx
+		outputCtx.outNode = &yaml.Node{Kind: yaml.MappingNode, Content: []*yaml.Node{
+			{Kind: yaml.ScalarNode, Value: "Properties"},
+			props,
+		}}
+		if err := resolveRefs(&outputCtx); err != nil {
+			return nil, fmt.Errorf("failed to resolve refs in output %s: %v", name, err)
+		}
+
+		_, outputs[name], _ = s11n.GetMapValue(props, "Value")
+	}
+
+	return outputs, nil
+}
+
+// replaceOutputRefs replaces !GetAtt LogicalId.OutputName and
+// ${LogicalId.OutputName} in n with the values of the module's Outputs.
+// If extended is true, the module has a resource named LogicalId, and
+// other attributes are left alone.
+func replaceOutputRefs(n *yaml.Node, logicalId string, outputs map[string]*yaml.Node, extended bool) error {
+	if n.Kind == yaml.MappingNode && len(n.Content) == 2 {
+		val := n.Content[1]
+
+		switch n.Content[0].Value {
+		case "Fn::GetAtt":
+			name, attr := "", ""
+			if val.Kind == yaml.ScalarNode {
+				name, attr, _ = strings.Cut(val.Value, ".")
+			} else if val.Kind == yaml.SequenceNode && len(val.Content) == 2 {
+				name, attr = val.Content[0].Value, val.Content[1].Value
+			}
+			if name == logicalId {
+				output, ok := outputs[attr]
+				if ok {
+					*n = *node.Clone(output)
+					return nil
+				}
+				if !extended {
+					return fmt.Errorf("module %s does not have an output named %s", logicalId, attr)
+				}
+			}
+		case "Fn::Sub":
+			return replaceOutputSub(val, logicalId, outputs, extended)
+		}
+	}
+
+	for _, c := range n.Content {
+		if err := replaceOutputRefs(c, logicalId, outputs, extended); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// replaceOutputSub replaces ${LogicalId.OutputName} in a Sub. Outputs that
+// can't be written inside the Sub string are added to its variables.
+func replaceOutputSub(n *yaml.Node, logicalId string, outputs map[string]*yaml.Node, extended bool) error {
+	if n.Kind == yaml.SequenceNode && len(n.Content) > 1 {
+		if err := replaceOutputRefs(n.Content[1], logicalId, outputs, extended); err != nil {
+			return err
+		}
+	}
+
+	_, err := rewriteSub(n, func(word string) (*yaml.Node, string, error) {
+		name, attr, _ := strings.Cut(word, ".")
+		if name != logicalId {
+			return nil, "", nil
+		}
+		output, ok := outputs[attr]
+		if !ok && extended {
+			return nil, "", nil
+		}
+		if !ok {
+			return nil, "", fmt.Errorf("module %s does not have an output named %s", logicalId, attr)
+		}
+		return node.Clone(output), logicalId + attr, nil
+	})
+	return err
+}
+
+// subReplacer returns the value of ${A.B} in a Sub string, and the name of
+// the variable to use if the value can't be written inside the string.
+// It returns a nil value to leave ${A.B} as it is.
+type subReplacer func(word string) (*yaml.Node, string, error)
+
+// subText returns the string of the value of a Sub, or nil
+func subText(n *yaml.Node) *yaml.Node {
+	if n.Kind == yaml.SequenceNode {
+		if len(n.Content) == 0 {
+			return nil
+		}
+		n = n.Content[0]
+	}
+	if n.Kind != yaml.ScalarNode {
+		return nil
+	}
+	return n
+}
+
+// rewriteSub replaces each ${A.B} in the value of a Sub with the value that
+// replace returns for it. Values that can't be written inside the Sub string
+// are added to its variables, once for each variable name.
+// It returns true if the Sub was changed.
+func rewriteSub(n *yaml.Node, replace subReplacer) (bool, error) {
+	text := subText(n)
+	if text == nil {
+		return false, nil
+	}
+	var vars *yaml.Node
+	if n.Kind == yaml.SequenceNode && len(n.Content) > 1 {
+		vars = n.Content[1]
+	}
+
+	words, err := parse.ParseSub(text.Value)
+	if err != nil {
+		return false, err
+	}
+
+	changed := false
+	newVars := make([]*yaml.Node, 0)
+	added := make(map[string]bool)
+	out := ""
+	for _, w := range words {
+		switch w.T {
+		case parse.STR:
+			// ParseSub turns ${!Literal} into ${Literal}
+			out += strings.ReplaceAll(w.W, "${", "${!")
+		case parse.AWS:
+			out += "${AWS::" + w.W + "}"
+		case parse.REF:
+			out += "${" + w.W + "}"
+		case parse.GETATT:
+			value, varName, err := replace(w.W)
+			if err != nil {
+				return false, err
+			}
+			if value == nil {
+				out += "${" + w.W + "}"
+				continue
+			}
+			changed = true
+			if s, ok := subString(value); ok {
+				out += s
+				continue
+			}
+			// Lists and other functions become a variable of the Sub
+			out += "${" + varName + "}"
+			if !added[varName] {
+				added[varName] = true
+				newVars = append(newVars,
+					&yaml.Node{Kind: yaml.ScalarNode, Value: varName}, value)
+			}
+		}
+	}
+
+	if !changed {
+		return false, nil
+	}
+
+	text.Value = out
+	if len(newVars) == 0 {
+		return true, nil
+	}
+	if vars == nil {
+		vars = &yaml.Node{Kind: yaml.MappingNode}
+		if n.Kind == yaml.SequenceNode {
+			n.Content = append(n.Content, vars)
+		} else {
+			*n = yaml.Node{Kind: yaml.SequenceNode, Content: []*yaml.Node{
+				{Kind: yaml.ScalarNode, Value: out},
+				vars,
+			}}
+		}
+	}
+	vars.Content = append(vars.Content, newVars...)
+
+	return true, nil
+}
+
+// subString returns the text that has the same value as n inside a
+// Sub string, if n is a string, a Ref, a GetAtt, or a Sub without variables
+func subString(n *yaml.Node) (string, bool) {
+	if n.Kind == yaml.ScalarNode {
+		return strings.ReplaceAll(n.Value, "${", "${!"), true
+	}
+	if n.Kind != yaml.MappingNode || len(n.Content) != 2 {
+		return "", false
+	}
+
+	val := n.Content[1]
+	switch n.Content[0].Value {
+	case "Ref":
+		if val.Kind == yaml.ScalarNode {
+			return "${" + val.Value + "}", true
+		}
+	case "Fn::GetAtt":
+		if val.Kind == yaml.ScalarNode {
+			return "${" + val.Value + "}", true
+		}
+		if val.Kind == yaml.SequenceNode && len(val.Content) == 2 &&
+			val.Content[0].Kind == yaml.ScalarNode && val.Content[1].Kind == yaml.ScalarNode {
+			return "${" + val.Content[0].Value + "." + val.Content[1].Value + "}", true
+		}
+	case "Fn::Sub":
+		if val.Kind == yaml.ScalarNode {
+			return val.Value, true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,146 @@
+// This file validates the Properties that are passed to a !Rain::Module
+package pkg
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/aws-cloudformation/rain/internal/node"
+	"github.com/aws-cloudformation/rain/internal/s11n"
+	"gopkg.in/yaml.v3"
+)
+
+// moduleProperties checks the Properties that the parent template sets
+// against the module's Parameters, and returns a copy of the Properties
+// with the Default of each parameter that was not set. If the module is
+// extended, properties that are not parameters belong to the ModuleExtension.
+func moduleProperties(moduleParams, templateProps *yaml.Node, extended bool) (*yaml.Node, error) {
+	props := &yaml.Node{Kind: yaml.MappingNode}
+	if templateProps != nil {
+		if templateProps.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("expected Properties to be a map")
+		}
+		props = node.Clone(templateProps)
+	}
+
+	for i := 0; i+1 < len(props.Content); i += 2 {
+		name := props.Content[i].Value
+		_, param, _ := s11n.GetMapValue(moduleParams, name)
+		if param == nil && !extended {
+			return nil, fmt.Errorf("%s is not a parameter of the module", name)
+		}
+	}
+
+	if moduleParams == nil {
+		return props, nil
+	}
+
+	for i := 0; i+1 < len(moduleParams.Content); i += 2 {
+		name, param := moduleParams.Content[i].Value, moduleParams.Content[i+1]
+
+		_, prop, _ := s11n.GetMapValue(props, name)
+		if prop == nil {
+			_, def, _ := s11n.GetMapValue(param, "Default")
+			if def == nil {
+				return nil, fmt.Errorf("missing required property %s", name)
+			}
+			props.Content = append(props.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Value: name}, node.Clone(def))
+			continue
+		}
+
+		if err := validateProperty(param, prop); err != nil {
+			return nil, fmt.Errorf("property %s: %v", name, err)
+		}
+	}
+
+	return props, nil
+}
+
+// isIntrinsic returns true if n is an intrinsic function,
+// which can't be validated until the stack is deployed
+func isIntrinsic(n *yaml.Node) bool {
+	if n.Kind != yaml.MappingNode || len(n.Content) != 2 {
+		return false
+	}
+	key := n.Content[0].Value
+	return key == "Ref" || key == "Condition" || strings.HasPrefix(key, "Fn::")
+}
+
+// validateProperty checks the value of a property against
+// the Type, AllowedValues and AllowedPattern of a parameter
+func validateProperty(param, prop *yaml.Node) error {
+	if isIntrinsic(prop) {
+		return nil
+	}
+
+	typeName := "String"
+	_, t, _ := s11n.GetMapValue(param, "Type")
+	if t != nil {
+		typeName = t.Value
+	}
+
+	// Lists can be a comma-delimited string or a sequence
+	values := []*yaml.Node{prop}
+	isList := typeName == "CommaDelimitedList" || strings.HasPrefix(typeName, "List<")
+	if isList {
+		switch prop.Kind {
+		case yaml.SequenceNode:
+			values = prop.Content
+		case yaml.ScalarNode:
+			values = make([]*yaml.Node, 0)
+			for _, v := range strings.Split(prop.Value, ",") {
+				values = append(values, &yaml.Node{Kind: yaml.ScalarNode, Value: strings.TrimSpace(v)})
+			}
+		default:
+			return fmt.Errorf("expected a list for type %s", typeName)
+		}
+	}
+
+	_, allowedValues, _ := s11n.GetMapValue(param, "AllowedValues")
+	_, allowedPattern, _ := s11n.GetMapValue(param, "AllowedPattern")
+	var pattern *regexp.Regexp
+	if allowedPattern != nil {
+		var err error
+		pattern, err = regexp.Compile("^(?:" + allowedPattern.Value + ")$")
+		if err != nil {
+			return fmt.Errorf("invalid AllowedPattern %s: %v", allowedPattern.Value, err)
+		}
+	}
+
+	for _, v := range values {
+		if isIntrinsic(v) {
+			continue
+		}
+		if v.Kind != yaml.ScalarNode {
+			return fmt.Errorf("expected a value for type %s", typeName)
+		}
+
+		if typeName == "Number" || typeName == "List<Number>" {
+			if _, err := strconv.ParseFloat(v.Value, 64); err != nil {
+				return fmt.Errorf("'%s' is not a number", v.Value)
+			}
+		}
+
+		if allowedValues != nil {
+			allowed := false
+			for _, a := range allowedValues.Content {
+				if a.Value == v.Value {
+					allowed = true
+					break
+				}
+			}
+			if !allowed {
+				return fmt.Errorf("'%s' is not one of the AllowedValues", v.Value)
+			}
+		}
+
+		if pattern != nil && !pattern.MatchString(v.Value) {
+			return fmt.Errorf("'%s' does not match the AllowedPattern %s", v.Value, allowedPattern.Value)
+		}
+	}
+
+	return nil
+}
@@ -22,6 +22,14 @@
 //	must be called "ModuleExtension", and it must have a Metadata entry called
 //	"Extends" that supplies the existing type to be extended. The Parameters section
 //	of the module can be used to define additional properties for the extension.
+//	Modules can be files, https URLs, s3:// URIs or git:: sources, and modules
+//	from remote sources are pinned in the lock file next to the template (see LockFile).
+//
+// `Rain::Constant`: insert the value of a constant from the Constants in the
+//
+//	Rain section of the template. Constants can also be used inside Fn::Sub
+//	strings as ${Rain::Constant.Name}, and they can refer to other constants.
+//	The Rain section is removed from the packaged template.
 package pkg
 
 import (
@@ -115,6 +123,11 @@ func Template(t cft.Template, rootDir string, fs *embed.FS) (cft.Template, error
 		}
 	}
 
+	// Constants have been replaced, so the Rain section is no longer needed
+	if removeRainSection(t) {
+		changed = true
+	}
+
 	var err error
 	if changed {
 		t, err = parse.Node(templateNode)
@@ -127,7 +140,9 @@ func Template(t cft.Template, rootDir string, fs *embed.FS) (cft.Template, error
 	//
 	// 1. find alias nodes and save them in map with anchor name as key
 	// 2. replace alias nodes with the actual node
-	// 3. Marshal and Unmarshal to resolve new line/column numbers
+	//
+	// Line and column numbers are left as they were in the source files,
+	// so that node.GetOrigin can report where each node came from.
 
 	v := visitor.NewVisitor(templateNode)
 	anchors := make(map[string]*yaml.Node)
@@ -140,11 +155,16 @@ func Template(t cft.Template, rootDir string, fs *embed.FS) (cft.Template, error
 		}
 	}
 
-	replaceAnchors := func(node *visitor.Visitor) {
-		yamlNode := node.GetYamlNode()
+	replaceAnchors := func(v *visitor.Visitor) {
+		yamlNode := v.GetYamlNode()
 		if yamlNode.Kind == yaml.AliasNode {
 			if anchor, ok := anchors[yamlNode.Value]; ok {
-				*yamlNode = *anchor
+				// Copy the anchor, so that changes to one don't change the other.
+				// Clone keeps the origins of the anchor's children, and the alias
+				// keeps its own position, which is where the value is used.
+				line, column := yamlNode.Line, yamlNode.Column
+				*yamlNode = *node.Clone(anchor)
+				yamlNode.Line, yamlNode.Column = line, column
 			}
 		}
 	}
@@ -152,26 +172,12 @@ func Template(t cft.Template, rootDir string, fs *embed.FS) (cft.Template, error
 	v.Visit(collectAnchors)
 	v.Visit(replaceAnchors)
 
-	// Marshal and Unmarshal to resolve new line/column numbers
-
-	serialized, err := yaml.Marshal(templateNode)
-	if err != nil {
-		return t, fmt.Errorf("failed to marshal template: %v", err)
-	}
-
-	err = yaml.Unmarshal(serialized, templateNode)
-	if err != nil {
-		return t, fmt.Errorf("failed to unmarshal template: %v", err)
-	}
-
-	// We lose the Document node here
-	retval := cft.Template{}
-	retval.Node = &yaml.Node{Kind: yaml.DocumentNode, Content: make([]*yaml.Node, 0)}
-	retval.Node.Content = append(retval.Node.Content, templateNode)
-
-	return retval, err
+	return cft.Template{Node: templateNode}, nil
 }
 
+// packaging counts the calls to File that are in progress
+var packaging int
+
 // File opens path as a CloudFormation template and returns a cft.Template
 // with assets included as per AWS CLI packaging rules
 // and any Rain:: functions used
@@ -181,6 +187,16 @@ func File(path string) (cft.Template, error) {
 	var t cft.Template
 	var err error
 
+	// Nested templates are packaged while the outer one is in progress,
+	// so only the outermost call can let go of the origins it doesn't need
+	packaging++
+	defer func() {
+		packaging--
+		if packaging == 0 && t.Node != nil {
+			node.KeepOrigins(t.Node)
+		}
+	}()
+
 	if strings.HasSuffix(path, ".pkl") {
 		yaml, err := rainpkl.Yaml(path)
 		if err != nil {
@@ -196,7 +212,36 @@ func File(path string) (cft.Template, error) {
 			config.Debugf("pkg.File unable to parse %v", path)
 			return t, err
 		}
+
+		// Remember where each node came from, for error messages.
+		// Pkl output is generated, so its line numbers are not useful.
+		node.Track(t.Node, path, nil)
+	}
+
+	// Pin the modules that the template reads from remote sources
+	dir := filepath.Dir(path)
+	lockPath := filepath.Join(dir, LockFile)
+	lock, err := ReadLock(lockPath)
+	if err != nil {
+		return t, err
+	}
+	lock.Frozen = Locked
+
+	// Nested templates have their own lock file
+	previous := moduleLock
+	moduleLock = lock
+	defer func() { moduleLock = previous }()
+
+	t, err = Template(t, dir, nil)
+	if err != nil {
+		return t, err
+	}
+
+	if lock.Changed() {
+		if err := lock.Write(lockPath); err != nil {
+			return t, fmt.Errorf("unable to write %s: %v", lockPath, err)
+		}
 	}
 
-	return Template(t, filepath.Dir(path), nil)
+	return t, nil
 }
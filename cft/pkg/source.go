@@ -0,0 +1,335 @@
+// This file reads modules from remote sources
+package pkg
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws-cloudformation/rain/internal/aws/s3"
+	"github.com/aws-cloudformation/rain/internal/config"
+)
+
+// ModuleCache is the directory where modules from remote sources are
+// saved by the sha256 of their contents, so that modules that are pinned
+// in the lock file can be read without a network connection.
+// The cache is not used if ModuleCache is empty.
+var ModuleCache = defaultModuleCache()
+
+func defaultModuleCache() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "rain", "modules")
+}
+
+// isRemote returns true if a module source is not a local file.
+//
+// Remote sources are:
+//
+//	https://example.com/path/module.yaml
+//	s3://bucket/path/module.yaml
+//	git::https://example.com/repo.git//path/module.yaml?ref=v1.2.0
+//
+// Any of them can end with ?sha256=<hex> (or &sha256=<hex>)
+// to pin the module to the sha256 of its contents.
+func isRemote(uri string) bool {
+	return strings.HasPrefix(uri, "https://") ||
+		strings.HasPrefix(uri, "s3://") ||
+		strings.HasPrefix(uri, "git::")
+}
+
+// splitPin removes the sha256 query parameter from a source,
+// and returns the source and the sha256
+func splitPin(uri string) (string, string, error) {
+	base, query, found := strings.Cut(uri, "?")
+	if !found {
+		return uri, "", nil
+	}
+
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid query in module source %s: %v", uri, err)
+	}
+	sum := values.Get("sha256")
+	if sum == "" {
+		return uri, "", nil
+	}
+
+	values.Del("sha256")
+	if len(values) == 0 {
+		return base, strings.ToLower(sum), nil
+	}
+	return base + "?" + values.Encode(), strings.ToLower(sum), nil
+}
+
+// gitSource is a module in a git repository, in the
+// form git::<repository>//<path>?ref=<branch, tag or commit>
+type gitSource struct {
+	repo string
+	path string
+	ref  string
+}
+
+func parseGit(source string) (gitSource, error) {
+	s := strings.TrimPrefix(source, "git::")
+	s, query, _ := strings.Cut(s, "?")
+
+	g := gitSource{}
+	if query != "" {
+		values, err := url.ParseQuery(query)
+		if err != nil {
+			return g, fmt.Errorf("invalid query in module source %s: %v", source, err)
+		}
+		g.ref = values.Get("ref")
+	}
+
+	// The path starts at the first // after the scheme of the repository
+	start := 0
+	if i := strings.Index(s, "://"); i >= 0 {
+		start = i + 3
+	}
+	i := strings.Index(s[start:], "//")
+	if i < 0 {
+		return g, fmt.Errorf("expected git::<repository>//<path> in module source %s", source)
+	}
+	g.repo = s[:start+i]
+	g.path = s[start+i+2:]
+	if g.repo == "" || g.path == "" {
+		return g, fmt.Errorf("expected git::<repository>//<path> in module source %s", source)
+	}
+
+	// The repository and ref are passed to git, which would read them as options
+	if strings.HasPrefix(g.repo, "-") || strings.HasPrefix(g.ref, "-") {
+		return g, fmt.Errorf("the repository and ref can't start with - in module source %s", source)
+	}
+
+	if !isLocalPath(g.path) {
+		return g, fmt.Errorf("the path is outside of the repository in module source %s", source)
+	}
+
+	return g, nil
+}
+
+// isLocalPath returns true if p is a relative path
+// that stays inside the directory that it starts from
+func isLocalPath(p string) bool {
+	clean := path.Clean(p)
+	return !path.IsAbs(clean) && clean != ".." && !strings.HasPrefix(clean, "../")
+}
+
+func (g gitSource) String() string {
+	s := "git::" + g.repo + "//" + g.path
+	if g.ref != "" {
+		s += "?ref=" + url.QueryEscape(g.ref)
+	}
+	return s
+}
+
+// remoteDir returns the directory of a remote source, which
+// the relative paths of modules inside the module start from
+func remoteDir(source string) (string, error) {
+	if strings.HasPrefix(source, "git::") {
+		g, err := parseGit(source)
+		if err != nil {
+			return "", err
+		}
+		g.path = path.Dir(g.path)
+		return g.String(), nil
+	}
+
+	base, _, _ := strings.Cut(source, "?")
+	scheme, rest, _ := strings.Cut(base, "://")
+	return scheme + "://" + path.Dir(rest), nil
+}
+
+// resolveSource returns the source of a module that is referred to
+// by uri, from a module or template in the directory root
+func resolveSource(uri string, root string) (string, error) {
+	if isRemote(uri) || !isRemote(root) || filepath.IsAbs(uri) {
+		return uri, nil
+	}
+
+	if strings.HasPrefix(root, "git::") {
+		g, err := parseGit(root)
+		if err != nil {
+			return "", err
+		}
+		g.path = path.Join(g.path, uri)
+		return g.String(), nil
+	}
+
+	scheme, rest, _ := strings.Cut(root, "://")
+	return scheme + "://" + path.Join(rest, uri), nil
+}
+
+// readRemote reads a module from a remote source. A module that is pinned,
+// with a sha256 parameter or in the lock file, is read from the cache if it is
+// there, and it is an error if the contents of the module do not match.
+// It returns the module's contents and its source without the sha256 parameter.
+func readRemote(uri string) ([]byte, string, error) {
+	source, expected, err := splitPin(uri)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if moduleLock != nil {
+		locked, ok := moduleLock.Modules[source]
+		if ok && expected != "" && expected != locked {
+			return nil, "", fmt.Errorf("the sha256 of module %s does not match %s", source, LockFile)
+		}
+		if !ok && moduleLock.Frozen {
+			return nil, "", fmt.Errorf("module %s is not in %s", source, LockFile)
+		}
+		if ok {
+			expected = locked
+		}
+	}
+
+	if expected != "" {
+		if content, ok := readCache(expected); ok {
+			config.Debugf("read module %s from the cache", source)
+			if moduleLock != nil {
+				if err := moduleLock.add(source, expected); err != nil {
+					return nil, "", err
+				}
+			}
+			return content, source, nil
+		}
+	}
+
+	content, err := fetch(source)
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to read module %s: %v", source, err)
+	}
+
+	sum := checksum(content)
+	if expected != "" && sum != expected {
+		return nil, "", fmt.Errorf("module %s has sha256 %s, but it is pinned to %s", source, sum, expected)
+	}
+
+	writeCache(sum, content)
+
+	if moduleLock != nil {
+		if err := moduleLock.add(source, sum); err != nil {
+			return nil, "", err
+		}
+	}
+
+	return content, source, nil
+}
+
+// checksum returns the hex encoded sha256 of content
+func checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// readCache reads a module from the cache, if it is there and it is not corrupted
+func readCache(sum string) ([]byte, bool) {
+	if ModuleCache == "" {
+		return nil, false
+	}
+	content, err := os.ReadFile(filepath.Join(ModuleCache, sum))
+	if err != nil || checksum(content) != sum {
+		return nil, false
+	}
+	return content, true
+}
+
+// writeCache saves a module in the cache. Errors are ignored,
+// since the module can be downloaded again.
+func writeCache(sum string, content []byte) {
+	if ModuleCache == "" {
+		return
+	}
+	if err := os.MkdirAll(ModuleCache, 0755); err != nil {
+		config.Debugf("unable to create module cache %s: %v", ModuleCache, err)
+		return
+	}
+	if err := os.WriteFile(filepath.Join(ModuleCache, sum), content, 0644); err != nil {
+		config.Debugf("unable to write module %s to the cache: %v", sum, err)
+	}
+}
+
+// fetch downloads a module from a remote source
+func fetch(source string) ([]byte, error) {
+	switch {
+	case strings.HasPrefix(source, "https://"):
+		resp, err := http.Get(source)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("%s", resp.Status)
+		}
+		return io.ReadAll(resp.Body)
+	case strings.HasPrefix(source, "s3://"):
+		bucket, key, found := strings.Cut(strings.TrimPrefix(source, "s3://"), "/")
+		if !found || key == "" {
+			return nil, fmt.Errorf("expected s3://<bucket>/<key>")
+		}
+		return s3.GetObject(bucket, key)
+	case strings.HasPrefix(source, "git::"):
+		g, err := parseGit(source)
+		if err != nil {
+			return nil, err
+		}
+		return fetchGit(g)
+	}
+
+	return nil, fmt.Errorf("unknown module source")
+}
+
+// fetchGit reads a file from a git repository with the git command.
+// Only the commit for the ref is fetched.
+func fetchGit(g gitSource) ([]byte, error) {
+	dir, err := os.MkdirTemp("", "rain-module-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	ref := g.ref
+	if ref == "" {
+		ref = "HEAD"
+	}
+
+	commands := [][]string{
+		{"init", "--quiet"},
+		{"fetch", "--quiet", "--depth", "1", "--", g.repo, ref},
+		{"checkout", "--quiet", "FETCH_HEAD"},
+	}
+	for _, args := range commands {
+		cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			return nil, fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(string(out)))
+		}
+	}
+
+	// The file can be a symlink to somewhere outside of the checkout
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return nil, err
+	}
+	file, err := filepath.EvalSymlinks(filepath.Join(root, filepath.FromSlash(g.path)))
+	if err != nil {
+		return nil, err
+	}
+	if rel, err := filepath.Rel(root, file); err != nil || !isLocalPath(filepath.ToSlash(rel)) {
+		return nil, fmt.Errorf("%s is outside of the repository", g.path)
+	}
+
+	return os.ReadFile(file)
+}
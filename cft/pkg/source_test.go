@@ -0,0 +1,200 @@
+package pkg
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseGit(t *testing.T) {
+	cases := map[string]gitSource{
+		"git::https://example.com/org/repo.git//modules/vpc.yaml?ref=v1.2.0": {
+			repo: "https://example.com/org/repo.git", path: "modules/vpc.yaml", ref: "v1.2.0"},
+		"git::git@example.com:org/repo.git//vpc.yaml": {
+			repo: "git@example.com:org/repo.git", path: "vpc.yaml"},
+		"git::/tmp/repo//a/b.yaml?ref=main": {
+			repo: "/tmp/repo", path: "a/b.yaml", ref: "main"},
+	}
+
+	for source, expected := range cases {
+		g, err := parseGit(source)
+		if err != nil {
+			t.Errorf("%s: %v", source, err)
+			continue
+		}
+		if g != expected {
+			t.Errorf("%s: expected %+v, got %+v", source, expected, g)
+		}
+		if g.String() != source {
+			t.Errorf("%s: got %s back", source, g.String())
+		}
+	}
+
+	for _, source := range []string{
+		"git::https://example.com/org/repo.git",
+		"git::https://example.com/r.git//../../etc/passwd",
+		"git::https://example.com/r.git//a/../../b.yaml",
+		"git::https://example.com/r.git///etc/passwd",
+		"git::--upload-pack=touch /tmp/x//a.yaml",
+		"git::https://example.com/r.git//a.yaml?ref=--upload-pack=x",
+	} {
+		if _, err := parseGit(source); err == nil {
+			t.Errorf("%s: expected an error", source)
+		}
+	}
+}
+
+func TestFetchGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	outside := filepath.Join(t.TempDir(), "secret.yaml")
+	if err := os.WriteFile(outside, []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	repo := t.TempDir()
+	module := "Resources:\n  Bucket:\n    Type: AWS::S3::Bucket\n"
+	if err := os.WriteFile(filepath.Join(repo, "bucket.yaml"), []byte(module), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(repo, "link.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"add", "."},
+		{"-c", "user.name=rain", "-c", "user.email=rain@example.com", "commit", "--quiet", "-m", "modules"},
+	} {
+		cmd := exec.Command("git", append([]string{"-C", repo}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v: %s", args[0], err, out)
+		}
+	}
+
+	content, err := fetchGit(gitSource{repo: repo, path: "bucket.yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != module {
+		t.Errorf("unexpected module %q", content)
+	}
+
+	if _, err := fetchGit(gitSource{repo: repo, path: "link.yaml"}); err == nil {
+		t.Error("expected an error for a symlink outside of the repository")
+	}
+}
+
+func TestSplitPin(t *testing.T) {
+	cases := map[string][2]string{
+		"https://example.com/m.yaml":                   {"https://example.com/m.yaml", ""},
+		"https://example.com/m.yaml?sha256=ABC":        {"https://example.com/m.yaml", "abc"},
+		"git::/repo//m.yaml?ref=v1&sha256=abc":         {"git::/repo//m.yaml?ref=v1", "abc"},
+		"s3://bucket/m.yaml?versionId=2&sha256=abc":    {"s3://bucket/m.yaml?versionId=2", "abc"},
+		"https://example.com/m.yaml?token=x":           {"https://example.com/m.yaml?token=x", ""},
+		"git::https://example.com/r.git//m.yaml?ref=1": {"git::https://example.com/r.git//m.yaml?ref=1", ""},
+	}
+
+	for uri, expected := range cases {
+		source, sum, err := splitPin(uri)
+		if err != nil {
+			t.Errorf("%s: %v", uri, err)
+			continue
+		}
+		if source != expected[0] || sum != expected[1] {
+			t.Errorf("%s: expected %v, got %s %s", uri, expected, source, sum)
+		}
+	}
+}
+
+func TestResolveSource(t *testing.T) {
+	cases := []struct {
+		uri, root, expected string
+	}{
+		{"./inner.yaml", "modules", "./inner.yaml"},
+		{"./inner.yaml", "git::/repo//modules?ref=v1", "git::/repo//modules/inner.yaml?ref=v1"},
+		{"../shared/a.yaml", "https://example.com/modules/vpc", "https://example.com/modules/shared/a.yaml"},
+		{"b.yaml", "s3://bucket/modules", "s3://bucket/modules/b.yaml"},
+		{"s3://other/c.yaml", "https://example.com/modules", "s3://other/c.yaml"},
+	}
+
+	for _, c := range cases {
+		source, err := resolveSource(c.uri, c.root)
+		if err != nil {
+			t.Errorf("%s in %s: %v", c.uri, c.root, err)
+			continue
+		}
+		if source != c.expected {
+			t.Errorf("%s in %s: expected %s, got %s", c.uri, c.root, c.expected, source)
+		}
+	}
+
+	dir, err := remoteDir("git::/repo//modules/vpc.yaml?ref=v1")
+	if err != nil || dir != "git::/repo//modules?ref=v1" {
+		t.Errorf("unexpected directory %s: %v", dir, err)
+	}
+}
+
+func TestReadRemote(t *testing.T) {
+	module := "Resources:\n  Bucket:\n    Type: AWS::S3::Bucket\n"
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(module))
+	}))
+
+	transport := http.DefaultTransport
+	http.DefaultTransport = server.Client().Transport
+	defer func() { http.DefaultTransport = transport }()
+
+	cache := ModuleCache
+	ModuleCache = t.TempDir()
+	defer func() { ModuleCache = cache }()
+
+	defer func() { moduleLock = nil }()
+
+	source := server.URL + "/bucket.yaml"
+	sum := checksum([]byte(module))
+
+	// The first read adds the module to the lock and the cache
+	moduleLock = NewLock()
+	content, _, err := readRemote(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != module || moduleLock.Modules[source] != sum || !moduleLock.Changed() {
+		t.Fatalf("unexpected lock %v", moduleLock.Modules)
+	}
+
+	lockPath := filepath.Join(t.TempDir(), LockFile)
+	if err := moduleLock.Write(lockPath); err != nil {
+		t.Fatal(err)
+	}
+
+	// Once the module is pinned, it is read from the cache
+	server.Close()
+	moduleLock, err = ReadLock(lockPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	moduleLock.Frozen = true
+	if _, _, err := readRemote(source); err != nil {
+		t.Errorf("expected the module to be read from the cache: %v", err)
+	}
+	if moduleLock.Changed() {
+		t.Error("expected the lock to be unchanged")
+	}
+
+	// A pin in the source has to match the lock
+	if _, _, err := readRemote(source + "?sha256=" + strings.Repeat("0", 64)); err == nil {
+		t.Error("expected an error for a sha256 that does not match the lock")
+	}
+
+	// Frozen locks can't have new modules
+	if _, _, err := readRemote(server.URL + "/other.yaml"); err == nil || !strings.Contains(err.Error(), "not in rain.lock") {
+		t.Errorf("expected an error for a module that is not in the lock, got %v", err)
+	}
+}
@@ -0,0 +1,244 @@
+package sam
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws-cloudformation/rain/cft"
+	"github.com/aws-cloudformation/rain/internal/node"
+	"github.com/aws-cloudformation/rain/internal/s11n"
+	"gopkg.in/yaml.v3"
+)
+
+// ImplicitApi is the logical id of the rest api that SAM creates
+// for Api events that don't have a RestApiId
+const ImplicitApi = "ServerlessRestApi"
+
+// restApiProperties are copied to the rest api as they are
+var restApiProperties = []string{
+	"BinaryMediaTypes",
+	"Description",
+	"DisableExecuteApiEndpoint",
+	"FailOnWarnings",
+	"MinimumCompressionSize",
+	"Mode",
+	"Name",
+}
+
+// stageProperties are copied to the stage as they are
+var stageProperties = []string{
+	"AccessLogSetting",
+	"CacheClusterEnabled",
+	"CacheClusterSize",
+	"CanarySetting",
+	"MethodSettings",
+	"TracingEnabled",
+	"Variables",
+}
+
+// apiSAMProperties are the serverless api properties that are translated
+var apiSAMProperties = []string{
+	"DefinitionBody",
+	"DefinitionUri",
+	"EndpointConfiguration",
+	"OpenApiVersion",
+	"StageName",
+	"Tags",
+}
+
+// restApi is a rest api that function events can add paths to
+type restApi struct {
+	r *cft.Resource
+
+	// implicit is true if the api is not in the template
+	implicit bool
+
+	// body is the OpenAPI definition, or nil if bodyS3 is set
+	body   *yaml.Node
+	bodyS3 *yaml.Node
+}
+
+// newRestApi reads an AWS::Serverless::Api
+func newRestApi(r *cft.Resource) (*restApi, error) {
+	props := r.Properties
+	if err := only(props, append(append(restApiProperties, stageProperties...), apiSAMProperties...)...); err != nil {
+		return nil, err
+	}
+	if props["StageName"] == nil {
+		return nil, fmt.Errorf("missing StageName")
+	}
+
+	a := &restApi{r: r}
+
+	switch {
+	case props["DefinitionBody"] != nil:
+		a.body = props["DefinitionBody"]
+	case props["DefinitionUri"] != nil:
+		location, err := s3Location(props["DefinitionUri"], "Bucket", "Key", "Version")
+		if err != nil {
+			return nil, fmt.Errorf("DefinitionUri: %v", err)
+		}
+		a.bodyS3 = location
+	default:
+		a.body = definition(props["OpenApiVersion"])
+	}
+
+	return a, nil
+}
+
+// implicitApi returns the rest api for Api events without a RestApiId,
+// with Globals applied the same way as for the apis in the template
+func (tr *translator) implicitApi() (*restApi, error) {
+	r := &cft.Resource{
+		LogicalId:  ImplicitApi,
+		Type:       "AWS::Serverless::Api",
+		Properties: map[string]*yaml.Node{"StageName": str("Prod")},
+	}
+	tr.applyGlobals("Api", r)
+
+	a, err := newRestApi(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", ImplicitApi, err)
+	}
+	a.implicit = true
+
+	return a, nil
+}
+
+// definition returns an empty OpenAPI definition
+func definition(version *yaml.Node) *yaml.Node {
+	d := mapping()
+	if version != nil && strings.HasPrefix(version.Value, "3") {
+		d.Content = append(d.Content, str("openapi"), str("3.0.1"))
+	} else {
+		d.Content = append(d.Content, str("swagger"), str("2.0"))
+	}
+	d.Content = append(d.Content,
+		str("info"), mapping("version", str("1.0"), "title", ref("AWS::StackName")),
+		str("paths"), mapping())
+	return d
+}
+
+// addPath adds an operation that invokes the function at uri to the api's definition
+func (a *restApi) addPath(path string, method string, uri string) error {
+	if a.body == nil || !isPlainMap(a.body) {
+		return fmt.Errorf("unable to add %s %s to the definition of %s", method, path, a.r.LogicalId)
+	}
+
+	_, paths, _ := s11n.GetMapValue(a.body, "paths")
+	if paths == nil {
+		paths = mapping()
+		a.body.Content = append(a.body.Content, str("paths"), paths)
+	}
+	_, p, _ := s11n.GetMapValue(paths, path)
+	if p == nil {
+		p = mapping()
+		paths.Content = append(paths.Content, str(path), p)
+	}
+
+	op := strings.ToLower(method)
+	if op == "any" {
+		op = "x-amazon-apigateway-any-method"
+	}
+	if _, existing, _ := s11n.GetMapValue(p, op); existing != nil {
+		return fmt.Errorf("%s %s is already defined in %s", method, path, a.r.LogicalId)
+	}
+
+	p.Content = append(p.Content, str(op), mapping(
+		"x-amazon-apigateway-integration", mapping(
+			"type", str("aws_proxy"),
+			"httpMethod", str("POST"),
+			"uri", sub("arn:${AWS::Partition}:apigateway:${AWS::Region}:lambda:path/2015-03-31/functions/"+uri+"/invocations"),
+		),
+		"responses", mapping(),
+	))
+
+	return nil
+}
+
+// writeApi replaces the serverless api with a rest api, a deployment and a stage
+func (tr *translator) writeApi(a *restApi) error {
+	sam := a.r.Properties
+	id := a.r.LogicalId
+
+	props := make(map[string]*yaml.Node)
+	for _, name := range restApiProperties {
+		if v, ok := sam[name]; ok {
+			props[name] = v
+		}
+	}
+	if a.body != nil {
+		props["Body"] = a.body
+	} else {
+		props["BodyS3Location"] = a.bodyS3
+	}
+
+	if ec := sam["EndpointConfiguration"]; ec != nil {
+		if isPlainMap(ec) {
+			c := mapping()
+			if _, t, _ := s11n.GetMapValue(ec, "Type"); t != nil {
+				c.Content = append(c.Content, str("Types"), seq(t))
+			}
+			if _, ids, _ := s11n.GetMapValue(ec, "VPCEndpointIds"); ids != nil {
+				c.Content = append(c.Content, str("VpcEndpointIds"), ids)
+			}
+			props["EndpointConfiguration"] = c
+		} else {
+			props["EndpointConfiguration"] = mapping("Types", seq(ec))
+		}
+	}
+
+	if a.implicit {
+		if _, err := tr.add(a.r, id, "AWS::ApiGateway::RestApi", props); err != nil {
+			return err
+		}
+	} else {
+		a.r.Type = "AWS::ApiGateway::RestApi"
+		a.r.Properties = props
+	}
+
+	// A new deployment is needed each time the definition changes
+	h := hash(props["Body"])
+	if a.body == nil {
+		h = hash(a.bodyS3)
+	}
+	deploymentId := id + "Deployment" + h
+	_, err := tr.add(a.r, deploymentId, "AWS::ApiGateway::Deployment", map[string]*yaml.Node{
+		"RestApiId":   ref(id),
+		"Description": str("RestApi deployment id: " + h),
+	})
+	if err != nil {
+		return err
+	}
+
+	stageName := sam["StageName"]
+	stageId := id + "Stage"
+	if stageName.Kind == yaml.ScalarNode {
+		stageId = id + alphanumeric(stageName.Value) + "Stage"
+	}
+	stage := map[string]*yaml.Node{
+		"DeploymentId": ref(deploymentId),
+		"RestApiId":    ref(id),
+		"StageName":    node.Clone(stageName),
+	}
+	for _, name := range stageProperties {
+		if v, ok := sam[name]; ok {
+			stage[name] = v
+		}
+	}
+	if sam["Tags"] != nil {
+		t, err := tags(sam["Tags"])
+		if err != nil {
+			return err
+		}
+		stage["Tags"] = t
+	}
+	if _, err := tr.add(a.r, stageId, "AWS::ApiGateway::Stage", stage); err != nil {
+		return err
+	}
+
+	tr.refs[id+".Stage"] = stageId
+	tr.refs[id+".Deployment"] = deploymentId
+
+	return nil
+}
@@ -0,0 +1,318 @@
+package sam
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/aws-cloudformation/rain/cft"
+	"github.com/aws-cloudformation/rain/internal/s11n"
+	"gopkg.in/yaml.v3"
+)
+
+// eventSourceMappingProperties are copied from SQS, Kinesis
+// and DynamoDB events to the event source mapping
+var eventSourceMappingProperties = []string{
+	"BatchSize",
+	"BisectBatchOnFunctionError",
+	"DestinationConfig",
+	"Enabled",
+	"FilterCriteria",
+	"FunctionResponseTypes",
+	"KmsKeyArn",
+	"MaximumBatchingWindowInSeconds",
+	"MaximumRecordAgeInSeconds",
+	"MaximumRetryAttempts",
+	"MetricsConfig",
+	"ParallelizationFactor",
+	"ScalingConfig",
+	"StartingPosition",
+	"StartingPositionTimestamp",
+	"TumblingWindowInSeconds",
+}
+
+// streamPolicies are the managed policies that let a function read from an event source
+var streamPolicies = map[string]string{
+	"SQS":      "service-role/AWSLambdaSQSQueueExecutionRole",
+	"Kinesis":  "service-role/AWSLambdaKinesisExecutionRole",
+	"DynamoDB": "service-role/AWSLambdaDynamoDBExecutionRole",
+}
+
+// event translates one of a function's Events
+func (tr *translator) event(f *function, name string, ev *yaml.Node) error {
+	_, typ, _ := s11n.GetMapValue(ev, "Type")
+	if typ == nil {
+		return fmt.Errorf("missing Type")
+	}
+
+	props := make(map[string]*yaml.Node)
+	_, p, _ := s11n.GetMapValue(ev, "Properties")
+	if p != nil {
+		if !isPlainMap(p) {
+			return fmt.Errorf("expected Properties to be a mapping")
+		}
+		for i := 0; i+1 < len(p.Content); i += 2 {
+			props[p.Content[i].Value] = p.Content[i+1]
+		}
+	}
+
+	id := f.r.LogicalId + name
+
+	switch typ.Value {
+	case "Api":
+		return tr.apiEvent(f, id, props)
+	case "S3":
+		return tr.s3Event(f, id, props)
+	case "SNS":
+		return tr.snsEvent(f, id, props)
+	case "SQS", "Kinesis", "DynamoDB":
+		return tr.streamEvent(f, id, typ.Value, props)
+	case "Schedule", "CloudWatchEvent", "EventBridgeRule":
+		return tr.ruleEvent(f, id, typ.Value, props)
+	}
+
+	return fmt.Errorf("event type %s is not supported", typ.Value)
+}
+
+// permission adds a permission for a service to invoke the function
+func (tr *translator) permission(f *function, id string, principal string, sourceArn *yaml.Node) (*cft.Resource, error) {
+	props := map[string]*yaml.Node{
+		"Action":       str("lambda:InvokeFunction"),
+		"FunctionName": f.name(),
+		"Principal":    str(principal),
+	}
+	if sourceArn != nil {
+		props["SourceArn"] = sourceArn
+	}
+	return tr.add(f.r, id, "AWS::Lambda::Permission", props)
+}
+
+// apiEvent adds a path to a rest api that invokes the function
+func (tr *translator) apiEvent(f *function, id string, props map[string]*yaml.Node) error {
+	if err := only(props, "Path", "Method", "RestApiId"); err != nil {
+		return err
+	}
+
+	path, method := props["Path"], props["Method"]
+	if path == nil || method == nil || path.Kind != yaml.ScalarNode || method.Kind != yaml.ScalarNode {
+		return fmt.Errorf("Path and Method must be strings")
+	}
+
+	apiId := ImplicitApi
+	if props["RestApiId"] != nil {
+		apiId = refName(props["RestApiId"])
+		if _, ok := tr.apis[apiId]; !ok {
+			return fmt.Errorf("RestApiId must be a Ref to an AWS::Serverless::Api in the template")
+		}
+	} else if _, ok := tr.apis[apiId]; !ok {
+		a, err := tr.implicitApi()
+		if err != nil {
+			return err
+		}
+		tr.apis[apiId] = a
+	}
+
+	if err := tr.apis[apiId].addPath(path.Value, method.Value, f.subArn()); err != nil {
+		return err
+	}
+
+	// Path parameters match anything
+	arnPath := path.Value
+	for strings.Contains(arnPath, "{") {
+		start := strings.Index(arnPath, "{")
+		end := strings.Index(arnPath[start:], "}")
+		if end < 0 {
+			break
+		}
+		arnPath = arnPath[:start] + "*" + arnPath[start+end+1:]
+	}
+	arnMethod := strings.ToUpper(method.Value)
+	if arnMethod == "ANY" {
+		arnMethod = "*"
+	}
+
+	_, err := tr.permission(f, id+"Permission", "apigateway.amazonaws.com",
+		sub(fmt.Sprintf("arn:${AWS::Partition}:execute-api:${AWS::Region}:${AWS::AccountId}:${%s}/*/%s%s",
+			apiId, arnMethod, arnPath)))
+	return err
+}
+
+// s3Event adds a notification to a bucket in the template
+func (tr *translator) s3Event(f *function, id string, props map[string]*yaml.Node) error {
+	if err := only(props, "Bucket", "Events", "Filter"); err != nil {
+		return err
+	}
+
+	var bucket *cft.Resource
+	if props["Bucket"] != nil {
+		bucket = tr.m.Resources[refName(props["Bucket"])]
+	}
+	if bucket == nil || bucket.Type != "AWS::S3::Bucket" {
+		return fmt.Errorf("Bucket must be a Ref to an AWS::S3::Bucket in the template")
+	}
+	events := props["Events"]
+	if events == nil {
+		return fmt.Errorf("missing Events")
+	}
+
+	// The bucket can't refer to itself, so the account is used instead of the source ARN
+	permission, err := tr.permission(f, id+"Permission", "s3.amazonaws.com", nil)
+	if err != nil {
+		return err
+	}
+	permission.Properties["SourceAccount"] = ref("AWS::AccountId")
+
+	nc := bucket.Properties["NotificationConfiguration"]
+	if nc == nil {
+		nc = mapping()
+		bucket.Properties["NotificationConfiguration"] = nc
+	}
+	_, configs, _ := s11n.GetMapValue(nc, "LambdaConfigurations")
+	if configs == nil {
+		configs = seq()
+		nc.Content = append(nc.Content, str("LambdaConfigurations"), configs)
+	}
+
+	items := []*yaml.Node{events}
+	if events.Kind == yaml.SequenceNode {
+		items = events.Content
+	}
+	for _, e := range items {
+		c := mapping("Event", e, "Function", f.arn())
+		if filter := props["Filter"]; filter != nil {
+			c.Content = append(c.Content, str("Filter"), filter)
+		}
+		configs.Content = append(configs.Content, c)
+	}
+
+	// The notification is checked when the bucket is created or updated
+	if !slices.Contains(bucket.DependsOn, permission.LogicalId) {
+		bucket.DependsOn = append(bucket.DependsOn, permission.LogicalId)
+	}
+
+	return nil
+}
+
+// snsEvent subscribes the function to a topic
+func (tr *translator) snsEvent(f *function, id string, props map[string]*yaml.Node) error {
+	if err := only(props, "Topic", "FilterPolicy", "FilterPolicyScope", "Region", "RedrivePolicy"); err != nil {
+		return err
+	}
+
+	topic := props["Topic"]
+	if topic == nil {
+		return fmt.Errorf("missing Topic")
+	}
+
+	subscription := map[string]*yaml.Node{
+		"Endpoint": f.arn(),
+		"Protocol": str("lambda"),
+		"TopicArn": topic,
+	}
+	for _, name := range []string{"FilterPolicy", "FilterPolicyScope", "Region", "RedrivePolicy"} {
+		if v, ok := props[name]; ok {
+			subscription[name] = v
+		}
+	}
+	if _, err := tr.add(f.r, id, "AWS::SNS::Subscription", subscription); err != nil {
+		return err
+	}
+
+	_, err := tr.permission(f, id+"Permission", "sns.amazonaws.com", topic)
+	return err
+}
+
+// streamEvent adds an event source mapping for a queue or a stream
+func (tr *translator) streamEvent(f *function, id string, typeName string, props map[string]*yaml.Node) error {
+	source := "Stream"
+	if typeName == "SQS" {
+		source = "Queue"
+	}
+	if err := only(props, append(eventSourceMappingProperties, source)...); err != nil {
+		return err
+	}
+	if props[source] == nil {
+		return fmt.Errorf("missing %s", source)
+	}
+
+	esm := map[string]*yaml.Node{
+		"EventSourceArn": props[source],
+		"FunctionName":   f.name(),
+	}
+	for _, name := range eventSourceMappingProperties {
+		if v, ok := props[name]; ok {
+			esm[name] = v
+		}
+	}
+
+	r, err := tr.add(f.r, id, "AWS::Lambda::EventSourceMapping", esm)
+	if err != nil {
+		return err
+	}
+
+	// Lambda checks that the function can read from the source
+	f.addManagedPolicy(awsManagedPolicy(streamPolicies[typeName]))
+	f.needsRole = append(f.needsRole, r)
+
+	return nil
+}
+
+// ruleEvent adds an EventBridge rule that invokes the function
+func (tr *translator) ruleEvent(f *function, id string, typeName string, props map[string]*yaml.Node) error {
+	rule := make(map[string]*yaml.Node)
+
+	if typeName == "Schedule" {
+		if err := only(props, "Schedule", "Input", "Enabled", "State", "Name", "Description"); err != nil {
+			return err
+		}
+		if props["Schedule"] == nil {
+			return fmt.Errorf("missing Schedule")
+		}
+		rule["ScheduleExpression"] = props["Schedule"]
+	} else {
+		if err := only(props, "Pattern", "EventBusName", "Input", "InputPath", "State", "RuleName"); err != nil {
+			return err
+		}
+		if props["Pattern"] == nil {
+			return fmt.Errorf("missing Pattern")
+		}
+		rule["EventPattern"] = props["Pattern"]
+	}
+
+	for from, to := range map[string]string{
+		"Name":         "Name",
+		"RuleName":     "Name",
+		"Description":  "Description",
+		"EventBusName": "EventBusName",
+		"State":        "State",
+	} {
+		if v, ok := props[from]; ok {
+			rule[to] = v
+		}
+	}
+	if enabled := props["Enabled"]; enabled != nil && rule["State"] == nil {
+		if enabled.Kind != yaml.ScalarNode {
+			return fmt.Errorf("expected Enabled to be true or false")
+		}
+		if enabled.Value == "false" {
+			rule["State"] = str("DISABLED")
+		} else {
+			rule["State"] = str("ENABLED")
+		}
+	}
+
+	target := mapping("Arn", f.arn(), "Id", str(id+"LambdaTarget"))
+	for _, name := range []string{"Input", "InputPath"} {
+		if v, ok := props[name]; ok {
+			target.Content = append(target.Content, str(name), v)
+		}
+	}
+	rule["Targets"] = seq(target)
+
+	if _, err := tr.add(f.r, id, "AWS::Events::Rule", rule); err != nil {
+		return err
+	}
+
+	_, err := tr.permission(f, id+"Permission", "events.amazonaws.com", getAtt(id, "Arn"))
+	return err
+}
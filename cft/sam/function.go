@@ -0,0 +1,374 @@
+package sam
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/aws-cloudformation/rain/cft"
+	"github.com/aws-cloudformation/rain/internal/node"
+	"github.com/aws-cloudformation/rain/internal/s11n"
+	"gopkg.in/yaml.v3"
+)
+
+// functionProperties are copied to the Lambda function as they are
+var functionProperties = []string{
+	"Architectures",
+	"CodeSigningConfigArn",
+	"Description",
+	"Environment",
+	"EphemeralStorage",
+	"FileSystemConfigs",
+	"FunctionName",
+	"Handler",
+	"ImageConfig",
+	"KmsKeyArn",
+	"Layers",
+	"LoggingConfig",
+	"MemorySize",
+	"PackageType",
+	"ReservedConcurrentExecutions",
+	"Runtime",
+	"RuntimeManagementConfig",
+	"SnapStart",
+	"Timeout",
+	"VpcConfig",
+}
+
+// functionSAMProperties are the serverless function properties that
+// are translated, or that SAM uses to create other resources
+var functionSAMProperties = []string{
+	"AssumeRolePolicyDocument",
+	"AutoPublishAlias",
+	"AutoPublishCodeSha256",
+	"CodeUri",
+	"DeadLetterQueue",
+	"Events",
+	"ImageUri",
+	"InlineCode",
+	"PermissionsBoundary",
+	"Policies",
+	"ProvisionedConcurrencyConfig",
+	"Role",
+	"RolePath",
+	"Tags",
+	"Tracing",
+	"VersionDescription",
+}
+
+// function holds what the events of a serverless function add to it
+type function struct {
+	r *cft.Resource
+
+	// alias is the logical id of the function's alias, if it has AutoPublishAlias
+	alias string
+
+	// managedPolicies and inlinePolicies are added to the generated role
+	managedPolicies []*yaml.Node
+	inlinePolicies  []*yaml.Node
+
+	// needsRole are resources that have to wait for the generated role
+	needsRole []*cft.Resource
+}
+
+// arn returns the ARN that events invoke
+func (f *function) arn() *yaml.Node {
+	if f.alias != "" {
+		return ref(f.alias)
+	}
+	return getAtt(f.r.LogicalId, "Arn")
+}
+
+// subArn returns the ARN that events invoke, for use in a Fn::Sub
+func (f *function) subArn() string {
+	if f.alias != "" {
+		return "${" + f.alias + "}"
+	}
+	return "${" + f.r.LogicalId + ".Arn}"
+}
+
+// name returns the value of FunctionName properties that refer to the function
+func (f *function) name() *yaml.Node {
+	if f.alias != "" {
+		return ref(f.alias)
+	}
+	return ref(f.r.LogicalId)
+}
+
+// addManagedPolicy adds a policy ARN to the generated role, once
+func (f *function) addManagedPolicy(arn *yaml.Node) {
+	for _, p := range f.managedPolicies {
+		if node.ToSJson(p) == node.ToSJson(arn) {
+			return
+		}
+	}
+	f.managedPolicies = append(f.managedPolicies, arn)
+}
+
+// awsManagedPolicy returns the ARN of an AWS managed policy like service-role/AWSLambdaRole
+func awsManagedPolicy(name string) *yaml.Node {
+	return sub("arn:${AWS::Partition}:iam::aws:policy/" + name)
+}
+
+// function translates an AWS::Serverless::Function
+func (tr *translator) function(r *cft.Resource) error {
+	tr.applyGlobals("Function", r)
+
+	sam := r.Properties
+	if err := only(sam, append(functionProperties, functionSAMProperties...)...); err != nil {
+		return err
+	}
+
+	f := &function{r: r}
+	props := make(map[string]*yaml.Node)
+	for _, name := range functionProperties {
+		if v, ok := sam[name]; ok {
+			props[name] = v
+		}
+	}
+
+	switch {
+	case sam["CodeUri"] != nil:
+		code, err := s3Location(sam["CodeUri"], "S3Bucket", "S3Key", "S3ObjectVersion")
+		if err != nil {
+			return fmt.Errorf("CodeUri: %v", err)
+		}
+		props["Code"] = code
+	case sam["InlineCode"] != nil:
+		props["Code"] = mapping("ZipFile", sam["InlineCode"])
+	case sam["ImageUri"] != nil:
+		props["Code"] = mapping("ImageUri", sam["ImageUri"])
+		if props["PackageType"] == nil {
+			props["PackageType"] = str("Image")
+		}
+	default:
+		return fmt.Errorf("one of CodeUri, InlineCode or ImageUri is required")
+	}
+
+	f.addManagedPolicy(awsManagedPolicy("service-role/AWSLambdaBasicExecutionRole"))
+
+	if tracing := sam["Tracing"]; tracing != nil {
+		props["TracingConfig"] = mapping("Mode", tracing)
+		if tracing.Value == "Active" {
+			f.addManagedPolicy(awsManagedPolicy("AWSXrayWriteOnlyAccess"))
+		}
+	}
+
+	if props["VpcConfig"] != nil {
+		f.addManagedPolicy(awsManagedPolicy("service-role/AWSLambdaVPCAccessExecutionRole"))
+	}
+
+	if dlq := sam["DeadLetterQueue"]; dlq != nil {
+		_, typ, _ := s11n.GetMapValue(dlq, "Type")
+		_, target, _ := s11n.GetMapValue(dlq, "TargetArn")
+		if typ == nil || target == nil {
+			return fmt.Errorf("DeadLetterQueue needs a Type and a TargetArn")
+		}
+		var action string
+		switch typ.Value {
+		case "SQS":
+			action = "sqs:SendMessage"
+		case "SNS":
+			action = "sns:Publish"
+		default:
+			return fmt.Errorf("DeadLetterQueue Type must be SQS or SNS")
+		}
+		props["DeadLetterConfig"] = mapping("TargetArn", target)
+		f.inlinePolicies = append(f.inlinePolicies, policyDocument(action, target))
+	}
+
+	t, err := tags(sam["Tags"], "lambda:createdBy", "SAM")
+	if err != nil {
+		return err
+	}
+	props["Tags"] = t
+
+	r.Type = "AWS::Lambda::Function"
+	r.Properties = props
+
+	if err := tr.alias(f, sam); err != nil {
+		return err
+	}
+
+	if events := sam["Events"]; events != nil {
+		if !isPlainMap(events) {
+			return fmt.Errorf("expected Events to be a mapping")
+		}
+		for i := 0; i+1 < len(events.Content); i += 2 {
+			name := events.Content[i].Value
+			if err := tr.event(f, name, events.Content[i+1]); err != nil {
+				return fmt.Errorf("Events/%s: %v", name, err)
+			}
+		}
+	}
+
+	// SAM ignores Policies when the function has its own role
+	if role := sam["Role"]; role != nil {
+		props["Role"] = role
+		return nil
+	}
+
+	return tr.role(f, sam)
+}
+
+// alias adds a version and an alias for a function with AutoPublishAlias
+func (tr *translator) alias(f *function, sam map[string]*yaml.Node) error {
+	name := sam["AutoPublishAlias"]
+	if name == nil {
+		if sam["ProvisionedConcurrencyConfig"] != nil {
+			return fmt.Errorf("ProvisionedConcurrencyConfig needs an AutoPublishAlias")
+		}
+		return nil
+	}
+	if name.Kind != yaml.ScalarNode {
+		return fmt.Errorf("expected AutoPublishAlias to be a string")
+	}
+
+	id := f.r.LogicalId
+
+	// A new version is published when the code changes
+	code := mapping("Code", f.r.Properties["Code"])
+	if sha := sam["AutoPublishCodeSha256"]; sha != nil {
+		node.SetMapValue(code, "Sha256", sha)
+	}
+	versionId := id + "Version" + hash(code)
+
+	versionProps := map[string]*yaml.Node{"FunctionName": ref(id)}
+	if d := sam["VersionDescription"]; d != nil {
+		versionProps["Description"] = d
+	}
+	version, err := tr.add(f.r, versionId, "AWS::Lambda::Version", versionProps)
+	if err != nil {
+		return err
+	}
+	version.DeletionPolicy = "Retain"
+
+	aliasId := id + "Alias" + alphanumeric(name.Value)
+	aliasProps := map[string]*yaml.Node{
+		"Name":            name,
+		"FunctionName":    ref(id),
+		"FunctionVersion": getAtt(versionId, "Version"),
+	}
+	if pc := sam["ProvisionedConcurrencyConfig"]; pc != nil {
+		aliasProps["ProvisionedConcurrencyConfig"] = pc
+	}
+	if _, err := tr.add(f.r, aliasId, "AWS::Lambda::Alias", aliasProps); err != nil {
+		return err
+	}
+
+	f.alias = aliasId
+	tr.refs[id+".Version"] = versionId
+	tr.refs[id+".Alias"] = aliasId
+
+	return nil
+}
+
+// role adds the execution role for a function without a Role
+func (tr *translator) role(f *function, sam map[string]*yaml.Node) error {
+	id := f.r.LogicalId + "Role"
+
+	if policies := sam["Policies"]; policies != nil {
+		items := []*yaml.Node{policies}
+		if policies.Kind == yaml.SequenceNode {
+			items = policies.Content
+		}
+		for _, p := range items {
+			switch {
+			case p.Kind == yaml.ScalarNode:
+				if strings.HasPrefix(p.Value, "arn:") {
+					f.addManagedPolicy(p)
+				} else {
+					f.addManagedPolicy(awsManagedPolicy(p.Value))
+				}
+			case !isPlainMap(p):
+				// Intrinsic functions are policy ARNs
+				f.addManagedPolicy(p)
+			case len(p.Content) == 2 && isPolicyTemplate(p.Content[0].Value):
+				doc, err := policyTemplate(p.Content[0].Value, p.Content[1])
+				if err != nil {
+					return fmt.Errorf("Policies: %v", err)
+				}
+				f.inlinePolicies = append(f.inlinePolicies, doc)
+			default:
+				if _, statement, _ := s11n.GetMapValue(p, "Statement"); statement == nil {
+					return fmt.Errorf("Policies: expected a policy ARN, a policy document, or a supported policy template")
+				}
+				f.inlinePolicies = append(f.inlinePolicies, p)
+			}
+		}
+	}
+
+	assume := sam["AssumeRolePolicyDocument"]
+	if assume == nil {
+		assume = mapping(
+			"Version", str("2012-10-17"),
+			"Statement", seq(mapping(
+				"Effect", str("Allow"),
+				"Principal", mapping("Service", seq(str("lambda.amazonaws.com"))),
+				"Action", seq(str("sts:AssumeRole")),
+			)),
+		)
+	}
+
+	t, err := tags(nil, "lambda:createdBy", "SAM")
+	if err != nil {
+		return err
+	}
+
+	props := map[string]*yaml.Node{
+		"AssumeRolePolicyDocument": assume,
+		"ManagedPolicyArns":        seq(f.managedPolicies...),
+		"Tags":                     t,
+	}
+	if len(f.inlinePolicies) > 0 {
+		inline := seq()
+		for i, doc := range f.inlinePolicies {
+			inline.Content = append(inline.Content, mapping(
+				"PolicyName", str(fmt.Sprintf("%sPolicy%d", id, i)),
+				"PolicyDocument", doc,
+			))
+		}
+		props["Policies"] = inline
+	}
+	if b := sam["PermissionsBoundary"]; b != nil {
+		props["PermissionsBoundary"] = b
+	}
+	if p := sam["RolePath"]; p != nil {
+		props["Path"] = p
+	}
+
+	if _, err := tr.add(f.r, id, "AWS::IAM::Role", props); err != nil {
+		return err
+	}
+	f.r.Properties["Role"] = getAtt(id, "Arn")
+
+	for _, r := range f.needsRole {
+		if !slices.Contains(r.DependsOn, id) {
+			r.DependsOn = append(r.DependsOn, id)
+		}
+	}
+
+	return nil
+}
+
+// policyDocument returns a policy document that allows one action on a resource
+func policyDocument(action string, resource *yaml.Node) *yaml.Node {
+	return mapping(
+		"Version", str("2012-10-17"),
+		"Statement", seq(mapping(
+			"Effect", str("Allow"),
+			"Action", str(action),
+			"Resource", resource,
+		)),
+	)
+}
+
+// alphanumeric removes anything that can't be in a logical id
+func alphanumeric(s string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return -1
+	}, s)
+}
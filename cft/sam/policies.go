@@ -0,0 +1,189 @@
+package sam
+
+import (
+	"fmt"
+
+	"github.com/aws-cloudformation/rain/internal/node"
+	"gopkg.in/yaml.v3"
+)
+
+// policyTemplates are the most common SAM policy templates.
+// Each template refers to its parameters with Ref.
+var policyTemplates = `
+S3ReadPolicy:
+  Statement:
+    - Effect: Allow
+      Action: [s3:GetObject, s3:ListBucket, s3:GetBucketLocation, s3:GetObjectVersion, s3:GetLifecycleConfiguration]
+      Resource:
+        - Fn::Sub: ["arn:${AWS::Partition}:s3:::${bucketName}", {bucketName: {Ref: BucketName}}]
+        - Fn::Sub: ["arn:${AWS::Partition}:s3:::${bucketName}/*", {bucketName: {Ref: BucketName}}]
+S3WritePolicy:
+  Statement:
+    - Effect: Allow
+      Action: [s3:PutObject, s3:PutObjectAcl, s3:PutLifecycleConfiguration]
+      Resource:
+        - Fn::Sub: ["arn:${AWS::Partition}:s3:::${bucketName}", {bucketName: {Ref: BucketName}}]
+        - Fn::Sub: ["arn:${AWS::Partition}:s3:::${bucketName}/*", {bucketName: {Ref: BucketName}}]
+S3CrudPolicy:
+  Statement:
+    - Effect: Allow
+      Action: [s3:GetObject, s3:ListBucket, s3:GetBucketLocation, s3:GetObjectVersion, s3:PutObject,
+        s3:PutObjectAcl, s3:GetLifecycleConfiguration, s3:PutLifecycleConfiguration, s3:DeleteObject]
+      Resource:
+        - Fn::Sub: ["arn:${AWS::Partition}:s3:::${bucketName}", {bucketName: {Ref: BucketName}}]
+        - Fn::Sub: ["arn:${AWS::Partition}:s3:::${bucketName}/*", {bucketName: {Ref: BucketName}}]
+DynamoDBReadPolicy:
+  Statement:
+    - Effect: Allow
+      Action: [dynamodb:GetItem, dynamodb:Scan, dynamodb:Query, dynamodb:BatchGetItem, dynamodb:DescribeTable]
+      Resource:
+        - Fn::Sub: ["arn:${AWS::Partition}:dynamodb:${AWS::Region}:${AWS::AccountId}:table/${tableName}", {tableName: {Ref: TableName}}]
+        - Fn::Sub: ["arn:${AWS::Partition}:dynamodb:${AWS::Region}:${AWS::AccountId}:table/${tableName}/index/*", {tableName: {Ref: TableName}}]
+DynamoDBWritePolicy:
+  Statement:
+    - Effect: Allow
+      Action: [dynamodb:PutItem, dynamodb:UpdateItem, dynamodb:BatchWriteItem]
+      Resource:
+        - Fn::Sub: ["arn:${AWS::Partition}:dynamodb:${AWS::Region}:${AWS::AccountId}:table/${tableName}", {tableName: {Ref: TableName}}]
+        - Fn::Sub: ["arn:${AWS::Partition}:dynamodb:${AWS::Region}:${AWS::AccountId}:table/${tableName}/index/*", {tableName: {Ref: TableName}}]
+DynamoDBCrudPolicy:
+  Statement:
+    - Effect: Allow
+      Action: [dynamodb:GetItem, dynamodb:DeleteItem, dynamodb:PutItem, dynamodb:Scan, dynamodb:Query, dynamodb:UpdateItem,
+        dynamodb:BatchWriteItem, dynamodb:BatchGetItem, dynamodb:DescribeTable, dynamodb:ConditionCheckItem]
+      Resource:
+        - Fn::Sub: ["arn:${AWS::Partition}:dynamodb:${AWS::Region}:${AWS::AccountId}:table/${tableName}", {tableName: {Ref: TableName}}]
+        - Fn::Sub: ["arn:${AWS::Partition}:dynamodb:${AWS::Region}:${AWS::AccountId}:table/${tableName}/index/*", {tableName: {Ref: TableName}}]
+SQSPollerPolicy:
+  Statement:
+    - Effect: Allow
+      Action: [sqs:ChangeMessageVisibility, sqs:ChangeMessageVisibilityBatch, sqs:DeleteMessage,
+        sqs:DeleteMessageBatch, sqs:GetQueueAttributes, sqs:ReceiveMessage]
+      Resource:
+        Fn::Sub: ["arn:${AWS::Partition}:sqs:${AWS::Region}:${AWS::AccountId}:${queueName}", {queueName: {Ref: QueueName}}]
+SQSSendMessagePolicy:
+  Statement:
+    - Effect: Allow
+      Action: [sqs:SendMessage*]
+      Resource:
+        Fn::Sub: ["arn:${AWS::Partition}:sqs:${AWS::Region}:${AWS::AccountId}:${queueName}", {queueName: {Ref: QueueName}}]
+SNSPublishMessagePolicy:
+  Statement:
+    - Effect: Allow
+      Action: [sns:Publish]
+      Resource:
+        Fn::Sub: ["arn:${AWS::Partition}:sns:${AWS::Region}:${AWS::AccountId}:${topicName}", {topicName: {Ref: TopicName}}]
+LambdaInvokePolicy:
+  Statement:
+    - Effect: Allow
+      Action: [lambda:InvokeFunction]
+      Resource:
+        Fn::Sub: ["arn:${AWS::Partition}:lambda:${AWS::Region}:${AWS::AccountId}:function:${functionName}*", {functionName: {Ref: FunctionName}}]
+SSMParameterReadPolicy:
+  Statement:
+    - Effect: Allow
+      Action: [ssm:DescribeParameters]
+      Resource: "*"
+    - Effect: Allow
+      Action: [ssm:GetParameters, ssm:GetParameter, ssm:GetParametersByPath]
+      Resource:
+        Fn::Sub: ["arn:${AWS::Partition}:ssm:${AWS::Region}:${AWS::AccountId}:parameter/${parameterName}", {parameterName: {Ref: ParameterName}}]
+AWSSecretsManagerGetSecretValuePolicy:
+  Statement:
+    - Effect: Allow
+      Action: [secretsmanager:GetSecretValue]
+      Resource: {Ref: SecretArn}
+KMSDecryptPolicy:
+  Statement:
+    - Effect: Allow
+      Action: [kms:Decrypt]
+      Resource:
+        Fn::Sub: ["arn:${AWS::Partition}:kms:${AWS::Region}:${AWS::AccountId}:key/${keyId}", {keyId: {Ref: KeyId}}]
+StepFunctionsExecutionPolicy:
+  Statement:
+    - Effect: Allow
+      Action: [states:StartExecution]
+      Resource:
+        Fn::Sub: ["arn:${AWS::Partition}:states:${AWS::Region}:${AWS::AccountId}:stateMachine:${stateMachineName}", {stateMachineName: {Ref: StateMachineName}}]
+EventBridgePutEventsPolicy:
+  Statement:
+    - Effect: Allow
+      Action: [events:PutEvents]
+      Resource:
+        Fn::Sub: ["arn:${AWS::Partition}:events:${AWS::Region}:${AWS::AccountId}:event-bus/${eventBusName}", {eventBusName: {Ref: EventBusName}}]
+CloudWatchPutMetricPolicy:
+  Statement:
+    - Effect: Allow
+      Action: [cloudwatch:PutMetricData]
+      Resource: "*"
+VPCAccessPolicy:
+  Statement:
+    - Effect: Allow
+      Action: [ec2:CreateNetworkInterface, ec2:DeleteNetworkInterface, ec2:DescribeNetworkInterfaces, ec2:DetachNetworkInterface]
+      Resource: "*"
+`
+
+// parsedPolicyTemplates is policyTemplates parsed into a mapping node
+var parsedPolicyTemplates = func() *yaml.Node {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(policyTemplates), &doc); err != nil {
+		panic(err)
+	}
+	return doc.Content[0]
+}()
+
+// isPolicyTemplate returns true if name is one of the supported policy templates
+func isPolicyTemplate(name string) bool {
+	for i := 0; i+1 < len(parsedPolicyTemplates.Content); i += 2 {
+		if parsedPolicyTemplates.Content[i].Value == name {
+			return true
+		}
+	}
+	return false
+}
+
+// policyTemplate returns the policy document for a SAM policy template,
+// with its parameters replaced by the values in params
+func policyTemplate(name string, params *yaml.Node) (*yaml.Node, error) {
+	var policy *yaml.Node
+	for i := 0; i+1 < len(parsedPolicyTemplates.Content); i += 2 {
+		if parsedPolicyTemplates.Content[i].Value == name {
+			policy = node.Clone(parsedPolicyTemplates.Content[i+1])
+		}
+	}
+	if policy == nil {
+		return nil, fmt.Errorf("policy template %s is not supported", name)
+	}
+
+	values := make(map[string]*yaml.Node)
+	if params != nil && params.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(params.Content); i += 2 {
+			values[params.Content[i].Value] = params.Content[i+1]
+		}
+	}
+
+	if err := replaceParams(policy, values); err != nil {
+		return nil, fmt.Errorf("policy template %s: %v", name, err)
+	}
+
+	return policy, nil
+}
+
+// replaceParams replaces each {Ref: Name} in n with values[Name]
+func replaceParams(n *yaml.Node, values map[string]*yaml.Node) error {
+	for i, c := range n.Content {
+		if c.Kind == yaml.MappingNode && len(c.Content) == 2 && c.Content[0].Value == "Ref" {
+			name := c.Content[1].Value
+			v, ok := values[name]
+			if !ok {
+				return fmt.Errorf("missing parameter %s", name)
+			}
+			n.Content[i] = node.Clone(v)
+			continue
+		}
+		if err := replaceParams(c, values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
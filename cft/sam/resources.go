@@ -0,0 +1,153 @@
+package sam
+
+import (
+	"fmt"
+
+	"github.com/aws-cloudformation/rain/cft"
+	"github.com/aws-cloudformation/rain/internal/s11n"
+	"gopkg.in/yaml.v3"
+)
+
+// attributeTypes maps SimpleTable key types to DynamoDB attribute types
+var attributeTypes = map[string]string{
+	"String": "S",
+	"Number": "N",
+	"Binary": "B",
+}
+
+// simpleTable translates an AWS::Serverless::SimpleTable into a DynamoDB table
+func (tr *translator) simpleTable(r *cft.Resource) error {
+	tr.applyGlobals("SimpleTable", r)
+
+	sam := r.Properties
+	if err := only(sam, "PrimaryKey", "ProvisionedThroughput", "TableName", "Tags",
+		"SSESpecification", "PointInTimeRecoverySpecification"); err != nil {
+		return err
+	}
+
+	keyName, keyType := str("id"), "S"
+	if pk := sam["PrimaryKey"]; pk != nil {
+		_, name, _ := s11n.GetMapValue(pk, "Name")
+		_, typ, _ := s11n.GetMapValue(pk, "Type")
+		if name == nil || typ == nil {
+			return fmt.Errorf("PrimaryKey needs a Name and a Type")
+		}
+		t, ok := attributeTypes[typ.Value]
+		if !ok {
+			return fmt.Errorf("PrimaryKey Type must be String, Number or Binary")
+		}
+		keyName, keyType = name, t
+	}
+
+	props := map[string]*yaml.Node{
+		"AttributeDefinitions": seq(mapping("AttributeName", keyName, "AttributeType", str(keyType))),
+		"KeySchema":            seq(mapping("AttributeName", keyName, "KeyType", str("HASH"))),
+	}
+	if pt := sam["ProvisionedThroughput"]; pt != nil {
+		props["ProvisionedThroughput"] = pt
+	} else {
+		props["BillingMode"] = str("PAY_PER_REQUEST")
+	}
+	for _, name := range []string{"TableName", "SSESpecification", "PointInTimeRecoverySpecification"} {
+		if v, ok := sam[name]; ok {
+			props[name] = v
+		}
+	}
+	if sam["Tags"] != nil {
+		t, err := tags(sam["Tags"])
+		if err != nil {
+			return err
+		}
+		props["Tags"] = t
+	}
+
+	r.Type = "AWS::DynamoDB::Table"
+	r.Properties = props
+
+	return nil
+}
+
+// layerVersion translates an AWS::Serverless::LayerVersion.
+// Unlike SAM, the logical id of the layer does not change with its content.
+func (tr *translator) layerVersion(r *cft.Resource) error {
+	tr.applyGlobals("LayerVersion", r)
+
+	sam := r.Properties
+	if err := only(sam, "ContentUri", "LayerName", "Description", "CompatibleRuntimes",
+		"CompatibleArchitectures", "LicenseInfo", "RetentionPolicy"); err != nil {
+		return err
+	}
+
+	if sam["ContentUri"] == nil {
+		return fmt.Errorf("missing ContentUri")
+	}
+	content, err := s3Location(sam["ContentUri"], "S3Bucket", "S3Key", "S3ObjectVersion")
+	if err != nil {
+		return fmt.Errorf("ContentUri: %v", err)
+	}
+
+	props := map[string]*yaml.Node{
+		"Content":   content,
+		"LayerName": str(r.LogicalId),
+	}
+	for _, name := range []string{"LayerName", "Description", "CompatibleRuntimes", "CompatibleArchitectures", "LicenseInfo"} {
+		if v, ok := sam[name]; ok {
+			props[name] = v
+		}
+	}
+
+	// Old versions are kept by default
+	if r.DeletionPolicy == "" {
+		r.DeletionPolicy = "Retain"
+		if policy := sam["RetentionPolicy"]; policy != nil {
+			switch policy.Value {
+			case "Retain", "Delete":
+				r.DeletionPolicy = policy.Value
+			default:
+				return fmt.Errorf("RetentionPolicy must be Retain or Delete")
+			}
+		}
+	}
+
+	r.Type = "AWS::Lambda::LayerVersion"
+	r.Properties = props
+
+	return nil
+}
+
+// application translates an AWS::Serverless::Application into a nested stack
+func (tr *translator) application(r *cft.Resource) error {
+	sam := r.Properties
+	if err := only(sam, "Location", "Parameters", "NotificationARNs", "Tags", "TimeoutInMinutes"); err != nil {
+		return err
+	}
+
+	location := sam["Location"]
+	if location == nil {
+		return fmt.Errorf("missing Location")
+	}
+	if location.Kind != yaml.ScalarNode {
+		return fmt.Errorf("Location must be a template URL, applications from the Serverless Application Repository are not supported")
+	}
+
+	props := map[string]*yaml.Node{
+		"TemplateURL": location,
+	}
+	for _, name := range []string{"Parameters", "NotificationARNs", "TimeoutInMinutes"} {
+		if v, ok := sam[name]; ok {
+			props[name] = v
+		}
+	}
+	if sam["Tags"] != nil {
+		t, err := tags(sam["Tags"])
+		if err != nil {
+			return err
+		}
+		props["Tags"] = t
+	}
+
+	r.Type = "AWS::CloudFormation::Stack"
+	r.Properties = props
+
+	return nil
+}
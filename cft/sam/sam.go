@@ -0,0 +1,419 @@
+// Package sam translates the AWS::Serverless transform into plain
+// CloudFormation on the client, so that rain can work with SAM templates
+// in commands that can't use transforms.
+//
+// Supported:
+//
+//	AWS::Serverless::Function, with its execution role, policies and policy
+//	templates, AutoPublishAlias, DeadLetterQueue, and Api, S3, SNS, SQS,
+//	Kinesis, DynamoDB, Schedule, CloudWatchEvent and EventBridgeRule events
+//	AWS::Serverless::Api, and the implicit ServerlessRestApi
+//	AWS::Serverless::SimpleTable
+//	AWS::Serverless::LayerVersion
+//	AWS::Serverless::Application, with a Location URL
+//	Globals for Function, Api, SimpleTable and LayerVersion
+//	References like MyApi.Stage, MyApi.Deployment, MyFunction.Alias and MyFunction.Version
+//
+// Anything else is an error, rather than a template that would
+// deploy differently than it would with the real transform.
+package sam
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/aws-cloudformation/rain/cft"
+	"github.com/aws-cloudformation/rain/internal/config"
+	"github.com/aws-cloudformation/rain/internal/node"
+	"github.com/aws-cloudformation/rain/internal/s11n"
+	"gopkg.in/yaml.v3"
+)
+
+// Transform is the name of the transform that Translate emulates
+const Transform = "AWS::Serverless-2016-10-31"
+
+// Globals is the name of the SAM section that holds properties for all resources of a type
+const Globals = "Globals"
+
+// globalSections are the sections of Globals that Translate applies
+var globalSections = []string{"Function", "Api", "SimpleTable", "LayerVersion"}
+
+// HasTransform returns true if the template declares the AWS::Serverless transform
+func HasTransform(t cft.Template) bool {
+	transform, err := t.GetSection(cft.Transform)
+	if err != nil {
+		return false
+	}
+
+	switch transform.Kind {
+	case yaml.ScalarNode:
+		return transform.Value == Transform
+	case yaml.SequenceNode:
+		for _, n := range transform.Content {
+			if n.Value == Transform {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+type translator struct {
+	m *cft.Model
+
+	// globals are the sections of Globals by resource type, like Function
+	globals map[string]*yaml.Node
+
+	// apis are the rest apis that function events add paths to, by logical id
+	apis map[string]*restApi
+
+	// refs maps SAM references like MyApi.Stage to the logical ids they refer to
+	refs map[string]string
+}
+
+// Translate returns a copy of the template with the AWS::Serverless transform
+// applied. Serverless resources are replaced with the resources that SAM would
+// create, Globals are merged into each resource and removed, and the transform
+// is removed from the Transform section.
+//
+// Code locations like CodeUri must already be packaged as S3 locations.
+func Translate(t cft.Template) (cft.Template, error) {
+	translated := cft.Template{Node: node.Clone(t.Node)}
+
+	m, err := translated.Model()
+	if err != nil {
+		return translated, err
+	}
+
+	tr := &translator{
+		m:       m,
+		globals: make(map[string]*yaml.Node),
+		apis:    make(map[string]*restApi),
+		refs:    make(map[string]string),
+	}
+
+	root := translated.Node.Content[0]
+	_, globals, _ := s11n.GetMapValue(root, Globals)
+	if globals != nil {
+		if globals.Kind != yaml.MappingNode {
+			return translated, fmt.Errorf("expected %s to be a mapping", Globals)
+		}
+		for i := 0; i+1 < len(globals.Content); i += 2 {
+			section := globals.Content[i].Value
+			if !slices.Contains(globalSections, section) {
+				return translated, fmt.Errorf("%s/%s is not supported", Globals, section)
+			}
+			tr.globals[section] = globals.Content[i+1]
+		}
+	}
+
+	names := m.Names(cft.Resources)
+
+	// Apis are written after the functions that add paths to them
+	for _, name := range names {
+		r := m.Resources[name]
+		if r.Type == "AWS::Serverless::Api" {
+			tr.applyGlobals("Api", r)
+			a, err := newRestApi(r)
+			if err != nil {
+				return translated, fmt.Errorf("%s: %v", name, err)
+			}
+			tr.apis[name] = a
+		}
+	}
+
+	count := 0
+	for _, name := range names {
+		r := m.Resources[name]
+		if strings.HasPrefix(r.Type, "AWS::Serverless::") {
+			count++
+		}
+		switch r.Type {
+		case "AWS::Serverless::Function":
+			err = tr.function(r)
+		case "AWS::Serverless::SimpleTable":
+			err = tr.simpleTable(r)
+		case "AWS::Serverless::LayerVersion":
+			err = tr.layerVersion(r)
+		case "AWS::Serverless::Application":
+			err = tr.application(r)
+		case "AWS::Serverless::Api":
+		default:
+			if strings.HasPrefix(r.Type, "AWS::Serverless::") {
+				err = fmt.Errorf("%s is not supported", r.Type)
+			}
+		}
+		if err != nil {
+			return translated, fmt.Errorf("%s: %v", name, err)
+		}
+	}
+
+	apiNames := make([]string, 0, len(tr.apis))
+	for name := range tr.apis {
+		apiNames = append(apiNames, name)
+	}
+	sort.Strings(apiNames)
+	for _, name := range apiNames {
+		if err := tr.writeApi(tr.apis[name]); err != nil {
+			return translated, fmt.Errorf("%s: %v", name, err)
+		}
+	}
+
+	if err := m.Write(); err != nil {
+		return translated, err
+	}
+
+	tr.rewriteRefs(root)
+
+	if globals != nil {
+		node.RemoveFromMap(root, Globals)
+	}
+	removeTransform(translated)
+
+	config.Debugf("Translated %d serverless resources into %d resources", count, len(m.Resources))
+
+	return translated, nil
+}
+
+// applyGlobals merges the section of Globals into the properties of r
+func (tr *translator) applyGlobals(section string, r *cft.Resource) {
+	g := tr.globals[section]
+	if g == nil || g.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(g.Content); i += 2 {
+		name := g.Content[i].Value
+		r.Properties[name] = mergeGlobal(g.Content[i+1], r.Properties[name])
+	}
+}
+
+// mergeGlobal merges a value from Globals with a resource's own value,
+// the way SAM does: mappings are merged, lists are concatenated,
+// and anything else in the resource replaces the global value
+func mergeGlobal(global *yaml.Node, local *yaml.Node) *yaml.Node {
+	if local == nil {
+		return node.Clone(global)
+	}
+
+	switch {
+	case global.Kind == yaml.SequenceNode && local.Kind == yaml.SequenceNode:
+		merged := node.Clone(global)
+		merged.Content = append(merged.Content, local.Content...)
+		return merged
+	case isPlainMap(global) && isPlainMap(local):
+		merged := node.Clone(global)
+		for i := 0; i+1 < len(local.Content); i += 2 {
+			key := local.Content[i].Value
+			_, existing, _ := s11n.GetMapValue(merged, key)
+			if existing == nil {
+				node.SetMapValue(merged, key, local.Content[i+1])
+			} else {
+				node.SetMapValue(merged, key, mergeGlobal(existing, local.Content[i+1]))
+			}
+		}
+		return merged
+	}
+
+	return local
+}
+
+// isPlainMap returns true if n is a mapping that is not an intrinsic function
+func isPlainMap(n *yaml.Node) bool {
+	if n.Kind != yaml.MappingNode {
+		return false
+	}
+	if len(n.Content) == 2 {
+		key := n.Content[0].Value
+		if key == "Ref" || key == "Condition" || strings.HasPrefix(key, "Fn::") {
+			return false
+		}
+	}
+	return true
+}
+
+// add adds a resource that SAM generates for the serverless resource from
+func (tr *translator) add(from *cft.Resource, logicalId string, typeName string,
+	props map[string]*yaml.Node) (*cft.Resource, error) {
+
+	if _, ok := tr.m.Resources[logicalId]; ok {
+		return nil, fmt.Errorf("unable to add %s, the template already has a resource with that name", logicalId)
+	}
+
+	r := &cft.Resource{
+		LogicalId:  logicalId,
+		Type:       typeName,
+		Properties: props,
+		Condition:  from.Condition,
+	}
+	tr.m.Resources[logicalId] = r
+
+	return r, nil
+}
+
+// only returns an error if props has a property that is not allowed
+func only(props map[string]*yaml.Node, allowed ...string) error {
+	names := make([]string, 0)
+	for name := range props {
+		if !slices.Contains(allowed, name) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	sort.Strings(names)
+	return fmt.Errorf("%s is not supported", strings.Join(names, ", "))
+}
+
+// rewriteRefs replaces references like MyApi.Stage in Ref, Fn::GetAtt and Fn::Sub
+func (tr *translator) rewriteRefs(n *yaml.Node) {
+	if len(tr.refs) == 0 {
+		return
+	}
+
+	if n.Kind == yaml.MappingNode && len(n.Content) == 2 {
+		arg := n.Content[1]
+		switch n.Content[0].Value {
+		case "Ref":
+			if id, ok := tr.refs[arg.Value]; ok {
+				arg.Value = id
+			}
+		case "Fn::Sub":
+			s := arg
+			if arg.Kind == yaml.SequenceNode && len(arg.Content) > 0 {
+				s = arg.Content[0]
+			}
+			for from, to := range tr.refs {
+				s.Value = strings.ReplaceAll(s.Value, "${"+from+"}", "${"+to+"}")
+			}
+		}
+	}
+
+	for _, c := range n.Content {
+		tr.rewriteRefs(c)
+	}
+}
+
+// removeTransform removes the serverless transform from the Transform section
+func removeTransform(t cft.Template) {
+	transform, err := t.GetSection(cft.Transform)
+	if err != nil {
+		return
+	}
+
+	if transform.Kind == yaml.SequenceNode {
+		transform.Content = slices.DeleteFunc(transform.Content, func(n *yaml.Node) bool {
+			return n.Value == Transform
+		})
+		if len(transform.Content) > 0 {
+			return
+		}
+	} else if transform.Value != Transform {
+		return
+	}
+
+	node.RemoveFromMap(t.Node.Content[0], string(cft.Transform))
+}
+
+// s3Location converts a SAM code location, which is either an S3 URI
+// or a mapping with Bucket, Key and Version, into a mapping with the
+// names that CloudFormation uses for the bucket, key and version
+func s3Location(n *yaml.Node, bucket, key, version string) (*yaml.Node, error) {
+	switch {
+	case n.Kind == yaml.ScalarNode:
+		if !strings.HasPrefix(n.Value, "s3://") {
+			return nil, fmt.Errorf("%s is not an S3 URI; package the template first", n.Value)
+		}
+		b, k, ok := strings.Cut(strings.TrimPrefix(n.Value, "s3://"), "/")
+		if !ok || b == "" || k == "" {
+			return nil, fmt.Errorf("%s is not a valid S3 URI", n.Value)
+		}
+		return mapping(bucket, str(b), key, str(k)), nil
+	case isPlainMap(n):
+		retval := mapping()
+		for _, names := range [][2]string{{"Bucket", bucket}, {"Key", key}, {"Version", version}} {
+			_, v, _ := s11n.GetMapValue(n, names[0])
+			if v != nil {
+				node.SetMapValue(retval, names[1], v)
+			}
+		}
+		return retval, nil
+	}
+
+	return nil, fmt.Errorf("expected an S3 URI or a mapping with Bucket and Key")
+}
+
+// tags converts a SAM map of tags into a list of Key and Value
+func tags(n *yaml.Node, extra ...string) (*yaml.Node, error) {
+	retval := seq()
+	for i := 0; i+1 < len(extra); i += 2 {
+		retval.Content = append(retval.Content, mapping("Key", str(extra[i]), "Value", str(extra[i+1])))
+	}
+	if n == nil {
+		return retval, nil
+	}
+	if !isPlainMap(n) {
+		return nil, fmt.Errorf("expected Tags to be a mapping")
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		retval.Content = append(retval.Content, mapping("Key", str(n.Content[i].Value), "Value", n.Content[i+1]))
+	}
+	return retval, nil
+}
+
+// hash returns a short hash of a value, which SAM uses in
+// the logical ids of resources that have to be replaced when it changes
+func hash(n *yaml.Node) string {
+	sum := sha1.Sum([]byte(node.ToSJson(n)))
+	return hex.EncodeToString(sum[:])[:10]
+}
+
+// refName returns the logical id that n refers to with Ref,
+// or the value of n if it is a string
+func refName(n *yaml.Node) string {
+	if n.Kind == yaml.ScalarNode {
+		return n.Value
+	}
+	if n.Kind == yaml.MappingNode && len(n.Content) == 2 && n.Content[0].Value == "Ref" {
+		return n.Content[1].Value
+	}
+	return ""
+}
+
+func str(s string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: s}
+}
+
+func boolean(b bool) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: fmt.Sprint(b)}
+}
+
+func ref(name string) *yaml.Node {
+	return mapping("Ref", str(name))
+}
+
+func getAtt(name, attr string) *yaml.Node {
+	return mapping("Fn::GetAtt", seq(str(name), str(attr)))
+}
+
+func sub(s string) *yaml.Node {
+	return mapping("Fn::Sub", str(s))
+}
+
+// mapping returns a mapping node from pairs of keys and value nodes
+func mapping(pairs ...any) *yaml.Node {
+	n := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		n.Content = append(n.Content, str(pairs[i].(string)), pairs[i+1].(*yaml.Node))
+	}
+	return n
+}
+
+func seq(items ...*yaml.Node) *yaml.Node {
+	return &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Content: items}
+}
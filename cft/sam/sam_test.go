@@ -0,0 +1,207 @@
+package sam
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws-cloudformation/rain/cft"
+	"github.com/aws-cloudformation/rain/cft/parse"
+	"github.com/aws-cloudformation/rain/internal/node"
+	"github.com/aws-cloudformation/rain/internal/s11n"
+)
+
+func translate(t *testing.T, source string) *cft.Model {
+	t.Helper()
+
+	tmpl, err := parse.String(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !HasTransform(tmpl) {
+		t.Fatal("expected the template to have the serverless transform")
+	}
+
+	translated, err := Translate(tmpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if HasTransform(translated) {
+		t.Error("expected the transform to be removed")
+	}
+	if _, err := translated.GetSection(Globals); err == nil {
+		t.Error("expected Globals to be removed")
+	}
+
+	m, err := translated.Model()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestTranslateFunction(t *testing.T) {
+	m := translate(t, `
+Transform: AWS::Serverless-2016-10-31
+Globals:
+  Function:
+    Runtime: python3.12
+    Environment:
+      Variables:
+        A: global
+Resources:
+  Queue:
+    Type: AWS::SQS::Queue
+  Fn:
+    Type: AWS::Serverless::Function
+    Properties:
+      CodeUri: s3://bucket/key.zip
+      Handler: app.handler
+      Environment:
+        Variables:
+          B: local
+      Policies:
+        - S3ReadPolicy:
+            BucketName: my-bucket
+      Events:
+        Messages:
+          Type: SQS
+          Properties:
+            Queue: !GetAtt Queue.Arn
+        Get:
+          Type: Api
+          Properties:
+            Path: /
+            Method: get
+`)
+
+	expected := map[string]string{
+		"Queue":                      "AWS::SQS::Queue",
+		"Fn":                         "AWS::Lambda::Function",
+		"FnRole":                     "AWS::IAM::Role",
+		"FnMessages":                 "AWS::Lambda::EventSourceMapping",
+		"FnGetPermission":            "AWS::Lambda::Permission",
+		"ServerlessRestApi":          "AWS::ApiGateway::RestApi",
+		"ServerlessRestApiProdStage": "AWS::ApiGateway::Stage",
+	}
+	for name, typeName := range expected {
+		r, ok := m.Resources[name]
+		if !ok {
+			t.Errorf("missing %s", name)
+			continue
+		}
+		if r.Type != typeName {
+			t.Errorf("%s: expected %s, got %s", name, typeName, r.Type)
+		}
+	}
+	if len(m.Resources) != len(expected)+1 {
+		t.Errorf("expected a deployment too, got %v", m.Names(cft.Resources))
+	}
+
+	fn := m.Resources["Fn"]
+	if fn.Properties["Runtime"].Value != "python3.12" {
+		t.Errorf("expected the global Runtime")
+	}
+	vars := node.ToSJson(fn.Properties["Environment"])
+	if !strings.Contains(vars, "global") || !strings.Contains(vars, "local") {
+		t.Errorf("expected global and local variables to be merged: %s", vars)
+	}
+	_, bucket, _ := s11n.GetMapValue(fn.Properties["Code"], "S3Bucket")
+	_, key, _ := s11n.GetMapValue(fn.Properties["Code"], "S3Key")
+	if bucket == nil || bucket.Value != "bucket" || key == nil || key.Value != "key.zip" {
+		t.Errorf("unexpected Code: %s", node.ToSJson(fn.Properties["Code"]))
+	}
+
+	role := node.ToSJson(m.Resources["FnRole"].Node)
+	for _, s := range []string{"AWSLambdaBasicExecutionRole", "AWSLambdaSQSQueueExecutionRole", "s3:GetObject", "my-bucket"} {
+		if !strings.Contains(role, s) {
+			t.Errorf("expected the role to contain %s: %s", s, role)
+		}
+	}
+
+	if deps := m.Resources["FnMessages"].DependsOn; len(deps) != 1 || deps[0] != "FnRole" {
+		t.Errorf("expected the event source mapping to depend on the role, got %v", deps)
+	}
+}
+
+func TestTranslateRefs(t *testing.T) {
+	m := translate(t, `
+Transform: [AWS::Serverless-2016-10-31]
+Resources:
+  Api:
+    Type: AWS::Serverless::Api
+    Properties:
+      StageName: v1
+  Fn:
+    Type: AWS::Serverless::Function
+    Properties:
+      InlineCode: "def handler(e, c): pass"
+      Handler: index.handler
+      Runtime: python3.12
+      Role: arn:aws:iam::123456789012:role/fn
+      AutoPublishAlias: live
+Outputs:
+  Stage:
+    Value: !Ref Api.Stage
+  Alias:
+    Value: !Sub "${Fn.Alias}"
+`)
+
+	if _, ok := m.Resources["FnRole"]; ok {
+		t.Error("expected no role for a function with a Role")
+	}
+	if v := m.Outputs["Stage"].Value.Content[1].Value; v != "Apiv1Stage" {
+		t.Errorf("unexpected Stage output: %s", v)
+	}
+	if v := m.Outputs["Alias"].Value.Content[1].Value; v != "${FnAliaslive}" {
+		t.Errorf("unexpected Alias output: %s", v)
+	}
+}
+
+func TestTranslateUnsupported(t *testing.T) {
+	cases := map[string]string{
+		"state machine": `
+Resources:
+  Machine:
+    Type: AWS::Serverless::StateMachine
+`,
+		"local code": `
+Resources:
+  Fn:
+    Type: AWS::Serverless::Function
+    Properties:
+      CodeUri: src/
+`,
+		"event type": `
+Resources:
+  Fn:
+    Type: AWS::Serverless::Function
+    Properties:
+      InlineCode: x
+      Events:
+        Http:
+          Type: HttpApi
+`,
+		"policy template": `
+Resources:
+  Fn:
+    Type: AWS::Serverless::Function
+    Properties:
+      InlineCode: x
+      Policies:
+        - AthenaQueryPolicy:
+            WorkGroupName: x
+`,
+	}
+
+	for name, source := range cases {
+		tmpl, err := parse.String("Transform: AWS::Serverless-2016-10-31\n" + source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Translate(tmpl); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
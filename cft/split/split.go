@@ -0,0 +1,442 @@
+// Package split partitions the resources of a template into nested stacks,
+// for templates that are close to the CloudFormation limits on the number
+// of resources or on the size of a template.
+//
+// The parent template keeps the Parameters, Mappings, Conditions, Rules and
+// Outputs of the original template, and has an AWS::CloudFormation::Stack
+// for each group of resources. A reference from a resource to a parameter,
+// or to a resource in another group, becomes a Parameter of the nested
+// stack, which the parent fills in from the parameter or from an Output
+// of the other nested stack.
+package split
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws-cloudformation/rain/cft"
+	"github.com/aws-cloudformation/rain/cft/graph"
+	"github.com/aws-cloudformation/rain/internal/config"
+	"github.com/aws-cloudformation/rain/internal/node"
+	"github.com/aws-cloudformation/rain/internal/s11n"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultMaxResources is the most resources that Split puts in a group
+// that it creates, which leaves room to add resources later
+const DefaultMaxResources = 200
+
+// Options configures Split
+type Options struct {
+	// Groups assigns resources to named groups. Resources that are not
+	// in a group are put in new groups named Part1, Part2, and so on.
+	Groups map[string]string
+
+	// MaxResources is the most resources in each group that Split creates.
+	// It does not limit the groups in Groups. The default is DefaultMaxResources.
+	MaxResources int
+
+	// TemplateURL returns the TemplateURL of the nested stack for a group.
+	// The default is the group name with a .yaml extension.
+	TemplateURL func(group string) string
+}
+
+// Stack is a nested stack created by Split
+type Stack struct {
+	// Group is the name of the group of resources in the stack
+	Group string
+
+	// LogicalId is the logical id of the nested stack in the parent template
+	LogicalId string
+
+	// Template is the nested stack's template
+	Template cft.Template
+
+	// Resources is the number of resources in the template
+	Resources int
+}
+
+// Split returns a parent template with a nested stack for each group of
+// resources in t, and the templates of the nested stacks in the order
+// that they appear in the parent
+func Split(t cft.Template, options Options) (cft.Template, []Stack, error) {
+	if options.MaxResources <= 0 {
+		options.MaxResources = DefaultMaxResources
+	}
+	if options.TemplateURL == nil {
+		options.TemplateURL = func(group string) string {
+			return group + ".yaml"
+		}
+	}
+
+	root := t.Node
+	if root.Kind == yaml.DocumentNode {
+		root = root.Content[0]
+	}
+	if _, transform, _ := s11n.GetMapValue(root, string(cft.Transform)); transform != nil {
+		return cft.Template{}, nil, fmt.Errorf("templates with a Transform can't be split; use rain pkg or rain render first")
+	}
+
+	m, err := t.Model()
+	if err != nil {
+		return cft.Template{}, nil, err
+	}
+	if len(m.Resources) == 0 {
+		return cft.Template{}, nil, fmt.Errorf("the template has no resources")
+	}
+
+	groupOf, groups, err := partition(m, graph.New(t), options)
+	if err != nil {
+		return cft.Template{}, nil, err
+	}
+
+	sp := &splitter{
+		m:       m,
+		root:    root,
+		groupOf: groupOf,
+		stacks:  make(map[string]*stack),
+		taken:   make(map[string]bool),
+	}
+	for _, section := range []cft.Section{cft.Parameters, cft.Resources} {
+		for _, name := range m.Names(section) {
+			sp.taken[name] = true
+		}
+	}
+
+	for _, group := range groups {
+		id := alphanumeric(group) + "Stack"
+		if alphanumeric(group) == "" || sp.taken[id] {
+			return cft.Template{}, nil, fmt.Errorf("unable to name the nested stack for group '%s'", group)
+		}
+		sp.taken[id] = true
+		sp.stacks[group] = newStack(group, id)
+	}
+
+	for name, group := range groupOf {
+		sp.stacks[group].resources[name] = true
+		sp.stacks[group].taken[name] = true
+	}
+	for _, s := range sp.stacks {
+		for name := range m.Parameters {
+			s.taken[name] = true
+		}
+	}
+	for _, name := range m.Names(cft.Resources) {
+		s := sp.stacks[groupOf[name]]
+		if err := sp.addResource(s, name); err != nil {
+			return cft.Template{}, nil, fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	for _, group := range groups {
+		if err := sp.addConditions(sp.stacks[group]); err != nil {
+			return cft.Template{}, nil, err
+		}
+	}
+
+	parent, err := sp.parent(groups, options)
+	if err != nil {
+		return cft.Template{}, nil, err
+	}
+
+	stacks := make([]Stack, 0, len(groups))
+	for _, group := range groups {
+		s := sp.stacks[group]
+		stacks = append(stacks, Stack{
+			Group:     group,
+			LogicalId: s.id,
+			Template:  sp.template(s),
+			Resources: len(s.resources),
+		})
+	}
+
+	config.Debugf("split %d resources into %d nested stacks", len(m.Resources), len(stacks))
+
+	return parent, stacks, nil
+}
+
+// partition assigns each resource to a group and returns the groups in order.
+// Groups from the options come first, in the order of their first resource.
+// The other resources are grouped so that few references cross between
+// groups: resources that don't refer to each other, even indirectly, can be
+// put in any group, and larger sets of connected resources are cut up in
+// dependency order, so that later groups only refer to earlier ones.
+func partition(m *cft.Model, g graph.Graph, options Options) (map[string]string, []string, error) {
+	groupOf := make(map[string]string)
+	groups := make([]string, 0)
+	named := make(map[string]bool)
+	for name, group := range options.Groups {
+		if _, ok := m.Resources[name]; !ok {
+			return nil, nil, fmt.Errorf("group %s: resource %s is not in the template", group, name)
+		}
+		groupOf[name] = group
+		named[group] = true
+	}
+	for _, name := range m.Names(cft.Resources) {
+		if group, ok := groupOf[name]; ok && !contains(groups, group) {
+			groups = append(groups, group)
+		}
+	}
+
+	sorted, err := g.TopoSort()
+	if err != nil {
+		return nil, nil, err
+	}
+	position := make(map[string]int)
+	for i, n := range sorted {
+		if n.Type == string(cft.Resources) {
+			position[n.Name] = i
+		}
+	}
+
+	// Find the sets of connected resources that are not in a group yet
+	neighbours := make(map[string][]string)
+	for _, name := range m.Names(cft.Resources) {
+		if _, ok := groupOf[name]; ok {
+			continue
+		}
+		for _, to := range g.Get(graph.Node{Type: string(cft.Resources), Name: name}) {
+			if _, ok := groupOf[to.Name]; ok || to.Type != string(cft.Resources) {
+				continue
+			}
+			neighbours[name] = append(neighbours[name], to.Name)
+			neighbours[to.Name] = append(neighbours[to.Name], name)
+		}
+	}
+
+	seen := make(map[string]bool)
+	components := make([][]string, 0)
+	for _, name := range m.Names(cft.Resources) {
+		if _, ok := groupOf[name]; ok || seen[name] {
+			continue
+		}
+		component := make([]string, 0)
+		queue := []string{name}
+		seen[name] = true
+		for len(queue) > 0 {
+			n := queue[0]
+			queue = queue[1:]
+			component = append(component, n)
+			for _, next := range neighbours[n] {
+				if !seen[next] {
+					seen[next] = true
+					queue = append(queue, next)
+				}
+			}
+		}
+		sort.Slice(component, func(i, j int) bool {
+			return position[component[i]] < position[component[j]]
+		})
+		components = append(components, component)
+	}
+
+	// Put the largest sets in first, so that the smaller ones fill the gaps
+	sort.SliceStable(components, func(i, j int) bool {
+		return len(components[i]) > len(components[j])
+	})
+
+	bins := make([][]string, 0)
+	for _, component := range components {
+		if len(component) > options.MaxResources {
+			for i := 0; i < len(component); i += options.MaxResources {
+				end := i + options.MaxResources
+				if end > len(component) {
+					end = len(component)
+				}
+				bins = append(bins, component[i:end:end])
+			}
+			continue
+		}
+
+		placed := false
+		for i, bin := range bins {
+			if len(bin)+len(component) <= options.MaxResources {
+				bins[i] = append(bin, component...)
+				placed = true
+				break
+			}
+		}
+		if !placed {
+			bins = append(bins, component)
+		}
+	}
+
+	n := 1
+	for _, bin := range bins {
+		group := fmt.Sprintf("Part%d", n)
+		for named[group] {
+			n++
+			group = fmt.Sprintf("Part%d", n)
+		}
+		n++
+
+		for _, name := range bin {
+			groupOf[name] = group
+		}
+		groups = append(groups, group)
+	}
+
+	// Nested stacks can't refer to each other
+	stacks := graph.Empty()
+	for _, name := range m.Names(cft.Resources) {
+		from := graph.Node{Type: "Groups", Name: groupOf[name]}
+		stacks.Link(from)
+		for _, to := range g.Get(graph.Node{Type: string(cft.Resources), Name: name}) {
+			if to.Type == string(cft.Resources) && groupOf[to.Name] != groupOf[name] {
+				stacks.Link(from, graph.Node{Type: "Groups", Name: groupOf[to.Name]})
+			}
+		}
+	}
+	if cycle := stacks.Cycle(); cycle != nil {
+		names := make([]string, len(cycle))
+		for i, c := range cycle {
+			names[i] = c.Name
+		}
+		return nil, nil, fmt.Errorf("groups refer to each other: %s", strings.Join(names, " -> "))
+	}
+
+	return groupOf, groups, nil
+}
+
+// parent returns the parent template, which is the original template
+// with a nested stack for each group in place of the resources
+func (sp *splitter) parent(groups []string, options Options) (cft.Template, error) {
+	out := &yaml.Node{Kind: yaml.MappingNode}
+
+	for i := 0; i+1 < len(sp.root.Content); i += 2 {
+		key, value := sp.root.Content[i], sp.root.Content[i+1]
+
+		switch key.Value {
+		case string(cft.Resources):
+			resources := &yaml.Node{Kind: yaml.MappingNode}
+			for _, group := range groups {
+				s := sp.stacks[group]
+				resources.Content = append(resources.Content, str(s.id), sp.stackResource(s, options))
+			}
+			value = resources
+		case string(cft.Outputs):
+			value = node.Clone(value)
+			for j := 1; j < len(value.Content); j += 2 {
+				if err := sp.rewrite(value.Content[j], nil); err != nil {
+					return cft.Template{}, fmt.Errorf("%s: %v", value.Content[j-1].Value, err)
+				}
+			}
+		default:
+			value = node.Clone(value)
+		}
+
+		out.Content = append(out.Content, node.Clone(key), value)
+	}
+
+	return cft.Template{Node: &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{out}}}, nil
+}
+
+// stackResource returns the AWS::CloudFormation::Stack for a nested stack
+func (sp *splitter) stackResource(s *stack, options Options) *yaml.Node {
+	r := mapping("Type", str("AWS::CloudFormation::Stack"))
+
+	if len(s.dependsOn) > 0 {
+		sort.Strings(s.dependsOn)
+		dependsOn := seq()
+		for _, d := range s.dependsOn {
+			dependsOn.Content = append(dependsOn.Content, str(d))
+		}
+		r.Content = append(r.Content, str("DependsOn"), dependsOn)
+	}
+
+	props := mapping("TemplateURL", str(options.TemplateURL(s.group)))
+	if len(s.stackParams.Content) > 0 {
+		props.Content = append(props.Content, str("Parameters"), s.stackParams)
+	}
+	r.Content = append(r.Content, str("Properties"), props)
+
+	return r
+}
+
+// template returns the template of a nested stack
+func (sp *splitter) template(s *stack) cft.Template {
+	out := &yaml.Node{Kind: yaml.MappingNode}
+
+	if _, v, _ := s11n.GetMapValue(sp.root, string(cft.AWSTemplateFormatVersion)); v != nil {
+		out.Content = append(out.Content, str(string(cft.AWSTemplateFormatVersion)), node.Clone(v))
+	}
+	out.Content = append(out.Content, str(string(cft.Description)),
+		str(fmt.Sprintf("Nested stack for the %s group of resources", s.group)))
+
+	if len(s.params.Content) > 0 {
+		out.Content = append(out.Content, str(string(cft.Parameters)), s.params)
+	}
+
+	if len(s.mappings) > 0 {
+		_, section, _ := s11n.GetMapValue(sp.root, string(cft.Mappings))
+		mappings := &yaml.Node{Kind: yaml.MappingNode}
+		for i := 0; section != nil && i+1 < len(section.Content); i += 2 {
+			if s.mappings[section.Content[i].Value] {
+				mappings.Content = append(mappings.Content, node.Clone(section.Content[i]), node.Clone(section.Content[i+1]))
+			}
+		}
+		out.Content = append(out.Content, str(string(cft.Mappings)), mappings)
+	}
+
+	if len(s.conditions) > 0 {
+		conditions := &yaml.Node{Kind: yaml.MappingNode}
+		for _, name := range sp.m.Names(cft.Conditions) {
+			if c, ok := s.conditions[name]; ok {
+				conditions.Content = append(conditions.Content, str(name), c)
+			}
+		}
+		out.Content = append(out.Content, str(string(cft.Conditions)), conditions)
+	}
+
+	out.Content = append(out.Content, str(string(cft.Resources)), s.resourceNodes)
+
+	if len(s.outputs.Content) > 0 {
+		out.Content = append(out.Content, str(string(cft.Outputs)), s.outputs)
+	}
+
+	return cft.Template{Node: &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{out}}}
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+// alphanumeric removes anything that can't be in a logical id
+func alphanumeric(s string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+func str(s string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: s}
+}
+
+func ref(name string) *yaml.Node {
+	return mapping("Ref", str(name))
+}
+
+func getAtt(name, attr string) *yaml.Node {
+	return mapping("Fn::GetAtt", seq(str(name), str(attr)))
+}
+
+// mapping returns a mapping node from pairs of keys and value nodes
+func mapping(pairs ...interface{}) *yaml.Node {
+	n := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		n.Content = append(n.Content, str(pairs[i].(string)), pairs[i+1].(*yaml.Node))
+	}
+	return n
+}
+
+func seq(items ...*yaml.Node) *yaml.Node {
+	return &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Content: items}
+}
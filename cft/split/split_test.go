@@ -0,0 +1,187 @@
+package split
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws-cloudformation/rain/cft"
+	"github.com/aws-cloudformation/rain/cft/format"
+	"github.com/aws-cloudformation/rain/cft/parse"
+)
+
+const source = `
+Parameters:
+  Env:
+    Type: String
+  Subnets:
+    Type: List<AWS::EC2::Subnet::Id>
+Conditions:
+  IsProd: !Equals [!Ref Env, prod]
+Resources:
+  Bucket:
+    Type: AWS::S3::Bucket
+    Condition: IsProd
+  Queue:
+    Type: AWS::SQS::Queue
+    DependsOn: Bucket
+    Properties:
+      QueueName: !Sub "${Env}-${!Literal}"
+  Topic:
+    Type: AWS::SNS::Topic
+    Properties:
+      DisplayName: !Sub "${Queue}-${Bucket.Arn}"
+      TopicName: !GetAtt Queue.QueueName
+  Other:
+    Type: AWS::SNS::Topic
+    Properties:
+      DisplayName: !Join [",", !Ref Subnets]
+Outputs:
+  Name:
+    Value: !GetAtt Topic.TopicName
+`
+
+func model(t *testing.T, tmpl cft.Template) *cft.Model {
+	m, err := tmpl.Model()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestSplit(t *testing.T) {
+	tmpl, err := parse.String(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parent, stacks, err := Split(tmpl, Options{
+		Groups: map[string]string{
+			"Bucket": "Storage",
+			"Queue":  "Storage",
+		},
+		MaxResources: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	groups := make([]string, 0)
+	for _, s := range stacks {
+		groups = append(groups, s.Group)
+	}
+	if strings.Join(groups, ",") != "Storage,Part1,Part2" {
+		t.Fatalf("unexpected groups %v", groups)
+	}
+
+	p := model(t, parent)
+	if len(p.Resources) != 3 || p.Resources["StorageStack"].Type != "AWS::CloudFormation::Stack" {
+		t.Errorf("expected a nested stack for each group: %v", p.Names(cft.Resources))
+	}
+	if _, ok := p.Parameters["Env"]; !ok {
+		t.Error("expected the parent to keep its parameters")
+	}
+
+	out := format.String(parent, format.Options{})
+	for _, expected := range []string{
+		"Value: !GetAtt Part1Stack.Outputs.TopicTopicName",
+		"Subnets: !Join",
+		"TemplateURL: Storage.yaml",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected %q in the parent:\n%s", expected, out)
+		}
+	}
+
+	storage := model(t, stacks[0].Template)
+	if storage.Resources["Queue"].DependsOn[0] != "Bucket" {
+		t.Error("expected the dependency in the same group to be kept")
+	}
+	if storage.Outputs["BucketArn"].Condition != "IsProd" {
+		t.Error("expected the output of a conditional resource to have its condition")
+	}
+	if _, ok := storage.Conditions["IsProd"]; !ok {
+		t.Error("expected the condition to be copied")
+	}
+	if _, ok := storage.Parameters["Env"]; !ok {
+		t.Error("expected the parameter that the condition refers to")
+	}
+
+	topic := model(t, stacks[1].Template)
+	for _, name := range []string{"Queue", "BucketArn", "QueueQueueName"} {
+		if _, ok := topic.Parameters[name]; !ok {
+			t.Errorf("expected parameter %s in %v", name, topic.Names(cft.Parameters))
+		}
+	}
+	out = format.String(stacks[1].Template, format.Options{})
+	for _, expected := range []string{
+		"DisplayName: !Sub ${Queue}-${BucketArn}",
+		"TopicName: !Ref QueueQueueName",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected %q in the nested stack:\n%s", expected, out)
+		}
+	}
+
+	out = format.String(parent, format.Options{})
+	if !strings.Contains(out, "- !GetAtt StorageStack.Outputs.BucketArn\n          - !Ref AWS::NoValue") {
+		t.Errorf("expected the output of a conditional resource to be optional:\n%s", out)
+	}
+}
+
+func TestPartition(t *testing.T) {
+	tmpl, err := parse.String(`
+Resources:
+  A:
+    Type: AWS::SNS::Topic
+  B:
+    Type: AWS::SNS::Topic
+    Properties:
+      DisplayName: !Ref A
+  C:
+    Type: AWS::SNS::Topic
+    Properties:
+      DisplayName: !Ref B
+  D:
+    Type: AWS::SNS::Topic
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, stacks, err := Split(tmpl, Options{MaxResources: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The chain is cut in dependency order, and D fills the gap
+	if len(stacks) != 2 || stacks[0].Resources != 2 || stacks[1].Resources != 2 {
+		t.Fatalf("expected two stacks of two resources")
+	}
+	if _, ok := model(t, stacks[1].Template).Resources["D"]; !ok {
+		t.Error("expected D to be put with C")
+	}
+}
+
+func TestSplitCycle(t *testing.T) {
+	tmpl, err := parse.String(`
+Resources:
+  A:
+    Type: AWS::SNS::Topic
+  B:
+    Type: AWS::SNS::Topic
+    Properties:
+      DisplayName: !Ref A
+  C:
+    Type: AWS::SNS::Topic
+    Properties:
+      DisplayName: !Ref B
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = Split(tmpl, Options{Groups: map[string]string{"A": "One", "B": "Two", "C": "One"}})
+	if err == nil || !strings.Contains(err.Error(), "groups refer to each other") {
+		t.Errorf("expected an error for groups that refer to each other: %v", err)
+	}
+}
@@ -0,0 +1,388 @@
+package split
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws-cloudformation/rain/cft"
+	"github.com/aws-cloudformation/rain/cft/parse"
+	"github.com/aws-cloudformation/rain/internal/node"
+	"gopkg.in/yaml.v3"
+)
+
+// splitter holds the state of a call to Split
+type splitter struct {
+	m       *cft.Model
+	root    *yaml.Node
+	groupOf map[string]string
+	stacks  map[string]*stack
+
+	// taken is every logical id in the original and parent templates
+	taken map[string]bool
+}
+
+// stack is a nested stack that is being built
+type stack struct {
+	group string
+	id    string
+
+	resources     map[string]bool
+	resourceNodes *yaml.Node
+
+	// params are the Parameters of the nested stack, and stackParams
+	// are the values that the parent template passes to them
+	params      *yaml.Node
+	stackParams *yaml.Node
+	paramNames  map[string]string
+
+	// taken is every logical id in the nested stack's template,
+	// and the parameters of the parent template
+	taken map[string]bool
+
+	outputs     *yaml.Node
+	outputNames map[string]string
+
+	// conditions are the conditions that the nested stack needs,
+	// which are nil until they are copied
+	conditions map[string]*yaml.Node
+	mappings   map[string]bool
+
+	// dependsOn are the other nested stacks that this one depends on
+	dependsOn []string
+}
+
+func newStack(group, id string) *stack {
+	return &stack{
+		group:         group,
+		id:            id,
+		resources:     make(map[string]bool),
+		resourceNodes: &yaml.Node{Kind: yaml.MappingNode},
+		params:        &yaml.Node{Kind: yaml.MappingNode},
+		stackParams:   &yaml.Node{Kind: yaml.MappingNode},
+		paramNames:    make(map[string]string),
+		taken:         make(map[string]bool),
+		outputs:       &yaml.Node{Kind: yaml.MappingNode},
+		outputNames:   make(map[string]string),
+		conditions:    make(map[string]*yaml.Node),
+		mappings:      make(map[string]bool),
+	}
+}
+
+// addResource copies a resource into its nested stack
+func (sp *splitter) addResource(s *stack, name string) error {
+	r := node.Clone(sp.m.Resources[name].Node)
+	out := &yaml.Node{Kind: r.Kind, Tag: r.Tag, Style: r.Style}
+
+	for i := 0; i+1 < len(r.Content); i += 2 {
+		key, val := r.Content[i], r.Content[i+1]
+
+		switch key.Value {
+		case "DependsOn":
+			deps := []*yaml.Node{val}
+			if val.Kind == yaml.SequenceNode {
+				deps = val.Content
+			}
+
+			// Dependencies on other groups become dependencies between the nested stacks
+			local := make([]*yaml.Node, 0)
+			for _, d := range deps {
+				other, ok := sp.stacks[sp.groupOf[d.Value]]
+				if !ok || other == s {
+					local = append(local, d)
+				} else if !contains(s.dependsOn, other.id) {
+					s.dependsOn = append(s.dependsOn, other.id)
+				}
+			}
+			if len(local) == 0 {
+				continue
+			}
+			if val.Kind == yaml.SequenceNode {
+				val.Content = local
+			}
+		case "Condition":
+			sp.condition(s, val.Value)
+		default:
+			if err := sp.rewrite(val, s); err != nil {
+				return err
+			}
+		}
+
+		out.Content = append(out.Content, key, val)
+	}
+
+	s.resourceNodes.Content = append(s.resourceNodes.Content, str(name), out)
+
+	return nil
+}
+
+// addConditions copies the conditions that a nested stack needs,
+// along with the conditions that they refer to
+func (sp *splitter) addConditions(s *stack) error {
+	for {
+		added := false
+		for _, name := range sp.m.Names(cft.Conditions) {
+			if c, ok := s.conditions[name]; !ok || c != nil {
+				continue
+			}
+			c := node.Clone(sp.m.Conditions[name])
+			if err := sp.rewrite(c, s); err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+			s.conditions[name] = c
+			added = true
+		}
+		if !added {
+			return nil
+		}
+	}
+}
+
+// condition records that a nested stack needs a condition
+func (sp *splitter) condition(s *stack, name string) {
+	if s == nil {
+		return
+	}
+	if _, ok := s.conditions[name]; !ok {
+		s.conditions[name] = nil
+	}
+}
+
+// isList returns true if a parameter type is a list, which
+// has to be joined to pass it to a nested stack
+func isList(typeName string) bool {
+	return typeName == "CommaDelimitedList" || strings.Contains(typeName, "List<")
+}
+
+// uniqueName returns name, or name with a number after it, so that it is not in taken
+func uniqueName(name string, taken map[string]bool) string {
+	unique := name
+	for i := 2; taken[unique]; i++ {
+		unique = fmt.Sprintf("%s%d", name, i)
+	}
+	taken[unique] = true
+	return unique
+}
+
+// input returns the name of the parameter of a nested stack that receives a
+// parameter of the parent template, or an attribute of a resource in another
+// nested stack. attr is empty for the value of Ref.
+func (sp *splitter) input(s *stack, name, attr string) string {
+	key := name + "." + attr
+	if p, ok := s.paramNames[key]; ok {
+		return p
+	}
+
+	var def, value *yaml.Node
+	paramName := name
+
+	if p, ok := sp.m.Parameters[name]; ok {
+		def = node.Clone(p.Node)
+		value = ref(name)
+		if isList(p.Type) {
+			value = mapping("Fn::Join", seq(str(","), value))
+		}
+	} else {
+		paramName = uniqueName(name+alphanumeric(attr), s.taken)
+		def = mapping("Type", str("String"))
+
+		other := sp.stacks[sp.groupOf[name]]
+		value = getAtt(other.id, "Outputs."+sp.output(other, name, attr))
+
+		// The output is missing when the resource's condition is false
+		if condition := sp.m.Resources[name].Condition; condition != "" {
+			def.Content = append(def.Content, str("Default"), str(""))
+			value = mapping("Fn::If", seq(str(condition), value, ref("AWS::NoValue")))
+		}
+	}
+
+	s.paramNames[key] = paramName
+	s.params.Content = append(s.params.Content, str(paramName), def)
+	s.stackParams.Content = append(s.stackParams.Content, str(paramName), value)
+
+	return paramName
+}
+
+// output returns the name of an output of a nested stack
+// that has the value of Ref or GetAtt for one of its resources
+func (sp *splitter) output(s *stack, name, attr string) string {
+	key := name + "." + attr
+	if o, ok := s.outputNames[key]; ok {
+		return o
+	}
+
+	taken := make(map[string]bool)
+	for _, o := range s.outputNames {
+		taken[o] = true
+	}
+	outputName := uniqueName(name+alphanumeric(attr), taken)
+
+	value := ref(name)
+	if attr != "" {
+		value = getAtt(name, attr)
+	}
+	o := mapping("Value", value)
+	if condition := sp.m.Resources[name].Condition; condition != "" {
+		o.Content = append(o.Content, str("Condition"), str(condition))
+	}
+
+	s.outputNames[key] = outputName
+	s.outputs.Content = append(s.outputs.Content, str(outputName), o)
+
+	return outputName
+}
+
+// local returns true if a name is a parameter or resource in the template
+// of the nested stack, or in the parent template if s is nil
+func (sp *splitter) local(s *stack, name string) bool {
+	if strings.HasPrefix(name, "AWS::") {
+		return true
+	}
+	if _, ok := sp.m.Resources[name]; !ok {
+		// Parameters are local to the parent, and unknown names are left alone
+		if _, ok := sp.m.Parameters[name]; !ok || s == nil {
+			return true
+		}
+		return false
+	}
+	return s != nil && s.resources[name]
+}
+
+// value returns the name to use in place of a reference to a resource
+// attribute or a parameter that is not local, as a parameter of the
+// nested stack, or as an output of another nested stack in the parent template
+func (sp *splitter) value(s *stack, name, attr string) (string, *yaml.Node) {
+	if s != nil {
+		p := sp.input(s, name, attr)
+		return p, ref(p)
+	}
+
+	other := sp.stacks[sp.groupOf[name]]
+	o := sp.output(other, name, attr)
+	return other.id + ".Outputs." + o, getAtt(other.id, "Outputs."+o)
+}
+
+// rewrite replaces the references in n that are not local to a nested
+// stack, or to the parent template if s is nil
+func (sp *splitter) rewrite(n *yaml.Node, s *stack) error {
+	if n.Kind == yaml.MappingNode && len(n.Content) == 2 {
+		val := n.Content[1]
+
+		switch n.Content[0].Value {
+		case "Ref":
+			if val.Kind == yaml.ScalarNode {
+				if !sp.local(s, val.Value) {
+					_, replacement := sp.value(s, val.Value, "")
+					*n = *replacement
+				}
+				return nil
+			}
+		case "Fn::GetAtt":
+			name, attr := "", ""
+			switch val.Kind {
+			case yaml.ScalarNode:
+				name, attr, _ = strings.Cut(val.Value, ".")
+			case yaml.SequenceNode:
+				if len(val.Content) > 0 {
+					name = val.Content[0].Value
+				}
+				if len(val.Content) > 1 {
+					attr = val.Content[1].Value
+					if val.Content[1].Kind != yaml.ScalarNode && !sp.local(s, name) {
+						return fmt.Errorf("unable to refer to an attribute of %s that is not a string", name)
+					}
+				}
+			}
+			if name != "" && !sp.local(s, name) {
+				_, replacement := sp.value(s, name, attr)
+				*n = *replacement
+				return nil
+			}
+		case "Fn::Sub":
+			return sp.rewriteSub(val, s)
+		case "Fn::If":
+			if val.Kind == yaml.SequenceNode && len(val.Content) > 0 {
+				sp.condition(s, val.Content[0].Value)
+			}
+		case "Condition":
+			if val.Kind == yaml.ScalarNode {
+				sp.condition(s, val.Value)
+				return nil
+			}
+		case "Fn::FindInMap":
+			if s != nil && val.Kind == yaml.SequenceNode && len(val.Content) > 0 {
+				s.mappings[val.Content[0].Value] = true
+			}
+		}
+	}
+
+	for _, c := range n.Content {
+		if err := sp.rewrite(c, s); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rewriteSub replaces the variables in a Fn::Sub that are not local
+func (sp *splitter) rewriteSub(n *yaml.Node, s *stack) error {
+	text := n
+	vars := make(map[string]bool)
+
+	if n.Kind == yaml.SequenceNode {
+		if len(n.Content) == 0 {
+			return nil
+		}
+		text = n.Content[0]
+		if len(n.Content) > 1 {
+			if n.Content[1].Kind == yaml.MappingNode {
+				for i := 0; i < len(n.Content[1].Content); i += 2 {
+					vars[n.Content[1].Content[i].Value] = true
+				}
+			}
+			if err := sp.rewrite(n.Content[1], s); err != nil {
+				return err
+			}
+		}
+	}
+	if text.Kind != yaml.ScalarNode {
+		return sp.rewrite(text, s)
+	}
+
+	words, err := parse.ParseSub(text.Value)
+	if err != nil {
+		return err
+	}
+
+	changed := false
+	replace := func(name, attr string, original string) string {
+		if vars[name] || sp.local(s, name) {
+			return "${" + original + "}"
+		}
+		changed = true
+		v, _ := sp.value(s, name, attr)
+		return "${" + v + "}"
+	}
+
+	out := ""
+	for _, w := range words {
+		switch w.T {
+		case parse.STR:
+			// ParseSub turns ${!Literal} into ${Literal}
+			out += strings.ReplaceAll(w.W, "${", "${!")
+		case parse.AWS:
+			out += "${AWS::" + w.W + "}"
+		case parse.REF:
+			out += replace(w.W, "", w.W)
+		case parse.GETATT:
+			name, attr, _ := strings.Cut(w.W, ".")
+			out += replace(name, attr, w.W)
+		}
+	}
+
+	if changed {
+		text.Value = out
+	}
+
+	return nil
+}
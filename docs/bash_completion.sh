@@ -579,6 +579,8 @@ _rain_cc_deploy()
     local_nonpersistent_flags+=("--region")
     local_nonpersistent_flags+=("--region=")
     local_nonpersistent_flags+=("-r")
+    flags+=("--replace-stateful")
+    local_nonpersistent_flags+=("--replace-stateful")
     flags+=("--s3-bucket=")
     two_word_flags+=("--s3-bucket")
     local_nonpersistent_flags+=("--s3-bucket")
@@ -1011,14 +1013,64 @@ _rain_diff()
     flags_with_completion=()
     flags_completion=()
 
+    flags+=("--config=")
+    two_word_flags+=("--config")
+    two_word_flags+=("-c")
+    local_nonpersistent_flags+=("--config")
+    local_nonpersistent_flags+=("--config=")
+    local_nonpersistent_flags+=("-c")
+    flags+=("--experimental")
+    flags+=("-x")
+    local_nonpersistent_flags+=("--experimental")
+    local_nonpersistent_flags+=("-x")
+    flags+=("--format=")
+    two_word_flags+=("--format")
+    two_word_flags+=("-f")
+    local_nonpersistent_flags+=("--format")
+    local_nonpersistent_flags+=("--format=")
+    local_nonpersistent_flags+=("-f")
     flags+=("--help")
     flags+=("-h")
     local_nonpersistent_flags+=("--help")
     local_nonpersistent_flags+=("-h")
+    flags+=("--identity-key=")
+    two_word_flags+=("--identity-key")
+    local_nonpersistent_flags+=("--identity-key")
+    local_nonpersistent_flags+=("--identity-key=")
     flags+=("--long")
     flags+=("-l")
     local_nonpersistent_flags+=("--long")
     local_nonpersistent_flags+=("-l")
+    flags+=("--params=")
+    two_word_flags+=("--params")
+    local_nonpersistent_flags+=("--params")
+    local_nonpersistent_flags+=("--params=")
+    flags+=("--profile=")
+    two_word_flags+=("--profile")
+    two_word_flags+=("-p")
+    local_nonpersistent_flags+=("--profile")
+    local_nonpersistent_flags+=("--profile=")
+    local_nonpersistent_flags+=("-p")
+    flags+=("--ref-edits")
+    local_nonpersistent_flags+=("--ref-edits")
+    flags+=("--region=")
+    two_word_flags+=("--region")
+    two_word_flags+=("-r")
+    local_nonpersistent_flags+=("--region")
+    local_nonpersistent_flags+=("--region=")
+    local_nonpersistent_flags+=("-r")
+    flags+=("--renames")
+    local_nonpersistent_flags+=("--renames")
+    flags+=("--stack=")
+    two_word_flags+=("--stack")
+    two_word_flags+=("-s")
+    local_nonpersistent_flags+=("--stack")
+    local_nonpersistent_flags+=("--stack=")
+    local_nonpersistent_flags+=("-s")
+    flags+=("--tags=")
+    two_word_flags+=("--tags")
+    local_nonpersistent_flags+=("--tags")
+    local_nonpersistent_flags+=("--tags=")
     flags+=("--debug")
     flags+=("--no-colour")
 
@@ -1041,6 +1093,8 @@ _rain_fmt()
     flags_with_completion=()
     flags_completion=()
 
+    flags+=("--cdk")
+    local_nonpersistent_flags+=("--cdk")
     flags+=("--datamodel")
     local_nonpersistent_flags+=("--datamodel")
     flags+=("--debug")
@@ -1049,6 +1103,10 @@ _rain_fmt()
     flags+=("-h")
     local_nonpersistent_flags+=("--help")
     local_nonpersistent_flags+=("-h")
+    flags+=("--ignore-file=")
+    two_word_flags+=("--ignore-file")
+    local_nonpersistent_flags+=("--ignore-file")
+    local_nonpersistent_flags+=("--ignore-file=")
     flags+=("--json")
     flags+=("-j")
     local_nonpersistent_flags+=("--json")
@@ -1067,6 +1125,10 @@ _rain_fmt()
     two_word_flags+=("--pkl-package")
     local_nonpersistent_flags+=("--pkl-package")
     local_nonpersistent_flags+=("--pkl-package=")
+    flags+=("--recursive")
+    flags+=("-r")
+    local_nonpersistent_flags+=("--recursive")
+    local_nonpersistent_flags+=("-r")
     flags+=("--unsorted")
     flags+=("-u")
     local_nonpersistent_flags+=("--unsorted")
@@ -1222,6 +1284,44 @@ _rain_info()
     noun_aliases=()
 }
 
+_rain_lint()
+{
+    last_command="rain_lint"
+
+    command_aliases=()
+
+    commands=()
+
+    flags=()
+    two_word_flags=()
+    local_nonpersistent_flags=()
+    flags_with_completion=()
+    flags_completion=()
+
+    flags+=("--experimental")
+    flags+=("-x")
+    local_nonpersistent_flags+=("--experimental")
+    local_nonpersistent_flags+=("-x")
+    flags+=("--format=")
+    two_word_flags+=("--format")
+    two_word_flags+=("-f")
+    local_nonpersistent_flags+=("--format")
+    local_nonpersistent_flags+=("--format=")
+    local_nonpersistent_flags+=("-f")
+    flags+=("--help")
+    flags+=("-h")
+    local_nonpersistent_flags+=("--help")
+    local_nonpersistent_flags+=("-h")
+    flags+=("--pkg")
+    local_nonpersistent_flags+=("--pkg")
+    flags+=("--debug")
+    flags+=("--no-colour")
+
+    must_have_one_flag=()
+    must_have_one_noun=()
+    noun_aliases=()
+}
+
 _rain_logs()
 {
     last_command="rain_logs"
@@ -1252,6 +1352,10 @@ _rain_logs()
     local_nonpersistent_flags+=("-d")
     flags+=("--debug")
     local_nonpersistent_flags+=("--debug")
+    flags+=("--experimental")
+    flags+=("-x")
+    local_nonpersistent_flags+=("--experimental")
+    local_nonpersistent_flags+=("-x")
     flags+=("--help")
     flags+=("-h")
     local_nonpersistent_flags+=("--help")
@@ -1274,6 +1378,12 @@ _rain_logs()
     local_nonpersistent_flags+=("--region")
     local_nonpersistent_flags+=("--region=")
     local_nonpersistent_flags+=("-r")
+    flags+=("--template=")
+    two_word_flags+=("--template")
+    two_word_flags+=("-t")
+    local_nonpersistent_flags+=("--template")
+    local_nonpersistent_flags+=("--template=")
+    local_nonpersistent_flags+=("-t")
     flags+=("--no-colour")
 
     must_have_one_flag=()
@@ -1355,6 +1465,218 @@ _rain_merge()
     local_nonpersistent_flags+=("--output")
     local_nonpersistent_flags+=("--output=")
     local_nonpersistent_flags+=("-o")
+    flags+=("--strategy=")
+    two_word_flags+=("--strategy")
+    two_word_flags+=("-s")
+    local_nonpersistent_flags+=("--strategy")
+    local_nonpersistent_flags+=("--strategy=")
+    local_nonpersistent_flags+=("-s")
+    flags+=("--debug")
+    flags+=("--no-colour")
+
+    must_have_one_flag=()
+    must_have_one_noun=()
+    noun_aliases=()
+}
+
+_rain_module_describe()
+{
+    last_command="rain_module_describe"
+
+    command_aliases=()
+
+    commands=()
+
+    flags=()
+    two_word_flags=()
+    local_nonpersistent_flags=()
+    flags_with_completion=()
+    flags_completion=()
+
+    flags+=("--help")
+    flags+=("-h")
+    local_nonpersistent_flags+=("--help")
+    local_nonpersistent_flags+=("-h")
+    flags+=("--debug")
+    flags+=("--no-colour")
+
+    must_have_one_flag=()
+    must_have_one_noun=()
+    noun_aliases=()
+}
+
+_rain_module_help()
+{
+    last_command="rain_module_help"
+
+    command_aliases=()
+
+    commands=()
+
+    flags=()
+    two_word_flags=()
+    local_nonpersistent_flags=()
+    flags_with_completion=()
+    flags_completion=()
+
+    flags+=("--debug")
+    flags+=("--no-colour")
+
+    must_have_one_flag=()
+    must_have_one_noun=()
+    has_completion_function=1
+    noun_aliases=()
+}
+
+_rain_module_init()
+{
+    last_command="rain_module_init"
+
+    command_aliases=()
+
+    commands=()
+
+    flags=()
+    two_word_flags=()
+    local_nonpersistent_flags=()
+    flags_with_completion=()
+    flags_completion=()
+
+    flags+=("--help")
+    flags+=("-h")
+    local_nonpersistent_flags+=("--help")
+    local_nonpersistent_flags+=("-h")
+    flags+=("--debug")
+    flags+=("--no-colour")
+
+    must_have_one_flag=()
+    must_have_one_noun=()
+    noun_aliases=()
+}
+
+_rain_module_ls()
+{
+    last_command="rain_module_ls"
+
+    command_aliases=()
+
+    commands=()
+
+    flags=()
+    two_word_flags=()
+    local_nonpersistent_flags=()
+    flags_with_completion=()
+    flags_completion=()
+
+    flags+=("--help")
+    flags+=("-h")
+    local_nonpersistent_flags+=("--help")
+    local_nonpersistent_flags+=("-h")
+    flags+=("--debug")
+    flags+=("--no-colour")
+
+    must_have_one_flag=()
+    must_have_one_noun=()
+    noun_aliases=()
+}
+
+_rain_module_test()
+{
+    last_command="rain_module_test"
+
+    command_aliases=()
+
+    commands=()
+
+    flags=()
+    two_word_flags=()
+    local_nonpersistent_flags=()
+    flags_with_completion=()
+    flags_completion=()
+
+    flags+=("--experimental")
+    flags+=("-x")
+    local_nonpersistent_flags+=("--experimental")
+    local_nonpersistent_flags+=("-x")
+    flags+=("--help")
+    flags+=("-h")
+    local_nonpersistent_flags+=("--help")
+    local_nonpersistent_flags+=("-h")
+    flags+=("--update")
+    local_nonpersistent_flags+=("--update")
+    flags+=("--debug")
+    flags+=("--no-colour")
+
+    must_have_one_flag=()
+    must_have_one_noun=()
+    noun_aliases=()
+}
+
+_rain_module()
+{
+    last_command="rain_module"
+
+    command_aliases=()
+
+    commands=()
+    commands+=("describe")
+    commands+=("help")
+    commands+=("init")
+    commands+=("ls")
+    if [[ -z "${BASH_VERSION:-}" || "${BASH_VERSINFO[0]:-}" -gt 3 ]]; then
+        command_aliases+=("list")
+        aliashash["list"]="ls"
+    fi
+    commands+=("test")
+
+    flags=()
+    two_word_flags=()
+    local_nonpersistent_flags=()
+    flags_with_completion=()
+    flags_completion=()
+
+    flags+=("--help")
+    flags+=("-h")
+    local_nonpersistent_flags+=("--help")
+    local_nonpersistent_flags+=("-h")
+    flags+=("--debug")
+    flags+=("--no-colour")
+
+    must_have_one_flag=()
+    must_have_one_noun=()
+    noun_aliases=()
+}
+
+_rain_patch()
+{
+    last_command="rain_patch"
+
+    command_aliases=()
+
+    commands=()
+
+    flags=()
+    two_word_flags=()
+    local_nonpersistent_flags=()
+    flags_with_completion=()
+    flags_completion=()
+
+    flags+=("--check")
+    flags+=("-c")
+    local_nonpersistent_flags+=("--check")
+    local_nonpersistent_flags+=("-c")
+    flags+=("--help")
+    flags+=("-h")
+    local_nonpersistent_flags+=("--help")
+    local_nonpersistent_flags+=("-h")
+    flags+=("--json")
+    flags+=("-j")
+    local_nonpersistent_flags+=("--json")
+    local_nonpersistent_flags+=("-j")
+    flags+=("--write")
+    flags+=("-w")
+    local_nonpersistent_flags+=("--write")
+    local_nonpersistent_flags+=("-w")
     flags+=("--debug")
     flags+=("--no-colour")
 
@@ -1381,6 +1703,8 @@ _rain_pkg()
     local_nonpersistent_flags+=("--datamodel")
     flags+=("--debug")
     local_nonpersistent_flags+=("--debug")
+    flags+=("--expand")
+    local_nonpersistent_flags+=("--expand")
     flags+=("--experimental")
     flags+=("-x")
     local_nonpersistent_flags+=("--experimental")
@@ -1389,6 +1713,12 @@ _rain_pkg()
     flags+=("-h")
     local_nonpersistent_flags+=("--help")
     local_nonpersistent_flags+=("-h")
+    flags+=("--locked")
+    local_nonpersistent_flags+=("--locked")
+    flags+=("--module-cache=")
+    two_word_flags+=("--module-cache")
+    local_nonpersistent_flags+=("--module-cache")
+    local_nonpersistent_flags+=("--module-cache=")
     flags+=("--node-style=")
     two_word_flags+=("--node-style")
     local_nonpersistent_flags+=("--node-style")
@@ -1399,6 +1729,10 @@ _rain_pkg()
     local_nonpersistent_flags+=("--output")
     local_nonpersistent_flags+=("--output=")
     local_nonpersistent_flags+=("-o")
+    flags+=("--params=")
+    two_word_flags+=("--params")
+    local_nonpersistent_flags+=("--params")
+    local_nonpersistent_flags+=("--params=")
     flags+=("--profile=")
     two_word_flags+=("--profile")
     two_word_flags+=("-p")
@@ -1419,6 +1753,74 @@ _rain_pkg()
     two_word_flags+=("--s3-prefix")
     local_nonpersistent_flags+=("--s3-prefix")
     local_nonpersistent_flags+=("--s3-prefix=")
+    flags+=("--sam-translate")
+    local_nonpersistent_flags+=("--sam-translate")
+    flags+=("--no-colour")
+
+    must_have_one_flag=()
+    must_have_one_noun=()
+    noun_aliases=()
+}
+
+_rain_render()
+{
+    last_command="rain_render"
+
+    command_aliases=()
+
+    commands=()
+
+    flags=()
+    two_word_flags=()
+    local_nonpersistent_flags=()
+    flags_with_completion=()
+    flags_completion=()
+
+    flags+=("--account=")
+    two_word_flags+=("--account")
+    local_nonpersistent_flags+=("--account")
+    local_nonpersistent_flags+=("--account=")
+    flags+=("--azs=")
+    two_word_flags+=("--azs")
+    local_nonpersistent_flags+=("--azs")
+    local_nonpersistent_flags+=("--azs=")
+    flags+=("--config=")
+    two_word_flags+=("--config")
+    two_word_flags+=("-c")
+    local_nonpersistent_flags+=("--config")
+    local_nonpersistent_flags+=("--config=")
+    local_nonpersistent_flags+=("-c")
+    flags+=("--help")
+    flags+=("-h")
+    local_nonpersistent_flags+=("--help")
+    local_nonpersistent_flags+=("-h")
+    flags+=("--json")
+    flags+=("-j")
+    local_nonpersistent_flags+=("--json")
+    local_nonpersistent_flags+=("-j")
+    flags+=("--params=")
+    two_word_flags+=("--params")
+    local_nonpersistent_flags+=("--params")
+    local_nonpersistent_flags+=("--params=")
+    flags+=("--profile=")
+    two_word_flags+=("--profile")
+    two_word_flags+=("-p")
+    local_nonpersistent_flags+=("--profile")
+    local_nonpersistent_flags+=("--profile=")
+    local_nonpersistent_flags+=("-p")
+    flags+=("--region=")
+    two_word_flags+=("--region")
+    two_word_flags+=("-r")
+    local_nonpersistent_flags+=("--region")
+    local_nonpersistent_flags+=("--region=")
+    local_nonpersistent_flags+=("-r")
+    flags+=("--stack-name=")
+    two_word_flags+=("--stack-name")
+    two_word_flags+=("-s")
+    local_nonpersistent_flags+=("--stack-name")
+    local_nonpersistent_flags+=("--stack-name=")
+    local_nonpersistent_flags+=("-s")
+    flags+=("--debug")
     flags+=("--no-colour")
 
     must_have_one_flag=()
@@ -1480,6 +1882,52 @@ _rain_rm()
     noun_aliases=()
 }
 
+_rain_split()
+{
+    last_command="rain_split"
+
+    command_aliases=()
+
+    commands=()
+
+    flags=()
+    two_word_flags=()
+    local_nonpersistent_flags=()
+    flags_with_completion=()
+    flags_completion=()
+
+    flags+=("--group=")
+    two_word_flags+=("--group")
+    two_word_flags+=("-g")
+    local_nonpersistent_flags+=("--group")
+    local_nonpersistent_flags+=("--group=")
+    local_nonpersistent_flags+=("-g")
+    flags+=("--help")
+    flags+=("-h")
+    local_nonpersistent_flags+=("--help")
+    local_nonpersistent_flags+=("-h")
+    flags+=("--json")
+    flags+=("-j")
+    local_nonpersistent_flags+=("--json")
+    local_nonpersistent_flags+=("-j")
+    flags+=("--max-resources=")
+    two_word_flags+=("--max-resources")
+    local_nonpersistent_flags+=("--max-resources")
+    local_nonpersistent_flags+=("--max-resources=")
+    flags+=("--output-dir=")
+    two_word_flags+=("--output-dir")
+    two_word_flags+=("-o")
+    local_nonpersistent_flags+=("--output-dir")
+    local_nonpersistent_flags+=("--output-dir=")
+    local_nonpersistent_flags+=("-o")
+    flags+=("--debug")
+    flags+=("--no-colour")
+
+    must_have_one_flag=()
+    must_have_one_noun=()
+    noun_aliases=()
+}
+
 _rain_stackset_deploy()
 {
     last_command="rain_stackset_deploy"
@@ -1737,10 +2185,18 @@ _rain_tree()
     flags+=("-d")
     local_nonpersistent_flags+=("--dot")
     local_nonpersistent_flags+=("-d")
+    flags+=("--format=")
+    two_word_flags+=("--format")
+    two_word_flags+=("-f")
+    local_nonpersistent_flags+=("--format")
+    local_nonpersistent_flags+=("--format=")
+    local_nonpersistent_flags+=("-f")
     flags+=("--help")
     flags+=("-h")
     local_nonpersistent_flags+=("--help")
     local_nonpersistent_flags+=("-h")
+    flags+=("--html")
+    local_nonpersistent_flags+=("--html")
     flags+=("--debug")
     flags+=("--no-colour")
 
@@ -1817,6 +2273,7 @@ _rain_root_command()
     commands+=("forecast")
     commands+=("help")
     commands+=("info")
+    commands+=("lint")
     commands+=("logs")
     if [[ -z "${BASH_VERSION:-}" || "${BASH_VERSINFO[0]:-}" -gt 3 ]]; then
         command_aliases+=("log")
@@ -1828,11 +2285,14 @@ _rain_root_command()
         aliashash["list"]="ls"
     fi
     commands+=("merge")
+    commands+=("module")
+    commands+=("patch")
     commands+=("pkg")
     if [[ -z "${BASH_VERSION:-}" || "${BASH_VERSINFO[0]:-}" -gt 3 ]]; then
         command_aliases+=("package")
         aliashash["package"]="pkg"
     fi
+    commands+=("render")
     commands+=("rm")
     if [[ -z "${BASH_VERSION:-}" || "${BASH_VERSINFO[0]:-}" -gt 3 ]]; then
         command_aliases+=("del")
@@ -1842,6 +2302,7 @@ _rain_root_command()
         command_aliases+=("remove")
         aliashash["remove"]="rm"
     fi
+    commands+=("split")
     commands+=("stackset")
     commands+=("tree")
     if [[ -z "${BASH_VERSION:-}" || "${BASH_VERSINFO[0]:-}" -gt 3 ]]; then
@@ -0,0 +1,207 @@
+package cfn
+
+import (
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/aws-cloudformation/rain/cft"
+	"github.com/aws-cloudformation/rain/cft/diff"
+	"github.com/aws-cloudformation/rain/internal/config"
+)
+
+// Replacement is a resource that will be replaced when the
+// stack is updated, because one of its create-only properties changed
+type Replacement struct {
+	LogicalId string
+	Type      string
+
+	// Paths are JSON pointers to the create-only properties that changed,
+	// or to the resource itself if it was renamed, like the paths in diff.Changes
+	Paths []string
+
+	// From is the old logical id of a renamed resource. CloudFormation
+	// creates the resource with the new logical id and deletes the old one.
+	From string
+}
+
+// Properties returns the changes that force the replacement,
+// relative to the resource, for example Properties/TableName
+func (r Replacement) Properties() []string {
+	retval := make([]string, 0, len(r.Paths))
+	for _, p := range r.Paths {
+		prop, ok := strings.CutPrefix(p, "/"+string(cft.Resources)+"/"+r.LogicalId+"/")
+		if ok {
+			retval = append(retval, prop)
+		}
+	}
+	return retval
+}
+
+// Reason returns why the resource will be replaced
+func (r Replacement) Reason() string {
+	if r.From != "" {
+		return "renamed from " + r.From
+	}
+	return strings.Join(r.Properties(), ", ")
+}
+
+// Stateful returns true if the replaced resource holds data that
+// is lost when it is replaced
+func (r Replacement) Stateful() bool {
+	return IsStateful(r.Type)
+}
+
+// statefulTypes are resource types that hold data
+var statefulTypes = []string{
+	"AWS::DocDB::DBCluster",
+	"AWS::DynamoDB::GlobalTable",
+	"AWS::DynamoDB::Table",
+	"AWS::EC2::Volume",
+	"AWS::EFS::FileSystem",
+	"AWS::ElastiCache::CacheCluster",
+	"AWS::ElastiCache::ReplicationGroup",
+	"AWS::Elasticsearch::Domain",
+	"AWS::FSx::FileSystem",
+	"AWS::Kinesis::Stream",
+	"AWS::Logs::LogGroup",
+	"AWS::Neptune::DBCluster",
+	"AWS::OpenSearchService::Domain",
+	"AWS::RDS::DBCluster",
+	"AWS::RDS::DBInstance",
+	"AWS::Redshift::Cluster",
+	"AWS::S3::Bucket",
+	"AWS::SQS::Queue",
+}
+
+// IsStateful returns true if resources of the type hold data
+// that is lost when they are replaced
+func IsStateful(typeName string) bool {
+	return slices.Contains(statefulTypes, typeName)
+}
+
+// Replacements returns the resources in d that will be replaced, in order of
+// their logical ids. d is a diff between a deployed template and the template t.
+// A resource is replaced if a create-only property from its registry schema,
+// its Type, or its logical id changed. Added and removed resources are not included.
+func Replacements(d diff.Diff, t cft.Template) []Replacement {
+	m, err := t.Model()
+	if err != nil {
+		config.Debugf("unable to check for replacements: %v", err)
+		return nil
+	}
+
+	renamed := make(map[string]bool)
+	found := make(map[string]*Replacement)
+	for _, c := range diff.Changes(d) {
+		// Paths look like /Resources/Name/Properties/A/B
+		parts := strings.Split(c.Path, "/")
+		if len(parts) < 3 || parts[1] != string(cft.Resources) {
+			continue
+		}
+
+		logicalId := parts[2]
+		r, ok := m.Resources[logicalId]
+		if !ok || renamed[logicalId] {
+			continue
+		}
+
+		if len(parts) == 3 {
+			// The changes to a renamed resource don't matter,
+			// since it is replaced anyway
+			if c.Mode == "renamed" {
+				renamed[logicalId] = true
+				found[logicalId] = &Replacement{LogicalId: logicalId, Type: r.Type,
+					Paths: []string{c.Path}, From: c.From}
+			}
+			continue
+		}
+
+		for _, prop := range replaces(r.Type, parts[3:], c.Value) {
+			path := strings.Join(append(parts[:3:3], prop...), "/")
+			if _, ok := found[logicalId]; !ok {
+				found[logicalId] = &Replacement{LogicalId: logicalId, Type: r.Type}
+			}
+			if !slices.Contains(found[logicalId].Paths, path) {
+				found[logicalId].Paths = append(found[logicalId].Paths, path)
+			}
+		}
+	}
+
+	retval := make([]Replacement, 0, len(found))
+	for _, r := range found {
+		retval = append(retval, *r)
+	}
+	sort.Slice(retval, func(i, j int) bool {
+		return retval[i].LogicalId < retval[j].LogicalId
+	})
+
+	return retval
+}
+
+// replaces returns the create-only elements of a resource that a change at
+// path, for example Properties/A/B/Fn::Sub, changes, like [[Properties A]].
+// value is the new value, or the old value if it was removed.
+func replaces(typeName string, path []string, value interface{}) [][]string {
+	switch path[0] {
+	case "Type":
+		return [][]string{{"Type"}}
+	case "Properties":
+		s := LocalSchema(typeName)
+		if s == nil {
+			return nil
+		}
+		if len(path) == 1 {
+			// All of the properties were added or removed
+			props, _ := value.(map[string]interface{})
+			names := make([]string, 0, len(props))
+			for name := range props {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			retval := make([][]string, 0)
+			for _, name := range names {
+				if s.IsCreateOnly([]string{name}) {
+					retval = append(retval, []string{"Properties", name})
+				}
+			}
+			return retval
+		}
+		if prop := s.createOnlyPath(path[1:]); prop != nil {
+			return [][]string{append([]string{"Properties"}, prop...)}
+		}
+	}
+
+	return nil
+}
+
+// IsCreateOnly returns true if changing the property at path forces the resource
+// to be replaced. path is a list of property names and list indexes below Properties.
+// A property is create-only if it is one of the schema's createOnlyProperties,
+// or if it contains or is contained by one of them.
+func (s *Schema) IsCreateOnly(path []string) bool {
+	return s.createOnlyPath(path) != nil
+}
+
+// createOnlyPath returns the part of path that is a create-only property,
+// or all of path if it contains one. It returns nil if the property at
+// path is not create-only.
+func (s *Schema) createOnlyPath(path []string) []string {
+	for _, p := range s.CreateOnlyProperties {
+		pointer := strings.Split(strings.TrimPrefix(p, "/properties/"), "/")
+
+		match := true
+		for i := 0; i < len(pointer) && i < len(path); i++ {
+			if pointer[i] != "*" && pointer[i] != path[i] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return path[:min(len(pointer), len(path))]
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,140 @@
+package cfn_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws-cloudformation/rain/cft/diff"
+	"github.com/aws-cloudformation/rain/cft/parse"
+	"github.com/aws-cloudformation/rain/internal/aws/cfn"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReplacements(t *testing.T) {
+	deployed, err := parse.String(`
+Resources:
+  Table:
+    Type: AWS::DynamoDB::Table
+    Properties:
+      TableName: !Sub "old-${AWS::StackName}"
+      BillingMode: PAY_PER_REQUEST
+  Cluster:
+    Type: AWS::RDS::DBCluster
+    Properties:
+      Engine: aurora-mysql
+      Port: 3306
+  Topic:
+    Type: AWS::SNS::Topic
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template, err := parse.String(`
+Resources:
+  Table:
+    Type: AWS::DynamoDB::Table
+    Properties:
+      TableName: !Sub "new-${AWS::StackName}"
+      BillingMode: PROVISIONED
+  Cluster:
+    Type: AWS::RDS::DBCluster
+    Properties:
+      Engine: aurora-mysql
+      Port: 3307
+  Topic:
+    Type: AWS::SQS::Queue
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []cfn.Replacement{
+		{
+			LogicalId: "Table",
+			Type:      "AWS::DynamoDB::Table",
+			Paths:     []string{"/Resources/Table/Properties/TableName"},
+		},
+		{
+			LogicalId: "Topic",
+			Type:      "AWS::SQS::Queue",
+			Paths:     []string{"/Resources/Topic/Type"},
+		},
+	}
+
+	actual := cfn.Replacements(diff.New(deployed, template), template)
+	if d := cmp.Diff(expected, actual); d != "" {
+		t.Error(d)
+	}
+
+	if !actual[0].Stateful() || actual[0].Reason() != "Properties/TableName" {
+		t.Errorf("unexpected replacement details: %v", actual[0])
+	}
+}
+
+func TestRenameReplacement(t *testing.T) {
+	deployed, err := parse.String(`
+Resources:
+  Bucket:
+    Type: AWS::S3::Bucket
+    Properties:
+      BucketName: data
+      VersioningConfiguration:
+        Status: Enabled
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template, err := parse.String(`
+Resources:
+  DataBucket:
+    Type: AWS::S3::Bucket
+    Properties:
+      BucketName: data
+      VersioningConfiguration:
+        Status: Enabled
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []cfn.Replacement{
+		{
+			LogicalId: "DataBucket",
+			Type:      "AWS::S3::Bucket",
+			Paths:     []string{"/Resources/DataBucket"},
+			From:      "Bucket",
+		},
+	}
+
+	d := diff.NewWithOptions(deployed, template, diff.Options{DetectRenames: true})
+	actual := cfn.Replacements(d, template)
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Fatal(diff)
+	}
+
+	if actual[0].Reason() != "renamed from Bucket" || len(actual[0].Properties()) != 0 {
+		t.Errorf("unexpected replacement details: %v", actual[0])
+	}
+}
+
+func TestIsCreateOnly(t *testing.T) {
+	s := &cfn.Schema{
+		CreateOnlyProperties: []string{"/properties/Name", "/properties/Config/Subnets", "/properties/Rules/*/Id"},
+	}
+
+	for path, expected := range map[string]bool{
+		"Name":           true,
+		"Description":    false,
+		"Config":         true,
+		"Config/Subnets": true,
+		"Config/Groups":  false,
+		"Rules/0/Id":     true,
+		"Rules/0/Action": false,
+	} {
+		if s.IsCreateOnly(strings.Split(path, "/")) != expected {
+			t.Errorf("%s: expected %v", path, expected)
+		}
+	}
+}
@@ -3,6 +3,11 @@ package cfn
 import (
 	"encoding/json"
 	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/aws-cloudformation/rain/internal/config"
 )
 
 type SchemaLike interface {
@@ -20,6 +25,10 @@ type Prop struct {
 	Ref                  string           `json:"$ref"`
 	MaxLength            int              `json:"maxLength"`
 	MinLength            int              `json:"minLength"`
+	Minimum              *float64         `json:"minimum"`
+	Maximum              *float64         `json:"maximum"`
+	MinItems             int              `json:"minItems"`
+	MaxItems             int              `json:"maxItems"`
 	Pattern              string           `json:"pattern"`
 	Examples             []any            `json:"examples"`
 	AdditionalProperties bool             `json:"additionalProperties"`
@@ -76,6 +85,80 @@ func ParseSchema(source string) (*Schema, error) {
 	return &s, nil
 }
 
+// localSchemas caches parsed registry schemas
+var localSchemas = make(map[string]*Schema)
+var localSchemasMu sync.Mutex
+
+// LocalSchema returns the parsed registry schema for a type,
+// or nil if rain does not have one
+func LocalSchema(typeName string) *Schema {
+	localSchemasMu.Lock()
+	defer localSchemasMu.Unlock()
+
+	if s, ok := localSchemas[typeName]; ok {
+		return s
+	}
+
+	// Only look for types that rain has a schema for, so that we never
+	// need to call the registry for things like third party types
+	var s *Schema
+	if slices.Contains(strings.Split(AllTypes, "\n"), typeName) {
+		source, err := GetTypeSchema(typeName, false)
+		if err == nil {
+			s, err = ParseSchema(source)
+		}
+		if err == nil {
+			// Apply patches to the schema
+			err = s.Patch()
+		}
+		if err != nil {
+			config.Debugf("unable to get the schema for %s: %v", typeName, err)
+			s = nil
+		}
+	}
+	localSchemas[typeName] = s
+
+	return s
+}
+
+// resolve follows a reference to a definition and the items of a list
+func (s *Schema) resolve(p *Prop) *Prop {
+	for p != nil {
+		if strings.HasPrefix(p.Ref, "#/definitions/") {
+			p = s.Definitions[strings.TrimPrefix(p.Ref, "#/definitions/")]
+			continue
+		}
+		if p.Items != nil {
+			p = p.Items
+			continue
+		}
+		return p
+	}
+	return nil
+}
+
+// IsJSON returns true if the property at path is an object without any
+// properties of its own, like a policy document or a map of names to values.
+// path is a list of property names below Properties, without list indexes.
+func (s *Schema) IsJSON(path []string) bool {
+	if len(path) == 0 {
+		return false
+	}
+
+	p := s.resolve(s.Properties[path[0]])
+	for _, name := range path[1:] {
+		if p == nil {
+			return false
+		}
+		p = s.resolve(p.Properties[name])
+	}
+	if p == nil || len(p.Properties) > 0 {
+		return false
+	}
+
+	return ConvertPropType(p.Type) == "object"
+}
+
 // Patch applies patches to the schema to add things like undocumented enums
 func (schema *Schema) Patch() error {
 	switch schema.TypeName {
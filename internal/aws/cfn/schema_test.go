@@ -111,3 +111,14 @@ func TestSchemaFiles(t *testing.T) {
 		}
 	}
 }
+
+func TestLocalSchemaPatched(t *testing.T) {
+	s := cfn.LocalSchema("AWS::IAM::Role")
+	if s == nil {
+		t.Fatal("expected a schema for AWS::IAM::Role")
+	}
+
+	if s.Properties["AssumeRolePolicyDocument"].Type != "object" {
+		t.Errorf("expected the patched type, got %v", s.Properties["AssumeRolePolicyDocument"].Type)
+	}
+}
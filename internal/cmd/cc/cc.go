@@ -16,6 +16,7 @@ var Experimental bool
 var yes bool
 var ignoreUnknownParams bool
 var unlock string
+var replaceStateful bool
 
 // Globals (seems bad..? but cumbersome to pass them around)
 var deployedTemplate cft.Template
@@ -6,10 +6,13 @@ import (
 	"path/filepath"
 
 	"github.com/aws-cloudformation/rain/cft"
+	"github.com/aws-cloudformation/rain/cft/eval"
 	"github.com/aws-cloudformation/rain/cft/format"
 	"github.com/aws-cloudformation/rain/cft/pkg"
+	"github.com/aws-cloudformation/rain/internal/aws"
 	"github.com/aws-cloudformation/rain/internal/aws/cfn"
 	"github.com/aws-cloudformation/rain/internal/aws/s3"
+	"github.com/aws-cloudformation/rain/internal/aws/sts"
 	"github.com/aws-cloudformation/rain/internal/cmd/forecast"
 	"github.com/aws-cloudformation/rain/internal/config"
 	"github.com/aws-cloudformation/rain/internal/console"
@@ -61,6 +64,20 @@ func deploy(cmd *cobra.Command, args []string) {
 	}
 	templateConfig = dc
 
+	// Evaluate conditions and intrinsic functions that do not depend on
+	// deployed resources, so that conditional resources are left out
+	accountId, err := sts.GetAccountID()
+	if err != nil {
+		panic(err)
+	}
+	template, err = eval.Render(template, eval.Options{
+		Parameters:       dc.ParamMap(),
+		PseudoParameters: eval.DefaultPseudoParameters(aws.Config().Region, accountId, name),
+	})
+	if err != nil {
+		panic(ui.Errorf(err, "unable to render template '%s'", fn))
+	}
+
 	// Before we do anything else, make sure that all types in the template
 	// are fully supported by Cloud Control API
 	types, err := template.GetTypes()
@@ -180,6 +197,7 @@ func init() {
 	CCDeployCmd.Flags().StringVarP(&configFilePath, "config", "c", "", "YAML or JSON file to set tags and parameters")
 	CCDeployCmd.Flags().StringVarP(&unlock, "unlock", "u", "", "Unlock <lockid> and continue")
 	CCDeployCmd.Flags().BoolVarP(&ignoreUnknownParams, "ignore-unknown-params", "", false, "Ignore unknown parameters")
+	CCDeployCmd.Flags().BoolVar(&replaceStateful, "replace-stateful", false, "Allow updates that replace resources that hold data, like databases and buckets")
 
 	addCommonParams(CCDeployCmd)
 
@@ -5,6 +5,7 @@ import (
 
 	"github.com/aws-cloudformation/rain/cft/diff"
 	"github.com/aws-cloudformation/rain/cft/graph"
+	"gopkg.in/yaml.v3"
 )
 
 func TestReady(t *testing.T) {
@@ -87,3 +88,21 @@ func TestVerifyDeletes(t *testing.T) {
 	}
 
 }
+
+func TestCreateFirst(t *testing.T) {
+	model := `{"QueueName": "orders", "FifoQueue": false}`
+
+	for source, expected := range map[string]bool{
+		"Type: AWS::SQS::Queue\nProperties:\n  QueueName: orders2":                   true,
+		"Type: AWS::SQS::Queue\nProperties:\n  QueueName: orders\n  FifoQueue: true": false,
+		"Type: AWS::SQS::Queue\nProperties:\n  FifoQueue: true":                      true,
+	} {
+		var n yaml.Node
+		if err := yaml.Unmarshal([]byte(source), &n); err != nil {
+			t.Fatal(err)
+		}
+		if actual := createFirst("AWS::SQS::Queue", n.Content[0], model); actual != expected {
+			t.Errorf("%q: expected %v, got %v", source, expected, actual)
+		}
+	}
+}
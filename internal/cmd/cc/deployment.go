@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/aws-cloudformation/rain/cft"
@@ -11,6 +12,7 @@ import (
 	"github.com/aws-cloudformation/rain/cft/format"
 	"github.com/aws-cloudformation/rain/cft/graph"
 	"github.com/aws-cloudformation/rain/internal/aws/ccapi"
+	"github.com/aws-cloudformation/rain/internal/aws/cfn"
 	"github.com/aws-cloudformation/rain/internal/config"
 	"github.com/aws-cloudformation/rain/internal/console"
 	"github.com/aws-cloudformation/rain/internal/console/spinner"
@@ -23,29 +25,11 @@ import (
 
 var createFormat table.Formatter
 var updateFormat table.Formatter
+var replaceFormat table.Formatter
 var deleteFormat table.Formatter
 var failFormat table.Formatter
 var successFormat table.Formatter
 
-// getTemplateResource returns the yaml node based on the logical id
-func getTemplateResource(template cft.Template, logicalId string) (*yaml.Node, error) {
-	rootMap := template.Node.Content[0]
-	_, resources, _ := s11n.GetMapValue(rootMap, "Resources")
-	if resources == nil {
-		panic("Expected to find a Resources section in the template")
-	}
-	for i, r := range resources.Content {
-		if i%2 != 0 {
-			continue
-		}
-		if logicalId == r.Value {
-			resource := resources.Content[i+1]
-			return resource, nil
-		}
-	}
-	return nil, fmt.Errorf("could not find Resource %v", logicalId)
-}
-
 // deployResource calls the Cloud Control API to deploy the resource
 func deployResource(resource *Resource) {
 	config.Debugf("Deploying %v...", resource)
@@ -106,6 +90,55 @@ func deployResource(resource *Resource) {
 			resource.Model = model
 		}
 
+	case diff.Replace:
+
+		// Cloud Control can't update create-only properties, so create the
+		// resource again and delete the old one. Like CloudFormation, the new
+		// resource is created first, unless it has the same name as the old one.
+		oldIdentifier := resource.Identifier
+		first := createFirst(resource.Type, resolvedNode, resource.Model)
+		if !first {
+			err = ccapi.DeleteResource(resource.Name, oldIdentifier, resource.Node)
+			if err != nil {
+				config.Debugf("deployResource replace failed to delete: %v", err)
+				resource.State = Failed
+				resource.Message = fmt.Sprintf("%v", err)
+				break
+			}
+		}
+
+		var identifier string
+		var model string
+		identifier, model, err = ccapi.CreateResource(resource.Name, resolvedNode)
+		if err != nil {
+			config.Debugf("deployResource replace failed to create: %v", err)
+			resource.State = Failed
+			if first {
+				resource.Message = fmt.Sprintf("%v", err)
+			} else {
+				resource.Message = fmt.Sprintf("the old resource %v was deleted, but the new one could not be created: %v",
+					oldIdentifier, err)
+			}
+			break
+		}
+
+		resource.Identifier = identifier
+		resource.Model = model
+
+		if first {
+			err = ccapi.DeleteResource(resource.Name, oldIdentifier, resource.Node)
+			if err != nil {
+				config.Debugf("deployResource replace failed to delete: %v", err)
+				resource.State = Failed
+				resource.Message = fmt.Sprintf("the new resource %v was created, but the old one %v could not be deleted: %v",
+					identifier, oldIdentifier, err)
+				break
+			}
+		}
+
+		resource.State = Deployed
+		resource.Message = "Success"
+
 	case diff.Delete:
 
 		err = ccapi.DeleteResource(resource.Name, resource.Identifier, resolvedNode)
@@ -130,6 +163,41 @@ func deployResource(resource *Resource) {
 
 }
 
+// createFirst returns true if the new resource for a replacement can be created
+// before the old one is deleted. Create-only properties that end with Name are
+// taken to be names, which have to be different in the new resource. A name
+// that is not set is generated, so it is different. model is the model of
+// the old resource.
+func createFirst(typeName string, resource *yaml.Node, model string) bool {
+	s := cfn.LocalSchema(typeName)
+	if s == nil {
+		return true
+	}
+
+	var old map[string]any
+	if err := json.Unmarshal([]byte(model), &old); err != nil {
+		config.Debugf("unable to read the model of %v: %v", typeName, err)
+		old = make(map[string]any)
+	}
+
+	_, props, _ := s11n.GetMapValue(resource, "Properties")
+	for _, p := range s.CreateOnlyProperties {
+		name := strings.TrimPrefix(p, "/properties/")
+		if strings.Contains(name, "/") || !strings.HasSuffix(name, "Name") {
+			continue
+		}
+		_, value, _ := s11n.GetMapValue(props, name)
+		if value == nil || value.Kind != yaml.ScalarNode {
+			continue
+		}
+		if oldValue, ok := old[name]; ok && fmt.Sprint(oldValue) == value.Value {
+			return false
+		}
+	}
+
+	return true
+}
+
 // ready returns true if the resource has no undeployed dependencies,
 // TODO: unless the Action is Delete, in which case it returns true if it has
 // no undeleted dependents
@@ -200,6 +268,11 @@ func (results *DeploymentResults) Summarize() {
 			if resource.State == Failed {
 				action = "Update"
 			}
+		case diff.Replace:
+			action = "Replaced"
+			if resource.State == Failed {
+				action = "Replace"
+			}
 		case diff.Delete:
 			action = "Deleted"
 			if resource.State == Failed {
@@ -256,6 +329,10 @@ func canDelete(resource *Resource, g *graph.Graph, resourceMap map[string]*Resou
 
 	dependents := g.GetReverse(graph.Node{Name: resource.Name, Type: "Resources"})
 	for _, n := range dependents {
+		if n.Type != "Resources" {
+			// Outputs and conditions are removed along with the template
+			continue
+		}
 		depResource, ok := resourceMap[n.Name]
 		if !ok {
 			// This should not happen
@@ -393,6 +470,11 @@ func DeployTemplate(template cft.Template) (*DeploymentResults, error) {
 	g := graph.New(template)
 	nodes := g.Nodes()
 
+	tm, err := template.Model()
+	if err != nil {
+		return nil, err
+	}
+
 	/*
 		Downwards is "depends on"
 
@@ -419,16 +501,16 @@ func DeployTemplate(template cft.Template) (*DeploymentResults, error) {
 	resourceMap := make(map[string]*Resource)
 	for _, n := range nodes {
 		if n.Type == "Resources" {
-			y, err := getTemplateResource(template, n.Name)
-			if err != nil {
+			res, ok := tm.Resources[n.Name]
+			if !ok {
 				panic(fmt.Sprintf("%v not found in Resources", n.Name))
 			}
+			y := res.Node
 
-			_, typeNode, _ := s11n.GetMapValue(y, "Type")
-			if typeNode == nil {
+			if res.Type == "" {
 				return nil, fmt.Errorf("expected resource %v to have a Type", n.Name)
 			}
-			typeName := typeNode.Value
+			typeName := res.Type
 
 			// Determine if this is a create, update, or delete
 			var action diff.ActionType
@@ -448,7 +530,7 @@ func DeployTemplate(template cft.Template) (*DeploymentResults, error) {
 							action = diff.ActionType(a)
 							isValid := false
 							switch action {
-							case diff.Create, diff.Update, diff.Delete, diff.None:
+							case diff.Create, diff.Update, diff.Replace, diff.Delete, diff.None:
 								isValid = true
 							}
 							if !isValid {
@@ -462,7 +544,7 @@ func DeployTemplate(template cft.Template) (*DeploymentResults, error) {
 							model = string(m)
 						} else if s.Value == "PriorJson" {
 							priorJson = stateNode.Content[i+1].Value
-						} else {
+						} else if s.Value != "ReplaceReason" {
 							config.Debugf("Unexpected State key %v", s.Value)
 						}
 					}
@@ -486,14 +568,17 @@ func DeployTemplate(template cft.Template) (*DeploymentResults, error) {
 		}
 	}
 
+	// Check to make sure there are no circular dependencies,
+	// which would leave resources waiting on each other forever
+	if cycle := g.Cycle(); cycle != nil {
+		return nil, fmt.Errorf("unable to deploy: %v", graph.CycleError{Path: cycle})
+	}
+
 	// Check to make sure there are no deletes with dependents that are not being deleted
 	if err = verifyDeletes(deletes, &g, resourceMap); err != nil {
 		return nil, fmt.Errorf("unable to deploy, deleted resources have one or more dependents: %v", err)
 	}
 
-	// Check to make sure there are no circular dependencies
-	// TODO - Does the graph do this for us already?
-
 	// Delete everything that needs to be deleted first
 	err = deployResources(deletes, results, &g)
 	if err != nil {
@@ -520,6 +605,7 @@ func DeployTemplate(template cft.Template) (*DeploymentResults, error) {
 func init() {
 	createFormat = color.New(color.FgCyan).SprintfFunc()
 	updateFormat = color.New(color.FgYellow).SprintfFunc()
+	replaceFormat = color.New(color.FgRed).SprintfFunc()
 	deleteFormat = color.New(color.FgMagenta).SprintfFunc()
 	failFormat = color.New(color.FgRed).Add(color.Bold).SprintfFunc()
 	successFormat = color.New(color.FgGreen).SprintfFunc()
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/aws-cloudformation/rain/cft/eval"
 	"github.com/aws-cloudformation/rain/cft/parse"
 	"github.com/aws-cloudformation/rain/internal/aws"
 	"github.com/aws-cloudformation/rain/internal/aws/sts"
@@ -25,19 +26,17 @@ import (
 //	Fn::GetAtt
 //	Fn::Sub
 //
+// Conditions, Fn::If, Fn::Base64, Fn::FindInMap, Fn::Join, Fn::Select
+// and Fn::Split are evaluated by eval.Render before deployment, as long as
+// they don't depend on deployed resources.
+//
 // Not Supported:
 //
-//	Fn::Base64
 //	Fn::Cidr
-//	Condition functions
-//	Fn::FindInMap
 //	Fn::ForEach
 //	Fn::GetAZs
 //	Fn::ImportValue
-//	Fn::Join
 //	Fn::Length
-//	Fn::Select
-//	Fn::Split
 //	Fn::ToJsonString
 //	Fn::Transform
 func Resolve(resource *Resource) (*yaml.Node, error) {
@@ -68,7 +67,7 @@ func resolveNode(n *yaml.Node, resource *Resource) (*yaml.Node, error) {
 			config.Debugf("This is a Ref")
 			refVal, err := resolveRef(mapval, resource)
 			if err != nil {
-				return nil, err
+				return nil, atOrigin(n, err)
 			}
 
 			/* We need to convert the entire Mapping node to a scalar
@@ -106,7 +105,7 @@ func resolveNode(n *yaml.Node, resource *Resource) (*yaml.Node, error) {
 			config.Debugf("This is a GetAtt")
 			getAttVal, err := resolveGetAtt(mapval, resource)
 			if err != nil {
-				return nil, err
+				return nil, atOrigin(n, err)
 			}
 
 			// Same as with Ref above, we need to replace the node
@@ -118,7 +117,7 @@ func resolveNode(n *yaml.Node, resource *Resource) (*yaml.Node, error) {
 			config.Debugf("This is a Sub")
 			subVal, err := resolveSub(mapval, resource)
 			if err != nil {
-				return nil, err
+				return nil, atOrigin(n, err)
 			}
 
 			// Same as with Ref above, we need to replace the node
@@ -150,6 +149,14 @@ func resolveNode(n *yaml.Node, resource *Resource) (*yaml.Node, error) {
 	return retval, nil
 }
 
+// atOrigin adds the source location of n to err, if it is known
+func atOrigin(n *yaml.Node, err error) error {
+	if origin, ok := node.GetOrigin(n); ok {
+		return fmt.Errorf("%s: %w", origin, err)
+	}
+	return err
+}
+
 func resolveRef(refNode *yaml.Node, resource *Resource) (string, error) {
 	if refNode.Kind != yaml.ScalarNode {
 		return "", fmt.Errorf("ref Value is not a scalar for %v", resource.Name)
@@ -173,26 +180,13 @@ func resolvePseudoParam(name string) (string, error) {
 		// TODO: Needs special handling to remove nodes from the template
 		return "", errors.New("unsupported: AWS::NoValue")
 	case "Partition":
-		region := aws.Config().Region
-		if strings.HasPrefix(region, "us-gov") {
-			return "aws-us-gov", nil
-		}
-		if strings.HasPrefix(region, "cn-") {
-			return "aws-cn", nil
-		}
-		if strings.HasPrefix(region, "us-iso-") {
-			return "aws-iso", nil
-		}
-		if strings.HasPrefix(region, "us-isob-") {
-			return "aws-iso-b", nil
-		}
-		return "aws", nil
+		return eval.Partition(aws.Config().Region), nil
+	case "URLSuffix":
+		return eval.URLSuffix(aws.Config().Region), nil
 	case "StackId":
 		return "", errors.New("unsupported: AWS::StackId")
 	case "StackName":
 		return "", errors.New("unsupported: AWS::StackName")
-	case "URLSuffix":
-		return "", errors.New("unsupported: AWS::URLSuffix")
 	default:
 		return "", fmt.Errorf("unexpected AWS::%s", p)
 	}
@@ -2,11 +2,13 @@ package cc
 
 import (
 	"fmt"
+	"slices"
 
 	"github.com/aws-cloudformation/rain/cft"
 	"github.com/aws-cloudformation/rain/cft/diff"
 	"github.com/aws-cloudformation/rain/cft/format"
 	"github.com/aws-cloudformation/rain/internal/aws/ccapi"
+	"github.com/aws-cloudformation/rain/internal/aws/cfn"
 	"github.com/aws-cloudformation/rain/internal/config"
 	"github.com/aws-cloudformation/rain/internal/node"
 	"github.com/aws-cloudformation/rain/internal/s11n"
@@ -43,7 +45,28 @@ func update(stateTemplate cft.Template, template cft.Template) (cft.Template, er
 	// Figure out what we're doing with each resource (create, update, delete, nothing)
 	actions := diff.GetResourceActions(d)
 
+	// Cloud Control rejects updates to create-only properties,
+	// so those resources have to be replaced
+	reasons := make(map[string]string)
+	for _, r := range cfn.Replacements(d, template) {
+		if slices.Contains(r.Properties(), "Type") {
+			return stateTemplate, fmt.Errorf("unable to change the Type of %v, give the resource a new logical id instead", r.LogicalId)
+		}
+		if actions[r.LogicalId] == diff.Update {
+			if r.Stateful() && !replaceStateful {
+				return stateTemplate, fmt.Errorf("unable to update %v, because it would be replaced (%v) and the data in the %v would be lost; use --replace-stateful to allow it",
+					r.LogicalId, r.Reason(), r.Type)
+			}
+			actions[r.LogicalId] = diff.Replace
+			reasons[r.LogicalId] = r.Reason()
+		}
+	}
+
 	// Iterate through the state resources and check the diff
+	stateModel, err := stateTemplate.Model()
+	if err != nil {
+		return stateTemplate, err
+	}
 	stateRootMap := stateTemplate.Node.Content[0]
 	_, stateResourceMap, _ := s11n.GetMapValue(stateRootMap, "Resources")
 	if stateResourceMap == nil {
@@ -79,28 +102,23 @@ func update(stateTemplate cft.Template, template cft.Template) (cft.Template, er
 	newTemplate.Node = node.Clone(template.Node)
 
 	// Get a reference to the resources in the new template
-	newRootMap := newTemplate.Node.Content[0]
-	_, newResourceMap, _ := s11n.GetMapValue(newRootMap, "Resources")
-	if newResourceMap == nil {
+	newModel, err := newTemplate.Model()
+	if err != nil {
+		return stateTemplate, err
+	}
+	newResourceMap, err := newTemplate.GetSection(cft.Resources)
+	if err != nil {
 		panic("Expected to find a Resources section in the new template")
 	}
 
 	stateResources := make(map[string]*yaml.Node, 0)
-	newResources := make(map[string]*yaml.Node, 0)
 	resourceActionStates := make(map[string]*yaml.Node) // "State" mapping node
 
-	for i, r := range stateResourceMap.Content {
-		if i%2 == 0 {
-			name := r.Value
-			stateResources[name] = stateResourceMap.Content[i+1]
-		}
+	for name, r := range stateModel.Resources {
+		stateResources[name] = r.Node
 	}
-	for i, r := range newResourceMap.Content {
-		if i%2 == 0 {
-			name := r.Value
-			newResources[name] = newResourceMap.Content[i+1]
-			resourceActionStates[name] = node.AddMap(newResources[name], "State")
-		}
+	for name, r := range newModel.Resources {
+		resourceActionStates[name] = node.AddMap(r.Node, "State")
 	}
 
 	// Iterate over the diff and add actions to the output file
@@ -124,8 +142,11 @@ func update(stateTemplate cft.Template, template cft.Template) (cft.Template, er
 			}
 			newResourceMap.Content = append(newResourceMap.Content, cloned)
 		} else {
-			// Create, Update, None
+			// Create, Update, Replace, None
 			node.Add(rmap, "Action", string(v))
+			if reason, ok := reasons[k]; ok {
+				node.Add(rmap, "ReplaceReason", reason)
+			}
 
 			// Add the identifier so we know what to update
 			if identifier, ok := identifiers[k]; ok {
@@ -236,9 +257,11 @@ func summarizeChanges(changes cft.Template) {
 	})
 	config.Debugf("change template: %v", d)
 
-	rootMap := changes.Node.Content[0]
-	_, resourceMap, _ := s11n.GetMapValue(rootMap, "Resources")
-	if resourceMap == nil {
+	m, err := changes.Model()
+	if err != nil {
+		panic(err)
+	}
+	if len(m.Resources) == 0 {
 		panic("expected Resources")
 	}
 
@@ -249,52 +272,60 @@ func summarizeChanges(changes cft.Template) {
 	headerFmt := color.New(color.FgBlue, color.Underline).SprintfFunc()
 	tbl.WithHeaderFormatter(headerFmt)
 
-	for i, v := range resourceMap.Content {
-		if i%2 == 0 {
-			var action string
-			var t string
-			var ident string
-			name := v.Value
-
-			// Get the Type
-			_, typeNode, _ := s11n.GetMapValue(resourceMap.Content[i+1], "Type")
-			if typeNode == nil {
-				panic(fmt.Sprintf("expected Type on resource %v", name))
-			}
-			t = typeNode.Value
-
-			// Get the action and identifier
-			_, stateMap, _ := s11n.GetMapValue(resourceMap.Content[i+1], "State")
-			if stateMap == nil {
-				action = "Create"
-				ident = ""
-			} else {
-				for si, sv := range stateMap.Content {
-					if si%2 == 0 {
-						val := stateMap.Content[si+1].Value
-						if sv.Value == "Action" {
-							action = val
-						} else if sv.Value == "Identifier" {
-							ident = val
-						}
+	replacements := make([]string, 0)
+	for _, name := range m.Names(cft.Resources) {
+		var action string
+		var ident string
+		r := m.Resources[name]
+
+		// Get the Type
+		if r.Type == "" {
+			panic(fmt.Sprintf("expected Type on resource %v", name))
+		}
+
+		// Get the action and identifier
+		_, stateMap, _ := s11n.GetMapValue(r.Node, "State")
+		if stateMap == nil {
+			action = "Create"
+			ident = ""
+		} else {
+			for si, sv := range stateMap.Content {
+				if si%2 == 0 {
+					val := stateMap.Content[si+1].Value
+					if sv.Value == "Action" {
+						action = val
+					} else if sv.Value == "Identifier" {
+						ident = val
+					} else if sv.Value == "ReplaceReason" {
+						replacements = append(replacements,
+							fmt.Sprintf("%v is replaced because %v changed", name, val))
 					}
 				}
 			}
-			//fmt.Printf("%v\t%v\t%v\t%v\n", name, t, action, ident)
-			var formatter table.Formatter
-			switch action {
-			case "Create":
-				formatter = createFormat
-			case "Update":
-				formatter = updateFormat
-			case "Delete":
-				formatter = deleteFormat
-			default:
-				formatter = nil
-			}
-			tbl.AddRowf(formatter, action, t, name, ident)
 		}
+		//fmt.Printf("%v\t%v\t%v\t%v\n", name, r.Type, action, ident)
+		var formatter table.Formatter
+		switch action {
+		case "Create":
+			formatter = createFormat
+		case "Update":
+			formatter = updateFormat
+		case "Delete":
+			formatter = deleteFormat
+		case "Replace":
+			formatter = replaceFormat
+		default:
+			formatter = nil
+		}
+		tbl.AddRowf(formatter, action, r.Type, name, ident)
 	}
 	tbl.Print()
 	fmt.Println()
+
+	for _, msg := range replacements {
+		fmt.Println(replaceFormat(msg))
+	}
+	if len(replacements) > 0 {
+		fmt.Println()
+	}
 }
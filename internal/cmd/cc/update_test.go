@@ -1,11 +1,14 @@
 package cc
 
 import (
+	"os"
+	"strings"
 	"testing"
 
 	"github.com/aws-cloudformation/rain/cft/format"
 	"github.com/aws-cloudformation/rain/cft/parse"
 	"github.com/aws-cloudformation/rain/internal/config"
+	"github.com/aws-cloudformation/rain/internal/s11n"
 )
 
 func TestUpdate(t *testing.T) {
@@ -36,3 +39,47 @@ func TestUpdate(t *testing.T) {
 	// TODO - Confirm that the change template resources have the correct State:Action
 
 }
+
+func TestUpdateReplace(t *testing.T) {
+	left, err := parse.File("../../../test/templates/ccdeploy1-state.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	source, err := os.ReadFile("../../../test/templates/ccdeploy2.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// QueueName is a create-only property
+	right, err := parse.String(strings.Replace(string(source), "QueueName: ccdeploy-b", "QueueName: ccdeploy-b2", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Queues are stateful, so replacing one has to be allowed
+	if _, err := update(left, right); err == nil || !strings.Contains(err.Error(), "--replace-stateful") {
+		t.Fatalf("expected an error for replacing a queue, got %v", err)
+	}
+
+	replaceStateful = true
+	defer func() { replaceStateful = false }()
+
+	changes, err := update(left, right)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := changes.Model()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, expected := range map[string]string{"A": "Update", "B": "Replace", "C": "Delete", "D": "Create"} {
+		_, state, _ := s11n.GetMapValue(m.Resources[name].Node, "State")
+		_, action, _ := s11n.GetMapValue(state, "Action")
+		if action.Value != expected {
+			t.Errorf("expected %s to be %s, got %s", name, expected, action.Value)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"path/filepath"
 
+	"github.com/aws-cloudformation/rain/cft"
 	"github.com/aws-cloudformation/rain/cft/format"
 	"github.com/aws-cloudformation/rain/internal/aws"
 	"github.com/aws-cloudformation/rain/internal/aws/cfn"
@@ -82,6 +83,7 @@ To list and delete changesets, use the ls and rm commands.
 		var stackName, changeSetName, fn string
 		var err error
 		var stack types.Stack
+		var template cft.Template
 
 		if changeset {
 
@@ -107,7 +109,7 @@ To list and delete changesets, use the ls and rm commands.
 
 			// Package template
 			spinner.Push(fmt.Sprintf("Preparing template '%s'", base))
-			template := PackageTemplate(fn, yes)
+			template = PackageTemplate(fn, yes)
 			spinner.Pop()
 
 			stackName = dc.GetStackName(suppliedStackName, base)
@@ -208,6 +210,12 @@ To list and delete changesets, use the ls and rm commands.
 			} else if status == "UPDATE_COMPLETE" {
 				fmt.Println(console.Green("Successfully updated " + stackName))
 			} else {
+				if sources := failedSources(stackName, template); len(sources) > 0 {
+					fmt.Println(console.Yellow("Failed resources were defined at:"))
+					for _, source := range sources {
+						fmt.Printf("  - %s\n", source)
+					}
+				}
 				panic(fmt.Errorf("failed deploying stack '%s'", stackName))
 			}
 		}
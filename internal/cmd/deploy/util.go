@@ -11,6 +11,7 @@ import (
 	"github.com/aws-cloudformation/rain/internal/config"
 	"github.com/aws-cloudformation/rain/internal/console"
 	"github.com/aws-cloudformation/rain/internal/console/spinner"
+	"github.com/aws-cloudformation/rain/internal/node"
 	"github.com/aws-cloudformation/rain/internal/ui"
 	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
 	"github.com/aws/smithy-go/ptr"
@@ -140,3 +141,49 @@ func CheckStack(stackName string) (types.Stack, bool) {
 
 	return stack, stackExists
 }
+
+// ResourceOrigins returns the source location of each resource in t
+// that came from a file, keyed by logical id. For a packaged template,
+// resources from modules point at the module file.
+func ResourceOrigins(t cft.Template) map[string]node.Origin {
+	origins := make(map[string]node.Origin)
+
+	m, err := t.Model()
+	if err != nil {
+		config.Debugf("unable to read the template model: %v", err)
+		return origins
+	}
+
+	for logicalId, r := range m.Resources {
+		if r.Origin.File != "" {
+			origins[logicalId] = r.Origin
+		}
+	}
+
+	return origins
+}
+
+// failedSources returns the source location of each resource
+// in the stack that failed, as lines to show the user
+func failedSources(stackName string, t cft.Template) []string {
+	origins := ResourceOrigins(t)
+	out := make([]string, 0)
+
+	resources, err := cfn.GetStackResources(stackName)
+	if err != nil {
+		config.Debugf("unable to get resources for stack %s: %v", stackName, err)
+		return out
+	}
+
+	for _, resource := range resources {
+		if ui.MapStatus(string(resource.ResourceStatus)).Category != ui.Failed {
+			continue
+		}
+		logicalId := ptr.ToString(resource.LogicalResourceId)
+		if origin, ok := origins[logicalId]; ok {
+			out = append(out, fmt.Sprintf("%s %s", console.Yellow(logicalId+":"), origin))
+		}
+	}
+
+	return out
+}
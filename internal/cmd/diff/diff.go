@@ -1,41 +1,217 @@
 package diff
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"path/filepath"
+	"strings"
 
+	"github.com/aws-cloudformation/rain/internal/aws/cfn"
+	"github.com/aws-cloudformation/rain/internal/console"
+	"github.com/aws-cloudformation/rain/internal/dc"
 	"github.com/aws-cloudformation/rain/internal/ui"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
 
+	"github.com/aws-cloudformation/rain/cft"
 	"github.com/aws-cloudformation/rain/cft/diff"
+	"github.com/aws-cloudformation/rain/cft/format"
 	"github.com/aws-cloudformation/rain/cft/parse"
 	"github.com/spf13/cobra"
 )
 
 var longDiff = false
+var identityKeys []string
+var detectRenames = true
+var refEdits = false
+var outputFormat = "text"
+var stackName string
+var experimental bool
+var params []string
+var tags []string
+var configFilePath string
 
 // Cmd is the diff command's entrypoint
 var Cmd = &cobra.Command{
-	Use:                   "diff <from> <to>",
-	Short:                 "Compare CloudFormation templates",
-	Long:                  "Outputs a summary of the changes necessary to transform the CloudFormation template named <from> into the template named <to>.",
-	Args:                  cobra.ExactArgs(2),
+	Use:   "diff <from> <to> | diff <template> --stack <stack>",
+	Short: "Compare CloudFormation templates",
+	Long: `Outputs a summary of the changes necessary to transform the CloudFormation template named <from> into the template named <to>.
+
+Use --stack to compare a local template with the template that is deployed to a stack. The local
+template is packaged first, the way rain deploy would package it. Changes to the stack's parameters
+are also shown, using the values from --params and --config, or the stack's current values for
+parameters that are not supplied. Tags are compared if they are supplied with --tags or --config.
+
+Elements of lists are matched by identity rather than by position, so inserting or reordering
+an element only shows the real change. Tags are matched by Key, security group rules by their
+protocol, ports, and source or destination, and lists of scalar values by aligning the elements
+that have not changed.
+
+Use --identity-key to choose the keys that identify the elements of other lists. The path is
+a list of keys, separated by slashes, that matches the end of the path to the list, and * matches
+any key. For example:
+
+  rain diff --identity-key Resources/*/Properties/Rules=Id old.yaml new.yaml
+
+Resources that were removed and added with the same Type and nearly the same content, for
+example because their logical id changed or they were moved in to or out of a module, are shown
+as renamed. Use --ref-edits to list the Ref, GetAtt, Sub, and DependsOn values in <to> that
+still use the old names.
+
+Resources that an update would replace, because their Type, their logical id, or one of the
+create-only properties in their registry schema changed, are listed after the diff. In json
+output, those changes have "replacement": true.
+
+Use --format to choose the output:
+  text       a summary of the changes (the default)
+  json       a list of changes, each with a JSON pointer to the changed element
+  jsonpatch  RFC 6902 operations that turn <from> into <to>, for use with rain patch
+  unified    a line-based diff of the two formatted templates
+
+Stack parameters and tags are only compared in text output.`,
+	Args:                  cobra.RangeArgs(1, 2),
 	DisableFlagsInUseLine: true,
 	Run: func(cmd *cobra.Command, args []string) {
-		leftFn, rightFn := args[0], args[1]
+		var leftFn, rightFn string
+		var left, right cft.Template
+		var stack types.Stack
+		var err error
+
+		if stackName != "" {
+			if len(args) != 1 {
+				panic(errors.New("expected one template to compare with the stack"))
+			}
+			leftFn, rightFn = stackName, args[0]
+			stack, left, right = stackTemplates(stackName, rightFn)
+		} else {
+			if len(args) != 2 {
+				panic(errors.New("expected two templates to compare, or one template and --stack"))
+			}
+			leftFn, rightFn = args[0], args[1]
 
-		left, err := parse.File(leftFn)
-		if err != nil {
-			panic(ui.Errorf(err, "unable to parse template '%s'", leftFn))
+			left, err = parse.File(leftFn)
+			if err != nil {
+				panic(ui.Errorf(err, "unable to parse template '%s'", leftFn))
+			}
+
+			right, err = parse.File(rightFn)
+			if err != nil {
+				panic(ui.Errorf(err, "unable to parse template '%s'", rightFn))
+			}
 		}
 
-		right, err := parse.File(rightFn)
-		if err != nil {
-			panic(ui.Errorf(err, "unable to parse template '%s'", leftFn))
+		options := diff.Options{
+			IdentityKeys:  make(map[string][]string),
+			DetectRenames: detectRenames,
+		}
+		for path, keys := range diff.DefaultIdentityKeys {
+			options.IdentityKeys[path] = keys
+		}
+		for _, k := range identityKeys {
+			path, keys, ok := strings.Cut(k, "=")
+			if !ok || path == "" || keys == "" {
+				panic(fmt.Errorf("invalid identity key '%s', expected path=Key1,Key2", k))
+			}
+			options.IdentityKeys[path] = strings.Split(keys, ",")
 		}
 
-		fmt.Print(ui.ColouriseDiff(diff.New(left, right), longDiff))
+		switch outputFormat {
+		case "text":
+			d := diff.NewWithOptions(left, right, options)
+			fmt.Print(ui.ColouriseDiff(d, longDiff))
+
+			printReplacements(cfn.Replacements(d, right))
+
+			if detectRenames && refEdits {
+				edits := diff.ReferenceEdits(right, diff.FindRenames(left, right, options))
+				if len(edits) > 0 {
+					fmt.Println()
+					fmt.Println("References to update:")
+					for _, edit := range edits {
+						fmt.Printf("  %s\n", edit)
+					}
+				}
+			}
+
+			if stackName != "" {
+				config, err := dc.GetDeployConfig(tags, params, configFilePath, filepath.Base(rightFn),
+					right, stack, true, true, false)
+				if err != nil {
+					panic(err)
+				}
+
+				d := stackConfigDiff(stack, config)
+				if longDiff || d.Mode() != diff.Unchanged {
+					fmt.Println()
+					fmt.Println("Stack parameters and tags:")
+					fmt.Print(ui.ColouriseDiff(d, longDiff))
+				}
+			}
+		case "json":
+			d := diff.NewWithOptions(left, right, options)
+			changes := diff.Changes(d)
+			replaced := make([]string, 0)
+			for _, r := range cfn.Replacements(d, right) {
+				replaced = append(replaced, r.Paths...)
+			}
+			// Changes inside a create-only property also replace the resource
+			for i := range changes {
+				for _, path := range replaced {
+					if changes[i].Path == path || strings.HasPrefix(changes[i].Path, path+"/") {
+						changes[i].Replacement = true
+					}
+				}
+			}
+			printJSON(changes)
+		case "jsonpatch":
+			printJSON(diff.JSONPatch(left, right))
+		case "unified":
+			fmt.Print(diff.Unified(leftFn, rightFn,
+				format.String(left, format.Options{}),
+				format.String(right, format.Options{})))
+		default:
+			panic(fmt.Errorf("unknown format '%s', expected text, json, jsonpatch, or unified", outputFormat))
+		}
 	},
 }
 
+// printReplacements lists the resources that will be replaced,
+// and the create-only properties that changed
+func printReplacements(replacements []cfn.Replacement) {
+	if len(replacements) == 0 {
+		return
+	}
+
+	fmt.Println()
+	fmt.Println("Resources that will be replaced:")
+	for _, r := range replacements {
+		msg := fmt.Sprintf("  %s (%s): %s", r.LogicalId, r.Type, r.Reason())
+		if r.Stateful() {
+			fmt.Println(console.Red(msg + " - the data in this resource will be lost"))
+		} else {
+			fmt.Println(console.Yellow(msg))
+		}
+	}
+}
+
+func printJSON(v interface{}) {
+	out, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		panic(ui.Errorf(err, "unable to output the diff"))
+	}
+
+	fmt.Println(string(out))
+}
+
 func init() {
 	Cmd.Flags().BoolVarP(&longDiff, "long", "l", false, "Include unchanged elements in diff output")
+	Cmd.Flags().StringVarP(&outputFormat, "format", "f", "text", "Output format: text, json, jsonpatch, or unified")
+	Cmd.Flags().StringVarP(&stackName, "stack", "s", "", "Compare the template with the one deployed to this stack")
+	Cmd.Flags().StringSliceVar(&params, "params", []string{}, "With --stack, the parameter values to compare; use the format key1=value1,key2=value2")
+	Cmd.Flags().StringSliceVar(&tags, "tags", []string{}, "With --stack, the tags to compare; use the format key1=value1,key2=value2")
+	Cmd.Flags().StringVarP(&configFilePath, "config", "c", "", "With --stack, a YAML or JSON file with the tags and parameters to compare")
+	Cmd.Flags().BoolVarP(&experimental, "experimental", "x", false, "Acknowledge that modules are experimental when packaging with --stack")
+	Cmd.Flags().BoolVar(&detectRenames, "renames", true, "Show resources with a new logical id as renamed")
+	Cmd.Flags().BoolVar(&refEdits, "ref-edits", false, "List references in <to> that still use the old names of renamed resources")
+	Cmd.Flags().StringArrayVar(&identityKeys, "identity-key", []string{}, "Match the elements of lists at a path by keys, in the form path=Key1,Key2")
 }
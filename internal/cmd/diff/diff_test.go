@@ -28,4 +28,7 @@ func Example_diff() {
 	// (+)       BucketName:
 	// (+)         Ref: Bucket1
 	// (+)     Type: AWS::S3::Bucket
+	//
+	// Resources that will be replaced:
+	//   Bucket1 (AWS::S3::Bucket): Properties/BucketName - the data in this resource will be lost
 }
@@ -0,0 +1,89 @@
+package diff
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/aws-cloudformation/rain/cft"
+	"github.com/aws-cloudformation/rain/cft/diff"
+	"github.com/aws-cloudformation/rain/cft/parse"
+	"github.com/aws-cloudformation/rain/cft/pkg"
+	"github.com/aws-cloudformation/rain/internal/aws/cfn"
+	"github.com/aws-cloudformation/rain/internal/console/spinner"
+	"github.com/aws-cloudformation/rain/internal/dc"
+	"github.com/aws-cloudformation/rain/internal/ui"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/aws/smithy-go/ptr"
+)
+
+// stackTemplates returns the template that is deployed to the stack, and the
+// local template packaged the way rain deploy would package it
+func stackTemplates(stackName, fn string) (types.Stack, cft.Template, cft.Template) {
+	spinner.Push(fmt.Sprintf("Getting the template for stack '%s'", stackName))
+	stack, err := cfn.GetStack(stackName)
+	if err != nil {
+		panic(ui.Errorf(err, "unable to find stack '%s'", stackName))
+	}
+
+	source, err := cfn.GetStackTemplate(stackName, false)
+	if err != nil {
+		panic(ui.Errorf(err, "unable to get the template for stack '%s'", stackName))
+	}
+	spinner.Pop()
+
+	deployed, err := parse.String(source)
+	if err != nil {
+		panic(ui.Errorf(err, "unable to parse the template for stack '%s'", stackName))
+	}
+
+	spinner.Push(fmt.Sprintf("Packaging template '%s'", filepath.Base(fn)))
+	pkg.Experimental = experimental
+	local, err := pkg.File(fn)
+	if err != nil {
+		panic(ui.Errorf(err, "unable to package template '%s'", fn))
+	}
+	spinner.Pop()
+
+	return stack, deployed, local
+}
+
+// stackConfigDiff compares the parameters and tags that rain deploy would
+// use for the template with the ones that the stack has now.
+// Tags are only compared if some were supplied, since the stack's
+// tags are left alone when they aren't.
+func stackConfigDiff(stack types.Stack, config *dc.DeployConfig) diff.Diff {
+	oldParams := make(map[string]interface{})
+	for _, p := range stack.Parameters {
+		oldParams[ptr.ToString(p.ParameterKey)] = ptr.ToString(p.ParameterValue)
+	}
+
+	newParams := make(map[string]interface{})
+	for _, p := range config.Params {
+		key := ptr.ToString(p.ParameterKey)
+		if ptr.ToBool(p.UsePreviousValue) {
+			newParams[key] = oldParams[key]
+		} else {
+			newParams[key] = ptr.ToString(p.ParameterValue)
+		}
+	}
+
+	before := map[string]interface{}{"Parameters": oldParams}
+	after := map[string]interface{}{"Parameters": newParams}
+
+	if len(config.Tags) > 0 {
+		oldTags := make(map[string]interface{})
+		for _, tag := range stack.Tags {
+			oldTags[ptr.ToString(tag.Key)] = ptr.ToString(tag.Value)
+		}
+
+		newTags := make(map[string]interface{})
+		for k, v := range config.Tags {
+			newTags[k] = v
+		}
+
+		before["Tags"] = oldTags
+		after["Tags"] = newTags
+	}
+
+	return diff.CompareMaps(before, after)
+}
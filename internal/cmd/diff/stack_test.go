@@ -0,0 +1,40 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/aws-cloudformation/rain/cft/diff"
+	"github.com/aws-cloudformation/rain/internal/dc"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/aws/smithy-go/ptr"
+)
+
+func TestStackConfigDiff(t *testing.T) {
+	stack := types.Stack{
+		Parameters: []types.Parameter{
+			{ParameterKey: ptr.String("Name"), ParameterValue: ptr.String("old")},
+			{ParameterKey: ptr.String("Size"), ParameterValue: ptr.String("1")},
+		},
+		Tags: []types.Tag{
+			{Key: ptr.String("Team"), Value: ptr.String("a")},
+		},
+	}
+
+	config := &dc.DeployConfig{
+		Params: []types.Parameter{
+			{ParameterKey: ptr.String("Name"), ParameterValue: ptr.String("new")},
+			{ParameterKey: ptr.String("Size"), UsePreviousValue: ptr.Bool(true)},
+		},
+	}
+
+	expected := "(|)map[Parameters:(|)map[Name:(>)new Size:(=)1]]"
+	if d := stackConfigDiff(stack, config); d.String() != expected {
+		t.Errorf("expected %s, got %s", expected, d.String())
+	}
+
+	config.Tags = map[string]string{"Team": "b"}
+	d := stackConfigDiff(stack, config)
+	if d.Mode() != diff.Involved {
+		t.Errorf("expected the tags to change, got %s", d.String())
+	}
+}
@@ -0,0 +1,53 @@
+package fmt
+
+import (
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/aws-cloudformation/rain/internal/aws/cfn"
+)
+
+// cdkClassName returns the name of the stack class for a template file,
+// for example my-bucket.yaml is MyBucketStack
+func cdkClassName(filename string) string {
+	if filename == "" || filename == "<stdin>" {
+		return ""
+	}
+
+	base := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	var sb strings.Builder
+	upper := true
+	for _, r := range base {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if sb.Len() == 0 && unicode.IsDigit(r) {
+			sb.WriteString("Stack")
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		sb.WriteRune(r)
+	}
+	if sb.Len() == 0 {
+		return ""
+	}
+
+	name := sb.String()
+	if !strings.HasSuffix(name, "Stack") {
+		name += "Stack"
+	}
+	return name
+}
+
+// cdkIsJSON uses the registry schema to decide if a property is a JSON value
+func cdkIsJSON(typeName string, path []string) bool {
+	s := cfn.LocalSchema(typeName)
+	if s == nil {
+		return false
+	}
+	return s.IsJSON(path)
+}
@@ -0,0 +1,227 @@
+package fmt
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/aws-cloudformation/rain/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+// IgnoreFile is the name of the file in a directory that lists
+// the files and directories that rain fmt -r skips
+const IgnoreFile = ".rainignore"
+
+// templateExtensions are the extensions of files that might be templates
+var templateExtensions = []string{".yaml", ".yml", ".json", ".template"}
+
+// ignoreRules are the patterns read from an ignore file.
+//
+// Each line is a pattern for filepath.Match. Blank lines and lines that start
+// with # are skipped. A pattern that ends with / only matches directories.
+// A pattern that contains any other / is matched against the path relative
+// to the directory being searched, and other patterns are matched against
+// the name of each file and directory.
+type ignoreRules []string
+
+// readIgnoreFile reads the patterns in an ignore file.
+// It is not an error if the file does not exist.
+func readIgnoreFile(path string) (ignoreRules, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	rules := make(ignoreRules, 0)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rules = append(rules, line)
+	}
+
+	return rules, scanner.Err()
+}
+
+// ignores returns true if the file or directory at rel, a slash-separated
+// path relative to the directory being searched, matches one of the rules
+func (rules ignoreRules) ignores(rel string, isDir bool) bool {
+	for _, rule := range rules {
+		if strings.HasSuffix(rule, "/") {
+			if !isDir {
+				continue
+			}
+			rule = strings.TrimSuffix(rule, "/")
+		}
+
+		name := filepath.Base(rel)
+		if strings.Contains(rule, "/") {
+			name = rel
+			rule = strings.TrimPrefix(rule, "/")
+		}
+
+		if ok, _ := filepath.Match(rule, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isTemplate returns true if the file has a template extension and
+// is a YAML or JSON mapping with AWSTemplateFormatVersion or Resources
+func isTemplate(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	found := false
+	for _, e := range templateExtensions {
+		if ext == e {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return false
+	}
+
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(source, &doc); err != nil {
+		config.Debugf("skipping %s: %v", path, err)
+		return false
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return false
+	}
+
+	m := doc.Content[0]
+	for i := 0; i < len(m.Content); i += 2 {
+		switch m.Content[i].Value {
+		case "AWSTemplateFormatVersion", "Resources":
+			return true
+		}
+	}
+
+	return false
+}
+
+// findTemplates returns the templates in and below each of the directories in roots,
+// skipping anything that matches the ignore file. If ignorePath is empty,
+// the .rainignore file at the top of each directory is used.
+// Files in roots are always included.
+func findTemplates(roots []string, ignorePath string) ([]string, error) {
+	var shared ignoreRules
+	if ignorePath != "" {
+		var err error
+		shared, err = readIgnoreFile(ignorePath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	found := make([]string, 0)
+	for _, root := range roots {
+		info, err := os.Stat(root)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			found = append(found, root)
+			continue
+		}
+
+		rules := shared
+		if ignorePath == "" {
+			rules, err = readIgnoreFile(filepath.Join(root, IgnoreFile))
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if path == root {
+				return nil
+			}
+
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+
+			if d.IsDir() {
+				if d.Name() == ".git" || rules.ignores(rel, true) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			if !rules.ignores(rel, false) && isTemplate(path) {
+				found = append(found, path)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return found, nil
+}
+
+// safeFormatFile returns a panic while formatting the file as an error,
+// since it can't be recovered from outside of the goroutine
+func safeFormatFile(filename string) (res result) {
+	defer func() {
+		if r := recover(); r != nil {
+			res = result{name: filename, err: fmt.Errorf("unable to format '%s': %v", filename, r)}
+		}
+	}()
+
+	return formatFile(filename)
+}
+
+// formatFiles formats the files in parallel and returns the
+// results in the same order as the file names
+func formatFiles(filenames []string) []result {
+	results := make([]result, len(filenames))
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < runtime.NumCPU(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = safeFormatFile(filenames[i])
+			}
+		}()
+	}
+
+	for i := range filenames {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
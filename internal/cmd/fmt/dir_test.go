@@ -0,0 +1,86 @@
+package fmt
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFindTemplates(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"a/template.yaml":     "Resources:\n  B:\n    Type: AWS::S3::Bucket\n",
+		"a/b/template.json":   `{"AWSTemplateFormatVersion": "2010-09-09"}`,
+		"a/b/config.yaml":     "name: not a template\n",
+		"a/notes.txt":         "Resources:\n",
+		"cdk.out/x.template":  "Resources: {}\n",
+		"skip/template.yaml":  "Resources: {}\n",
+		"other/skipped.yml":   "Resources: {}\n",
+		"other/template.yml":  "Resources: {}\n",
+		".git/template.yaml":  "Resources: {}\n",
+		IgnoreFile:            "# comment\ncdk.out/\nskip/template.yaml\nskipped.*\n",
+		"unparseable.yaml":    "Resources: [\n",
+		"other/sequence.yaml": "- Resources\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	found, err := findTemplates([]string{dir}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		filepath.Join(dir, "a/b/template.json"),
+		filepath.Join(dir, "a/template.yaml"),
+		filepath.Join(dir, "other/template.yml"),
+	}
+	if d := cmp.Diff(expected, found); d != "" {
+		t.Error(d)
+	}
+
+	// An empty ignore file only skips .git
+	empty := filepath.Join(t.TempDir(), "ignore")
+	if err := os.WriteFile(empty, []byte{}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	found, err = findTemplates([]string{dir}, empty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 6 {
+		t.Errorf("expected 6 templates, got %v", found)
+	}
+}
+
+func TestFormatFiles(t *testing.T) {
+	names := []string{
+		"../../../test/templates/success.template",
+		"../../../test/templates/failure.template",
+		"../../../test/templates/missing.template",
+	}
+
+	results := formatFiles(names)
+
+	for i, res := range results {
+		if res.name != names[i] {
+			t.Errorf("expected result %d to be %s, got %s", i, names[i], res.name)
+		}
+	}
+	if results[0].err != nil || results[0].output == "" {
+		t.Errorf("expected %s to be formatted: %v", names[0], results[0].err)
+	}
+	if results[2].err == nil {
+		t.Errorf("expected an error for %s", names[2])
+	}
+}
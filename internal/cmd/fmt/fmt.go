@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"strings"
 
 	rainpkl "github.com/aws-cloudformation/rain/pkl"
@@ -20,10 +21,13 @@ import (
 
 var jsonFlag bool
 var pklFlag bool
+var cdkFlag bool
 var verifyFlag bool
 var writeFlag bool
 var unsortedFlag bool
 var dataModel bool
+var recursiveFlag bool
+var ignoreFile string
 
 // pklPackageAlias is the package name to use in module imports
 var pklPackageAlias string = "@cfn"
@@ -38,7 +42,7 @@ type result struct {
 	err    error
 }
 
-func formatString(input string, res *result) {
+func formatString(input string, res *result, jsonOutput bool) {
 
 	// Parse the template
 	source, err := parse.String(string(input))
@@ -55,10 +59,19 @@ func formatString(input string, res *result) {
 			res.err = err
 			return
 		}
+	} else if cdkFlag {
+		res.output, err = format.CftToCdk(source, format.CdkOptions{
+			ClassName: cdkClassName(res.name),
+			IsJSON:    cdkIsJSON,
+		})
+		if err != nil {
+			res.err = err
+			return
+		}
 	} else {
 		// Format the output
 		res.output = format.String(source, format.Options{
-			JSON:     jsonFlag,
+			JSON:     jsonOutput,
 			Unsorted: unsortedFlag,
 		})
 
@@ -72,7 +85,7 @@ func formatString(input string, res *result) {
 	}
 }
 
-func formatReader(name string, r io.Reader) result {
+func formatReader(name string, r io.Reader, jsonOutput bool) result {
 	res := result{
 		name: name,
 	}
@@ -84,7 +97,7 @@ func formatReader(name string, r io.Reader) result {
 		return res
 	}
 
-	formatString(string(input), &res)
+	formatString(string(input), &res, jsonOutput)
 
 	return res
 }
@@ -108,10 +121,11 @@ func formatFile(filename string) result {
 
 		yaml, err := rainpkl.Yaml(filename)
 		if err != nil {
-			panic(err)
+			res.err = ui.Errorf(err, "unable to read '%s'", filename)
+			return res
 		}
 
-		formatString(yaml, &res)
+		formatString(yaml, &res, jsonFlag)
 
 		return res
 	}
@@ -123,16 +137,37 @@ func formatFile(filename string) result {
 			err:  ui.Errorf(err, "unable to read '%s'", filename),
 		}
 	}
+	defer r.Close()
+
+	// Templates found with --recursive keep their format
+	jsonOutput := jsonFlag || (recursiveFlag && strings.EqualFold(filepath.Ext(filename), ".json"))
 
-	return formatReader(filename, r)
+	return formatReader(filename, r, jsonOutput)
 }
 
 // Cmd is the fmt command's entrypoint
 var Cmd = &cobra.Command{
-	Use:                   "fmt <filename>...",
-	Aliases:               []string{"format"},
-	Short:                 "Format CloudFormation templates",
-	Long:                  "Reads CloudFormation templates from filename arguments (or stdin if no filenames are supplied) and formats them",
+	Use:     "fmt <filename>...",
+	Aliases: []string{"format"},
+	Short:   "Format CloudFormation templates",
+	Long: `Reads CloudFormation templates from filename arguments (or stdin if no filenames are supplied) and formats them.
+
+Use --recursive to format every template in and below the directories named as arguments.
+Files with a .yaml, .yml, .json, or .template extension are formatted if they have an
+AWSTemplateFormatVersion or a Resources section, and anything else is skipped. JSON files
+are formatted as JSON.
+
+Files and directories that match a pattern in the .rainignore file at the top of each
+directory are skipped, or in the file named by --ignore-file. Each line of the file is
+a pattern like *.json or cdk.out/, and lines that start with # are comments.
+
+Files are formatted in parallel. With --verify, the exit status is 1 if any file
+would be reformatted or can't be read, and a summary is printed at the end.
+
+Use --cdk to convert a template into a TypeScript CDK stack that creates the same
+resources with L1 (Cfn*) constructs. Parameters, conditions, mappings and outputs are
+converted too, and each construct keeps its logical id, so the stack can be deployed
+over one that was created from the template. The class is named after the file.`,
 	DisableFlagsInUseLine: true,
 	Run: func(cmd *cobra.Command, args []string) {
 		var results []result
@@ -152,46 +187,76 @@ var Cmd = &cobra.Command{
 			writeFlag = false // Can't write back to stdin ;)
 
 			results = []result{
-				formatReader("<stdin>", os.Stdin),
+				formatReader("<stdin>", os.Stdin, jsonFlag),
 			}
 		} else {
-			results = make([]result, len(args))
-			for i, filename := range args {
-				results[i] = formatFile(filename)
+			filenames := args
+			if recursiveFlag {
+				var err error
+				filenames, err = findTemplates(args, ignoreFile)
+				if err != nil {
+					panic(ui.Errorf(err, "unable to find templates"))
+				}
 			}
+
+			results = formatFiles(filenames)
 		}
 
 		hasErr := false
+		failed := 0
+		reformat := 0
 
 		for i, res := range results {
 			if res.err != nil {
 				fmt.Fprintln(os.Stderr, console.Red(res.err))
 				hasErr = true
+				failed++
+				if verifyFlag {
+					continue
+				}
 				break
 			}
 
 			if verifyFlag {
 				if res.ok {
-					fmt.Println(console.Green(fmt.Sprintf("%s: formatted OK", res.name)))
+					if !recursiveFlag {
+						fmt.Println(console.Green(fmt.Sprintf("%s: formatted OK", res.name)))
+					}
 				} else {
 					fmt.Fprintln(os.Stderr, console.Red(fmt.Sprintf("%s: would reformat", res.name)))
 					hasErr = true
+					reformat++
 				}
 			} else if writeFlag {
-				os.WriteFile(res.name, []byte(res.output), 0644)
+				if !res.ok {
+					err := os.WriteFile(res.name, []byte(res.output), 0644)
+					if err != nil {
+						panic(ui.Errorf(err, "unable to write '%s'", res.name))
+					}
+				}
 			} else {
-				if len(args) > 1 {
+				if len(results) > 1 {
 					fmt.Printf("--- # %s\n", res.name)
 				}
 
 				fmt.Print(res.output)
 
-				if len(args) > 1 && i == len(args)-1 {
+				if len(results) > 1 && i == len(results)-1 {
 					fmt.Println("...")
 				}
 			}
 		}
 
+		if verifyFlag && (recursiveFlag || len(results) > 1) {
+			summary := fmt.Sprintf("%d files checked, %d would be reformatted, %d could not be formatted",
+				len(results), reformat, failed)
+			if hasErr {
+				fmt.Fprintln(os.Stderr, console.Red(summary))
+			} else {
+				fmt.Println(console.Green(summary))
+			}
+		}
+
 		if hasErr {
 			os.Exit(1)
 		}
@@ -202,11 +267,14 @@ func init() {
 	Cmd.Flags().BoolVarP(&jsonFlag, "json", "j", false, "Output the template as JSON (default format: YAML).")
 	Cmd.Flags().BoolVarP(&pklFlag, "pkl", "p", false, "Output the template as Pkl (default format: YAML).")
 	Cmd.Flags().BoolVar(&pklBasic, "pkl-basic", false, "Don't use Pkl modules for output")
+	Cmd.Flags().BoolVar(&cdkFlag, "cdk", false, "Output the template as a TypeScript CDK stack that uses L1 constructs")
 	Cmd.Flags().BoolVarP(&verifyFlag, "verify", "v", false, "Check if the input is already correctly formatted and exit.\nThe exit status will be 0 if so and 1 if not.")
 	Cmd.Flags().BoolVarP(&writeFlag, "write", "w", false, "Write the output back to the file rather than to stdout.")
 	Cmd.Flags().BoolVarP(&unsortedFlag, "unsorted", "u", false, "Do not sort the template's properties.")
 	Cmd.Flags().BoolVar(&config.Debug, "debug", false, "Output debugging information")
 	Cmd.Flags().BoolVar(&dataModel, "datamodel", false, "Output the go yaml data model")
+	Cmd.Flags().BoolVarP(&recursiveFlag, "recursive", "r", false, "Format the templates in and below the directories named as arguments")
+	Cmd.Flags().StringVar(&ignoreFile, "ignore-file", "", "With --recursive, the file that lists patterns to skip (default: .rainignore in each directory)")
 	Cmd.Flags().StringVar(&pklPackageAlias, "pkl-package", "@cfn", "An alias or full package URI for the Pkl package for generated Pkl files")
 	Cmd.Flags().StringVar(&format.NodeStyle, "node-style", "", format.NodeStyleDocs)
 }
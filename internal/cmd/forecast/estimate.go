@@ -6,7 +6,6 @@ import (
 	"github.com/aws-cloudformation/rain/cft"
 	"github.com/aws-cloudformation/rain/cft/graph"
 	"github.com/aws-cloudformation/rain/internal/config"
-	"github.com/aws-cloudformation/rain/internal/s11n"
 )
 
 // Estimates is a map of resource type name to ResourceEstimates, which are based on historical averages
@@ -63,25 +62,18 @@ func GetResourceEstimate(resourceType string, action StackAction) (int, error) {
 // For example, "MyBucket" would return "AWS::S3::Bucket" if it is present,
 // otherwise "" is returned
 func getResourceType(t cft.Template, logicalId string) string {
-	rootMap := t.Node.Content[0]
-	_, resources, _ := s11n.GetMapValue(rootMap, "Resources")
-	if resources == nil {
-		panic("Expected to find a Resources section in the template")
+	m, err := t.Model()
+	if err != nil {
+		panic(err)
 	}
-	for i, r := range resources.Content {
-		if i%2 != 0 {
-			continue
-		}
-		if logicalId == r.Value {
-			resource := resources.Content[i+1]
-			_, typeNode, _ := s11n.GetMapValue(resource, "Type")
-			if typeNode == nil {
-				panic(fmt.Sprintf("Expected %v to have a Type", logicalId))
-			}
-			return typeNode.Value
-		}
+	r, ok := m.Resources[logicalId]
+	if !ok {
+		return ""
+	}
+	if r.Type == "" {
+		panic(fmt.Sprintf("Expected %v to have a Type", logicalId))
 	}
-	return ""
+	return r.Type
 }
 
 // addDurations is a recursive function that dives down dependencies to add
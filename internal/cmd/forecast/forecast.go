@@ -9,17 +9,18 @@ import (
 	"strings"
 
 	"github.com/aws-cloudformation/rain/cft"
-	"github.com/aws-cloudformation/rain/cft/format"
-	"github.com/aws-cloudformation/rain/cft/parse"
+	"github.com/aws-cloudformation/rain/cft/eval"
 	"github.com/aws-cloudformation/rain/cft/pkg"
 	"github.com/aws-cloudformation/rain/internal/aws"
 	"github.com/aws-cloudformation/rain/internal/aws/cfn"
 	"github.com/aws-cloudformation/rain/internal/aws/iam"
+	"github.com/aws-cloudformation/rain/internal/aws/sts"
 	"github.com/aws-cloudformation/rain/internal/cmd/deploy"
 	"github.com/aws-cloudformation/rain/internal/config"
 	"github.com/aws-cloudformation/rain/internal/console"
 	"github.com/aws-cloudformation/rain/internal/console/spinner"
 	"github.com/aws-cloudformation/rain/internal/dc"
+	"github.com/aws-cloudformation/rain/internal/node"
 	"github.com/aws-cloudformation/rain/internal/s11n"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v3"
@@ -69,10 +70,11 @@ type PredictionInput struct {
 	dc          *dc.DeployConfig
 	env         Env
 	roleArn     string
+	replacement *cfn.Replacement
 }
 
-// LineNumber is the current line number in the template
-var LineNumber int
+// Position is where the current resource was written in the source files
+var Position node.Origin
 
 // Forecast represents predictions for a single resource in the template
 type Forecast struct {
@@ -101,7 +103,7 @@ func (f *Forecast) Append(forecast Forecast) {
 
 // Add adds a pass or fail message, formatting it to include the type name and logical id
 func (f *Forecast) Add(passed bool, message string) {
-	msg := fmt.Sprintf("%v: %v %v - %v", LineNumber, f.TypeName, f.LogicalId, message)
+	msg := fmt.Sprintf("%v: %v %v - %v", Position, f.TypeName, f.LogicalId, message)
 	if passed {
 		f.Passed = append(f.Passed, msg)
 	} else {
@@ -203,12 +205,15 @@ func forecastForType(input PredictionInput) Forecast {
 		input.stackExists, input.source.Node, input.dc) {
 		forecast.Add(false, "Already exists")
 	} else {
-		LineNumber = input.resource.Line
+		Position, _ = node.GetOrigin(input.resource)
 		forecast.Add(true, "Does not exist")
 	}
 
 	spinner.Pop()
 
+	// Warn about resources that the update will replace
+	checkReplacement(input, &forecast)
+
 	// Check permissions
 	if !SkipIAM {
 		err := checkPermissions(input, &forecast)
@@ -244,7 +249,7 @@ func forecastForType(input PredictionInput) Forecast {
 
 // Query the account to make predictions about deployment failures.
 // Returns true if no failures are predicted.
-func predict(source cft.Template, stackName string, stack types.Stack, stackExists bool, dc *dc.DeployConfig) bool {
+func predict(source cft.Template, stackName string, accountId string, stack types.Stack, stackExists bool, dc *dc.DeployConfig) bool {
 
 	config.Debugf("About to make API calls for failure prediction...")
 
@@ -257,37 +262,37 @@ func predict(source cft.Template, stackName string, stack types.Stack, stackExis
 
 	forecast := makeForecast("", "")
 
-	rootMap := source.Node.Content[0]
-
-	// Add the --debug arg to see a json version of the yaml node data model for the template
-	//config.Debugf("node: %v", toJson(rootMap))
+	m, err := source.Model()
+	if err != nil {
+		panic(err)
+	}
 
 	// Iterate over each resource
 
-	_, resources, _ := s11n.GetMapValue(rootMap, "Resources")
-	if resources == nil {
+	if len(m.Resources) == 0 {
 		panic("Expected to find a Resources section in the template")
 	}
 
-	for i, r := range resources.Content {
+	// Find the resources that an update will replace
+	replacements := make(map[string]cfn.Replacement)
+	if stackExists {
+		replacements = getReplacements(source, stackName, accountId, stack)
+	}
 
-		if i%2 != 0 {
-			continue
-		}
-		logicalId := r.Value
-		LineNumber = r.Line
+	for _, logicalId := range m.Names(cft.Resources) {
+
+		res := m.Resources[logicalId]
+		Position = res.Origin
 		config.Debugf("logicalId: %v", logicalId)
 
-		resource := resources.Content[i+1]
-		_, typeNode, _ := s11n.GetMapValue(resource, "Type")
-		if typeNode == nil {
+		if res.Type == "" {
 			panic(fmt.Sprintf("Expected %v to have a Type", logicalId))
 		}
 
 		// Check the type and call functions that make checks
 		// on that type of resource.
 
-		typeName := typeNode.Value // Should be something like AWS::S3::Bucket
+		typeName := res.Type // Should be something like AWS::S3::Bucket
 		config.Debugf("typeName: %v", typeName)
 
 		spinner.Push(fmt.Sprintf("Checking %s: %s", typeName, logicalId))
@@ -295,12 +300,15 @@ func predict(source cft.Template, stackName string, stack types.Stack, stackExis
 		input := PredictionInput{}
 		input.logicalId = logicalId
 		input.source = source
-		input.resource = resource
+		input.resource = res.Node
 		input.stackName = stackName
 		input.stackExists = stackExists
 		input.stack = stack
 		input.typeName = typeName
 		input.dc = dc
+		if r, ok := replacements[logicalId]; ok {
+			input.replacement = &r
+		}
 		cfg := aws.Config()
 		callerArn, err := iam.GetCallerArn(cfg) // arn:aws:iam::755952356119:role/Admin
 		if err != nil {
@@ -382,7 +390,7 @@ NOTE: This is an experimental feature!
 
 To use this command, add --experimental or -x as an argument.
 
-This command is not a linter! Use cfn-lint for that. The forecast command 
+This command is not a linter! Use rain lint or cfn-lint for that. The forecast command 
 is concerned with things that could go wrong during deployment, after the 
 template has been checked to make sure it has a valid syntax.
 
@@ -390,6 +398,8 @@ This command checks for some common issues across all resources:
 
 - The resource already exists
 - You do not have permissions to create/update/delete the resource
+- An update replaces a resource that holds data, like a database or a bucket,
+  because one of its create-only properties changed
 - (More to come.. service quotas, drift issues)
 
 Resource-specific checks:
@@ -424,15 +434,6 @@ Resource-specific checks:
 			panic(err)
 		}
 
-		// Packaging is necessary if we want to forecast a template with
-		// modules or anything else that needs packaging.
-		// But.. we lost line numbers, so we need to re-parse the file
-		content := format.CftToYaml(source)
-		source, err = parse.String(content)
-		if err != nil {
-			panic(err)
-		}
-
 		stackName := dc.GetStackName(suppliedStackName, base)
 
 		// Check current stack status
@@ -454,7 +455,28 @@ Resource-specific checks:
 			panic(err)
 		}
 
-		if !predict(source, stackName, stack, stackExists, dc) {
+		// Evaluate conditions and intrinsic functions so that we only
+		// check the resources that will actually be deployed
+		values := dc.ParamMap()
+		for _, p := range stack.Parameters {
+			// Parameters that use their previous value
+			if _, ok := values[*p.ParameterKey]; !ok && p.ParameterValue != nil && *p.ParameterValue != "****" {
+				values[*p.ParameterKey] = *p.ParameterValue
+			}
+		}
+		accountId, err := sts.GetAccountID()
+		if err != nil {
+			panic(err)
+		}
+		source, err = eval.Render(source, eval.Options{
+			Parameters:       values,
+			PseudoParameters: eval.DefaultPseudoParameters(aws.Config().Region, accountId, stackName),
+		})
+		if err != nil {
+			panic(err)
+		}
+
+		if !predict(source, stackName, accountId, stack, stackExists, dc) {
 			os.Exit(1)
 		}
 
@@ -56,7 +56,7 @@ func checkRDSDBCluster(input PredictionInput) Forecast {
 				}
 			}
 			if unexpected {
-				LineNumber = input.resource.Line
+				Position, _ = node.GetOrigin(input.resource)
 				config.Debugf("db cluster resource: %s", node.ToJson(input.resource))
 				forecast.Add(false, fmt.Sprintf("unexpected EngineVersion: %s", engineVersion.Value))
 			} else {
@@ -129,39 +129,33 @@ func checkRDSDBCluster(input PredictionInput) Forecast {
 
 	// TODO: Move this to DBInstance checks when we implement them
 
-	resources, err := input.source.GetSection(cft.Resources)
+	m, err := input.source.Model()
 	if err == nil {
-		for i := 0; i < len(resources.Content); i += 2 {
-			logicalId := resources.Content[i].Value
+		for _, logicalId := range m.Names(cft.Resources) {
 			config.Debugf("Looking for instances: %s", logicalId)
-			r := resources.Content[i+1]
-			_, t, _ := s11n.GetMapValue(r, "Type")
-			if t != nil && t.Value == "AWS::RDS::DBInstance" {
-				config.Debugf("Found instance")
-				_, instanceProps, _ := s11n.GetMapValue(r, "Properties")
-				if instanceProps != nil {
-					for j := 0; j < len(instanceProps.Content); j += 2 {
-						propName := instanceProps.Content[j].Value
-						if propName == "EngineVersion" {
-							evNode := instanceProps.Content[j+1]
-							config.Debugf("instanceVersion: %s", node.ToSJson(evNode))
-
-							// Resolve refs first
-							resolveParamRefs(propName, evNode, input.dc, instanceProps)
-
-							config.Debugf("instanceVersion after: %s", node.ToSJson(evNode))
-
-							instanceVersion := evNode.Value
-							if evNode.Kind == yaml.ScalarNode && instanceVersion != clusterEngineVersion {
-								forecast.Add(false, fmt.Sprintf(
-									"engine mismatch with %s: %s != %s",
-									logicalId, instanceVersion, clusterEngineVersion))
-							} else {
-								forecast.Add(true, "instance engine version matches")
-							}
-						}
-					}
-				}
+			r := m.Resources[logicalId]
+			if r.Type != "AWS::RDS::DBInstance" {
+				continue
+			}
+			config.Debugf("Found instance")
+			evNode, ok := r.Properties["EngineVersion"]
+			if !ok {
+				continue
+			}
+			config.Debugf("instanceVersion: %s", node.ToSJson(evNode))
+
+			// Resolve refs first
+			resolveParamRefs("EngineVersion", evNode, input.dc, r.Node)
+
+			config.Debugf("instanceVersion after: %s", node.ToSJson(evNode))
+
+			instanceVersion := evNode.Value
+			if evNode.Kind == yaml.ScalarNode && instanceVersion != clusterEngineVersion {
+				forecast.Add(false, fmt.Sprintf(
+					"engine mismatch with %s: %s != %s",
+					logicalId, instanceVersion, clusterEngineVersion))
+			} else {
+				forecast.Add(true, "instance engine version matches")
 			}
 		}
 	}
@@ -0,0 +1,83 @@
+package forecast
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws-cloudformation/rain/cft"
+	"github.com/aws-cloudformation/rain/cft/diff"
+	"github.com/aws-cloudformation/rain/cft/eval"
+	"github.com/aws-cloudformation/rain/cft/parse"
+	"github.com/aws-cloudformation/rain/internal/aws"
+	"github.com/aws-cloudformation/rain/internal/aws/cfn"
+	"github.com/aws-cloudformation/rain/internal/config"
+	"github.com/aws-cloudformation/rain/internal/s11n"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+)
+
+// getReplacements compares the template with the one that is deployed to the
+// stack, and returns the resources that the update will replace by logical id
+func getReplacements(source cft.Template, stackName string, accountId string, stack types.Stack) map[string]cfn.Replacement {
+	retval := make(map[string]cfn.Replacement)
+
+	deployedSource, err := cfn.GetStackTemplate(stackName, false)
+	if err != nil {
+		config.Debugf("unable to get the template for stack %v: %v", stackName, err)
+		return retval
+	}
+
+	deployed, err := parse.String(deployedSource)
+	if err != nil {
+		config.Debugf("unable to parse the template for stack %v: %v", stackName, err)
+		return retval
+	}
+
+	// Render the deployed template with the stack's current parameter
+	// values, so that it can be compared with the rendered source
+	values := make(map[string]string)
+	for _, p := range stack.Parameters {
+		if p.ParameterValue != nil && *p.ParameterValue != "****" {
+			values[*p.ParameterKey] = *p.ParameterValue
+		}
+	}
+	deployed, err = eval.Render(deployed, eval.Options{
+		Parameters:       values,
+		PseudoParameters: eval.DefaultPseudoParameters(aws.Config().Region, accountId, stackName),
+	})
+	if err != nil {
+		config.Debugf("unable to render the template for stack %v: %v", stackName, err)
+		return retval
+	}
+
+	for _, r := range cfn.Replacements(diff.New(deployed, source), source) {
+		retval[r.LogicalId] = r
+	}
+
+	return retval
+}
+
+// checkReplacement reports a resource that the update will replace.
+// The check fails if the resource holds data that will be lost,
+// unless its UpdateReplacePolicy keeps the old resource.
+func checkReplacement(input PredictionInput, forecast *Forecast) {
+	if input.replacement == nil {
+		return
+	}
+
+	msg := fmt.Sprintf("Will be replaced because %v changed",
+		strings.Join(input.replacement.Properties(), ", "))
+
+	if !input.replacement.Stateful() {
+		forecast.Add(true, msg)
+		return
+	}
+
+	_, policy, _ := s11n.GetMapValue(input.resource, "UpdateReplacePolicy")
+	if policy != nil && (policy.Value == "Retain" || policy.Value == "Snapshot") {
+		forecast.Add(true, fmt.Sprintf("%v, UpdateReplacePolicy is %v", msg, policy.Value))
+		return
+	}
+
+	forecast.Add(false, msg+", and the data in it will be lost. "+
+		"Set UpdateReplacePolicy to Retain or Snapshot to keep the old resource")
+}
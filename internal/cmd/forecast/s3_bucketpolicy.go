@@ -7,6 +7,7 @@ import (
 	"github.com/aws-cloudformation/rain/internal/aws/iam"
 	"github.com/aws-cloudformation/rain/internal/config"
 	"github.com/aws-cloudformation/rain/internal/console/spinner"
+	"github.com/aws-cloudformation/rain/internal/node"
 	"github.com/aws-cloudformation/rain/internal/s11n"
 )
 
@@ -43,7 +44,7 @@ func checkS3BucketPolicy(input PredictionInput) Forecast {
 			}
 
 			if !res {
-				LineNumber = policyDocument.Line
+				Position, _ = node.GetOrigin(policyDocument)
 				forecast.Add(false, "Invalid principal in policy document")
 			} else {
 				forecast.Add(true, "Principal is valid")
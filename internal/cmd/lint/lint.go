@@ -0,0 +1,96 @@
+package lint
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/aws-cloudformation/rain/cft"
+	"github.com/aws-cloudformation/rain/cft/parse"
+	"github.com/aws-cloudformation/rain/cft/pkg"
+	"github.com/aws-cloudformation/rain/internal/console"
+	"github.com/aws-cloudformation/rain/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var outputFormat string
+var pkgFlag bool
+var experimental bool
+
+// Cmd is the lint command's entrypoint
+var Cmd = &cobra.Command{
+	Use:   "lint <template> [<template>...]",
+	Short: "Check resource properties against the registry schemas",
+	Long: `Checks the Properties of each resource in a template against the CloudFormation registry
+schema for its type. The schemas are built in to rain, so no AWS credentials are needed.
+
+Reports unknown resource types and properties, missing required properties, values with the
+wrong type, values that break enum, pattern, length, or minimum and maximum constraints,
+and read-only properties that are set in the template.
+
+Values that are intrinsic functions are not checked.
+
+Use --pkg to package the template first, so that resources from modules and included files
+are checked. Findings point at the file and line where the value was written, along with the
+chain of modules that brought it in.
+
+Use --format to choose text, json, or sarif output. The exit code is 1 if any errors are found.`,
+	Args:                  cobra.MinimumNArgs(1),
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		findings := make([]Finding, 0)
+
+		for _, fileName := range args {
+			var t cft.Template
+			var err error
+			if pkgFlag {
+				pkg.Experimental = experimental
+				t, err = pkg.File(fileName)
+			} else {
+				t, err = parse.File(fileName)
+			}
+			if err != nil {
+				panic(ui.Errorf(err, "unable to read template '%s'", fileName))
+			}
+
+			f, err := Template(fileName, t)
+			if err != nil {
+				panic(ui.Errorf(err, "unable to lint template '%s'", fileName))
+			}
+
+			findings = append(findings, f...)
+		}
+
+		var out string
+		var err error
+		switch outputFormat {
+		case "text":
+			out = formatText(findings)
+			if len(findings) == 0 {
+				out = console.Green("No problems found")
+			}
+		case "json":
+			out, err = formatJSON(findings)
+		case "sarif":
+			out, err = formatSARIF(findings)
+		default:
+			panic(fmt.Errorf("unknown format '%s', expected text, json, or sarif", outputFormat))
+		}
+		if err != nil {
+			panic(err)
+		}
+
+		fmt.Println(out)
+
+		for _, f := range findings {
+			if f.Severity == Error {
+				os.Exit(1)
+			}
+		}
+	},
+}
+
+func init() {
+	Cmd.Flags().StringVarP(&outputFormat, "format", "f", "text", "output format: text, json, or sarif")
+	Cmd.Flags().BoolVar(&pkgFlag, "pkg", false, "package the template before checking it")
+	Cmd.Flags().BoolVarP(&experimental, "experimental", "x", false, "acknowledge that modules are experimental when using --pkg")
+}
@@ -0,0 +1,37 @@
+package lint_test
+
+import (
+	"fmt"
+
+	"github.com/aws-cloudformation/rain/cft/parse"
+	"github.com/aws-cloudformation/rain/internal/cmd/lint"
+)
+
+func Example_lint() {
+	fileName := "../../../test/templates/lint.yaml"
+
+	t, err := parse.File(fileName)
+	if err != nil {
+		panic(err)
+	}
+
+	findings, err := lint.Template("lint.yaml", t)
+	if err != nil {
+		panic(err)
+	}
+
+	for _, f := range findings {
+		fmt.Println(f)
+	}
+	// Output:
+	// lint.yaml:10:7: error: Bucket: Arn is read-only (read-only)
+	// lint.yaml:11:7: error: Bucket: unknown property BucketNam (unknown-property)
+	// lint.yaml:12:22: error: Bucket: AccessControl: 'Everyone' is not one of AuthenticatedRead, AwsExecRead, BucketOwnerFullControl, BucketOwnerRead, LogDeliveryWrite, Private, PublicRead, PublicReadWrite (enum)
+	// lint.yaml:13:26: error: Bucket: ObjectLockEnabled: expected boolean, got 'maybe' (type)
+	// lint.yaml:15:16: error: Bucket: Tags/0/Key: expected at least 1 characters, got 0 (length)
+	// lint.yaml:17:11: error: Bucket: missing required property Tags/1/Key (required)
+	// lint.yaml:23:26: error: Cidr: Ipv6NetmaskLength: 200 is more than the maximum 128 (range)
+	// lint.yaml:28:24: error: Log: RetentionInDays: expected integer, got array (type)
+	// lint.yaml:29:21: error: Log: LogGroupName: 'not valid!' does not match the pattern ^[.\-_/#A-Za-z0-9]{1,512}\Z (pattern)
+	// lint.yaml:32:11: error: Nope: unknown resource type AWS::Nope::Nothing (unknown-type)
+}
@@ -0,0 +1,415 @@
+package lint
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/aws-cloudformation/rain/cft"
+	"github.com/aws-cloudformation/rain/internal/aws/cfn"
+	"github.com/aws-cloudformation/rain/internal/config"
+	"github.com/aws-cloudformation/rain/internal/node"
+	"gopkg.in/yaml.v3"
+)
+
+// Error is the severity of a Finding
+const Error = "error"
+
+// Rules that a Finding can break
+const (
+	RuleUnknownType     = "unknown-type"
+	RuleUnknownProperty = "unknown-property"
+	RuleRequired        = "required"
+	RuleType            = "type"
+	RuleEnum            = "enum"
+	RulePattern         = "pattern"
+	RuleLength          = "length"
+	RuleRange           = "range"
+	RuleReadOnly        = "read-only"
+)
+
+// ruleDescriptions are used in SARIF output
+var ruleDescriptions = map[string]string{
+	RuleUnknownType:     "The resource type is not in the CloudFormation registry",
+	RuleUnknownProperty: "The property is not defined in the resource schema",
+	RuleRequired:        "A required property is missing",
+	RuleType:            "The value does not have the type defined in the resource schema",
+	RuleEnum:            "The value is not one of the allowed values",
+	RulePattern:         "The value does not match the pattern in the resource schema",
+	RuleLength:          "The value is too short or too long",
+	RuleRange:           "The value is outside the allowed range",
+	RuleReadOnly:        "The property is read-only and can't be set in a template",
+}
+
+// Finding is a problem found in a template
+type Finding struct {
+	File     string   `json:"file"`
+	Line     int      `json:"line"`
+	Column   int      `json:"column"`
+	Modules  []string `json:"modules,omitempty"`
+	Resource string   `json:"resource"`
+	Path     string   `json:"path,omitempty"`
+	Rule     string   `json:"rule"`
+	Severity string   `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+func (f Finding) String() string {
+	s := fmt.Sprintf("%s:%d:%d: %s: %s: %s (%s)",
+		f.File, f.Line, f.Column, f.Severity, f.Resource, f.Message, f.Rule)
+	if len(f.Modules) > 0 {
+		s += fmt.Sprintf(" via %s", strings.Join(f.Modules, " > "))
+	}
+	return s
+}
+
+// patterns caches compiled schema patterns.
+// A nil value means the pattern is not supported by Go's regexp package.
+var patterns = make(map[string]*regexp.Regexp)
+
+// Template checks each resource in the template against its registry schema
+func Template(fileName string, t cft.Template) ([]Finding, error) {
+	m, err := t.Model()
+	if err != nil {
+		return nil, err
+	}
+
+	findings := make([]Finding, 0)
+
+	for _, logicalId := range m.Names(cft.Resources) {
+		r := m.Resources[logicalId]
+
+		v := &validator{
+			file:      fileName,
+			logicalId: logicalId,
+			findings:  &findings,
+		}
+
+		// Skip things like modules and custom resources
+		if r.Type == "" || !strings.HasPrefix(r.Type, "AWS::") {
+			continue
+		}
+
+		schema := cfn.LocalSchema(r.Type)
+		if schema == nil {
+			if !strings.HasPrefix(r.Type, "AWS::Serverless::") {
+				typeNode := r.Node
+				for i := 0; i+1 < len(r.Node.Content); i += 2 {
+					if r.Node.Content[i].Value == "Type" {
+						typeNode = r.Node.Content[i+1]
+					}
+				}
+				v.add(typeNode, "", RuleUnknownType, Error,
+					fmt.Sprintf("unknown resource type %s", r.Type))
+			}
+			continue
+		}
+
+		v.schema = schema
+		root := &cfn.Prop{
+			Type:                 "object",
+			Properties:           schema.Properties,
+			Required:             schema.Required,
+			AdditionalProperties: schema.AdditionalProperties,
+		}
+
+		props := r.Node
+		for i := 0; i+1 < len(r.Node.Content); i += 2 {
+			if r.Node.Content[i].Value == "Properties" {
+				props = r.Node.Content[i+1]
+			}
+		}
+		if props == r.Node {
+			// There is no Properties section
+			empty := &yaml.Node{Kind: yaml.MappingNode, Line: r.Line, Column: r.Node.Column}
+			node.InheritOrigin(empty, r.Node)
+			v.required(root, empty, nil)
+			continue
+		}
+
+		v.validate(root, props, nil, "/properties", true)
+	}
+
+	return findings, nil
+}
+
+type validator struct {
+	file      string
+	logicalId string
+	schema    *cfn.Schema
+	findings  *[]Finding
+}
+
+func (v *validator) add(n *yaml.Node, path string, rule string, severity string, message string) {
+	// Nodes from a packaged template know which file they came from
+	origin, ok := node.GetOrigin(n)
+	if !ok || origin.File == "" {
+		origin.File = v.file
+	}
+
+	*v.findings = append(*v.findings, Finding{
+		File:     origin.File,
+		Line:     origin.Line,
+		Column:   origin.Column,
+		Modules:  origin.Modules,
+		Resource: v.logicalId,
+		Path:     path,
+		Rule:     rule,
+		Severity: severity,
+		Message:  message,
+	})
+}
+
+// resolve follows $ref to the schema definitions
+func (v *validator) resolve(p *cfn.Prop) *cfn.Prop {
+	for i := 0; p != nil && p.Ref != "" && i < 10; i++ {
+		name := strings.TrimPrefix(p.Ref, "#/definitions/")
+		def, ok := v.schema.Definitions[name]
+		if !ok {
+			config.Debugf("unable to resolve %s in %s", p.Ref, v.schema.TypeName)
+			return nil
+		}
+		p = def
+	}
+	return p
+}
+
+// isIntrinsic returns true if the node is an intrinsic function or
+// a rain directive, which can't be validated until the template is deployed
+func isIntrinsic(n *yaml.Node) bool {
+	if n.Kind != yaml.MappingNode || len(n.Content) != 2 {
+		return false
+	}
+	key := n.Content[0].Value
+	return key == "Ref" || key == "Condition" ||
+		strings.HasPrefix(key, "Fn::") || strings.HasPrefix(key, "Rain::")
+}
+
+// propTypes returns the JSON schema types that are allowed for the property
+func propTypes(p *cfn.Prop) []string {
+	switch t := p.Type.(type) {
+	case string:
+		if t != "" {
+			return []string{t}
+		}
+	case []any:
+		retval := make([]string, 0)
+		for _, item := range t {
+			if s, ok := item.(string); ok {
+				retval = append(retval, s)
+			}
+		}
+		return retval
+	}
+
+	switch {
+	case len(p.Properties) > 0:
+		return []string{"object"}
+	case p.Items != nil:
+		return []string{"array"}
+	}
+
+	return nil
+}
+
+func matchesType(n *yaml.Node, t string) bool {
+	switch t {
+	case "object":
+		return n.Kind == yaml.MappingNode
+	case "array":
+		return n.Kind == yaml.SequenceNode
+	case "string":
+		return n.Kind == yaml.ScalarNode
+	case "integer":
+		_, err := strconv.ParseInt(n.Value, 10, 64)
+		return n.Kind == yaml.ScalarNode && err == nil
+	case "number":
+		_, err := strconv.ParseFloat(n.Value, 64)
+		return n.Kind == yaml.ScalarNode && err == nil
+	case "boolean":
+		_, err := strconv.ParseBool(n.Value)
+		return n.Kind == yaml.ScalarNode && err == nil
+	}
+	return true
+}
+
+func kindName(n *yaml.Node) string {
+	switch n.Kind {
+	case yaml.MappingNode:
+		return "object"
+	case yaml.SequenceNode:
+		return "array"
+	}
+	return fmt.Sprintf("'%s'", n.Value)
+}
+
+// validate checks a value in the template against a schema property.
+// path is the location in the template and pointer is the
+// location in the schema's format, like /properties/Tags/*/Key
+func (v *validator) validate(p *cfn.Prop, n *yaml.Node, path []string, pointer string, closed bool) {
+	p = v.resolve(p)
+	if n.Kind == yaml.AliasNode && n.Alias != nil {
+		n = n.Alias
+	}
+	if p == nil || isIntrinsic(n) {
+		return
+	}
+
+	pathString := strings.Join(path, "/")
+
+	types := propTypes(p)
+	if len(types) > 0 {
+		ok := false
+		for _, t := range types {
+			if matchesType(n, t) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			v.add(n, pathString, RuleType, Error, fmt.Sprintf("%s: expected %s, got %s",
+				pathString, strings.Join(types, " or "), kindName(n)))
+			return
+		}
+	}
+
+	switch n.Kind {
+	case yaml.ScalarNode:
+		v.scalar(p, n, pathString, types)
+	case yaml.SequenceNode:
+		if p.MinItems > 0 && len(n.Content) < p.MinItems {
+			v.add(n, pathString, RuleLength, Error, fmt.Sprintf("%s: expected at least %d items, got %d",
+				pathString, p.MinItems, len(n.Content)))
+		}
+		if p.MaxItems > 0 && len(n.Content) > p.MaxItems {
+			v.add(n, pathString, RuleLength, Error, fmt.Sprintf("%s: expected at most %d items, got %d",
+				pathString, p.MaxItems, len(n.Content)))
+		}
+		if p.Items != nil {
+			for i, item := range n.Content {
+				v.validate(p.Items, item, extend(path, fmt.Sprint(i)), pointer+"/*", false)
+			}
+		}
+	case yaml.MappingNode:
+		v.required(p, n, path)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key := n.Content[i]
+			childPath := extend(path, key.Value)
+			childPointer := pointer + "/" + key.Value
+
+			if slices.Contains(v.schema.ReadOnlyProperties, childPointer) {
+				v.add(key, strings.Join(childPath, "/"), RuleReadOnly, Error,
+					fmt.Sprintf("%s is read-only", strings.Join(childPath, "/")))
+				continue
+			}
+
+			child, ok := p.Properties[key.Value]
+			if !ok {
+				// Objects without properties in the schema, like policy
+				// documents, and objects that allow additional properties
+				// can have anything in them
+				if !p.AdditionalProperties &&
+					(closed || (len(p.Properties) > 0 && p.PatternProperties == nil)) {
+					v.add(key, strings.Join(childPath, "/"), RuleUnknownProperty, Error,
+						fmt.Sprintf("unknown property %s", strings.Join(childPath, "/")))
+				}
+				continue
+			}
+
+			v.validate(child, n.Content[i+1], childPath, childPointer, false)
+		}
+	}
+}
+
+// extend returns a copy of path with name added to the end
+func extend(path []string, name string) []string {
+	retval := make([]string, len(path), len(path)+1)
+	copy(retval, path)
+	return append(retval, name)
+}
+
+// ignoreRequired lists properties that are required by a registry
+// schema but are optional in a CloudFormation template
+var ignoreRequired = map[string][]string{
+	"AWS::CloudFormation::Stack": {"StackName"},
+}
+
+// required checks that all required properties are set on an object
+func (v *validator) required(p *cfn.Prop, n *yaml.Node, path []string) {
+	for _, name := range p.Required {
+		if len(path) == 0 && slices.Contains(ignoreRequired[v.schema.TypeName], name) {
+			continue
+		}
+		found := false
+		for i := 0; i < len(n.Content); i += 2 {
+			if n.Content[i].Value == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing := strings.Join(extend(path, name), "/")
+			v.add(n, strings.Join(path, "/"), RuleRequired, Error,
+				fmt.Sprintf("missing required property %s", missing))
+		}
+	}
+}
+
+func (v *validator) scalar(p *cfn.Prop, n *yaml.Node, path string, types []string) {
+	if len(p.Enum) > 0 {
+		allowed := make([]string, 0, len(p.Enum))
+		for _, e := range p.Enum {
+			allowed = append(allowed, fmt.Sprint(e))
+		}
+		if !slices.Contains(allowed, n.Value) {
+			v.add(n, path, RuleEnum, Error, fmt.Sprintf("%s: '%s' is not one of %s",
+				path, n.Value, strings.Join(allowed, ", ")))
+		}
+	}
+
+	if p.Pattern != "" {
+		re, ok := patterns[p.Pattern]
+		if !ok {
+			var err error
+			// Go uses \z for the end of the text
+			re, err = regexp.Compile(strings.ReplaceAll(p.Pattern, `\Z`, `\z`))
+			if err != nil {
+				config.Debugf("unable to compile pattern %s: %v", p.Pattern, err)
+			}
+			patterns[p.Pattern] = re
+		}
+		if re != nil && !re.MatchString(n.Value) {
+			v.add(n, path, RulePattern, Error, fmt.Sprintf("%s: '%s' does not match the pattern %s",
+				path, n.Value, p.Pattern))
+		}
+	}
+
+	if slices.Contains(types, "string") || len(types) == 0 {
+		length := len([]rune(n.Value))
+		if p.MinLength > 0 && length < p.MinLength {
+			v.add(n, path, RuleLength, Error, fmt.Sprintf("%s: expected at least %d characters, got %d",
+				path, p.MinLength, length))
+		}
+		if p.MaxLength > 0 && length > p.MaxLength {
+			v.add(n, path, RuleLength, Error, fmt.Sprintf("%s: expected at most %d characters, got %d",
+				path, p.MaxLength, length))
+		}
+	}
+
+	if p.Minimum != nil || p.Maximum != nil {
+		f, err := strconv.ParseFloat(n.Value, 64)
+		if err != nil {
+			return
+		}
+		if p.Minimum != nil && f < *p.Minimum {
+			v.add(n, path, RuleRange, Error, fmt.Sprintf("%s: %s is less than the minimum %v",
+				path, n.Value, *p.Minimum))
+		}
+		if p.Maximum != nil && f > *p.Maximum {
+			v.add(n, path, RuleRange, Error, fmt.Sprintf("%s: %s is more than the maximum %v",
+				path, n.Value, *p.Maximum))
+		}
+	}
+}
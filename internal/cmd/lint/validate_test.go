@@ -0,0 +1,45 @@
+package lint
+
+import (
+	"testing"
+
+	"github.com/aws-cloudformation/rain/cft/parse"
+	"github.com/aws-cloudformation/rain/internal/aws/cfn"
+)
+
+func TestAdditionalProperties(t *testing.T) {
+	tmpl, err := parse.String(`
+Open:
+  Name: a
+  Extra: b
+Closed:
+  Name: a
+  Extra: b
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := tmpl.Node.Content[0]
+
+	props := map[string]*cfn.Prop{"Name": {Type: "string"}}
+	open := &cfn.Prop{Type: "object", Properties: props, AdditionalProperties: true}
+	closed := &cfn.Prop{Type: "object", Properties: props}
+
+	findings := make([]Finding, 0)
+	v := &validator{
+		file:      "test.yaml",
+		logicalId: "Test",
+		schema:    &cfn.Schema{TypeName: "Test::Type"},
+		findings:  &findings,
+	}
+
+	v.validate(open, root.Content[1], []string{"Open"}, "/properties/Open", false)
+	if len(findings) != 0 {
+		t.Errorf("expected no findings for an object with additionalProperties: %v", findings)
+	}
+
+	v.validate(closed, root.Content[3], []string{"Closed"}, "/properties/Closed", false)
+	if len(findings) != 1 || findings[0].Rule != RuleUnknownProperty || findings[0].Path != "Closed/Extra" {
+		t.Errorf("expected an unknown property: %v", findings)
+	}
+}
@@ -3,7 +3,10 @@ package logs
 import (
 	"fmt"
 
+	"github.com/aws-cloudformation/rain/cft/pkg"
+	"github.com/aws-cloudformation/rain/internal/cmd/deploy"
 	"github.com/aws-cloudformation/rain/internal/config"
+	"github.com/aws-cloudformation/rain/internal/node"
 	"github.com/aws-cloudformation/rain/internal/ui"
 
 	"github.com/spf13/cobra"
@@ -13,6 +16,8 @@ var allLogs = false
 var chart = false
 var logsLength uint
 var logsDays uint
+var templateFile string
+var experimental bool
 
 // Cmd is the logs command's entrypoint
 var Cmd = &cobra.Command{
@@ -21,7 +26,10 @@ var Cmd = &cobra.Command{
 	Long: `Shows the event log for a stack and its nested stack. Optionally, filter by a specific resource by name, or see a gantt chart of the most recent stack action.
 
 By default, only show log entries that contain a useful message (e.g. a failure message).
-You can use the --all flag to change this behaviour.`,
+You can use the --all flag to change this behaviour.
+
+Use --template to show where each resource was defined in the template the stack was deployed from.
+Resources that came from a module point at the module file.`,
 	Args:                  cobra.RangeArgs(1, 2),
 	Aliases:               []string{"log"},
 	DisableFlagsInUseLine: true,
@@ -46,7 +54,16 @@ You can use the --all flag to change this behaviour.`,
 					fmt.Println("No interesting log messages to display. To see everything, use the --all flag")
 				}
 			} else {
-				printLogs(logsLength, logsDays, logs)
+				var origins map[string]node.Origin
+				if templateFile != "" {
+					pkg.Experimental = experimental
+					t, err := pkg.File(templateFile)
+					if err != nil {
+						panic(ui.Errorf(err, "unable to package template '%s'", templateFile))
+					}
+					origins = deploy.ResourceOrigins(t)
+				}
+				printLogs(logsLength, logsDays, logs, stackName, origins)
 			}
 		} else {
 			err := createChart(stackName)
@@ -63,4 +80,6 @@ func init() {
 	Cmd.Flags().BoolVar(&config.Debug, "debug", false, "Output debugging information")
 	Cmd.Flags().UintVarP(&logsLength, "length", "l", 0, "Number of logs to display")
 	Cmd.Flags().UintVarP(&logsDays, "days", "d", 0, "Age of the logs to display in days")
+	Cmd.Flags().StringVarP(&templateFile, "template", "t", "", "Template the stack was deployed from, to show where resources were defined")
+	Cmd.Flags().BoolVarP(&experimental, "experimental", "x", false, "Enable experimental features")
 }
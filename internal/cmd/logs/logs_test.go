@@ -19,6 +19,9 @@ func Example_logs_help() {
 	// By default, only show log entries that contain a useful message (e.g. a failure message).
 	// You can use the --all flag to change this behaviour.
 	//
+	// Use --template to show where each resource was defined in the template the stack was deployed from.
+	// Resources that came from a module point at the module file.
+	//
 	// Usage:
 	//   logs <stack> (<resource>)
 	//
@@ -26,10 +29,12 @@ func Example_logs_help() {
 	//   logs, log
 	//
 	// Flags:
-	//   -a, --all           include uninteresting logs
-	//   -c, --chart         Output a gantt chart of the most recent action as an html file
-	//   -d, --days uint     Age of the logs to display in days
-	//       --debug         Output debugging information
-	//   -h, --help          help for logs
-	//   -l, --length uint   Number of logs to display
+	//   -a, --all               include uninteresting logs
+	//   -c, --chart             Output a gantt chart of the most recent action as an html file
+	//   -d, --days uint         Age of the logs to display in days
+	//       --debug             Output debugging information
+	//   -x, --experimental      Enable experimental features
+	//   -h, --help              help for logs
+	//   -l, --length uint       Number of logs to display
+	//   -t, --template string   Template the stack was deployed from, to show where resources were defined
 }
@@ -11,6 +11,7 @@ import (
 	"github.com/aws-cloudformation/rain/internal/aws/cfn"
 	"github.com/aws-cloudformation/rain/internal/console"
 	"github.com/aws-cloudformation/rain/internal/console/spinner"
+	"github.com/aws-cloudformation/rain/internal/node"
 	"github.com/aws-cloudformation/rain/internal/ui"
 )
 
@@ -69,7 +70,10 @@ func reduceLogs(logsRange uint, logsDays uint, logs *events) {
 
 }
 
-func printLogs(logsRange uint, logsDays uint, logs events) {
+// printLogs prints each event on a line.
+// Events for resources in stackName that are in origins
+// also show where the resource was defined.
+func printLogs(logsRange uint, logsDays uint, logs events, stackName string, origins map[string]node.Origin) {
 	reduceLogs(logsRange, logsDays, &logs)
 	for _, log := range logs {
 		fmt.Printf("%s %s/%s (%s) %s",
@@ -84,6 +88,12 @@ func printLogs(logsRange uint, logsDays uint, logs events) {
 			fmt.Printf(" %q", ptr.ToString(log.ResourceStatusReason))
 		}
 
+		if ptr.ToString(log.StackName) == stackName {
+			if origin, ok := origins[ptr.ToString(log.LogicalResourceId)]; ok {
+				fmt.Printf(" %s", console.Grey(origin.String()))
+			}
+		}
+
 		fmt.Println()
 	}
 }
@@ -13,17 +13,34 @@ import (
 
 var forceMerge = false
 var outFn = ""
+var strategyFlags []string
 
 // Cmd is the merge command's entrypoint
 var Cmd = &cobra.Command{
-	Use:                   "merge <template> <template> ...",
-	Short:                 "Merge two or more CloudFormation templates",
-	Long:                  "Merges all specified CloudFormation templates, print the resultant template to standard out",
+	Use:   "merge <template> <template> ...",
+	Short: "Merge two or more CloudFormation templates",
+	Long: `Merges all specified CloudFormation templates, print the resultant template to standard out.
+
+Comments and the order of each template are kept. Parameters that are the same in more than one template are only included once.
+Other clashing elements cause an error, unless --strategy sets what to do with them:
+
+  fail          stop with an error (the default)
+  prefer-first  keep the element from the earlier template
+  prefer-last   keep the element from the later template
+  rename        keep both, giving the later one a new name and updating the references to it
+
+Use --strategy rename to set the strategy for every section, or --strategy Resources=rename to set it for one section.
+--force is the same as --strategy rename.`,
 	Args:                  cobra.MinimumNArgs(2),
 	DisableFlagsInUseLine: true,
 	Run: func(cmd *cobra.Command, args []string) {
 		var err error
 
+		strategies, err = parseStrategies(strategyFlags)
+		if err != nil {
+			panic(ui.Errorf(err, "invalid --strategy"))
+		}
+
 		templates := make([]cft.Template, len(args))
 
 		for i, fn := range args {
@@ -58,5 +75,6 @@ var Cmd = &cobra.Command{
 
 func init() {
 	Cmd.Flags().StringVarP(&outFn, "output", "o", "", "Output merged template to a file")
-	Cmd.Flags().BoolVarP(&forceMerge, "force", "f", false, "Don't warn on clashing attributes; rename them instead. Note: this will not rename Refs, GetAtts, etc.")
+	Cmd.Flags().BoolVarP(&forceMerge, "force", "f", false, "Don't warn on clashing attributes; rename them and the references to them instead")
+	Cmd.Flags().StringSliceVarP(&strategyFlags, "strategy", "s", []string{}, "what to do with clashing elements: fail, prefer-first, prefer-last or rename; use Section=strategy to set it for one section")
 }
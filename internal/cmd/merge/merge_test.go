@@ -1,8 +1,10 @@
 package merge
 
 import (
+	"strings"
 	"testing"
 
+	"github.com/aws-cloudformation/rain/cft/format"
 	"github.com/aws-cloudformation/rain/cft/parse"
 	"github.com/google/go-cmp/cmp"
 )
@@ -174,7 +176,7 @@ func TestForceMergeTemplatesSuccess(t *testing.T) {
 		},
 		"Parameters": map[string]interface{}{
 			"Name": map[string]interface{}{
-				"Type": "String",
+				"Type": "Number",
 			},
 		},
 	})
@@ -200,8 +202,8 @@ func TestForceMergeTemplatesSuccess(t *testing.T) {
 			"Name": map[string]interface{}{
 				"Type": "String",
 			},
-			"Name_2": map[string]interface{}{
-				"Type": "String",
+			"Name2": map[string]interface{}{
+				"Type": "Number",
 			},
 		},
 	})
@@ -313,3 +315,222 @@ func TestMergeTemplatesClash(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestForceMergeRenamesReferences(t *testing.T) {
+	dst, err := parse.String(`
+Parameters:
+  Name:
+    Type: String
+Conditions:
+  IsProd: !Equals [!Ref Name, prod]
+Resources:
+  Bucket:
+    Type: AWS::S3::Bucket
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := parse.String(`
+Metadata:
+  AWS::CloudFormation::Interface:
+    ParameterGroups:
+      - Parameters: [Name]
+Parameters:
+  Name:
+    Type: Number
+Mappings:
+  Sizes:
+    prod:
+      Size: 10
+Conditions:
+  IsProd: !Equals [!Ref Name, prod]
+  IsBig: !And [!Condition IsProd, !Equals [!Ref Name, big]]
+Resources:
+  Bucket:
+    Type: AWS::S3::Bucket
+    Condition: IsProd
+    Properties:
+      BucketName: !Sub "${Name}-${Bucket2}-${!Literal}"
+  Bucket2:
+    Type: AWS::S3::Bucket
+    DependsOn: Bucket
+    Properties:
+      BucketName: !Sub
+        - "${Name}-${Bucket.Arn}"
+        - Name: !If [IsBig, !FindInMap [Sizes, prod, Size], !GetAtt Bucket.Arn]
+Outputs:
+  Arn:
+    Condition: IsProd
+    Value: !GetAtt [Bucket, Arn]
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected, err := parse.String(`
+Metadata:
+  AWS::CloudFormation::Interface:
+    ParameterGroups:
+      - Parameters: [Name2]
+Parameters:
+  Name:
+    Type: String
+  Name2:
+    Type: Number
+Mappings:
+  Sizes:
+    prod:
+      Size: 10
+Conditions:
+  IsProd: !Equals [!Ref Name, prod]
+  IsProd2: !Equals [!Ref Name2, prod]
+  IsBig: !And [!Condition IsProd2, !Equals [!Ref Name2, big]]
+Resources:
+  Bucket:
+    Type: AWS::S3::Bucket
+  Bucket3:
+    Type: AWS::S3::Bucket
+    Condition: IsProd2
+    Properties:
+      BucketName: !Sub "${Name2}-${Bucket2}-${!Literal}"
+  Bucket2:
+    Type: AWS::S3::Bucket
+    DependsOn: Bucket3
+    Properties:
+      BucketName: !Sub
+        - "${Name}-${Bucket3.Arn}"
+        - Name: !If [IsBig, !FindInMap [Sizes, prod, Size], !GetAtt Bucket3.Arn]
+Outputs:
+  Arn:
+    Condition: IsProd2
+    Value: !GetAtt [Bucket3, Arn]
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	forceMerge = true
+	actual, err := mergeTemplates(dst, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d := cmp.Diff(actual.Map(), expected.Map()); d != "" {
+		t.Errorf(d)
+	}
+}
+
+func TestMergeKeepsComments(t *testing.T) {
+	dst, err := parse.String(`
+Resources:
+  # The first bucket
+  Bucket:
+    Type: AWS::S3::Bucket
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := parse.String(`
+Resources:
+  Queue:
+    Type: AWS::SQS::Queue # A queue
+  Topic:
+    Type: AWS::SNS::Topic
+    Properties:
+      DisplayName: !Sub "${Queue}"
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	forceMerge = false
+	strategies = map[string]string{}
+	actual, err := mergeTemplates(dst, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := format.String(actual, format.Options{})
+	for _, expected := range []string{"# The first bucket", "# A queue", "DisplayName: !Sub ${Queue}"} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected %q in:\n%s", expected, out)
+		}
+	}
+	if strings.Index(out, "Queue:") > strings.Index(out, "Topic:") {
+		t.Errorf("expected the order of the resources to be kept:\n%s", out)
+	}
+}
+
+func TestMergeStrategies(t *testing.T) {
+	dst, _ := parse.Map(map[string]interface{}{
+		"Parameters": map[string]interface{}{
+			"Name": map[string]interface{}{"Type": "String"},
+		},
+		"Resources": map[string]interface{}{
+			"Bucket": map[string]interface{}{"Type": "AWS::S3::Bucket"},
+		},
+	})
+
+	src, _ := parse.Map(map[string]interface{}{
+		"Parameters": map[string]interface{}{
+			"Name": map[string]interface{}{"Type": "String"},
+		},
+		"Resources": map[string]interface{}{
+			"Bucket": map[string]interface{}{"Type": "AWS::SQS::Queue"},
+		},
+	})
+
+	forceMerge = false
+	for strategy, expected := range map[string]map[string]interface{}{
+		strategyPreferFirst: {
+			"Bucket": map[string]interface{}{"Type": "AWS::S3::Bucket"},
+		},
+		strategyPreferLast: {
+			"Bucket": map[string]interface{}{"Type": "AWS::SQS::Queue"},
+		},
+		strategyRename: {
+			"Bucket":  map[string]interface{}{"Type": "AWS::S3::Bucket"},
+			"Bucket2": map[string]interface{}{"Type": "AWS::SQS::Queue"},
+		},
+	} {
+		strategies = map[string]string{"Resources": strategy}
+		actual, err := mergeTemplates(dst, src)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The identical parameter is not a clash
+		params := map[string]interface{}{
+			"Name": map[string]interface{}{"Type": "String"},
+		}
+		if d := cmp.Diff(actual.Map()["Parameters"], params); d != "" {
+			t.Errorf("%s: %s", strategy, d)
+		}
+		if d := cmp.Diff(actual.Map()["Resources"], expected); d != "" {
+			t.Errorf("%s: %s", strategy, d)
+		}
+	}
+
+	strategies = map[string]string{"Outputs": strategyRename}
+	if _, err := mergeTemplates(dst, src); err == nil {
+		t.Error("expected the clashing resource to fail")
+	}
+	strategies = map[string]string{}
+}
+
+func TestParseStrategies(t *testing.T) {
+	actual, err := parseStrategies([]string{"prefer-last", "Resources=rename"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"": "prefer-last", "Resources": "rename"}
+	if d := cmp.Diff(actual, expected); d != "" {
+		t.Error(d)
+	}
+
+	if _, err := parseStrategies([]string{"Resources=merge"}); err == nil {
+		t.Error("expected an unknown strategy to fail")
+	}
+}
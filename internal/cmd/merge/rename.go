@@ -0,0 +1,334 @@
+package merge
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws-cloudformation/rain/cft"
+	"github.com/aws-cloudformation/rain/cft/parse"
+	"github.com/aws-cloudformation/rain/internal/node"
+	"github.com/aws-cloudformation/rain/internal/s11n"
+	"gopkg.in/yaml.v3"
+)
+
+// renames holds the new names given to clashing elements of a template.
+// Parameters and resources share a map because Ref can refer to either.
+type renames struct {
+	refs       map[string]string
+	conditions map[string]string
+	mappings   map[string]string
+	outputs    map[string]string
+}
+
+// rootMap returns the top level mapping of a template, which is not
+// inside a document node when the template was made with parse.Map
+func rootMap(t cft.Template) *yaml.Node {
+	if t.Node == nil {
+		return nil
+	}
+	if t.Node.Kind == yaml.DocumentNode {
+		if len(t.Node.Content) == 0 {
+			return nil
+		}
+		return t.Node.Content[0]
+	}
+	return t.Node
+}
+
+// names returns the names in a section of a template
+func names(t cft.Template, section cft.Section) map[string]bool {
+	out := make(map[string]bool)
+	_, s, _ := s11n.GetMapValue(rootMap(t), string(section))
+	if s == nil || s.Kind != yaml.MappingNode {
+		return out
+	}
+	for i := 0; i+1 < len(s.Content); i += 2 {
+		out[s.Content[i].Value] = true
+	}
+	return out
+}
+
+// section returns a section of a template, or nil
+func section(t cft.Template, name cft.Section) *yaml.Node {
+	_, s, _ := s11n.GetMapValue(rootMap(t), string(name))
+	return s
+}
+
+// newNames returns a new name for each name in src that is also in taken,
+// unless keep returns true for it. New names are alphanumeric so that
+// they are valid logical ids.
+func newNames(src map[string]bool, taken map[string]bool, keep func(name string) bool) map[string]string {
+	sorted := make([]string, 0, len(src))
+	for name := range src {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	used := make(map[string]bool)
+	for name := range taken {
+		used[name] = true
+	}
+	for name := range src {
+		used[name] = true
+	}
+
+	out := make(map[string]string)
+	for _, name := range sorted {
+		if !taken[name] || keep(name) {
+			continue
+		}
+		for i := 2; true; i++ {
+			newName := fmt.Sprintf("%s%d", name, i)
+			if !used[newName] {
+				used[newName] = true
+				out[name] = newName
+				break
+			}
+		}
+	}
+	return out
+}
+
+// renameClashes returns a copy of src where each parameter, resource,
+// condition, mapping and output that is also in dst has a new name,
+// if renamed returns true for its section, and every reference to
+// those elements uses the new name. Parameters that are the same in
+// both templates keep their names.
+func renameClashes(dst, src cft.Template, renamed func(section cft.Section) bool) cft.Template {
+	union := func(t cft.Template) map[string]bool {
+		out := names(t, cft.Parameters)
+		for name := range names(t, cft.Resources) {
+			out[name] = true
+		}
+		return out
+	}
+
+	srcParams := names(src, cft.Parameters)
+	keepRef := func(name string) bool {
+		if srcParams[name] {
+			if !renamed(cft.Parameters) {
+				return true
+			}
+			_, d, _ := s11n.GetMapValue(section(dst, cft.Parameters), name)
+			_, s, _ := s11n.GetMapValue(section(src, cft.Parameters), name)
+			return d != nil && equal(d, s)
+		}
+		return !renamed(cft.Resources)
+	}
+	keep := func(s cft.Section) func(string) bool {
+		return func(string) bool {
+			return !renamed(s)
+		}
+	}
+
+	r := renames{
+		refs:       newNames(union(src), union(dst), keepRef),
+		conditions: newNames(names(src, cft.Conditions), names(dst, cft.Conditions), keep(cft.Conditions)),
+		mappings:   newNames(names(src, cft.Mappings), names(dst, cft.Mappings), keep(cft.Mappings)),
+		outputs:    newNames(names(src, cft.Outputs), names(dst, cft.Outputs), keep(cft.Outputs)),
+	}
+
+	out := cft.Template{Node: node.Clone(src.Node)}
+	root := rootMap(out)
+	if root == nil {
+		return out
+	}
+
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		section, body := root.Content[i].Value, root.Content[i+1]
+
+		switch cft.Section(section) {
+		case cft.Parameters:
+			r.renameKeys(body, r.refs)
+		case cft.Resources:
+			r.renameKeys(body, r.refs)
+			r.renameAttributes(body, true)
+		case cft.Conditions:
+			r.renameKeys(body, r.conditions)
+		case cft.Mappings:
+			r.renameKeys(body, r.mappings)
+		case cft.Outputs:
+			r.renameKeys(body, r.outputs)
+			r.renameAttributes(body, false)
+		case cft.Metadata:
+			r.renameInterface(body)
+		}
+
+		r.walk(body, cft.Section(section) == cft.Conditions)
+	}
+
+	return out
+}
+
+// renameKeys renames the keys of a section
+func (r renames) renameKeys(section *yaml.Node, names map[string]string) {
+	if section.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i < len(section.Content); i += 2 {
+		if to, ok := names[section.Content[i].Value]; ok {
+			section.Content[i].Value = to
+		}
+	}
+}
+
+// rename sets n to its new name, if n is a string with one
+func rename(n *yaml.Node, names map[string]string) {
+	if n == nil || n.Kind != yaml.ScalarNode {
+		return
+	}
+	if to, ok := names[n.Value]; ok {
+		n.Value = to
+	}
+}
+
+// renameAttributes renames the Condition and DependsOn attributes
+// of the resources or outputs in a section
+func (r renames) renameAttributes(section *yaml.Node, resources bool) {
+	if section.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 1; i < len(section.Content); i += 2 {
+		element := section.Content[i]
+		if element.Kind != yaml.MappingNode {
+			continue
+		}
+
+		_, condition, _ := s11n.GetMapValue(element, "Condition")
+		rename(condition, r.conditions)
+
+		if !resources {
+			continue
+		}
+
+		_, dependsOn, _ := s11n.GetMapValue(element, "DependsOn")
+		if dependsOn == nil {
+			continue
+		}
+		if dependsOn.Kind == yaml.SequenceNode {
+			for _, d := range dependsOn.Content {
+				rename(d, r.refs)
+			}
+		} else {
+			rename(dependsOn, r.refs)
+		}
+	}
+}
+
+// renameInterface renames the parameters in AWS::CloudFormation::Interface metadata
+func (r renames) renameInterface(metadata *yaml.Node) {
+	_, iface, _ := s11n.GetMapValue(metadata, "AWS::CloudFormation::Interface")
+	if iface == nil {
+		return
+	}
+
+	_, groups, _ := s11n.GetMapValue(iface, "ParameterGroups")
+	if groups != nil && groups.Kind == yaml.SequenceNode {
+		for _, group := range groups.Content {
+			_, params, _ := s11n.GetMapValue(group, "Parameters")
+			if params != nil && params.Kind == yaml.SequenceNode {
+				for _, p := range params.Content {
+					rename(p, r.refs)
+				}
+			}
+		}
+	}
+
+	_, labels, _ := s11n.GetMapValue(iface, "ParameterLabels")
+	if labels != nil {
+		r.renameKeys(labels, r.refs)
+	}
+}
+
+// walk renames the targets of the intrinsic functions in n.
+// Condition functions are only renamed inside the Conditions section,
+// since elsewhere Condition is an attribute or a property.
+func (r renames) walk(n *yaml.Node, conditions bool) {
+	if n.Kind == yaml.MappingNode && len(n.Content) == 2 {
+		val := n.Content[1]
+
+		switch n.Content[0].Value {
+		case "Ref":
+			rename(val, r.refs)
+		case "Fn::GetAtt":
+			if val.Kind == yaml.SequenceNode && len(val.Content) > 0 {
+				rename(val.Content[0], r.refs)
+			} else if val.Kind == yaml.ScalarNode {
+				name, attr, found := strings.Cut(val.Value, ".")
+				if to, ok := r.refs[name]; ok && found {
+					val.Value = to + "." + attr
+				}
+			}
+		case "Fn::Sub":
+			if val.Kind == yaml.ScalarNode {
+				val.Value = r.sub(val.Value, nil)
+			} else if val.Kind == yaml.SequenceNode && len(val.Content) > 0 {
+				// Variables in the map hide the names in the template
+				vars := make(map[string]bool)
+				if len(val.Content) > 1 && val.Content[1].Kind == yaml.MappingNode {
+					for i := 0; i < len(val.Content[1].Content); i += 2 {
+						vars[val.Content[1].Content[i].Value] = true
+					}
+				}
+				if val.Content[0].Kind == yaml.ScalarNode {
+					val.Content[0].Value = r.sub(val.Content[0].Value, vars)
+				}
+			}
+		case "Fn::FindInMap":
+			if val.Kind == yaml.SequenceNode && len(val.Content) > 0 {
+				rename(val.Content[0], r.mappings)
+			}
+		case "Fn::If":
+			if val.Kind == yaml.SequenceNode && len(val.Content) > 0 {
+				rename(val.Content[0], r.conditions)
+			}
+		case "Condition":
+			if conditions {
+				rename(val, r.conditions)
+			}
+		}
+	}
+
+	for _, c := range n.Content {
+		r.walk(c, conditions)
+	}
+}
+
+// sub renames the variables in a Fn::Sub string, unless they are in vars
+func (r renames) sub(s string, vars map[string]bool) string {
+	words, err := parse.ParseSub(s)
+	if err != nil {
+		return s
+	}
+
+	changed := false
+	newName := func(name string) string {
+		if to, ok := r.refs[name]; ok && !vars[name] {
+			changed = true
+			return to
+		}
+		return name
+	}
+
+	out := ""
+	for _, w := range words {
+		switch w.T {
+		case parse.STR:
+			// ParseSub turns ${!Literal} into ${Literal}
+			out += strings.ReplaceAll(w.W, "${", "${!")
+		case parse.AWS:
+			out += "${AWS::" + w.W + "}"
+		case parse.REF:
+			out += "${" + newName(w.W) + "}"
+		case parse.GETATT:
+			name, attr, _ := strings.Cut(w.W, ".")
+			out += "${" + newName(name) + "." + attr + "}"
+		}
+	}
+
+	if !changed {
+		return s
+	}
+	return out
+}
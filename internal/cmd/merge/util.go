@@ -2,146 +2,258 @@ package merge
 
 import (
 	"fmt"
+	"reflect"
+	"strings"
 
 	"github.com/aws-cloudformation/rain/cft"
 	"github.com/aws-cloudformation/rain/cft/parse"
+	"github.com/aws-cloudformation/rain/internal/node"
+	"gopkg.in/yaml.v3"
 )
 
-func checkMerge(name string, dst, src map[string]interface{}) error {
-	if _, ok := dst[name]; !ok {
-		dst[name] = src[name]
-	} else {
-		dstMap := dst[name].(map[string]interface{})
-		srcMap := src[name].(map[string]interface{})
-
-		for key, value := range srcMap {
-			if _, ok := dstMap[key]; ok {
-				if forceMerge {
-					for i := 2; true; i++ {
-						newKey := fmt.Sprintf("%s_%d", key, i)
-						if _, ok := dst[newKey]; !ok {
-							key = newKey
-							break
-						}
-					}
-				} else {
-					return fmt.Errorf("templates have clashing %s: %s", name, key)
-				}
+// Strategies for elements that are in more than one template
+const (
+	strategyFail        = "fail"
+	strategyPreferFirst = "prefer-first"
+	strategyPreferLast  = "prefer-last"
+	strategyRename      = "rename"
+)
+
+var validStrategies = []string{strategyFail, strategyPreferFirst, strategyPreferLast, strategyRename}
+
+// strategies maps section names to strategies.
+// The empty section name is the strategy for every other section.
+var strategies = map[string]string{}
+
+// parseStrategies reads the values of the --strategy flag,
+// which are either a strategy or Section=strategy
+func parseStrategies(values []string) (map[string]string, error) {
+	out := make(map[string]string)
+	for _, v := range values {
+		section, strategy, found := strings.Cut(v, "=")
+		if !found {
+			section, strategy = "", v
+		}
+
+		valid := false
+		for _, s := range validStrategies {
+			if strategy == s {
+				valid = true
 			}
+		}
+		if !valid {
+			return nil, fmt.Errorf("unknown strategy '%s'; use one of %s", strategy, strings.Join(validStrategies, ", "))
+		}
 
-			dstMap[key] = value
+		out[section] = strategy
+	}
+	return out, nil
+}
+
+// strategyFor returns the strategy for clashing elements in a section
+func strategyFor(section string) string {
+	if s, ok := strategies[section]; ok {
+		return s
+	}
+	if s, ok := strategies[""]; ok {
+		return s
+	}
+	if forceMerge {
+		return strategyRename
+	}
+	return strategyFail
+}
+
+// equal returns true if two nodes have the same value, ignoring comments and style
+func equal(a, b *yaml.Node) bool {
+	var av, bv interface{}
+	if a.Decode(&av) != nil || b.Decode(&bv) != nil {
+		return false
+	}
+	return reflect.DeepEqual(av, bv)
+}
+
+// indexOf returns the index of a key in a mapping node, or -1
+func indexOf(m *yaml.Node, key string) int {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// mergeValue adds a key and its value from the source template to a mapping
+// in the destination template, using the section's strategy if the key is already there
+func mergeValue(section string, dst *yaml.Node, key, value *yaml.Node) error {
+	i := indexOf(dst, key.Value)
+	if i < 0 {
+		dst.Content = append(dst.Content, key, value)
+		return nil
+	}
+
+	// The same parameter is often declared by templates that are meant to be merged
+	if section == string(cft.Parameters) && equal(dst.Content[i+1], value) {
+		return nil
+	}
+
+	switch strategyFor(section) {
+	case strategyPreferFirst:
+		return nil
+	case strategyPreferLast:
+		dst.Content[i+1] = value
+		return nil
+	case strategyRename:
+		for n := 2; true; n++ {
+			newKey := fmt.Sprintf("%s_%d", key.Value, n)
+			if indexOf(dst, newKey) < 0 {
+				key.Value = newKey
+				break
+			}
+		}
+		dst.Content = append(dst.Content, key, value)
+		return nil
+	}
+
+	return fmt.Errorf("templates have clashing %s: %s", section, key.Value)
+}
+
+// mergeSection merges a section that is a mapping, like Resources
+func mergeSection(section string, dst, src *yaml.Node) error {
+	if dst.Kind != yaml.MappingNode || src.Kind != yaml.MappingNode {
+		return fmt.Errorf("%s section is not an object (key-value pairs)", strings.ToLower(section))
+	}
+
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		if err := mergeValue(section, dst, src.Content[i], src.Content[i+1]); err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
-func mergeTemplates(dstTemplate, srcTemplate cft.Template) (cft.Template, error) {
-	dst := dstTemplate.Map()
-	src := srcTemplate.Map()
+// mergeMetadata merges the Metadata section, combining the
+// parameter groups and labels of AWS::CloudFormation::Interface
+func mergeMetadata(dst, src *yaml.Node) error {
+	section := string(cft.Metadata)
+	if dst.Kind != yaml.MappingNode || src.Kind != yaml.MappingNode {
+		return fmt.Errorf("metadata section is not an object (key-value pairs)")
+	}
 
-	for key, value := range src {
-		switch key {
-		case "AWSTemplateFormatVersion": // Always overwrite
-			dst[key] = value
-		case "Description": // Combine descriptions
-			if _, ok := dst[key]; !ok {
-				dst[key] = src[key]
-			} else {
-				dst[key] = dst[key].(string) + "\n" + src[key].(string)
-			}
-		case "Transform": // Append transforms
-			if _, ok := dst[key]; !ok {
-				dst[key] = src[key]
-			} else {
-				if _, ok := dst[key].([]interface{}); !ok {
-					// Convert to a slice
-					dst[key] = []interface{}{dst[key]}
-				}
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		key, value := src.Content[i], src.Content[i+1]
 
-				dst[key] = append(dst[key].([]interface{}), src[key])
+		j := indexOf(dst, key.Value)
+		if key.Value != "AWS::CloudFormation::Interface" || j < 0 {
+			if err := mergeValue(section, dst, key, value); err != nil {
+				return err
 			}
+			continue
+		}
 
-		case "Metadata": // Combine metadata
-			if _, ok := dst[key]; !ok {
-				dst[key] = map[string]interface{}{}
-			}
+		dstInterface := dst.Content[j+1]
+		if dstInterface.Kind != yaml.MappingNode || value.Kind != yaml.MappingNode {
+			return fmt.Errorf("metadata key %s is not an object (key-value pairs)", key.Value)
+		}
 
-			dstMap, ok := dst[key].(map[string]interface{})
-			if !ok {
-				return cft.Template{}, fmt.Errorf("metadata section is not an object (key-value pairs)")
-			}
-			srcMap, ok := src[key].(map[string]interface{})
-			if !ok {
-				return cft.Template{}, fmt.Errorf("metadata section is not an object (key-value pairs)")
+		for k := 0; k+1 < len(value.Content); k += 2 {
+			name, srcValue := value.Content[k], value.Content[k+1]
+
+			l := indexOf(dstInterface, name.Value)
+			if l < 0 {
+				dstInterface.Content = append(dstInterface.Content, name, srcValue)
+				continue
 			}
+			dstValue := dstInterface.Content[l+1]
 
-			for k := range srcMap {
-				if k == "AWS::CloudFormation::Interface" {
-					if _, ok := dstMap[k]; !ok {
-						dstMap[k] = map[string]interface{}{}
-					}
-
-					dstInterface, ok := dstMap[k].(map[string]interface{})
-					if !ok {
-						return cft.Template{}, fmt.Errorf("metadata key %s is not an object (key-value pairs)", k)
-					}
-					srcInterface, ok := srcMap[k].(map[string]interface{})
-					if !ok {
-						return cft.Template{}, fmt.Errorf("metadata key %s is not an object (key-value pairs)", k)
-					}
-
-					// Concatenate ParameterGroups
-					if _, ok := srcInterface["ParameterGroups"]; ok {
-						if _, ok := dstInterface["ParameterGroups"]; !ok {
-							dstInterface["ParameterGroups"] = []interface{}{}
-						}
-						dstParameterGroups, ok := dstInterface["ParameterGroups"].([]interface{})
-						if !ok {
-							return cft.Template{}, fmt.Errorf("metadata key ParameterGroups is not an array")
-						}
-						srcParameterGroups, ok := srcInterface["ParameterGroups"].([]interface{})
-						if !ok {
-							return cft.Template{}, fmt.Errorf("metadata key ParameterGroups is not an array")
-						}
-
-						dstInterface["ParameterGroups"] = append(dstParameterGroups, srcParameterGroups...)
-					}
-
-					// Combine ParameterLabels
-					if _, ok := srcInterface["ParameterLabels"]; ok {
-						if err := checkMerge("ParameterLabels", dstInterface, srcInterface); err != nil {
-							return cft.Template{}, err
-						}
-					}
-					dstMap[k] = dstInterface
-				} else {
-					if _, ok = dstMap[k]; !ok {
-						dstMap[k] = srcMap[k]
-					} else {
-						if forceMerge {
-							for i := 2; true; i++ {
-								newKey := fmt.Sprintf("%s_%d", k, i)
-								if _, ok := dstMap[newKey]; !ok {
-									dstMap[newKey] = srcMap[k]
-									break
-								}
-							}
-						} else {
-							return cft.Template{}, fmt.Errorf("templates have clashing %s: %s", key, k)
-						}
-					}
-					dst[key] = dstMap
+			switch name.Value {
+			case "ParameterGroups": // Concatenate ParameterGroups
+				if dstValue.Kind != yaml.SequenceNode || srcValue.Kind != yaml.SequenceNode {
+					return fmt.Errorf("metadata key ParameterGroups is not an array")
+				}
+				dstValue.Content = append(dstValue.Content, srcValue.Content...)
+			case "ParameterLabels": // Combine ParameterLabels
+				if err := mergeSection("ParameterLabels", dstValue, srcValue); err != nil {
+					return err
+				}
+			default:
+				if err := mergeValue(section, dstInterface, name, srcValue); err != nil {
+					return err
 				}
 			}
+		}
+	}
+
+	return nil
+}
+
+// renamedSection returns true if elements of a section that clash are renamed
+func renamedSection(section cft.Section) bool {
+	return strategyFor(string(section)) == strategyRename
+}
+
+// mergeTemplates merges src into a copy of dst, keeping the comments,
+// the order of the keys and the style of both templates
+func mergeTemplates(dstTemplate, srcTemplate cft.Template) (cft.Template, error) {
+	srcTemplate = renameClashes(dstTemplate, srcTemplate, renamedSection)
+
+	doc := node.Clone(dstTemplate.Node)
+	if doc == nil {
+		doc = &yaml.Node{Kind: yaml.MappingNode}
+	}
+	if doc.Kind != yaml.DocumentNode {
+		doc = &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{doc}}
+	}
+	if len(doc.Content) == 0 {
+		doc.Content = append(doc.Content, &yaml.Node{Kind: yaml.MappingNode})
+	}
+	dst := doc.Content[0]
+
+	src := rootMap(srcTemplate)
+	if src == nil {
+		return parse.Node(doc)
+	}
+
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		key, value := src.Content[i], src.Content[i+1]
+
+		j := indexOf(dst, key.Value)
+		if j < 0 {
+			dst.Content = append(dst.Content, key, value)
+			continue
+		}
+		existing := dst.Content[j+1]
 
+		switch key.Value {
+		case "AWSTemplateFormatVersion": // Always overwrite
+			dst.Content[j+1] = value
+		case "Description": // Combine descriptions
+			if existing.Kind != yaml.ScalarNode || value.Kind != yaml.ScalarNode {
+				return cft.Template{}, fmt.Errorf("description is not a string")
+			}
+			existing.Value = existing.Value + "\n" + value.Value
+		case "Transform": // Append transforms
+			if existing.Kind != yaml.SequenceNode {
+				// Convert to a sequence
+				existing = &yaml.Node{Kind: yaml.SequenceNode, Content: []*yaml.Node{existing}}
+				dst.Content[j+1] = existing
+			}
+			if value.Kind == yaml.SequenceNode {
+				existing.Content = append(existing.Content, value.Content...)
+			} else {
+				existing.Content = append(existing.Content, value)
+			}
+		case "Metadata": // Combine metadata
+			if err := mergeMetadata(existing, value); err != nil {
+				return cft.Template{}, err
+			}
 		default:
-			err := checkMerge(key, dst, src)
-			if err != nil {
+			if err := mergeSection(key.Value, existing, value); err != nil {
 				return cft.Template{}, err
 			}
 		}
 	}
 
-	return parse.Map(dst)
+	return parse.Node(doc)
 }
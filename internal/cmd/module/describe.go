@@ -0,0 +1,117 @@
+package module
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws-cloudformation/rain/cft"
+	"github.com/aws-cloudformation/rain/cft/format"
+	"github.com/aws-cloudformation/rain/cft/pkg"
+	"github.com/aws-cloudformation/rain/internal/console"
+	"github.com/aws-cloudformation/rain/internal/ui"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// DescribeCmd is the module describe command's entrypoint
+var DescribeCmd = &cobra.Command{
+	Use:   "describe <uri>",
+	Short: "Show the properties and outputs of a module",
+	Long: `Shows the interface of a module: the properties that a template sets with Properties,
+which are the module's Parameters, and the outputs that a template can refer to
+with !GetAtt ModuleName.OutputName. The module can be a file or an https URL.`,
+	Args:                  cobra.ExactArgs(1),
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		t, err := pkg.ReadModule(args[0])
+		if err != nil {
+			panic(ui.Errorf(err, "unable to read module '%s'", args[0]))
+		}
+
+		out, err := describe(t)
+		if err != nil {
+			panic(ui.Errorf(err, "unable to describe module '%s'", args[0]))
+		}
+
+		fmt.Print(out)
+	},
+}
+
+// describe returns a description of a module's interface
+func describe(t cft.Template) (string, error) {
+	m, err := t.Model()
+	if err != nil {
+		return "", err
+	}
+
+	out := strings.Builder{}
+
+	if description, err := t.GetSection(cft.Description); err == nil && description.Value != "" {
+		out.WriteString(strings.TrimSpace(description.Value) + "\n\n")
+	}
+
+	out.WriteString(console.Yellow("Properties:") + "\n")
+	names := m.Names(cft.Parameters)
+	if len(names) == 0 {
+		out.WriteString("  (none)\n")
+	}
+	for _, name := range names {
+		p := m.Parameters[name]
+
+		typeName := p.Type
+		if typeName == "" {
+			typeName = "String"
+		}
+		required := "required"
+		if p.Default != nil {
+			required = "optional"
+		}
+		out.WriteString(fmt.Sprintf("  %s (%s, %s)\n", console.Bold(name), typeName, required))
+
+		if p.Description != "" {
+			out.WriteString(fmt.Sprintf("    %s\n", p.Description))
+		}
+		if p.Default != nil {
+			out.WriteString(fmt.Sprintf("    Default: %s\n", value(p.Default)))
+		}
+		if len(p.AllowedValues) > 0 {
+			out.WriteString(fmt.Sprintf("    AllowedValues: %s\n", strings.Join(p.AllowedValues, ", ")))
+		}
+		if p.AllowedPattern != "" {
+			out.WriteString(fmt.Sprintf("    AllowedPattern: %s\n", p.AllowedPattern))
+		}
+	}
+
+	out.WriteString("\n" + console.Yellow("Outputs:") + "\n")
+	names = m.Names(cft.Outputs)
+	if len(names) == 0 {
+		out.WriteString("  (none)\n")
+	}
+	for _, name := range names {
+		o := m.Outputs[name]
+		out.WriteString(fmt.Sprintf("  %s\n", console.Bold(name)))
+		if o.Description != "" {
+			out.WriteString(fmt.Sprintf("    %s\n", o.Description))
+		}
+	}
+
+	out.WriteString("\n" + console.Yellow("Resources:") + "\n")
+	for _, name := range m.Names(cft.Resources) {
+		r := m.Resources[name]
+		line := fmt.Sprintf("  %s (%s)", console.Bold(name), r.Type)
+		if r.Condition != "" {
+			line += fmt.Sprintf(" if %s", r.Condition)
+		}
+		out.WriteString(line + "\n")
+	}
+
+	return out.String(), nil
+}
+
+// value formats a parameter's default value on one line
+func value(n *yaml.Node) string {
+	if n.Kind == yaml.ScalarNode {
+		return n.Value
+	}
+	return strings.TrimSpace(format.String(cft.Template{Node: n}, format.Options{JSON: true, Unsorted: true}))
+}
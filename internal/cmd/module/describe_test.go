@@ -0,0 +1,65 @@
+package module
+
+import (
+	"testing"
+
+	"github.com/aws-cloudformation/rain/cft/parse"
+)
+
+func TestDescribe(t *testing.T) {
+	source := `
+Description: A bucket
+Parameters:
+  Name:
+    Type: String
+    Description: The name of the bucket
+    AllowedPattern: "[a-z-]+"
+  Retention:
+    Type: String
+    Default: Delete
+    AllowedValues: [Delete, Retain]
+Conditions:
+  IsRetained: !Equals [!Ref Retention, Retain]
+Resources:
+  Bucket:
+    Type: AWS::S3::Bucket
+  Backup:
+    Type: AWS::S3::Bucket
+    Condition: IsRetained
+Outputs:
+  Arn:
+    Description: The bucket's ARN
+    Value: !GetAtt Bucket.Arn
+`
+	tmpl, err := parse.String(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := describe(tmpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `A bucket
+
+Properties:
+  Name (String, required)
+    The name of the bucket
+    AllowedPattern: [a-z-]+
+  Retention (String, optional)
+    Default: Delete
+    AllowedValues: Delete, Retain
+
+Outputs:
+  Arn
+    The bucket's ARN
+
+Resources:
+  Bucket (AWS::S3::Bucket)
+  Backup (AWS::S3::Bucket) if IsRetained
+`
+	if out != expected {
+		t.Errorf("unexpected description:\n%s", out)
+	}
+}
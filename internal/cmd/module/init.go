@@ -0,0 +1,134 @@
+package module
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws-cloudformation/rain/internal/console"
+	"github.com/aws-cloudformation/rain/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// ModuleFile is the name of the module that rain module init creates
+const ModuleFile = "module.yaml"
+
+// TestDir is the directory that holds a module's tests
+const TestDir = "test"
+
+const moduleTemplate = `Description: |
+  The %[1]s module. Use it in a template with:
+
+    Resources:
+      MyBucket:
+        Type: !Rain::Module "./%[1]s/module.yaml"
+        Properties:
+          Name: my-bucket
+
+Parameters:
+  Name:
+    Type: String
+    Description: The name of the bucket
+
+Resources:
+  # ModuleExtension is the resource that the module extends. It takes the name
+  # of the resource in the parent template, so in the example above it becomes
+  # MyBucket. The other resources in the module are prefixed with that name.
+  ModuleExtension:
+    Type: AWS::S3::Bucket
+    Metadata:
+      Extends: AWS::S3::Bucket
+    Properties:
+      BucketName: !Ref Name
+      BucketEncryption:
+        ServerSideEncryptionConfiguration:
+          - ServerSideEncryptionByDefault:
+              SSEAlgorithm: AES256
+      PublicAccessBlockConfiguration:
+        BlockPublicAcls: true
+        BlockPublicPolicy: true
+        IgnorePublicAcls: true
+        RestrictPublicBuckets: true
+
+Outputs:
+  Arn:
+    Description: The ARN of the bucket
+    Value: !GetAtt ModuleExtension.Arn
+`
+
+const testTemplate = `# A template that uses the module. rain module test packages it
+# and compares the result to basic-expect.yaml
+Resources:
+  Example:
+    Type: !Rain::Module "../module.yaml"
+    Properties:
+      Name: example-bucket
+
+Outputs:
+  BucketArn:
+    Value: !GetAtt Example.Arn
+`
+
+// InitCmd is the module init command's entrypoint
+var InitCmd = &cobra.Command{
+	Use:   "init <directory>",
+	Short: "Create a new module",
+	Long: `Creates a directory with a module and a test for it:
+
+  <directory>/module.yaml              The module, with Parameters and a ModuleExtension resource
+  <directory>/test/basic-template.yaml A template that uses the module
+  <directory>/test/basic-expect.yaml   The template after it is packaged
+
+Run "rain module test <directory>" to check the module after you change it.`,
+	Args:                  cobra.ExactArgs(1),
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		dir := args[0]
+
+		if err := scaffold(dir); err != nil {
+			panic(ui.Errorf(err, "unable to create module '%s'", dir))
+		}
+
+		// Write the expected output of the test
+		if _, err := runTests(dir, true); err != nil {
+			panic(ui.Errorf(err, "unable to test module '%s'", dir))
+		}
+
+		fmt.Println(console.Green(fmt.Sprintf("Created module %s", filepath.Join(dir, ModuleFile))))
+	},
+}
+
+// scaffold creates a module and a test template in dir,
+// which must not already have a module in it
+func scaffold(dir string) error {
+	modulePath := filepath.Join(dir, ModuleFile)
+	if _, err := os.Stat(modulePath); err == nil {
+		return fmt.Errorf("%s already exists", modulePath)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, TestDir), 0755); err != nil {
+		return err
+	}
+
+	name := filepath.Base(filepath.Clean(dir))
+	if name == "." || name == string(filepath.Separator) {
+		name = "new"
+	}
+	name = strings.ReplaceAll(name, " ", "-")
+
+	files := map[string]string{
+		modulePath: fmt.Sprintf(moduleTemplate, name),
+		filepath.Join(dir, TestDir, "basic"+templateSuffix): testTemplate,
+	}
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
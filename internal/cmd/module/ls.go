@@ -0,0 +1,60 @@
+package module
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws-cloudformation/rain/cft/parse"
+	"github.com/aws-cloudformation/rain/cft/pkg"
+	"github.com/aws-cloudformation/rain/internal/console"
+	"github.com/aws-cloudformation/rain/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// LsCmd is the module ls command's entrypoint
+var LsCmd = &cobra.Command{
+	Use:   "ls <template>",
+	Short: "List the modules that a template uses",
+	Long: `Lists the modules that a template inserts with !Rain::Module, and the modules
+that those modules use, with the resource that uses each module and where it is read from.`,
+	Args:                  cobra.ExactArgs(1),
+	Aliases:               []string{"list"},
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		fn := args[0]
+
+		t, err := parse.File(fn)
+		if err != nil {
+			panic(ui.Errorf(err, "unable to read template '%s'", fn))
+		}
+
+		refs, err := pkg.ListModules(t, filepath.Dir(fn))
+		if err != nil {
+			panic(ui.Errorf(err, "unable to list the modules in '%s'", fn))
+		}
+
+		if len(refs) == 0 {
+			fmt.Printf("%s does not use any modules\n", fn)
+			return
+		}
+
+		fmt.Print(formatModules(refs))
+	},
+}
+
+// formatModules returns a tree of modules, indented by the modules that use them
+func formatModules(refs []*pkg.ModuleRef) string {
+	out := strings.Builder{}
+
+	var walk func(refs []*pkg.ModuleRef, depth int)
+	walk = func(refs []*pkg.ModuleRef, depth int) {
+		for _, ref := range refs {
+			fmt.Fprintf(&out, "%s%s: %s\n", strings.Repeat("  ", depth), console.Bold(ref.LogicalId), ref.Source)
+			walk(ref.Modules, depth+1)
+		}
+	}
+	walk(refs, 0)
+
+	return out.String()
+}
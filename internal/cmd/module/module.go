@@ -0,0 +1,21 @@
+// Package module implements the rain module command,
+// which works with the modules used by !Rain::Module
+package module
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// Cmd is the module command's entrypoint
+var Cmd = &cobra.Command{
+	Use:   "module <command>",
+	Short: "Work with modules for the !Rain::Module directive",
+	Long:  "Work with the modules that templates insert with the !Rain::Module directive when they are packaged with \"rain pkg\".",
+}
+
+func init() {
+	Cmd.AddCommand(DescribeCmd)
+	Cmd.AddCommand(InitCmd)
+	Cmd.AddCommand(LsCmd)
+	Cmd.AddCommand(TestCmd)
+}
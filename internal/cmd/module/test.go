@@ -0,0 +1,163 @@
+package module
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws-cloudformation/rain/cft/diff"
+	"github.com/aws-cloudformation/rain/cft/format"
+	"github.com/aws-cloudformation/rain/cft/parse"
+	"github.com/aws-cloudformation/rain/cft/pkg"
+	"github.com/aws-cloudformation/rain/internal/console"
+	"github.com/aws-cloudformation/rain/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+const templateSuffix = "-template.yaml"
+const expectSuffix = "-expect.yaml"
+
+var update bool
+var experimental bool
+
+// testResult is the outcome of packaging one test template
+type testResult struct {
+	name string
+
+	// diff is the difference from the expected output, if there is one
+	diff string
+
+	// err is set if the template could not be packaged or compared
+	err error
+}
+
+func (r testResult) passed() bool {
+	return r.err == nil && r.diff == ""
+}
+
+// TestCmd is the module test command's entrypoint
+var TestCmd = &cobra.Command{
+	Use:   "test [directory]",
+	Short: "Package the test templates of a module and compare them to the expected output",
+	Long: `Packages each <name>-template.yaml in the module's test directory, and compares
+the result to <name>-expect.yaml. The directory defaults to the current directory,
+and it can be the module's directory or its test directory.
+
+Use --update to write the expected output of each test from the packaged template,
+after checking that the changes are what you want.
+
+Modules are experimental, so add --experimental, or list modules under experimental in .rain.yaml.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dir := "."
+		if len(args) > 0 {
+			dir = args[0]
+		}
+
+		pkg.Experimental = experimental
+
+		results, err := runTests(dir, update)
+		if err != nil {
+			panic(ui.Errorf(err, "unable to test module '%s'", dir))
+		}
+
+		failed := 0
+		for _, r := range results {
+			switch {
+			case r.err != nil:
+				failed++
+				fmt.Printf("%s %s: %v\n", console.Red("FAIL"), r.name, r.err)
+			case r.diff != "":
+				failed++
+				fmt.Printf("%s %s: the packaged template does not match %s\n%s\n",
+					console.Red("FAIL"), r.name, r.name+expectSuffix, r.diff)
+			case update:
+				fmt.Printf("%s %s\n", console.Yellow("UPDATED"), r.name)
+			default:
+				fmt.Printf("%s %s\n", console.Green("PASS"), r.name)
+			}
+		}
+
+		summary := fmt.Sprintf("%d tests, %d failed", len(results), failed)
+		if failed > 0 {
+			fmt.Fprintln(os.Stderr, console.Red(summary))
+			os.Exit(1)
+		}
+		fmt.Println(console.Green(summary))
+	},
+}
+
+// testDir returns the directory with the test templates of a module
+func testDir(dir string) string {
+	d := filepath.Join(dir, TestDir)
+	if info, err := os.Stat(d); err == nil && info.IsDir() {
+		return d
+	}
+	return dir
+}
+
+// runTests packages the test templates of the module in dir and compares
+// them to the expected output. If update is true, the expected output
+// is written instead.
+func runTests(dir string, update bool) ([]testResult, error) {
+	d := testDir(dir)
+
+	templates, err := filepath.Glob(filepath.Join(d, "*"+templateSuffix))
+	if err != nil {
+		return nil, err
+	}
+	if len(templates) == 0 {
+		return nil, fmt.Errorf("no test templates named <name>%s in %s", templateSuffix, d)
+	}
+
+	results := make([]testResult, 0, len(templates))
+	for _, path := range templates {
+		name := strings.TrimSuffix(filepath.Base(path), templateSuffix)
+		results = append(results, runTest(d, name, update))
+	}
+
+	return results, nil
+}
+
+// runTest packages <name>-template.yaml in dir and
+// compares it to <name>-expect.yaml
+func runTest(dir string, name string, update bool) testResult {
+	r := testResult{name: name}
+	expectPath := filepath.Join(dir, name+expectSuffix)
+
+	packaged, err := pkg.File(filepath.Join(dir, name+templateSuffix))
+	if err != nil {
+		r.err = err
+		return r
+	}
+
+	if update {
+		out := format.String(packaged, format.Options{Unsorted: true})
+		r.err = os.WriteFile(expectPath, []byte(out), 0644)
+		return r
+	}
+
+	if _, err := os.Stat(expectPath); err != nil {
+		r.err = fmt.Errorf("missing %s, run with --update to create it", filepath.Base(expectPath))
+		return r
+	}
+
+	expected, err := parse.File(expectPath)
+	if err != nil {
+		r.err = err
+		return r
+	}
+
+	d := diff.New(packaged, expected)
+	if d.Mode() != "=" {
+		r.diff = d.Format(true)
+	}
+
+	return r
+}
+
+func init() {
+	TestCmd.Flags().BoolVar(&update, "update", false, "Write the expected output of each test from the packaged template")
+	TestCmd.Flags().BoolVarP(&experimental, "experimental", "x", false, "Acknowledge that modules are experimental")
+}
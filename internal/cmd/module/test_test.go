@@ -0,0 +1,132 @@
+package module
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws-cloudformation/rain/cft/parse"
+	"github.com/aws-cloudformation/rain/cft/pkg"
+)
+
+func TestInitAndTest(t *testing.T) {
+	pkg.Experimental = true
+
+	dir := filepath.Join(t.TempDir(), "bucket")
+
+	if err := scaffold(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := scaffold(dir); err == nil {
+		t.Error("expected an error when the module already exists")
+	}
+
+	// There is no expected output yet
+	results, err := runTests(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].err == nil {
+		t.Fatalf("expected the test to fail without %s: %v", "basic"+expectSuffix, results)
+	}
+
+	if _, err := runTests(dir, true); err != nil {
+		t.Fatal(err)
+	}
+	results, err = runTests(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || !results[0].passed() {
+		t.Fatalf("expected the test to pass: %v", results)
+	}
+
+	// Change the module so that the output no longer matches
+	modulePath := filepath.Join(dir, ModuleFile)
+	content, err := os.ReadFile(modulePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed := strings.Replace(string(content), "SSEAlgorithm: AES256", "SSEAlgorithm: aws:kms", 1)
+	if err := os.WriteFile(modulePath, []byte(changed), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The test directory can be passed instead of the module directory
+	results, err = runTests(filepath.Join(dir, TestDir), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].err != nil || results[0].diff == "" {
+		t.Fatalf("expected the test to show a difference: %v", results)
+	}
+}
+
+func TestRunTestsEmpty(t *testing.T) {
+	if _, err := runTests(t.TempDir(), false); err == nil {
+		t.Error("expected an error for a directory without tests")
+	}
+}
+
+func TestListModules(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"template.yaml": `
+Resources:
+  Site:
+    Type: !Rain::Module "./modules/site.yaml"
+  Plain:
+    Type: AWS::SNS::Topic
+`,
+		"modules/site.yaml": `
+Resources:
+  Logs:
+    Type: !Rain::Module "./bucket.yaml"
+  Content:
+    Type: !Rain::Module "./bucket.yaml"
+`,
+		"modules/bucket.yaml": `
+Resources:
+  ModuleExtension:
+    Type: AWS::S3::Bucket
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tmpl, err := parse.File(filepath.Join(dir, "template.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	refs, err := pkg.ListModules(tmpl, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := strings.Join([]string{
+		"Site: " + filepath.Join(dir, "modules", "site.yaml"),
+		"  Logs: " + filepath.Join(dir, "modules", "bucket.yaml"),
+		"  Content: " + filepath.Join(dir, "modules", "bucket.yaml"),
+		"",
+	}, "\n")
+	if got := formatModules(refs); got != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, got)
+	}
+
+	// A module that uses itself is an error
+	recursive := "Resources:\n  Self:\n    Type: !Rain::Module \"./bucket.yaml\"\n"
+	if err := os.WriteFile(filepath.Join(dir, "modules", "bucket.yaml"), []byte(recursive), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pkg.ListModules(tmpl, dir); err == nil {
+		t.Error("expected an error for a module that uses itself")
+	}
+}
@@ -0,0 +1,83 @@
+package patch
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/aws-cloudformation/rain/cft/diff"
+	"github.com/aws-cloudformation/rain/cft/format"
+	"github.com/aws-cloudformation/rain/cft/parse"
+	"github.com/aws-cloudformation/rain/internal/console"
+	"github.com/aws-cloudformation/rain/internal/ui"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var writeFlag bool
+var checkFlag bool
+var jsonFlag bool
+
+// Cmd is the patch command's entrypoint
+var Cmd = &cobra.Command{
+	Use:   "patch <template> <patch>",
+	Short: "Apply a JSON Patch to a CloudFormation template",
+	Long: `Applies the RFC 6902 JSON Patch operations in the file named <patch> to the template named <template>,
+and outputs the patched template. The patch can be written in JSON or YAML.
+
+Comments and the formatting of the parts of the template that the patch does not touch are kept.
+
+Use rain diff --format jsonpatch to create a patch from two templates.
+Use --check to only report whether the patch applies, for example after adding test operations to it.`,
+	Args:                  cobra.ExactArgs(2),
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		templateFn, patchFn := args[0], args[1]
+
+		t, err := parse.File(templateFn)
+		if err != nil {
+			panic(ui.Errorf(err, "unable to parse template '%s'", templateFn))
+		}
+
+		source, err := os.ReadFile(patchFn)
+		if err != nil {
+			panic(ui.Errorf(err, "unable to read patch '%s'", patchFn))
+		}
+
+		var ops []diff.Operation
+		err = yaml.Unmarshal(source, &ops)
+		if err != nil {
+			panic(ui.Errorf(err, "unable to parse patch '%s'", patchFn))
+		}
+
+		err = diff.Apply(t, ops)
+		if err != nil {
+			panic(ui.Errorf(err, "unable to apply patch '%s' to '%s'", patchFn, templateFn))
+		}
+
+		if checkFlag {
+			fmt.Println(console.Green(fmt.Sprintf("%s: patch applies OK", templateFn)))
+			return
+		}
+
+		output := format.String(t, format.Options{
+			JSON:     jsonFlag,
+			Unsorted: true,
+		})
+
+		if writeFlag {
+			err = os.WriteFile(templateFn, []byte(output), 0644)
+			if err != nil {
+				panic(ui.Errorf(err, "unable to write '%s'", templateFn))
+			}
+			return
+		}
+
+		fmt.Print(output)
+	},
+}
+
+func init() {
+	Cmd.Flags().BoolVarP(&writeFlag, "write", "w", false, "Write the patched template back to the file, replacing its current contents")
+	Cmd.Flags().BoolVarP(&checkFlag, "check", "c", false, "Check that the patch applies without outputting the template")
+	Cmd.Flags().BoolVarP(&jsonFlag, "json", "j", false, "Output the template as JSON")
+}
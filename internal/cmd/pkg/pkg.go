@@ -4,10 +4,13 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/aws-cloudformation/rain/cft/eval"
 	"github.com/aws-cloudformation/rain/cft/format"
 	cftpkg "github.com/aws-cloudformation/rain/cft/pkg"
+	"github.com/aws-cloudformation/rain/cft/sam"
 	"github.com/aws-cloudformation/rain/internal/config"
 	"github.com/aws-cloudformation/rain/internal/console/spinner"
+	"github.com/aws-cloudformation/rain/internal/dc"
 	"github.com/aws-cloudformation/rain/internal/node"
 	"github.com/aws-cloudformation/rain/internal/ui"
 	"github.com/spf13/cobra"
@@ -15,6 +18,10 @@ import (
 
 var outFn = ""
 var dataModel bool
+var expand bool
+var samTranslate bool
+var params []string
+var locked bool
 
 // Experimental is an optional argument that enables experimental features
 var Experimental bool
@@ -33,6 +40,10 @@ You may use the following, rain-specific directives in templates packaged with "
 
   !Rain::Env <name>            Reads the <name> environmental variable and inserts value into the template as a string
 
+  !Rain::Constant <name>       Inserts the value of the constant <name> from the Constants in the Rain section
+                               of the template. Constants can be used in Sub strings as ${Rain::Constant.<name>},
+                               and they can refer to each other. The Rain section is removed from the output.
+
   !Rain::S3Http <path>         Uploads <path> (zipping first if it is a directory) to S3
                                and embeds the S3 HTTP URL into the template as a string
 
@@ -56,6 +67,28 @@ You may use the following, rain-specific directives in templates packaged with "
                                of the module can be used to define additional properties for the extension.
                                This is an experimental directive that must be enabled by adding the 
                                --experimental arg on the command line.
+
+Modules can be local files, https URLs, s3://<bucket>/<key>, or files in git repositories:
+
+  !Rain::Module git::https://github.com/org/repo.git//path/module.yaml?ref=v1.2.0
+
+Modules from remote sources are pinned by the sha256 of their contents in rain.lock, which is
+written next to the template. Add ?sha256=<hex> to a source to pin it in the template instead.
+Pinned modules are saved in a local cache, so that they can be read without a network connection.
+Use --locked in CI to make sure that rain.lock is up to date. Remove a module from rain.lock
+to update it. Other commands that package templates, like deploy and lint --pkg, use rain.lock
+in the same way.
+
+Use --expand to apply the AWS::LanguageExtensions transform locally, the way CloudFormation would.
+Fn::ForEach loops are expanded, Fn::Length and Fn::ToJsonString are evaluated, and DeletionPolicy
+and UpdateReplacePolicy values that are intrinsic functions are resolved. Parameters without
+a value set with --params use their Default.
+
+Use --sam-translate to apply the AWS::Serverless transform locally. Serverless functions, apis,
+simple tables, layers and applications are replaced with the resources that SAM would create,
+including execution roles, event sources and Globals, so that the output can be used with commands
+that don't support transforms, like "rain diff", "rain tree", and "rain cc deploy". Anything that
+rain can't translate is an error.
 `,
 	Args:                  cobra.ExactArgs(1),
 	Aliases:               []string{"package"},
@@ -64,6 +97,7 @@ You may use the following, rain-specific directives in templates packaged with "
 		fn := args[0]
 
 		cftpkg.Experimental = Experimental
+		cftpkg.Locked = locked
 
 		spinner.Push(fmt.Sprintf("Packaging template '%s'", fn))
 		packaged, err := cftpkg.File(fn)
@@ -72,6 +106,22 @@ You may use the following, rain-specific directives in templates packaged with "
 		}
 		spinner.Pop()
 
+		if expand {
+			packaged, err = eval.Expand(packaged, eval.Options{
+				Parameters: dc.ListToMap("param", params),
+			})
+			if err != nil {
+				panic(ui.Errorf(err, "unable to expand template '%s'", fn))
+			}
+		}
+
+		if samTranslate {
+			packaged, err = sam.Translate(packaged)
+			if err != nil {
+				panic(ui.Errorf(err, "unable to translate template '%s'", fn))
+			}
+		}
+
 		var out string
 		if dataModel {
 			out = node.ToJson(packaged.Node)
@@ -93,4 +143,12 @@ func init() {
 	Cmd.Flags().BoolVar(&config.Debug, "debug", false, "Output debugging information")
 	Cmd.Flags().BoolVar(&dataModel, "datamodel", false, "Output the go yaml data model")
 	Cmd.Flags().StringVar(&format.NodeStyle, "node-style", "", format.NodeStyleDocs)
+	Cmd.Flags().BoolVar(&expand, "expand", false, "Expand the AWS::LanguageExtensions transform")
+	Cmd.Flags().BoolVar(&samTranslate, "sam-translate", false, "Translate the AWS::Serverless transform into plain CloudFormation")
+	Cmd.Flags().StringSliceVar(&params, "params", []string{}, "set parameter values for --expand; use the format key1=value1,key2=value2")
+	Cmd.Flags().BoolVar(&locked, "locked", false, "Fail if a module from a remote source is not pinned in "+cftpkg.LockFile)
+	Cmd.Flags().StringVar(&cftpkg.ModuleCache, "module-cache", cftpkg.ModuleCache, "Directory to cache modules from remote sources in, rain/modules in the user cache directory by default; set to \"\" to disable the cache")
+
+	// The default depends on the user, so leave it out of the usage and the docs
+	Cmd.Flags().Lookup("module-cache").DefValue = ""
 }
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/aws-cloudformation/rain/cft/format"
+	"github.com/aws-cloudformation/rain/internal/aws/s3"
+	"github.com/aws-cloudformation/rain/internal/config"
+	"github.com/aws-cloudformation/rain/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// experimentalFeatures are the names of the features that the
+// --experimental flag enables for each command, by command name.
+// Other commands use it for modules.
+var experimentalFeatures = map[string]string{
+	"cc":       "cc",
+	"forecast": "forecast",
+	"logs":     "logs",
+}
+
+// projectDir returns the directory to start looking for the project
+// configuration file in: the directory of the first argument that is
+// a file or a directory, or the working directory
+func projectDir(args []string) string {
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			continue
+		}
+		if info.IsDir() {
+			return arg
+		}
+		return filepath.Dir(arg)
+	}
+
+	return "."
+}
+
+// ApplyProject reads the project configuration file for the command's
+// arguments, and uses its settings for anything that was not set with a flag
+func ApplyProject(c *cobra.Command, args []string) {
+	p, err := config.FindProject(projectDir(args))
+	if err != nil {
+		panic(ui.Errorf(err, "unable to read the project settings"))
+	}
+	config.Project = p
+
+	if config.Region == "" {
+		config.Region = p.Region
+	}
+	if config.Profile == "" {
+		config.Profile = p.Profile
+	}
+	if s3.BucketName == "" {
+		s3.BucketName = p.Artifacts.Bucket
+	}
+	if s3.BucketKeyPrefix == "" {
+		s3.BucketKeyPrefix = p.Artifacts.Prefix
+	}
+	if format.NodeStyle == "" {
+		format.NodeStyle = p.Format.NodeStyle
+	}
+
+	for path, keys := range p.Format.Order {
+		format.SetOrder(path, keys)
+	}
+
+	// Boolean flags can only be turned on by the project
+	setFlag := func(name string, value bool) {
+		f := c.Flags().Lookup(name)
+		if !value || f == nil || f.Changed {
+			return
+		}
+		if err := c.Flags().Set(name, "true"); err != nil {
+			panic(ui.Errorf(err, "unable to set --%s from %s", name, p.Path))
+		}
+	}
+
+	setFlag("json", p.Format.JSON)
+	setFlag("unsorted", p.Format.Unsorted)
+
+	feature := "modules"
+	for parent := c; parent != nil; parent = parent.Parent() {
+		if f, ok := experimentalFeatures[parent.Name()]; ok {
+			feature = f
+			break
+		}
+	}
+	setFlag("experimental", p.IsExperimental(feature))
+}
@@ -18,11 +18,16 @@ import (
 	rainfmt "github.com/aws-cloudformation/rain/internal/cmd/fmt"
 	"github.com/aws-cloudformation/rain/internal/cmd/forecast"
 	"github.com/aws-cloudformation/rain/internal/cmd/info"
+	"github.com/aws-cloudformation/rain/internal/cmd/lint"
 	"github.com/aws-cloudformation/rain/internal/cmd/logs"
 	"github.com/aws-cloudformation/rain/internal/cmd/ls"
 	"github.com/aws-cloudformation/rain/internal/cmd/merge"
+	"github.com/aws-cloudformation/rain/internal/cmd/module"
+	"github.com/aws-cloudformation/rain/internal/cmd/patch"
 	"github.com/aws-cloudformation/rain/internal/cmd/pkg"
+	"github.com/aws-cloudformation/rain/internal/cmd/render"
 	"github.com/aws-cloudformation/rain/internal/cmd/rm"
+	"github.com/aws-cloudformation/rain/internal/cmd/split"
 	"github.com/aws-cloudformation/rain/internal/cmd/stackset"
 	"github.com/aws-cloudformation/rain/internal/cmd/tree"
 	"github.com/aws-cloudformation/rain/internal/cmd/watch"
@@ -34,6 +39,9 @@ var Cmd = &cobra.Command{
 	Use:     "rain",
 	Long:    "Rain is a command line tool for working with AWS CloudFormation templates and stacks",
 	Version: config.VERSION,
+
+	// Use the settings in the project's .rain.yaml
+	PersistentPreRun: cmd.ApplyProject,
 }
 
 const usageTemplate = `Usage:{{if .Runnable}}
@@ -94,10 +102,15 @@ func init() {
 	// Template commands
 	addCommand(templateGroup, true, false, bootstrap.Cmd)
 	addCommand(templateGroup, true, false, build.Cmd)
-	addCommand(templateGroup, false, false, diff.Cmd)
+	addCommand(templateGroup, true, false, diff.Cmd)
 	addCommand(templateGroup, false, false, rainfmt.Cmd)
+	addCommand(templateGroup, false, false, lint.Cmd)
 	addCommand(templateGroup, false, false, merge.Cmd)
+	addCommand(templateGroup, false, false, module.Cmd)
+	addCommand(templateGroup, false, false, patch.Cmd)
 	addCommand(templateGroup, true, true, pkg.Cmd)
+	addCommand(templateGroup, true, false, render.Cmd)
+	addCommand(templateGroup, false, false, split.Cmd)
 	addCommand(templateGroup, false, false, tree.Cmd)
 	addCommand(templateGroup, true, false, forecast.Cmd)
 
@@ -34,9 +34,14 @@ func Example_rainhelp() {
 	//   diff        Compare CloudFormation templates
 	//   fmt         Format CloudFormation templates
 	//   forecast    Predict deployment failures
+	//   lint        Check resource properties against the registry schemas
 	//   merge       Merge two or more CloudFormation templates
+	//   module      Work with modules for the !Rain::Module directive
+	//   patch       Apply a JSON Patch to a CloudFormation template
 	//   pkg         Package local artifacts into a template
-	//   tree        Find dependencies of Resources and Outputs in a local template
+	//   render      Evaluate conditions and intrinsic functions in a local template
+	//   split       Split a template into nested stacks
+	//   tree        Find dependencies between the elements of a local template
 	//
 	// Other Commands:
 	//   completion  Generate the autocompletion script for the specified shell
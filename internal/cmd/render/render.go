@@ -0,0 +1,91 @@
+package render
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/aws-cloudformation/rain/cft/eval"
+	"github.com/aws-cloudformation/rain/cft/format"
+	"github.com/aws-cloudformation/rain/cft/parse"
+	"github.com/aws-cloudformation/rain/internal/config"
+	"github.com/aws-cloudformation/rain/internal/dc"
+	"github.com/aws-cloudformation/rain/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var params []string
+var configFilePath string
+var accountId string
+var stackName string
+var azs []string
+var jsonFlag bool
+
+// Cmd is the render command's entrypoint
+var Cmd = &cobra.Command{
+	Use:   "render <template>",
+	Short: "Evaluate conditions and intrinsic functions in a local template",
+	Long: `Renders a template as CloudFormation would see it, given parameter values.
+
+Conditions are evaluated and Resources and Outputs with a false Condition are removed.
+Fn::If, Fn::FindInMap, Fn::Select, Fn::Split, Fn::Join, Fn::Sub, Fn::GetAZs, Fn::Base64
+and Refs to parameters and pseudo-parameters are resolved. Values that are only known after
+deployment, like Refs to resources and Fn::GetAtt, are left as they are.
+
+Parameters without a value or a Default are not resolved. Conditions that depend on them
+are kept, along with the Resources, Outputs and Fn::If that use those conditions.
+The AWS::Region pseudo-parameter comes from --region, AWS_REGION or AWS_DEFAULT_REGION.`,
+	Args:                  cobra.ExactArgs(1),
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		fileName := args[0]
+
+		t, err := parse.File(fileName)
+		if err != nil {
+			panic(ui.Errorf(err, "unable to parse template '%s'", fileName))
+		}
+
+		values := make(map[string]string)
+		if configFilePath != "" {
+			values, _, err = dc.ReadConfigFile(configFilePath)
+			if err != nil {
+				panic(err)
+			}
+		}
+		for k, v := range dc.ListToMap("param", params) {
+			values[k] = v
+		}
+
+		rendered, err := eval.Render(t, eval.Options{
+			Parameters:       values,
+			PseudoParameters: eval.DefaultPseudoParameters(region(), accountId, stackName),
+			AZs:              azs,
+		})
+		if err != nil {
+			panic(ui.Errorf(err, "unable to render template '%s'", fileName))
+		}
+
+		fmt.Println(format.String(rendered, format.Options{
+			JSON: jsonFlag,
+		}))
+	},
+}
+
+// region returns the region to render for, without loading AWS credentials
+func region() string {
+	if config.Region != "" {
+		return config.Region
+	}
+	if r := os.Getenv("AWS_REGION"); r != "" {
+		return r
+	}
+	return os.Getenv("AWS_DEFAULT_REGION")
+}
+
+func init() {
+	Cmd.Flags().StringSliceVar(&params, "params", []string{}, "set parameter values; use the format key1=value1,key2=value2")
+	Cmd.Flags().StringVarP(&configFilePath, "config", "c", "", "YAML or JSON file to set parameters")
+	Cmd.Flags().StringVar(&accountId, "account", "", "the value of AWS::AccountId")
+	Cmd.Flags().StringVarP(&stackName, "stack-name", "s", "", "the value of AWS::StackName")
+	Cmd.Flags().StringSliceVar(&azs, "azs", []string{}, "the availability zones returned by Fn::GetAZs")
+	Cmd.Flags().BoolVarP(&jsonFlag, "json", "j", false, "Output the template as JSON")
+}
@@ -0,0 +1,32 @@
+package render_test
+
+import (
+	"os"
+
+	"github.com/aws-cloudformation/rain/internal/cmd/render"
+	"github.com/aws-cloudformation/rain/internal/console"
+)
+
+func Example_render() {
+	os.Args = []string{
+		os.Args[0],
+		"../../../test/templates/success.template",
+		"--params", "BucketName=my-bucket",
+	}
+
+	console.NoColour = true
+
+	render.Cmd.Execute()
+	// Output:
+	// Description: This template succeeds
+	//
+	// Parameters:
+	//   BucketName:
+	//     Type: String
+	//
+	// Resources:
+	//   Bucket1:
+	//     Type: AWS::S3::Bucket
+	//     Properties:
+	//       BucketName: my-bucket
+}
@@ -0,0 +1,148 @@
+package split
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws-cloudformation/rain/cft/format"
+	"github.com/aws-cloudformation/rain/cft/parse"
+	"github.com/aws-cloudformation/rain/cft/split"
+	"github.com/aws-cloudformation/rain/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+// The limits that CloudFormation sets for a template
+const (
+	maxResources    = 500
+	maxTemplateSize = 460800
+)
+
+var outputDir = ""
+var groupFlags []string
+var maxGroupSize = split.DefaultMaxResources
+var jsonFlag = false
+
+// Cmd is the split command's entrypoint
+var Cmd = &cobra.Command{
+	Use:   "split <template>",
+	Short: "Split a template into nested stacks",
+	Long: `Splits the resources of a template into groups, and writes a parent template with an AWS::CloudFormation::Stack for each group,
+and a template for each nested stack. Use this for templates that are close to the limit of 500 resources, or to the size limit of a template.
+
+References between groups are passed through the Parameters of the nested stacks, from the Outputs of the nested stacks they refer to.
+The parent template keeps the Parameters, Conditions, Mappings, Rules and Outputs of the original template.
+
+Use --group to choose the resources in a group, for example --group Network=Vpc,Subnet1,Subnet2.
+The other resources are grouped so that few references cross between groups, with at most --max-resources in each group.
+
+The templates are written to the directory of the original template, or to --output-dir,
+as <name>-parent.yaml and <name>-<group>.yaml. Use rain pkg on the parent template to upload the nested stacks.`,
+	Args:                  cobra.ExactArgs(1),
+	DisableFlagsInUseLine: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		fn := args[0]
+
+		t, err := parse.File(fn)
+		if err != nil {
+			panic(ui.Errorf(err, "unable to parse template '%s'", fn))
+		}
+
+		groups, err := parseGroups(groupFlags)
+		if err != nil {
+			panic(ui.Errorf(err, "invalid --group"))
+		}
+
+		ext := ".yaml"
+		if jsonFlag {
+			ext = ".json"
+		}
+		base := strings.TrimSuffix(filepath.Base(fn), filepath.Ext(fn))
+		name := func(group string) string {
+			return fmt.Sprintf("%s-%s%s", base, strings.ToLower(group), ext)
+		}
+
+		parent, stacks, err := split.Split(t, split.Options{
+			Groups:       groups,
+			MaxResources: maxGroupSize,
+			TemplateURL:  name,
+		})
+		if err != nil {
+			panic(ui.Errorf(err, "unable to split '%s'", fn))
+		}
+
+		dir := outputDir
+		if dir == "" {
+			dir = filepath.Dir(fn)
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			panic(ui.Errorf(err, "unable to create '%s'", dir))
+		}
+
+		options := format.Options{JSON: jsonFlag}
+
+		files := map[string]string{
+			filepath.Join(dir, name("parent")): format.String(parent, options),
+		}
+		order := []string{filepath.Join(dir, name("parent"))}
+		for _, s := range stacks {
+			path := filepath.Join(dir, name(s.Group))
+			if _, ok := files[path]; ok {
+				panic(fmt.Errorf("groups have the same file name: %s", path))
+			}
+
+			out := format.String(s.Template, options)
+			if s.Resources > maxResources {
+				panic(fmt.Errorf("group %s has %d resources, which is more than %d", s.Group, s.Resources, maxResources))
+			}
+			if len(out) > maxTemplateSize {
+				panic(fmt.Errorf("the template for group %s is too large to deploy; use a smaller --max-resources", s.Group))
+			}
+
+			files[path] = out
+			order = append(order, path)
+		}
+
+		for i, path := range order {
+			if err := os.WriteFile(path, []byte(files[path]), 0644); err != nil {
+				panic(ui.Errorf(err, "unable to write '%s'", path))
+			}
+			switch {
+			case i == 0:
+				fmt.Printf("Wrote %s\n", path)
+			case stacks[i-1].Resources == 1:
+				fmt.Printf("Wrote %s (1 resource)\n", path)
+			default:
+				fmt.Printf("Wrote %s (%d resources)\n", path, stacks[i-1].Resources)
+			}
+		}
+	},
+}
+
+// parseGroups reads the values of the --group flag, which are a
+// group name and a comma separated list of resources
+func parseGroups(values []string) (map[string]string, error) {
+	groups := make(map[string]string)
+	for _, v := range values {
+		group, resources, found := strings.Cut(v, "=")
+		if !found || group == "" || resources == "" {
+			return nil, fmt.Errorf("expected Group=Resource1,Resource2: '%s'", v)
+		}
+		for _, r := range strings.Split(resources, ",") {
+			r = strings.TrimSpace(r)
+			if other, ok := groups[r]; ok && other != group {
+				return nil, fmt.Errorf("%s is in groups %s and %s", r, other, group)
+			}
+			groups[r] = group
+		}
+	}
+	return groups, nil
+}
+
+func init() {
+	Cmd.Flags().StringVarP(&outputDir, "output-dir", "o", "", "write the templates to this directory instead of the directory of the template")
+	Cmd.Flags().StringArrayVarP(&groupFlags, "group", "g", []string{}, "put resources in a group; use the format Group=Resource1,Resource2")
+	Cmd.Flags().IntVar(&maxGroupSize, "max-resources", split.DefaultMaxResources, "the most resources in each group that rain creates")
+	Cmd.Flags().BoolVarP(&jsonFlag, "json", "j", false, "write the templates as JSON")
+}
@@ -0,0 +1,33 @@
+package split
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseGroups(t *testing.T) {
+	actual, err := parseGroups([]string{"Network=Vpc, Subnet", "Data=Table"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"Vpc":    "Network",
+		"Subnet": "Network",
+		"Table":  "Data",
+	}
+	if d := cmp.Diff(actual, expected); d != "" {
+		t.Error(d)
+	}
+
+	for _, invalid := range [][]string{
+		{"Network"},
+		{"Network="},
+		{"Network=Vpc", "Data=Vpc"},
+	} {
+		if _, err := parseGroups(invalid); err == nil {
+			t.Errorf("expected %v to be invalid", invalid)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package tree
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aws-cloudformation/rain/cft"
+	"github.com/aws-cloudformation/rain/cft/graph"
+	"github.com/aws-cloudformation/rain/internal/config"
+)
+
+//go:embed viewer-template.html
+var viewerTemplate string
+
+// jsonNode is an element of the template in --format json output
+type jsonNode struct {
+	Id      string `json:"id"`
+	Section string `json:"section"`
+	Name    string `json:"name"`
+
+	// Type is the resource type, for resources
+	Type string `json:"type,omitempty"`
+}
+
+// jsonEdge is a dependency in --format json output.
+// From depends on To.
+type jsonEdge struct {
+	From  string   `json:"from"`
+	To    string   `json:"to"`
+	Kinds []string `json:"kinds"`
+}
+
+// jsonGraph is the document written by --format json
+type jsonGraph struct {
+	Nodes []jsonNode `json:"nodes"`
+	Edges []jsonEdge `json:"edges"`
+	Cycle []string   `json:"cycle,omitempty"`
+}
+
+// toJSON returns a serializable version of the graph
+func toJSON(g graph.Graph, t cft.Template) jsonGraph {
+	types := make(map[string]string)
+	if m, err := t.Model(); err == nil {
+		for name, r := range m.Resources {
+			types[name] = r.Type
+		}
+	} else {
+		config.Debugf("unable to read resource types: %v", err)
+	}
+
+	out := jsonGraph{
+		Nodes: make([]jsonNode, 0),
+		Edges: make([]jsonEdge, 0),
+	}
+
+	for _, n := range g.Nodes() {
+		jn := jsonNode{
+			Id:      n.String(),
+			Section: n.Type,
+			Name:    n.Name,
+		}
+		if n.Type == string(cft.Resources) {
+			jn.Type = types[n.Name]
+		}
+		out.Nodes = append(out.Nodes, jn)
+
+		for _, e := range g.Edges(n) {
+			kinds := make([]string, len(e.Kinds))
+			for i, k := range e.Kinds {
+				kinds[i] = string(k)
+			}
+			out.Edges = append(out.Edges, jsonEdge{
+				From:  e.From.String(),
+				To:    e.To.String(),
+				Kinds: kinds,
+			})
+		}
+	}
+
+	for _, n := range g.Cycle() {
+		out.Cycle = append(out.Cycle, n.String())
+	}
+
+	return out
+}
+
+// printJSON outputs the graph as a JSON document
+func printJSON(g graph.Graph, t cft.Template) error {
+	out, err := json.MarshalIndent(toJSON(g, t), "", "    ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(out))
+
+	return nil
+}
+
+// printHTML outputs a self-contained html page with
+// an interactive view of the graph
+func printHTML(g graph.Graph, t cft.Template, title string) error {
+	data, err := json.Marshal(toJSON(g, t))
+	if err != nil {
+		return err
+	}
+
+	titleJSON, err := json.Marshal(title)
+	if err != nil {
+		return err
+	}
+
+	rendered := strings.Replace(viewerTemplate, "__DATA__", string(data), 1)
+	rendered = strings.Replace(rendered, "__TITLE__", string(titleJSON), 1)
+
+	fmt.Println(rendered)
+
+	return nil
+}
+
+// mermaidShapes wraps the label of each type of node
+var mermaidShapes = map[string][2]string{
+	"Parameters": {"{", "}"},
+	"Mappings":   {"[/", "/]"},
+	"Conditions": {"{{", "}}"},
+	"Resources":  {"(", ")"},
+	"Outputs":    {"[", "]"},
+}
+
+var mermaidUnsafe = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+func mermaidId(n graph.Node) string {
+	return mermaidUnsafe.ReplaceAllString(n.Type+"_"+n.Name, "_")
+}
+
+// printMermaid outputs the graph as a mermaid flowchart
+func printMermaid(g graph.Graph) {
+	out := strings.Builder{}
+
+	out.WriteString("flowchart LR\n")
+
+	for _, section := range sections {
+		nodes := make([]graph.Node, 0)
+		for _, el := range g.Nodes() {
+			if el.Type == section {
+				nodes = append(nodes, el)
+			}
+		}
+		if len(nodes) == 0 {
+			continue
+		}
+
+		out.WriteString(fmt.Sprintf("    subgraph %s\n", section))
+		for _, el := range nodes {
+			shape := mermaidShapes[section]
+			out.WriteString(fmt.Sprintf("        %s%s\"%s\"%s\n", mermaidId(el), shape[0], el.Name, shape[1]))
+		}
+		out.WriteString("    end\n")
+	}
+
+	for _, from := range g.Nodes() {
+		for _, e := range g.Edges(from) {
+			kinds := make([]string, len(e.Kinds))
+			for i, k := range e.Kinds {
+				kinds[i] = string(k)
+			}
+
+			arrow := "-->"
+			if len(kinds) > 0 {
+				arrow = fmt.Sprintf("-->|%s|", strings.Join(kinds, ", "))
+			}
+
+			out.WriteString(fmt.Sprintf("    %s %s %s\n", mermaidId(e.To), arrow, mermaidId(from)))
+		}
+	}
+
+	fmt.Print(out.String())
+}
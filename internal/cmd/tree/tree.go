@@ -1,22 +1,36 @@
 package tree
 
 import (
+	"fmt"
+
 	"github.com/aws-cloudformation/rain/internal/ui"
 
 	"github.com/aws-cloudformation/rain/cft/graph"
 	"github.com/aws-cloudformation/rain/cft/parse"
+	"github.com/aws-cloudformation/rain/internal/console"
 	"github.com/spf13/cobra"
 )
 
 var allLinks = false
 var dotGraph = false
 var twoWayTree = false
+var outputFormat = "text"
+var htmlViewer = false
 
 // Cmd is the tree command's entrypoint
 var Cmd = &cobra.Command{
-	Use:                   "tree [template]",
-	Short:                 "Find dependencies of Resources and Outputs in a local template",
-	Long:                  "Find and display the dependencies between Parameters, Resources, and Outputs in a CloudFormation template.",
+	Use:   "tree [template]",
+	Short: "Find dependencies between the elements of a local template",
+	Long: `Find and display the dependencies between Parameters, Mappings, Conditions, Resources, and Outputs in a CloudFormation template.
+
+Use --format to choose the output:
+  text     an indented list of each element's dependencies (the default)
+  dot      a GraphViz DOT graph
+  mermaid  a mermaid flowchart, for Markdown documents and wikis
+  json     a list of nodes, and edges labelled with the kind of reference
+
+Use --html to output a self-contained web page with an interactive view of the graph.
+Click an element to highlight its dependencies and dependents, and filter elements by resource type.`,
 	Args:                  cobra.ExactArgs(1),
 	Aliases:               []string{"graph"},
 	DisableFlagsInUseLine: true,
@@ -31,11 +45,31 @@ var Cmd = &cobra.Command{
 		g := graph.New(t)
 
 		if dotGraph {
+			outputFormat = "dot"
+		}
+
+		switch {
+		case htmlViewer:
+			err = printHTML(g, t, fileName)
+		case outputFormat == "text":
+			for _, section := range sections {
+				printGraph(g, section)
+			}
+
+			if cycle := g.Cycle(); cycle != nil {
+				console.Errorf("%v", graph.CycleError{Path: cycle})
+			}
+		case outputFormat == "dot":
 			printDot(g)
-		} else {
-			printGraph(g, "Parameters")
-			printGraph(g, "Resources")
-			printGraph(g, "Outputs")
+		case outputFormat == "mermaid":
+			printMermaid(g)
+		case outputFormat == "json":
+			err = printJSON(g, t)
+		default:
+			panic(fmt.Errorf("unknown format '%s', expected text, dot, mermaid, or json", outputFormat))
+		}
+		if err != nil {
+			panic(ui.Errorf(err, "unable to output the graph for '%s'", fileName))
 		}
 	},
 }
@@ -43,5 +77,7 @@ var Cmd = &cobra.Command{
 func init() {
 	Cmd.Flags().BoolVarP(&allLinks, "all", "a", false, "Display all elements, even those without any dependencies")
 	Cmd.Flags().BoolVarP(&twoWayTree, "both", "b", false, "For each element, display both its dependencies and its dependents")
-	Cmd.Flags().BoolVarP(&dotGraph, "dot", "d", false, "Output the graph in GraphViz DOT format")
+	Cmd.Flags().BoolVarP(&dotGraph, "dot", "d", false, "Output the graph in GraphViz DOT format (the same as --format dot)")
+	Cmd.Flags().StringVarP(&outputFormat, "format", "f", "text", "Output format: text, dot, mermaid, or json")
+	Cmd.Flags().BoolVar(&htmlViewer, "html", false, "Output an html page with an interactive view of the graph")
 }
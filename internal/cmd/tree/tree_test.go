@@ -23,3 +23,57 @@ func Example_tree() {
 	//       Parameters:
 	//         - BucketName
 }
+
+func Example_tree_mermaid() {
+	os.Args = []string{
+		os.Args[0],
+		"--format", "mermaid",
+		"../../../test/templates/success.template",
+	}
+
+	tree.Cmd.Execute()
+	// Output:
+	// flowchart LR
+	//     subgraph Parameters
+	//         Parameters_BucketName{"BucketName"}
+	//     end
+	//     subgraph Resources
+	//         Resources_Bucket1("Bucket1")
+	//     end
+	//     Parameters_BucketName -->|Ref| Resources_Bucket1
+}
+
+func Example_tree_json() {
+	os.Args = []string{
+		os.Args[0],
+		"--format", "json",
+		"../../../test/templates/success.template",
+	}
+
+	tree.Cmd.Execute()
+	// Output:
+	// {
+	//     "nodes": [
+	//         {
+	//             "id": "Parameters/BucketName",
+	//             "section": "Parameters",
+	//             "name": "BucketName"
+	//         },
+	//         {
+	//             "id": "Resources/Bucket1",
+	//             "section": "Resources",
+	//             "name": "Bucket1",
+	//             "type": "AWS::S3::Bucket"
+	//         }
+	//     ],
+	//     "edges": [
+	//         {
+	//             "from": "Resources/Bucket1",
+	//             "to": "Parameters/BucketName",
+	//             "kinds": [
+	//                 "Ref"
+	//             ]
+	//         }
+	//     ]
+	// }
+}
@@ -8,6 +8,9 @@ import (
 	"github.com/aws-cloudformation/rain/internal/console"
 )
 
+// sections are the parts of the template that are shown, in order
+var sections = []string{"Parameters", "Mappings", "Conditions", "Resources", "Outputs"}
+
 func printLinks(links []graph.Node, typeFilter string) {
 	names := make([]string, 0)
 	for _, to := range links {
@@ -68,9 +71,9 @@ func printGraph(g graph.Graph, typeFilter string) {
 				fmt.Println("    DependsOn: []")
 			} else {
 				fmt.Println("    DependsOn:")
-				printLinks(fromLinks[el], "Parameters")
-				printLinks(fromLinks[el], "Resources")
-				printLinks(fromLinks[el], "Outputs")
+				for _, section := range sections {
+					printLinks(fromLinks[el], section)
+				}
 			}
 		}
 
@@ -79,9 +82,9 @@ func printGraph(g graph.Graph, typeFilter string) {
 				fmt.Println("    UsedBy: []")
 			} else {
 				fmt.Println("    UsedBy:")
-				printLinks(toLinks[el], "Parameters")
-				printLinks(toLinks[el], "Resources")
-				printLinks(toLinks[el], "Outputs")
+				for _, section := range sections {
+					printLinks(toLinks[el], section)
+				}
 			}
 		}
 	}
@@ -89,6 +92,8 @@ func printGraph(g graph.Graph, typeFilter string) {
 
 var dotShapes = map[string]string{
 	"Parameters": "diamond",
+	"Mappings":   "folder",
+	"Conditions": "hexagon",
 	"Resources":  "Mrecord",
 	"Outputs":    "rectangle",
 }
@@ -115,9 +120,9 @@ func printDot(graph graph.Graph) {
 		out.WriteString("\n")
 	}
 
-	doGroup("Parameters")
-	doGroup("Resources")
-	doGroup("Outputs")
+	for _, section := range sections {
+		doGroup(section)
+	}
 
 	for _, from := range graph.Nodes() {
 		fromStr := fmt.Sprintf("%s: %s", from.Type, from.Name)
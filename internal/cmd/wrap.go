@@ -55,6 +55,8 @@ func Wrap(name string, src *cobra.Command) {
 		Long:  src.Long,
 		Args:  src.Args,
 		Run:   src.Run,
+
+		PersistentPreRun: ApplyProject,
 	}
 
 	// Set default options
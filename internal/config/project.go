@@ -0,0 +1,116 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProjectFile is the name of the project configuration file.
+// Rain looks for it in the directory of the template it is working
+// with, and then in each parent directory.
+const ProjectFile = ".rain.yaml"
+
+// ProjectConfig holds the settings from a project configuration file.
+// Command line flags take precedence over these settings.
+type ProjectConfig struct {
+	// Region and Profile are the AWS region and CLI profile to use
+	Region  string `yaml:"region"`
+	Profile string `yaml:"profile"`
+
+	// Artifacts is where rain uploads assets when it packages a template
+	Artifacts struct {
+		Bucket string `yaml:"bucket"`
+		Prefix string `yaml:"prefix"`
+	} `yaml:"artifacts"`
+
+	Format ProjectFormat `yaml:"format"`
+
+	// Experimental lists the experimental features to enable without -x:
+	// modules, cc, forecast, or logs
+	Experimental []string `yaml:"experimental"`
+
+	// Path is the file the settings were read from,
+	// or empty if no file was found
+	Path string `yaml:"-"`
+}
+
+// ProjectFormat holds the settings for formatting templates
+type ProjectFormat struct {
+	// JSON outputs templates as JSON
+	JSON bool `yaml:"json"`
+
+	// Unsorted leaves the keys of templates in their original order
+	Unsorted bool `yaml:"unsorted"`
+
+	// NodeStyle is the same as the --node-style flag
+	NodeStyle string `yaml:"nodeStyle"`
+
+	// Order sets the order of the keys in mappings, by the path to the mapping.
+	// Paths are keys separated by slashes, * matches any key, and an empty
+	// path is the top level of the template. For example:
+	//
+	//	Resources/*: [Type, Condition, DependsOn, Properties]
+	Order map[string][]string `yaml:"order"`
+}
+
+// Project holds the project settings for the current command
+var Project = &ProjectConfig{}
+
+// FindProject looks for a project configuration file in dir and each of its
+// parents, and reads the first one it finds.
+// It returns empty settings if there is no file.
+func FindProject(dir string) (*ProjectConfig, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		path := filepath.Join(dir, ProjectFile)
+		if _, err := os.Stat(path); err == nil {
+			return ReadProject(path)
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return &ProjectConfig{}, nil
+		}
+		dir = parent
+	}
+}
+
+// ReadProject reads a project configuration file
+func ReadProject(path string) (*ProjectConfig, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// Unknown settings are an error, to catch typos
+	p := &ProjectConfig{}
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(p); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("unable to read %s: %v", path, err)
+	}
+	p.Path = path
+
+	Debugf("Read project settings from %s", path)
+
+	return p, nil
+}
+
+// IsExperimental returns true if the project enables the experimental feature
+func (p *ProjectConfig) IsExperimental(feature string) bool {
+	for _, f := range p.Experimental {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
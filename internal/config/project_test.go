@@ -0,0 +1,55 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws-cloudformation/rain/internal/config"
+)
+
+func TestFindProject(t *testing.T) {
+	dir := t.TempDir()
+	sub := filepath.Join(dir, "a", "b")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	source := `
+region: us-west-2
+artifacts:
+  bucket: my-bucket
+  prefix: team
+format:
+  unsorted: true
+  order:
+    Resources/*: [Type, Properties]
+experimental: [modules]
+`
+	path := filepath.Join(dir, config.ProjectFile)
+	if err := os.WriteFile(path, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := config.FindProject(sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.Path != path || p.Region != "us-west-2" || p.Artifacts.Bucket != "my-bucket" ||
+		p.Artifacts.Prefix != "team" || !p.Format.Unsorted || len(p.Format.Order["Resources/*"]) != 2 {
+		t.Errorf("unexpected settings: %+v", p)
+	}
+
+	if !p.IsExperimental("modules") || p.IsExperimental("cc") {
+		t.Errorf("unexpected experimental features: %v", p.Experimental)
+	}
+
+	// Typos are errors
+	if err := os.WriteFile(path, []byte("regoin: us-west-2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := config.FindProject(sub); err == nil {
+		t.Error("expected an error for an unknown setting")
+	}
+}
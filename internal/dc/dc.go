@@ -45,6 +45,20 @@ func (dc DeployConfig) GetParam(name string) (string, bool) {
 	return "", false
 }
 
+// ParamMap returns the supplied parameter values as a map
+func (dc DeployConfig) ParamMap() map[string]string {
+	retval := make(map[string]string)
+	for _, p := range dc.Params {
+		switch {
+		case p.ResolvedValue != nil:
+			retval[*p.ParameterKey] = *p.ResolvedValue
+		case p.ParameterValue != nil:
+			retval[*p.ParameterKey] = *p.ParameterValue
+		}
+	}
+	return retval
+}
+
 // GetParameters checks the combined params supplied as args and in a file
 // and asks the user to supply any values that are missing
 func GetParameters(
@@ -214,6 +228,29 @@ func ConfigFromStack(stack types.Stack) (string, error) {
 	return string(configFileContent), err
 }
 
+// ReadConfigFile returns the parameters and tags from a YAML or JSON config file
+func ReadConfigFile(configFilePath string) (map[string]string, map[string]string, error) {
+	configFileContent, err := os.ReadFile(configFilePath)
+	if err != nil {
+		return nil, nil, ui.Errorf(err, "unable to read config file '%s'", configFilePath)
+	}
+
+	var configFile configFileFormat
+	err = yaml.Unmarshal([]byte(configFileContent), &configFile)
+	if err != nil {
+		return nil, nil, ui.Errorf(err, "unable to parse yaml in '%s'", configFilePath)
+	}
+
+	if configFile.Parameters == nil {
+		configFile.Parameters = make(map[string]string)
+	}
+	if configFile.Tags == nil {
+		configFile.Tags = make(map[string]string)
+	}
+
+	return configFile.Parameters, configFile.Tags, nil
+}
+
 // GetDeployConfig populates an instance of DeployConfig based on user-supplied values
 func GetDeployConfig(
 	tags []string,
@@ -238,20 +275,12 @@ func GetDeployConfig(
 	var combinedParameters map[string]string
 
 	if len(configFilePath) != 0 {
-		configFileContent, err := os.ReadFile(configFilePath)
+		var err error
+		combinedParameters, combinedTags, err = ReadConfigFile(configFilePath)
 		if err != nil {
-			panic(ui.Errorf(err, "unable to read config file '%s'", configFilePath))
+			panic(err)
 		}
 
-		var configFile configFileFormat
-		err = yaml.Unmarshal([]byte(configFileContent), &configFile)
-		if err != nil {
-			panic(ui.Errorf(err, "unable to parse yaml in '%s'", configFilePath))
-		}
-
-		combinedTags = configFile.Tags
-		combinedParameters = configFile.Parameters
-
 		for k, v := range parsedTagFlag {
 			if _, ok := combinedTags[k]; ok {
 				fmt.Println(console.Yellow(fmt.Sprintf("tags flag overrides tag in config file: %s", k)))
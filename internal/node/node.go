@@ -36,7 +36,6 @@ func Clone(node *yaml.Node) *yaml.Node {
 		Value:       node.Value,
 		Anchor:      node.Anchor,
 		Alias:       Clone(node.Alias),
-		Content:     make([]*yaml.Node, len(node.Content)),
 		HeadComment: node.HeadComment,
 		LineComment: node.LineComment,
 		FootComment: node.FootComment,
@@ -44,10 +43,16 @@ func Clone(node *yaml.Node) *yaml.Node {
 		Column:      node.Column,
 	}
 
+	// Scalars have nil Content, and the copy should too
+	if node.Content != nil {
+		out.Content = make([]*yaml.Node, len(node.Content))
+	}
 	for i, child := range node.Content {
 		out.Content[i] = Clone(child)
 	}
 
+	copyOrigin(node, out)
+
 	return out
 }
 
@@ -177,3 +177,51 @@ func TestSetMapValue(t *testing.T) {
 		t.Errorf("Unexpected value: %v", n.Content[1].Value)
 	}
 }
+
+func TestCloneKeepsOrigin(t *testing.T) {
+	var doc yaml.Node
+	err := yaml.Unmarshal([]byte("A:\n  B: c\n"), &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	node.Track(&doc, "module.yaml", []string{"Parent", "Child"})
+
+	c := node.Clone(doc.Content[0].Content[1])
+	c.Line = 100
+
+	origin, ok := node.GetOrigin(c.Content[1])
+	if !ok {
+		t.Fatal("expected the clone to have an origin")
+	}
+	if origin.String() != "module.yaml:2:6 (via Parent > Child)" {
+		t.Errorf("unexpected origin %s", origin)
+	}
+
+	origin, ok = node.GetOrigin(&yaml.Node{Line: 3, Column: 4})
+	if ok || origin.String() != "3:4" {
+		t.Errorf("unexpected origin %s for an untracked node", origin)
+	}
+}
+
+func TestKeepOrigins(t *testing.T) {
+	var kept, dropped yaml.Node
+	if err := yaml.Unmarshal([]byte("A:\n  B: c\n"), &kept); err != nil {
+		t.Fatal(err)
+	}
+	if err := yaml.Unmarshal([]byte("D: e\n"), &dropped); err != nil {
+		t.Fatal(err)
+	}
+
+	node.Track(&kept, "template.yaml", nil)
+	node.Track(&dropped, "module.yaml", nil)
+
+	node.KeepOrigins(&kept)
+
+	if origin, ok := node.GetOrigin(kept.Content[0].Content[1].Content[1]); !ok || origin.String() != "template.yaml:2:6" {
+		t.Errorf("unexpected origin %s for a kept node", origin)
+	}
+	if _, ok := node.GetOrigin(dropped.Content[0].Content[1]); ok {
+		t.Error("expected the origin of the dropped node to be forgotten")
+	}
+}
@@ -0,0 +1,142 @@
+package node
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Origin is the place in a source file that a node was read from.
+// Nodes that rain pkg brings in from modules or includes remember
+// the file they were written in, rather than their position in the
+// packaged template, so that errors can point at the real source.
+type Origin struct {
+	File   string
+	Line   int
+	Column int
+
+	// Modules is the chain of module resources that brought the node
+	// into the template, outermost first
+	Modules []string
+}
+
+// String returns the origin in file:line:column form,
+// followed by the module chain if there is one
+func (o Origin) String() string {
+	var out strings.Builder
+
+	if o.File != "" {
+		out.WriteString(o.File)
+		out.WriteString(":")
+	}
+	out.WriteString(fmt.Sprintf("%d:%d", o.Line, o.Column))
+
+	if len(o.Modules) > 0 {
+		out.WriteString(" (via ")
+		out.WriteString(strings.Join(o.Modules, " > "))
+		out.WriteString(")")
+	}
+
+	return out.String()
+}
+
+// origins is shared by the whole process. pkg.File calls KeepOrigins
+// when it is done, so that it only holds the nodes of the last template.
+var origins = struct {
+	sync.RWMutex
+	m map[*yaml.Node]Origin
+}{m: make(map[*yaml.Node]Origin)}
+
+// Track records file and modules as the origin of n and all of its descendants,
+// using the line and column numbers that the nodes currently have
+func Track(n *yaml.Node, file string, modules []string) {
+	if n == nil {
+		return
+	}
+
+	SetOrigin(n, Origin{
+		File:    file,
+		Line:    n.Line,
+		Column:  n.Column,
+		Modules: modules,
+	})
+
+	for _, child := range n.Content {
+		Track(child, file, modules)
+	}
+}
+
+// SetOrigin records the origin of a single node
+func SetOrigin(n *yaml.Node, o Origin) {
+	origins.Lock()
+	defer origins.Unlock()
+	origins.m[n] = o
+}
+
+// GetOrigin returns the recorded origin of n.
+// If nothing has been recorded, it returns the node's own
+// line and column, with no file, and false.
+func GetOrigin(n *yaml.Node) (Origin, bool) {
+	if n == nil {
+		return Origin{}, false
+	}
+
+	origins.RLock()
+	defer origins.RUnlock()
+	if o, ok := origins.m[n]; ok {
+		return o, true
+	}
+
+	return Origin{Line: n.Line, Column: n.Column}, false
+}
+
+// InheritOrigin gives n and all of its descendants the origin of from.
+// It is used for nodes that are generated to replace a node from a source file.
+func InheritOrigin(n *yaml.Node, from *yaml.Node) {
+	o, ok := GetOrigin(from)
+	if !ok || n == nil {
+		return
+	}
+	SetOrigin(n, o)
+	for _, child := range n.Content {
+		InheritOrigin(child, from)
+	}
+}
+
+// copyOrigin gives to the origin of from, if from has one
+func copyOrigin(from, to *yaml.Node) {
+	origins.RLock()
+	o, ok := origins.m[from]
+	origins.RUnlock()
+	if ok {
+		SetOrigin(to, o)
+	}
+}
+
+// KeepOrigins forgets the origins of all nodes other than n and its descendants,
+// so that the nodes of source files and modules that are no longer used
+// can be garbage collected
+func KeepOrigins(n *yaml.Node) {
+	keep := make(map[*yaml.Node]Origin)
+
+	origins.Lock()
+	defer origins.Unlock()
+
+	var walk func(*yaml.Node)
+	walk = func(n *yaml.Node) {
+		if n == nil {
+			return
+		}
+		if o, ok := origins.m[n]; ok {
+			keep[n] = o
+		}
+		for _, child := range n.Content {
+			walk(child)
+		}
+	}
+	walk(n)
+
+	origins.m = keep
+}